@@ -1,24 +1,41 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/bytedance/sonic"
 	db2 "github.com/crafty-ezhik/blog-api/db"
 	"github.com/crafty-ezhik/blog-api/internal/auth"
 	"github.com/crafty-ezhik/blog-api/internal/comment"
 	"github.com/crafty-ezhik/blog-api/internal/config"
+	"github.com/crafty-ezhik/blog-api/internal/health"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
 	"github.com/crafty-ezhik/blog-api/internal/post"
 	"github.com/crafty-ezhik/blog-api/internal/routes"
+	"github.com/crafty-ezhik/blog-api/internal/storage"
+	"github.com/crafty-ezhik/blog-api/internal/tag"
+	"github.com/crafty-ezhik/blog-api/internal/token"
 	"github.com/crafty-ezhik/blog-api/internal/user"
+	"github.com/crafty-ezhik/blog-api/migrations"
 	"github.com/crafty-ezhik/blog-api/pkg/jwt"
+	"github.com/crafty-ezhik/blog-api/pkg/lockout"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/mailer"
 	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/migrate"
+	"github.com/crafty-ezhik/blog-api/pkg/oidc"
 	"github.com/crafty-ezhik/blog-api/pkg/validate"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 /*
@@ -45,19 +62,35 @@ func main() {
 	}
 	// Get database connection
 
-	db := db2.GetConnection(cfg)
+	db, err := db2.GetConnection(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Init redis
-	logger.Log.Debug("Init Redis Client")
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port),
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.Db,
-	})
+	// Init storages: Redis или память процесса
+	stores, err := storage.New(cfg.Redis)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stores.Close()
 
 	// Init JWT
-	jwtService := jwt.NewJWTService(jwt.NewRedisStorage(rdb))
-	jwtAuth := jwt.NewJWT(jwtService, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL, cfg.Auth.SigningKey)
+	jwtService := jwt.NewJWTService(stores.BlackList, stores.TokenVersions, stores.Sessions)
+	signingKeys, err := jwt.NewKeySetFromConfig(cfg.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	jwtAuth := jwt.NewJWTWithKeys(jwtService, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL, signingKeys, jwt.ClaimsOptions{
+		Issuer:   cfg.Auth.Issuer,
+		Audience: cfg.Auth.Audience,
+		Leeway:   cfg.Auth.Leeway,
+	})
+
+	// Init mailer
+	mailSender, err := mailer.NewSender(cfg.Mail)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Init Validator
 	logger.Log.Debug("Init validator")
@@ -70,18 +103,62 @@ func main() {
 	userRepo := user.NewUserRepository(db)
 	postRepo := post.NewPostRepository(db)
 	commentRepo := comment.NewCommentRepository(db)
+	tagRepo := tag.NewTagRepository(db)
+	tokenRepo := token.NewTokenRepository(db)
 
 	// Services
-	userService := user.NewUserService(userRepo)
-	authService := auth.NewAuthService(cfg, userRepo, jwtAuth)
+	userService := user.NewUserService(userRepo, user.NewUnitOfWork(db))
+	authService := auth.NewAuthService(cfg, userRepo, tokenRepo, jwtAuth, stores.OneTimeTokens, mailSender,
+		oidc.NewClient(cfg.OAuth, stores.OAuthStates, oidc.NewHTTPClient(cfg.OAuth.HTTPTimeout)), lockout.NewGuard(stores.Lockout, cfg.Lockout))
 	postService := post.NewPostService(postRepo)
-	commentService := comment.NewCommentService(commentRepo, postRepo)
+	commentService := comment.NewCommentService(commentRepo, postRepo, comment.NewUnitOfWork(db), cfg.Comments)
+	tagService := tag.NewTagService(tagRepo)
+	tokenService := token.NewTokenService(tokenRepo)
+
+	// Публикация запланированных статей
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go post.NewScheduler(postRepo, cfg.Scheduler.Interval).Run(ctx)
 
 	// Handlers
 	authHandler := auth.NewAuthHandler(userService, authService, v)
 	userHandler := user.NewUserHandler(userService, postService, v)
 	postHandler := post.NewPostHandler(postService, v)
 	commentHandler := comment.NewCommentHandler(commentService, v)
+	tagHandler := tag.NewTagHandler(tagService, postService)
+	tokenHandler := token.NewTokenHandler(tokenService, v)
+
+	// Health checks: схема должна быть на версии, которую ожидает сборка
+	schema, err := migrations.All(cfg.DB.Driver)
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator, err := migrate.New(db, schema)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// База в памяти создается пустой при каждом запуске, накатить миграции заранее некому
+	if cfg.DB.Driver == db2.DriverSQLite && (cfg.DB.Path == "" || cfg.DB.Path == db2.MemoryPath) {
+		applied, err := migrator.Up(0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logger.Log.Info("In-memory database migrated", zap.Int("applied", len(applied)))
+	}
+	// Первый администратор: роль admin выдается пользователю из jwt.bootstrap_admin_email
+	if cfg.Auth.BootstrapAdminEmail != "" {
+		err = userService.BootstrapAdmin(ctx, cfg.Auth.BootstrapAdminEmail)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			logger.Log.Warn("Bootstrap admin is not registered yet", zap.String("email", cfg.Auth.BootstrapAdminEmail))
+		case err != nil:
+			log.Fatal(err)
+		default:
+			logger.Log.Info("Bootstrap admin granted", zap.String("email", cfg.Auth.BootstrapAdminEmail))
+		}
+	}
+	healthHandler := health.NewHealthHandler(cfg.Server.HealthTimeout,
+		health.Database(db), health.Redis(stores.Redis), health.Migrations(migrator))
 
 	// Init Fiber App
 	logger.Log.Debug("Init fiber")
@@ -94,6 +171,9 @@ func main() {
 	// Middleware для логирование запросов
 	app.Use(middleware.LogMiddleware())
 
+	// Дедлайн запроса: контекст передается в сервисы, репозитории и Redis
+	app.Use(middleware.RequestTimeout(cfg.Server.RequestTimeout))
+
 	// CORS Middleware
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "https://gofiber.io, https://gofiber.net, http://localhost",
@@ -106,11 +186,31 @@ func main() {
 		UserHandler:    userHandler,
 		PostHandler:    postHandler,
 		CommentHandler: commentHandler,
+		TagHandler:     tagHandler,
+		TokenHandler:   tokenHandler,
+		HealthHandler:  healthHandler,
 		JWT:            jwtAuth,
+		RoleProvider:   userService,
+		Permissions:    policy.Checker{},
+		PersonalTokens: tokenService,
+		RateLimiter:    stores.RateLimiter,
+		RateLimits:     cfg.RateLimit,
 	}
 
 	routes.SetupRoutes(app, routeDeps)
 
+	// Graceful shutdown: сначала /readyz начинает отвечать 503, и только через server.shutdown_delay
+	// сервер перестает принимать соединения и дожидается текущих запросов
+	go func() {
+		<-ctx.Done()
+		logger.Log.Info("Shutting down")
+		healthHandler.Shutdown()
+		time.Sleep(cfg.Server.ShutdownDelay)
+		if err := app.Shutdown(); err != nil {
+			logger.Log.Error("Failed to shut down", zap.Error(err))
+		}
+	}()
+
 	// Start app
 	logger.Log.Debug("Start app...")
 	err = app.Listen(fmt.Sprintf(":%d", cfg.Server.Port))
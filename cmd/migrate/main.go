@@ -0,0 +1,142 @@
+// Команда migrate - управление схемой БД:
+//
+//	migrate up [N]       применить ожидающие миграции (все или N)
+//	migrate down [N]     откатить N последних миграций (по умолчанию одну)
+//	migrate status       примененные и ожидающие миграции
+//	migrate redo         откатить и заново применить последнюю миграцию
+//	migrate create NAME  создать пустые up и down скрипты для каждой СУБД в migrations
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/db"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"github.com/crafty-ezhik/blog-api/migrations"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/migrate"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const usage = "usage: migrate up [N] | down [N] | status | redo | create NAME"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	command, args := args[0], args[1:]
+
+	// create работает только с файлами и не требует подключения к БД
+	if command == "create" {
+		if len(args) != 1 {
+			return errors.New(usage)
+		}
+		for _, driver := range migrations.Drivers {
+			all, err := migrations.All(driver)
+			if err != nil {
+				return err
+			}
+			var last uint64
+			if len(all) > 0 {
+				last = all[len(all)-1].Version
+			}
+			up, down, err := migrate.Create(filepath.Join(migrations.Dir, driver), args[0], last)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Созданы %s и %s\n", up, down)
+		}
+		return nil
+	}
+
+	n := 0
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+			return fmt.Errorf("invalid count %q: %s", args[0], usage)
+		}
+	}
+
+	cfg, err := config.LoadConfig("./configs")
+	if err != nil {
+		return err
+	}
+	if err = logger.InitLogger(cfg); err != nil {
+		return err
+	}
+	all, err := migrations.All(cfg.DB.Driver)
+	if err != nil {
+		return err
+	}
+	conn, err := db.GetConnection(cfg)
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(conn, all)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		done, err := migrator.Up(n)
+		printMigrations("Применена", done)
+		if err == nil && len(done) == 0 {
+			fmt.Println("Схема актуальна")
+		}
+		return err
+	case "down":
+		done, err := migrator.Down(n)
+		printMigrations("Откачена", done)
+		return err
+	case "redo":
+		redone, err := migrator.Redo()
+		if redone != nil {
+			printMigrations("Переприменена", []migrate.Migration{*redone})
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		printStatus(statuses)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q: %s", command, usage)
+	}
+}
+
+func printMigrations(action string, done []migrate.Migration) {
+	for _, m := range done {
+		fmt.Printf("%s %04d_%s\n", action, m.Version, m.Name)
+	}
+}
+
+func printStatus(statuses []migrate.Status) {
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Migration == nil:
+			state = "applied, missing in build"
+		case s.Modified:
+			state = "applied, modified"
+		case s.Applied:
+			state = "applied"
+		}
+		applied := ""
+		if s.Applied {
+			applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%04d  %-40s %-19s  %s\n", s.Version, s.Name, applied, state)
+	}
+}
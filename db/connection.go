@@ -1,26 +1,148 @@
 package db
 
 import (
+	"database/sql"
 	"fmt"
 	"github.com/crafty-ezhik/blog-api/internal/config"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"time"
 )
 
-func GetConnection(conf *config.Config) *gorm.DB {
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// MemoryPath - база SQLite в памяти процесса
+const MemoryPath = ":memory:"
+
+const (
+	DefaultConnectAttempts = 5
+	DefaultConnectBackoff  = time.Second
+	// maxConnectBackoff - предел паузы между попытками подключения
+	maxConnectBackoff = 30 * time.Second
+)
+
+// sleep - подменяется в тестах
+var sleep = time.Sleep
+
+// GetConnection - подключение к основной БД и репликам. Пока БД недоступна, подключение повторяется
+// database.connect_attempts раз с удваивающейся паузой, после чего возвращается последняя ошибка
+func GetConnection(conf *config.Config) (*gorm.DB, error) {
 	logger.Log.Debug("Get connection to database")
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
-		conf.DB.Host,
-		conf.DB.Username,
-		conf.DB.Password,
-		conf.DB.Database,
-		conf.DB.Port,
-		conf.DB.SSLMode)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+
+	primary, err := dialector(conf.DB, conf.DB.Host, conf.DB.Port, conf.DB.Path)
+	if err != nil {
+		return nil, err
+	}
+	replicas := make([]gorm.Dialector, 0, len(conf.DB.Replicas))
+	for _, replica := range conf.DB.Replicas {
+		d, err := dialector(conf.DB, replica.Host, replica.Port, replica.Path)
+		if err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, d)
+	}
+
+	attempts, backoff := conf.DB.ConnectAttempts, conf.DB.ConnectBackoff
+	if attempts <= 0 {
+		attempts = DefaultConnectAttempts
+	}
+	if backoff <= 0 {
+		backoff = DefaultConnectBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		db, err := open(conf.DB, primary, replicas)
+		if err == nil {
+			return db, nil
+		}
+		if attempt == attempts {
+			return nil, fmt.Errorf("connect to database: %w", err)
+		}
+		logger.Log.Warn("Database is unavailable, retrying",
+			zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		sleep(backoff)
+		backoff = min(2*backoff, maxConnectBackoff)
+	}
+}
+
+func open(conf config.DbConfig, primary gorm.Dialector, replicas []gorm.Dialector) (*gorm.DB, error) {
+	db, err := gorm.Open(primary, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	configurePool(conf, sqlDB)
+
+	if len(replicas) > 0 {
+		resolver := dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		}, ReplicaResolver)
+		if err = db.Use(resolver); err != nil {
+			sqlDB.Close()
+			return nil, err
+		}
+		resolver.Call(func(pool gorm.ConnPool) error {
+			if replica, ok := pool.(*sql.DB); ok {
+				configurePool(conf, replica)
+			}
+			return nil
+		})
+	}
+	return db, nil
+}
+
+func configurePool(conf config.DbConfig, pool *sql.DB) {
+	if conf.Driver == DriverSQLite {
+		// SQLite допускает одного писателя, а база в памяти существует только внутри своего соединения,
+		// поэтому все запросы идут через одно соединение, которое не закрывается
+		pool.SetMaxOpenConns(1)
+		pool.SetMaxIdleConns(1)
+		return
+	}
+	if conf.MaxOpen > 0 {
+		pool.SetMaxOpenConns(conf.MaxOpen)
+	}
+	if conf.MaxIdle > 0 {
+		pool.SetMaxIdleConns(conf.MaxIdle)
+	}
+	pool.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	pool.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+}
+
+func dialector(conf config.DbConfig, host string, port int, path string) (gorm.Dialector, error) {
+	switch conf.Driver {
+	case "", DriverPostgres:
+		dsn := fmt.Sprintf(
+			"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+			host,
+			conf.Username,
+			conf.Password,
+			conf.Database,
+			port,
+			conf.SSLMode)
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		return sqlite.New(sqlite.Config{DriverName: sqliteDriverName, DSN: sqliteDSN(path)}), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", conf.Driver)
+	}
+}
+
+// sqliteDSN - файл базы с включенной проверкой внешних ключей, как в PostgreSQL
+func sqliteDSN(path string) string {
+	if path == "" || path == MemoryPath {
+		return "file::memory:?_foreign_keys=on"
 	}
-	return db
+	return "file:" + path + "?_foreign_keys=on&_busy_timeout=5000"
 }
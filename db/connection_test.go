@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type note struct {
+	ID   uint
+	Text string
+}
+
+func TestGetConnection_Retry(t *testing.T) {
+	logger.Log = zap.NewNop()
+	var pauses []time.Duration
+	sleep = func(d time.Duration) { pauses = append(pauses, d) }
+	defer func() { sleep = time.Sleep }()
+
+	_, err := GetConnection(&config.Config{DB: config.DbConfig{
+		Driver:          DriverSQLite,
+		Path:            filepath.Join(t.TempDir(), "missing", "blog.db"),
+		ConnectAttempts: 4,
+		ConnectBackoff:  20 * time.Second,
+	}})
+	assert.Error(t, err)
+	assert.Equal(t, []time.Duration{20 * time.Second, 30 * time.Second, 30 * time.Second}, pauses)
+}
+
+func TestGetConnection_Replicas(t *testing.T) {
+	logger.Log = zap.NewNop()
+	dir := t.TempDir()
+	primaryPath, replicaPath := filepath.Join(dir, "primary.db"), filepath.Join(dir, "replica.db")
+
+	// Реплика наполняется отдельно, чтобы было видно, откуда прочитаны данные
+	for path, text := range map[string]string{primaryPath: "primary", replicaPath: "replica"} {
+		conn, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, conn.AutoMigrate(&note{}))
+		require.NoError(t, conn.Create(&note{Text: text}).Error)
+		sqlDB, _ := conn.DB()
+		sqlDB.Close()
+	}
+
+	conn, err := GetConnection(&config.Config{DB: config.DbConfig{
+		Driver:   DriverSQLite,
+		Path:     primaryPath,
+		Replicas: []config.ReplicaConfig{{Path: replicaPath}},
+	}})
+	require.NoError(t, err)
+
+	var n note
+	require.NoError(t, conn.First(&n).Error)
+	assert.Equal(t, "primary", n.Text)
+
+	require.NoError(t, Replica(conn).First(&n).Error)
+	assert.Equal(t, "replica", n.Text)
+
+	// Запись через Replica все равно идет в основную БД
+	require.NoError(t, Replica(conn).Create(&note{Text: "written"}).Error)
+	var count int64
+	require.NoError(t, conn.Model(&note{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	// Внутри транзакции чтение идет из основной БД
+	err = conn.Transaction(func(tx *gorm.DB) error {
+		return Replica(tx).First(&n).Error
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "primary", n.Text)
+}
+
+func TestConfigurePool(t *testing.T) {
+	pool, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer pool.Close()
+
+	configurePool(config.DbConfig{Driver: DriverPostgres, MaxOpen: 7, MaxIdle: 3}, pool)
+	assert.Equal(t, 7, pool.Stats().MaxOpenConnections)
+
+	configurePool(config.DbConfig{Driver: DriverSQLite, MaxOpen: 7}, pool)
+	assert.Equal(t, 1, pool.Stats().MaxOpenConnections)
+}
+
+func TestGetConnection_SQLiteUnicodeLower(t *testing.T) {
+	logger.Log = zap.NewNop()
+	conn, err := GetConnection(&config.Config{DB: config.DbConfig{Driver: DriverSQLite, Path: MemoryPath}})
+	require.NoError(t, err)
+	sqlDB, err := conn.DB()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	var lower, upper string
+	require.NoError(t, conn.Raw("SELECT LOWER(?), UPPER(?)", "Блог на Fiber", "ёжик").Row().Scan(&lower, &upper))
+	assert.Equal(t, "блог на fiber", lower)
+	assert.Equal(t, "ЁЖИК", upper)
+}
@@ -0,0 +1,16 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ReplicaResolver - имя резолвера dbresolver, через который чтение уходит на реплики
+const ReplicaResolver = "replicas"
+
+// Replica - запросы на чтение через tx выполняются на одной из реплик. Без настроенных реплик
+// и внутри транзакции запросы идут в основную БД, запись всегда идет в основную БД.
+// Реплика может отставать, поэтому Replica подходит для списков и поиска, но не для чтения после записи
+func Replica(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(dbresolver.Use(ReplicaResolver))
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/mattn/go-sqlite3"
+	"strings"
+)
+
+// sqliteDriverName - драйвер SQLite, в котором LOWER и UPPER работают со всем Unicode.
+// Встроенные функции SQLite меняют регистр только у ASCII, из-за чего поиск без учета регистра
+// не находил кириллицу. Функции приложения с тем же именем заменяют встроенные для всех запросов соединения
+const sqliteDriverName = "sqlite3_unicode"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("lower", strings.ToLower, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("upper", strings.ToUpper, true)
+		},
+	})
+}
@@ -1,12 +1,20 @@
 package auth
 
 import (
+	"errors"
 	"github.com/crafty-ezhik/blog-api/internal/user"
 	"github.com/crafty-ezhik/blog-api/pkg/jwt"
+	"github.com/crafty-ezhik/blog-api/pkg/lockout"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/oidc"
 	"github.com/crafty-ezhik/blog-api/pkg/req"
 	"github.com/crafty-ezhik/blog-api/pkg/validate"
 	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"math"
+	"strconv"
 )
 
 type AuthHandler interface {
@@ -14,6 +22,22 @@ type AuthHandler interface {
 	Register(c *fiber.Ctx) error
 	Logout(c *fiber.Ctx) error
 	Refresh(c *fiber.Ctx) error
+	VerifyEmail(c *fiber.Ctx) error
+	ForgotPassword(c *fiber.Ctx) error
+	ResetPassword(c *fiber.Ctx) error
+	ChangePassword(c *fiber.Ctx) error
+	ChangeEmail(c *fiber.Ctx) error
+	ConfirmEmailChange(c *fiber.Ctx) error
+	LoginMFA(c *fiber.Ctx) error
+	EnrollTOTP(c *fiber.Ctx) error
+	ConfirmTOTP(c *fiber.Ctx) error
+	GetSessions(c *fiber.Ctx) error
+	DeleteSession(c *fiber.Ctx) error
+	DeleteAllSessions(c *fiber.Ctx) error
+	JWKS(c *fiber.Ctx) error
+	OAuthStart(c *fiber.Ctx) error
+	OAuthCallback(c *fiber.Ctx) error
+	UnlockUser(c *fiber.Ctx) error
 }
 
 type AuthHandlerImpl struct {
@@ -36,15 +60,55 @@ func (h *AuthHandlerImpl) Login(c *fiber.Ctx) error {
 	if err != nil {
 		return nil
 	}
-	responseData, cookie, err := h.AuthService.Login(body)
+	responseData, cookie, err := h.AuthService.Login(c.UserContext(), body, sessionMeta(c))
 	if err != nil {
+		var locked *lockout.LockedError
+		if errors.As(err, &locked) {
+			return lockedResponse(c, locked)
+		}
+		if err.Error() == ErrEmailNotVerified {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"error":   err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"error":   err.Error(),
 		})
 	}
 
-	// Установка refresh в куки
+	// Установка refresh в куки. При включенной 2FA токенов еще нет
+	if cookie != nil {
+		c.Cookie(cookie)
+	}
+	return c.Status(fiber.StatusOK).JSON(responseData)
+}
+
+func (h *AuthHandlerImpl) LoginMFA(c *fiber.Ctx) error {
+	body, err := req.HandleBody[MFALoginRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+	responseData, cookie, err := h.AuthService.LoginMFA(c.UserContext(), body, sessionMeta(c))
+	if err != nil {
+		var locked *lockout.LockedError
+		if errors.As(err, &locked) {
+			return lockedResponse(c, locked)
+		}
+		if errors.Is(err, jwt.ErrOneTimeTokenInvalid) || err.Error() == ErrInvalidMFACode {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"success": false,
+				"error":   err.Error(),
+			})
+		}
+		logger.Log.Error("MFA login failed", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Internal server error",
+		})
+	}
+
 	c.Cookie(cookie)
 	return c.Status(fiber.StatusOK).JSON(responseData)
 }
@@ -54,11 +118,11 @@ func (h *AuthHandlerImpl) Logout(c *fiber.Ctx) error {
 	if refreshToken == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"success": false,
-			"err":     jwt.ErrInBlackList,
+			"err":     jwt.ErrInBlackList.Error(),
 		})
 	}
 
-	cookie, err := h.AuthService.Logout(refreshToken)
+	cookie, err := h.AuthService.Logout(c.UserContext(), refreshToken)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"success": false,
@@ -81,7 +145,7 @@ func (h *AuthHandlerImpl) Register(c *fiber.Ctx) error {
 	if err != nil {
 		return nil
 	}
-	ok, err := h.AuthService.Register(body)
+	ok, err := h.AuthService.Register(c.UserContext(), body)
 	if err != nil || !ok {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"success": false,
@@ -101,10 +165,10 @@ func (h *AuthHandlerImpl) Refresh(c *fiber.Ctx) error {
 	if refreshToken == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"success": false,
-			"err":     jwt.ErrInBlackList,
+			"err":     jwt.ErrInBlackList.Error(),
 		})
 	}
-	tokens, cookie, err := h.AuthService.Refresh(refreshToken)
+	tokens, cookie, err := h.AuthService.Refresh(c.UserContext(), refreshToken, sessionMeta(c))
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"success": false,
@@ -119,3 +183,421 @@ func (h *AuthHandlerImpl) Refresh(c *fiber.Ctx) error {
 		"access_token": tokens.AccessToken,
 	})
 }
+
+func (h *AuthHandlerImpl) VerifyEmail(c *fiber.Ctx) error {
+	body, err := req.HandleBody[VerifyEmailRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+	err = h.AuthService.VerifyEmail(c.UserContext(), body.Token)
+	if err != nil {
+		return oneTimeTokenError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Email verified",
+	})
+}
+
+func (h *AuthHandlerImpl) ForgotPassword(c *fiber.Ctx) error {
+	body, err := req.HandleBody[ForgotPasswordRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+
+	// Ответ не зависит от результата, чтобы по нему нельзя было узнать, зарегистрирован ли email
+	err = h.AuthService.ForgotPassword(c.UserContext(), body.Email)
+	if err != nil {
+		logger.Log.Error("Failed to send password reset email", zap.Error(err))
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "If the email is registered, a reset link has been sent",
+	})
+}
+
+func (h *AuthHandlerImpl) ResetPassword(c *fiber.Ctx) error {
+	body, err := req.HandleBody[ResetPasswordRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+	err = h.AuthService.ResetPassword(c.UserContext(), body.Token, body.Password)
+	if err != nil {
+		return oneTimeTokenError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Password has been reset",
+	})
+}
+
+func (h *AuthHandlerImpl) ChangePassword(c *fiber.Ctx) error {
+	body, err := req.HandleBody[ChangePasswordRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+
+	ctxUserID, ok := c.Locals(middleware.UserIDKey).(uint)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "user id must be a uint",
+		})
+	}
+
+	responseData, cookie, err := h.AuthService.ChangePassword(c.UserContext(), ctxUserID, body, sessionMeta(c))
+	if err != nil {
+		return credentialsError(c, err)
+	}
+
+	// Старый refresh токен больше недействителен, отдаем новый
+	c.Cookie(cookie)
+	return c.Status(fiber.StatusOK).JSON(responseData)
+}
+
+func (h *AuthHandlerImpl) ChangeEmail(c *fiber.Ctx) error {
+	body, err := req.HandleBody[ChangeEmailRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+
+	ctxUserID, ok := c.Locals(middleware.UserIDKey).(uint)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "user id must be a uint",
+		})
+	}
+
+	err = h.AuthService.RequestEmailChange(c.UserContext(), ctxUserID, body)
+	if err != nil {
+		return credentialsError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "Confirmation link has been sent to the new email",
+	})
+}
+
+func (h *AuthHandlerImpl) ConfirmEmailChange(c *fiber.Ctx) error {
+	body, err := req.HandleBody[ConfirmEmailRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+	err = h.AuthService.ConfirmEmailChange(c.UserContext(), body.Token)
+	if errors.Is(err, user.ErrEmailTaken) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+	if err != nil {
+		return oneTimeTokenError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Email changed",
+	})
+}
+
+func (h *AuthHandlerImpl) EnrollTOTP(c *fiber.Ctx) error {
+	ctxUserID, ok := c.Locals(middleware.UserIDKey).(uint)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "user id must be a uint",
+		})
+	}
+
+	data, err := h.AuthService.EnrollTOTP(c.UserContext(), ctxUserID)
+	if err != nil {
+		return totpError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+func (h *AuthHandlerImpl) ConfirmTOTP(c *fiber.Ctx) error {
+	body, err := req.HandleBody[TOTPConfirmRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+
+	ctxUserID, ok := c.Locals(middleware.UserIDKey).(uint)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "user id must be a uint",
+		})
+	}
+
+	data, err := h.AuthService.ConfirmTOTP(c.UserContext(), ctxUserID, body.Code)
+	if err != nil {
+		return totpError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+func (h *AuthHandlerImpl) GetSessions(c *fiber.Ctx) error {
+	ctxUserID, ok := c.Locals(middleware.UserIDKey).(uint)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "user id must be a uint",
+		})
+	}
+	currentSessionID, _ := c.Locals(middleware.SessionIDKey).(string)
+
+	sessions, err := h.AuthService.ListSessions(c.UserContext(), ctxUserID, currentSessionID)
+	if err != nil {
+		logger.Log.Error("Failed to list sessions", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Something went wrong",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    sessions,
+	})
+}
+
+func (h *AuthHandlerImpl) DeleteSession(c *fiber.Ctx) error {
+	ctxUserID, ok := c.Locals(middleware.UserIDKey).(uint)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "user id must be a uint",
+		})
+	}
+
+	sessionID := c.Params("id")
+	err := h.AuthService.RevokeSession(c.UserContext(), ctxUserID, sessionID)
+	if errors.Is(err, jwt.ErrSessionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Session not found",
+		})
+	}
+	if err != nil {
+		logger.Log.Error("Failed to revoke session", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Something went wrong",
+		})
+	}
+
+	// Завершена текущая сессия - refresh токен в cookie больше не нужен
+	if currentSessionID, _ := c.Locals(middleware.SessionIDKey).(string); currentSessionID == sessionID {
+		c.Cookie(clearRefreshCookie())
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Session revoked",
+	})
+}
+
+func (h *AuthHandlerImpl) DeleteAllSessions(c *fiber.Ctx) error {
+	ctxUserID, ok := c.Locals(middleware.UserIDKey).(uint)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "user id must be a uint",
+		})
+	}
+
+	cookie, err := h.AuthService.RevokeAllSessions(c.UserContext(), ctxUserID)
+	if err != nil {
+		logger.Log.Error("Failed to revoke sessions", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Something went wrong",
+		})
+	}
+
+	c.Cookie(cookie)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Logged out from all devices",
+	})
+}
+
+// sessionMeta - данные об устройстве для сессии
+func sessionMeta(c *fiber.Ctx) jwt.SessionMeta {
+	return jwt.SessionMeta{
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IP:        c.IP(),
+	}
+}
+
+// lockedResponse - 429 для заблокированного входа, Retry-After в целых секундах с округлением вверх
+func lockedResponse(c *fiber.Ctx, locked *lockout.LockedError) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"success": false,
+		"error":   locked.Error(),
+	})
+}
+
+// totpError - ответ на ошибку при подключении 2FA
+func totpError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	message := "Something went wrong"
+	switch {
+	case err.Error() == ErrTOTPAlreadyEnabled:
+		status, message = fiber.StatusConflict, err.Error()
+	case err.Error() == ErrTOTPNotEnrolled, err.Error() == ErrInvalidMFACode:
+		status, message = fiber.StatusBadRequest, err.Error()
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status, message = fiber.StatusNotFound, "user not found"
+	default:
+		logger.Log.Error("TOTP enrollment failed", zap.Error(err))
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error":   message,
+	})
+}
+
+// credentialsError - ответ на ошибку при изменении пароля или email
+func credentialsError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	message := "Something went wrong"
+	switch {
+	case err.Error() == ErrInvalidCredentials:
+		status, message = fiber.StatusForbidden, "Current password is incorrect"
+	case err.Error() == ErrSameEmail:
+		status, message = fiber.StatusBadRequest, err.Error()
+	case err.Error() == ErrUserExisted:
+		status, message = fiber.StatusConflict, err.Error()
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status, message = fiber.StatusNotFound, "user not found"
+	default:
+		logger.Log.Error("Credentials update failed", zap.Error(err))
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error":   message,
+	})
+}
+
+// oneTimeTokenError - ответ на ошибку при использовании токена из письма
+func oneTimeTokenError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, jwt.ErrOneTimeTokenInvalid) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+	logger.Log.Error("One-time token operation failed", zap.Error(err))
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"error":   "Internal server error",
+	})
+}
+
+// JWKS - публикует открытые ключи, чтобы другие сервисы могли проверять access токены без общего секрета
+func (h *AuthHandlerImpl) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(h.AuthService.JWKS())
+}
+
+// OAuthStart - перенаправляет на вход у провайдера. state дополнительно сохраняется в cookie,
+// чтобы завершить вход мог только браузер, который его начал
+func (h *AuthHandlerImpl) OAuthStart(c *fiber.Ctx) error {
+	redirectURL, cookie, err := h.AuthService.OAuthStart(c.UserContext(), c.Params("provider"))
+	if err != nil {
+		return oauthError(c, err)
+	}
+
+	c.Cookie(cookie)
+	return c.Redirect(redirectURL, fiber.StatusFound)
+}
+
+// OAuthCallback - завершает вход через провайдера. Ответ такой же, как у Login
+func (h *AuthHandlerImpl) OAuthCallback(c *fiber.Ctx) error {
+	c.Cookie(ClearOAuthStateCookie())
+
+	if reason := c.Query("error"); reason != "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"error":   "Provider denied access: " + reason,
+		})
+	}
+	state := c.Query("state")
+	if state == "" || c.Cookies(OAuthStateCookie) != state {
+		return oauthError(c, oidc.ErrInvalidState)
+	}
+
+	responseData, cookie, err := h.AuthService.OAuthLogin(c.UserContext(), c.Params("provider"), state, c.Query("code"), sessionMeta(c))
+	if err != nil {
+		return oauthError(c, err)
+	}
+
+	// При включенной 2FA токенов еще нет
+	if cookie != nil {
+		c.Cookie(cookie)
+	}
+	return c.Status(fiber.StatusOK).JSON(responseData)
+}
+
+// oauthError - ответ на ошибку входа через провайдера
+func oauthError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, oidc.ErrUnknownProvider):
+		status = fiber.StatusNotFound
+	case errors.Is(err, oidc.ErrInvalidState):
+		status = fiber.StatusBadRequest
+	case err.Error() == ErrProviderEmail:
+		status = fiber.StatusForbidden
+	case errors.Is(err, oidc.ErrExchange), errors.Is(err, oidc.ErrInvalidToken):
+		status = fiber.StatusUnauthorized
+	}
+	if status == fiber.StatusInternalServerError {
+		logger.Log.Error("OAuth login failed", zap.Error(err))
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"error":   "Internal server error",
+		})
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
+// UnlockUser - снимает блокировку входа пользователя до истечения ее срока
+func (h *AuthHandlerImpl) UnlockUser(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "id must be an integer",
+		})
+	}
+
+	err = h.AuthService.UnlockUser(c.UserContext(), uint(userID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "user not found",
+		})
+	}
+	if err != nil {
+		logger.Log.Error("Failed to unlock user", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Something went wrong",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "User unlocked",
+	})
+}
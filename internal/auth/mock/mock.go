@@ -10,6 +10,7 @@
 package mock_auth
 
 import (
+	context "context"
 	reflect "reflect"
 
 	auth "github.com/crafty-ezhik/blog-api/internal/auth"
@@ -42,10 +43,113 @@ func (m *MockAuthService) EXPECT() *MockAuthServiceMockRecorder {
 	return m.recorder
 }
 
+// ChangePassword mocks base method.
+func (m *MockAuthService) ChangePassword(ctx context.Context, userID uint, data *auth.ChangePasswordRequest, meta jwt.SessionMeta) (*auth.LoginResponse, *fiber.Cookie, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, userID, data, meta)
+	ret0, _ := ret[0].(*auth.LoginResponse)
+	ret1, _ := ret[1].(*fiber.Cookie)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockAuthServiceMockRecorder) ChangePassword(ctx, userID, data, meta any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockAuthService)(nil).ChangePassword), ctx, userID, data, meta)
+}
+
+// ConfirmEmailChange mocks base method.
+func (m *MockAuthService) ConfirmEmailChange(ctx context.Context, token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmEmailChange", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmEmailChange indicates an expected call of ConfirmEmailChange.
+func (mr *MockAuthServiceMockRecorder) ConfirmEmailChange(ctx, token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmEmailChange", reflect.TypeOf((*MockAuthService)(nil).ConfirmEmailChange), ctx, token)
+}
+
+// ConfirmTOTP mocks base method.
+func (m *MockAuthService) ConfirmTOTP(ctx context.Context, userID uint, code string) (*auth.RecoveryCodesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", ctx, userID, code)
+	ret0, _ := ret[0].(*auth.RecoveryCodesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockAuthServiceMockRecorder) ConfirmTOTP(ctx, userID, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockAuthService)(nil).ConfirmTOTP), ctx, userID, code)
+}
+
+// EnrollTOTP mocks base method.
+func (m *MockAuthService) EnrollTOTP(ctx context.Context, userID uint) (*auth.TOTPEnrollResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTOTP", ctx, userID)
+	ret0, _ := ret[0].(*auth.TOTPEnrollResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTOTP indicates an expected call of EnrollTOTP.
+func (mr *MockAuthServiceMockRecorder) EnrollTOTP(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTOTP", reflect.TypeOf((*MockAuthService)(nil).EnrollTOTP), ctx, userID)
+}
+
+// ForgotPassword mocks base method.
+func (m *MockAuthService) ForgotPassword(ctx context.Context, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgotPassword", ctx, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgotPassword indicates an expected call of ForgotPassword.
+func (mr *MockAuthServiceMockRecorder) ForgotPassword(ctx, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgotPassword", reflect.TypeOf((*MockAuthService)(nil).ForgotPassword), ctx, email)
+}
+
+// JWKS mocks base method.
+func (m *MockAuthService) JWKS() jwt.JWKS {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS")
+	ret0, _ := ret[0].(jwt.JWKS)
+	return ret0
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockAuthServiceMockRecorder) JWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockAuthService)(nil).JWKS))
+}
+
+// ListSessions mocks base method.
+func (m *MockAuthService) ListSessions(ctx context.Context, userID uint, currentSessionID string) ([]auth.SessionResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessions", ctx, userID, currentSessionID)
+	ret0, _ := ret[0].([]auth.SessionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessions indicates an expected call of ListSessions.
+func (mr *MockAuthServiceMockRecorder) ListSessions(ctx, userID, currentSessionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessions", reflect.TypeOf((*MockAuthService)(nil).ListSessions), ctx, userID, currentSessionID)
+}
+
 // Login mocks base method.
-func (m *MockAuthService) Login(data *auth.LoginRequest) (*auth.LoginResponse, *fiber.Cookie, error) {
+func (m *MockAuthService) Login(ctx context.Context, data *auth.LoginRequest, meta jwt.SessionMeta) (*auth.LoginResponse, *fiber.Cookie, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Login", data)
+	ret := m.ctrl.Call(m, "Login", ctx, data, meta)
 	ret0, _ := ret[0].(*auth.LoginResponse)
 	ret1, _ := ret[1].(*fiber.Cookie)
 	ret2, _ := ret[2].(error)
@@ -53,30 +157,78 @@ func (m *MockAuthService) Login(data *auth.LoginRequest) (*auth.LoginResponse, *
 }
 
 // Login indicates an expected call of Login.
-func (mr *MockAuthServiceMockRecorder) Login(data any) *gomock.Call {
+func (mr *MockAuthServiceMockRecorder) Login(ctx, data, meta any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockAuthService)(nil).Login), ctx, data, meta)
+}
+
+// LoginMFA mocks base method.
+func (m *MockAuthService) LoginMFA(ctx context.Context, data *auth.MFALoginRequest, meta jwt.SessionMeta) (*auth.LoginResponse, *fiber.Cookie, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoginMFA", ctx, data, meta)
+	ret0, _ := ret[0].(*auth.LoginResponse)
+	ret1, _ := ret[1].(*fiber.Cookie)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoginMFA indicates an expected call of LoginMFA.
+func (mr *MockAuthServiceMockRecorder) LoginMFA(ctx, data, meta any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockAuthService)(nil).Login), data)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginMFA", reflect.TypeOf((*MockAuthService)(nil).LoginMFA), ctx, data, meta)
 }
 
 // Logout mocks base method.
-func (m *MockAuthService) Logout(tokenStr string) (*fiber.Cookie, error) {
+func (m *MockAuthService) Logout(ctx context.Context, tokenStr string) (*fiber.Cookie, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Logout", tokenStr)
+	ret := m.ctrl.Call(m, "Logout", ctx, tokenStr)
 	ret0, _ := ret[0].(*fiber.Cookie)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Logout indicates an expected call of Logout.
-func (mr *MockAuthServiceMockRecorder) Logout(tokenStr any) *gomock.Call {
+func (mr *MockAuthServiceMockRecorder) Logout(ctx, tokenStr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockAuthService)(nil).Logout), ctx, tokenStr)
+}
+
+// OAuthLogin mocks base method.
+func (m *MockAuthService) OAuthLogin(ctx context.Context, provider, state, code string, meta jwt.SessionMeta) (*auth.LoginResponse, *fiber.Cookie, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OAuthLogin", ctx, provider, state, code, meta)
+	ret0, _ := ret[0].(*auth.LoginResponse)
+	ret1, _ := ret[1].(*fiber.Cookie)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// OAuthLogin indicates an expected call of OAuthLogin.
+func (mr *MockAuthServiceMockRecorder) OAuthLogin(ctx, provider, state, code, meta any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OAuthLogin", reflect.TypeOf((*MockAuthService)(nil).OAuthLogin), ctx, provider, state, code, meta)
+}
+
+// OAuthStart mocks base method.
+func (m *MockAuthService) OAuthStart(ctx context.Context, provider string) (string, *fiber.Cookie, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OAuthStart", ctx, provider)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(*fiber.Cookie)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// OAuthStart indicates an expected call of OAuthStart.
+func (mr *MockAuthServiceMockRecorder) OAuthStart(ctx, provider any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockAuthService)(nil).Logout), tokenStr)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OAuthStart", reflect.TypeOf((*MockAuthService)(nil).OAuthStart), ctx, provider)
 }
 
 // Refresh mocks base method.
-func (m *MockAuthService) Refresh(tokenStr string) (*jwt.Tokens, *fiber.Cookie, error) {
+func (m *MockAuthService) Refresh(ctx context.Context, tokenStr string, meta jwt.SessionMeta) (*jwt.Tokens, *fiber.Cookie, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Refresh", tokenStr)
+	ret := m.ctrl.Call(m, "Refresh", ctx, tokenStr, meta)
 	ret0, _ := ret[0].(*jwt.Tokens)
 	ret1, _ := ret[1].(*fiber.Cookie)
 	ret2, _ := ret[2].(error)
@@ -84,22 +236,107 @@ func (m *MockAuthService) Refresh(tokenStr string) (*jwt.Tokens, *fiber.Cookie,
 }
 
 // Refresh indicates an expected call of Refresh.
-func (mr *MockAuthServiceMockRecorder) Refresh(tokenStr any) *gomock.Call {
+func (mr *MockAuthServiceMockRecorder) Refresh(ctx, tokenStr, meta any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockAuthService)(nil).Refresh), tokenStr)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockAuthService)(nil).Refresh), ctx, tokenStr, meta)
 }
 
 // Register mocks base method.
-func (m *MockAuthService) Register(data *auth.RegisterRequest) (bool, error) {
+func (m *MockAuthService) Register(ctx context.Context, data *auth.RegisterRequest) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Register", data)
+	ret := m.ctrl.Call(m, "Register", ctx, data)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Register indicates an expected call of Register.
-func (mr *MockAuthServiceMockRecorder) Register(data any) *gomock.Call {
+func (mr *MockAuthServiceMockRecorder) Register(ctx, data any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockAuthService)(nil).Register), ctx, data)
+}
+
+// RequestEmailChange mocks base method.
+func (m *MockAuthService) RequestEmailChange(ctx context.Context, userID uint, data *auth.ChangeEmailRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestEmailChange", ctx, userID, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestEmailChange indicates an expected call of RequestEmailChange.
+func (mr *MockAuthServiceMockRecorder) RequestEmailChange(ctx, userID, data any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestEmailChange", reflect.TypeOf((*MockAuthService)(nil).RequestEmailChange), ctx, userID, data)
+}
+
+// ResetPassword mocks base method.
+func (m *MockAuthService) ResetPassword(ctx context.Context, token, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, token, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockAuthServiceMockRecorder) ResetPassword(ctx, token, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockAuthService)(nil).ResetPassword), ctx, token, password)
+}
+
+// RevokeAllSessions mocks base method.
+func (m *MockAuthService) RevokeAllSessions(ctx context.Context, userID uint) (*fiber.Cookie, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAllSessions", ctx, userID)
+	ret0, _ := ret[0].(*fiber.Cookie)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAllSessions indicates an expected call of RevokeAllSessions.
+func (mr *MockAuthServiceMockRecorder) RevokeAllSessions(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAllSessions", reflect.TypeOf((*MockAuthService)(nil).RevokeAllSessions), ctx, userID)
+}
+
+// RevokeSession mocks base method.
+func (m *MockAuthService) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, userID, sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockAuthServiceMockRecorder) RevokeSession(ctx, userID, sessionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockAuthService)(nil).RevokeSession), ctx, userID, sessionID)
+}
+
+// UnlockUser mocks base method.
+func (m *MockAuthService) UnlockUser(ctx context.Context, userID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockUser", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockUser indicates an expected call of UnlockUser.
+func (mr *MockAuthServiceMockRecorder) UnlockUser(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockUser", reflect.TypeOf((*MockAuthService)(nil).UnlockUser), ctx, userID)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockAuthService) VerifyEmail(ctx context.Context, token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockAuthServiceMockRecorder) VerifyEmail(ctx, token any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockAuthService)(nil).Register), data)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockAuthService)(nil).VerifyEmail), ctx, token)
 }
@@ -1,79 +1,194 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/crafty-ezhik/blog-api/internal/config"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/token"
 	"github.com/crafty-ezhik/blog-api/internal/user"
 	cjwt "github.com/crafty-ezhik/blog-api/pkg/jwt"
+	"github.com/crafty-ezhik/blog-api/pkg/lockout"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/mailer"
+	"github.com/crafty-ezhik/blog-api/pkg/oidc"
+	"github.com/crafty-ezhik/blog-api/pkg/totp"
 	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"strings"
+	"sync"
+	"time"
 )
 
 //go:generate mockgen -source=service.go -destination=mock/mock.go
 
+// Время жизни одноразовых ссылок, если оно не задано в конфиге
+const (
+	DefaultVerifyEmailTTL   = 24 * time.Hour
+	DefaultResetPasswordTTL = time.Hour
+	DefaultMFATTL           = 5 * time.Minute
+	DefaultTOTPIssuer       = "blog-api"
+
+	recoveryCodesCount = 10
+
+	// OAuthStateCookie - cookie, привязывающая начатый вход через провайдера к браузеру
+	OAuthStateCookie = "oauth_state"
+)
+
 const (
 	ErrUserExisted        = "the user with this email already exists"
 	ErrInvalidCredentials = "invalid credentials"
+	ErrEmailNotVerified   = "email is not verified"
+	ErrSameEmail          = "new email matches the current one"
+	ErrTOTPAlreadyEnabled = "two-factor authentication is already enabled"
+	ErrTOTPNotEnrolled    = "two-factor authentication is not enrolled"
+	ErrInvalidMFACode     = "invalid authentication code"
+	ErrProviderEmail      = "email is not verified by the provider"
 )
 
 type AuthService interface {
-	Register(data *RegisterRequest) (bool, error)
-	Login(data *LoginRequest) (*LoginResponse, *fiber.Cookie, error)
-	Refresh(tokenStr string) (*cjwt.Tokens, *fiber.Cookie, error)
-	Logout(tokenStr string) (*fiber.Cookie, error)
+	Register(ctx context.Context, data *RegisterRequest) (bool, error)
+	Login(ctx context.Context, data *LoginRequest, meta cjwt.SessionMeta) (*LoginResponse, *fiber.Cookie, error)
+	Refresh(ctx context.Context, tokenStr string, meta cjwt.SessionMeta) (*cjwt.Tokens, *fiber.Cookie, error)
+	Logout(ctx context.Context, tokenStr string) (*fiber.Cookie, error)
+	VerifyEmail(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	ChangePassword(ctx context.Context, userID uint, data *ChangePasswordRequest, meta cjwt.SessionMeta) (*LoginResponse, *fiber.Cookie, error)
+	RequestEmailChange(ctx context.Context, userID uint, data *ChangeEmailRequest) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	LoginMFA(ctx context.Context, data *MFALoginRequest, meta cjwt.SessionMeta) (*LoginResponse, *fiber.Cookie, error)
+	EnrollTOTP(ctx context.Context, userID uint) (*TOTPEnrollResponse, error)
+	ConfirmTOTP(ctx context.Context, userID uint, code string) (*RecoveryCodesResponse, error)
+	ListSessions(ctx context.Context, userID uint, currentSessionID string) ([]SessionResponse, error)
+	RevokeSession(ctx context.Context, userID uint, sessionID string) error
+	RevokeAllSessions(ctx context.Context, userID uint) (*fiber.Cookie, error)
+	JWKS() cjwt.JWKS
+	OAuthStart(ctx context.Context, provider string) (string, *fiber.Cookie, error)
+	OAuthLogin(ctx context.Context, provider, state, code string, meta cjwt.SessionMeta) (*LoginResponse, *fiber.Cookie, error)
+	UnlockUser(ctx context.Context, userID uint) error
 }
 
 type AuthServiceimpl struct {
-	cfg      *config.Config
-	jwtAuth  *cjwt.JWT
-	UserRepo user.UserRepository
+	cfg       *config.Config
+	jwtAuth   *cjwt.JWT
+	UserRepo  user.UserRepository
+	TokenRepo token.TokenRepository
+	tokens    cjwt.OneTimeTokenStorage
+	mailer   mailer.Sender
+	oauth    *oidc.Client
+	lockout  *lockout.Guard
 }
 
-func NewAuthService(cfg *config.Config, userRepo user.UserRepository, jwtAuth *cjwt.JWT) *AuthServiceimpl {
+func NewAuthService(cfg *config.Config, userRepo user.UserRepository, tokenRepo token.TokenRepository, jwtAuth *cjwt.JWT,
+	tokens cjwt.OneTimeTokenStorage, sender mailer.Sender, oauth *oidc.Client, guard *lockout.Guard) *AuthServiceimpl {
 	logger.Log.Debug("Init auth service")
-	return &AuthServiceimpl{cfg: cfg, UserRepo: userRepo, jwtAuth: jwtAuth}
+	return &AuthServiceimpl{
+		cfg:       cfg,
+		UserRepo:  userRepo,
+		TokenRepo: tokenRepo,
+		jwtAuth:   jwtAuth,
+		tokens:    tokens,
+		mailer:    sender,
+		oauth:     oauth,
+		lockout:   guard,
+	}
 }
 
-func (s *AuthServiceimpl) Login(data *LoginRequest) (*LoginResponse, *fiber.Cookie, error) {
-	existedUser, err := s.UserRepo.FindByEmail(data.Email)
+func (s *AuthServiceimpl) Login(ctx context.Context, data *LoginRequest, meta cjwt.SessionMeta) (*LoginResponse, *fiber.Cookie, error) {
+	if s.lockout != nil {
+		if err := s.lockout.Check(ctx, data.Email, meta.IP); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	existedUser, err := s.UserRepo.FindByEmail(ctx, data.Email)
 	if err != nil || existedUser == nil {
-		return nil, nil, errors.New(ErrInvalidCredentials)
+		// Сравнение с фиктивным хэшем, чтобы по времени ответа нельзя было узнать, зарегистрирован ли email
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(data.Password))
+		return nil, nil, s.loginFailed(ctx, data.Email, meta.IP)
 	}
 
 	hashedPassword := existedUser.Password
 	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(data.Password))
 	if err != nil {
-		return nil, nil, errors.New(ErrInvalidCredentials)
+		return nil, nil, s.loginFailed(ctx, data.Email, meta.IP)
 	}
 
-	accessToken, err := s.jwtAuth.GenerateToken(existedUser.ID, cjwt.Access)
-	if err != nil {
-		return nil, nil, err
+	if s.cfg.Auth.RequireVerifiedEmail && existedUser.EmailVerifiedAt == nil {
+		return nil, nil, errors.New(ErrEmailNotVerified)
 	}
 
-	refreshToken, err := s.jwtAuth.GenerateToken(existedUser.ID, cjwt.Refresh)
-	if err != nil {
-		return nil, nil, err
+	return s.completeLogin(ctx, existedUser, meta)
+}
+
+// loginFailed - учитывает неудачную попытку входа. Для неизвестного email она учитывается так же,
+// как для существующего, иначе блокировка выдавала бы наличие аккаунта
+func (s *AuthServiceimpl) loginFailed(ctx context.Context, email, ip string) error {
+	s.countLoginFailure(ctx, email, ip)
+	return errors.New(ErrInvalidCredentials)
+}
+
+// mfaFailed - неверный код второго шага учитывается тем же счетчиком, что и неверный пароль,
+// иначе, зная пароль, коды можно было бы перебирать без ограничений
+func (s *AuthServiceimpl) mfaFailed(ctx context.Context, email, ip string) error {
+	s.countLoginFailure(ctx, email, ip)
+	return errors.New(ErrInvalidMFACode)
+}
+
+func (s *AuthServiceimpl) countLoginFailure(ctx context.Context, email, ip string) {
+	if s.lockout != nil {
+		if err := s.lockout.Fail(ctx, email, ip); err != nil {
+			logger.Log.Error("Failed to count login failure", zap.Error(err))
+		}
 	}
+}
 
-	cookie := new(fiber.Cookie)
-	cookie.Name = "refresh_token"
-	cookie.Value = refreshToken
-	cookie.Path = "/"
-	cookie.MaxAge = int(s.cfg.Auth.RefreshTTL.Seconds())
-	cookie.SameSite = fiber.CookieSameSiteLaxMode
-	cookie.HTTPOnly = true
-	cookie.Secure = true
+// loginSucceeded - сбрасывает счетчик аккаунта. Вызывается только после полного входа:
+// сброс после одного пароля позволял бы обнулять счетчик между попытками подобрать код 2FA
+func (s *AuthServiceimpl) loginSucceeded(ctx context.Context, email string) {
+	if s.lockout != nil {
+		if err := s.lockout.Reset(ctx, email); err != nil {
+			logger.Log.Error("Failed to reset login failures", zap.Error(err))
+		}
+	}
+}
 
-	output := &LoginResponse{AccessToken: accessToken, RefreshToken: refreshToken}
+// dummyPasswordHash - хэш для сравнения, когда пользователь не найден. Стоимость та же, что у настоящих паролей
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// completeLogin - завершает проверенный вход: выдает токены или, с включенной 2FA, MFA challenge
+func (s *AuthServiceimpl) completeLogin(ctx context.Context, u *models.User, meta cjwt.SessionMeta) (*LoginResponse, *fiber.Cookie, error) {
+	// С включенной 2FA токены выдаются только после проверки кода в LoginMFA
+	if u.TOTPEnabled {
+		mfaToken, err := cjwt.NewOneTimeToken()
+		if err != nil {
+			return nil, nil, err
+		}
+		err = s.tokens.SaveOneTimeToken(ctx, cjwt.PurposeMFA, mfaToken, u.ID, ttlOrDefault(s.cfg.Auth.MFATTL, DefaultMFATTL))
+		if err != nil {
+			return nil, nil, err
+		}
+		return &LoginResponse{MFARequired: true, MFAToken: mfaToken}, nil, nil
+	}
 
-	return output, cookie, nil
+	s.loginSucceeded(ctx, u.Email)
+	return s.issueTokens(ctx, u.ID, meta)
 }
 
-func (s *AuthServiceimpl) Register(data *RegisterRequest) (bool, error) {
-	existedUser, _ := s.UserRepo.FindByEmail(data.Email)
+func (s *AuthServiceimpl) Register(ctx context.Context, data *RegisterRequest) (bool, error) {
+	existedUser, _ := s.UserRepo.FindByEmail(ctx, data.Email)
 	if existedUser != nil {
 		return false, errors.New(ErrUserExisted)
 	}
@@ -87,45 +202,616 @@ func (s *AuthServiceimpl) Register(data *RegisterRequest) (bool, error) {
 		Email:    data.Email,
 		Password: string(hashedPass),
 		Age:      data.Age,
+		Role:     models.RoleAuthor,
 	}
-	err = s.UserRepo.Create(newUser)
+	err = s.UserRepo.Create(ctx, newUser)
 	if err != nil {
 		return false, err
 	}
+
+	// Аккаунт уже создан, поэтому ошибка отправки письма не отменяет регистрацию
+	err = s.sendToken(ctx, newUser.Email, newUser, cjwt.PurposeVerifyEmail)
+	if err != nil {
+		logger.Log.Error("Failed to send verification email", zap.Uint("user_id", newUser.ID), zap.Error(err))
+	}
 	return true, nil
 }
 
-func (s *AuthServiceimpl) Refresh(tokenStr string) (*cjwt.Tokens, *fiber.Cookie, error) {
-	tokens, err := s.jwtAuth.Refresh(tokenStr)
+func (s *AuthServiceimpl) Refresh(ctx context.Context, tokenStr string, meta cjwt.SessionMeta) (*cjwt.Tokens, *fiber.Cookie, error) {
+	tokens, err := s.jwtAuth.Refresh(ctx, tokenStr, meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, s.refreshCookie(tokens.RefreshToken), nil
+}
+
+func (s *AuthServiceimpl) Logout(ctx context.Context, tokenStr string) (*fiber.Cookie, error) {
+	err := s.jwtAuth.Logout(ctx, tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	return clearRefreshCookie(), nil
+}
+
+func (s *AuthServiceimpl) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := s.tokens.ConsumeOneTimeToken(ctx, cjwt.PurposeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return s.UserRepo.Update(ctx, userID, &models.User{EmailVerifiedAt: &now})
+}
+
+// ForgotPassword - отправляет ссылку для сброса пароля.
+// Для неизвестного email ошибка не возвращается, чтобы нельзя было перебирать зарегистрированные адреса.
+// По той же причине письмо отправляется в фоне: иначе ответ для известного адреса приходил бы заметно позже
+func (s *AuthServiceimpl) ForgotPassword(ctx context.Context, email string) error {
+	existedUser, err := s.UserRepo.FindByEmail(ctx, email)
+	if err != nil || existedUser == nil {
+		logger.Log.Debug("Password reset requested for unknown email")
+		return nil
+	}
+	token, err := cjwt.NewOneTimeToken()
+	if err != nil {
+		return err
+	}
+	// Письмо отправляется уже после ответа, поэтому отмена запроса не должна его прерывать
+	sendCtx := context.WithoutCancel(ctx)
+	go func() {
+		if err := s.sendResetToken(sendCtx, existedUser, token); err != nil {
+			logger.Log.Error("Error sending password reset email", zap.Uint("user_id", existedUser.ID), zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// sendResetToken - действительна только последняя ссылка сброса пароля: ее хэш сохраняется у пользователя
+// и заменяется при следующем запросе, поэтому ссылки из прежних писем перестают работать
+func (s *AuthServiceimpl) sendResetToken(ctx context.Context, u *models.User, token string) error {
+	tokenHash := cjwt.HashOneTimeToken(token)
+	if err := s.UserRepo.Update(ctx, u.ID, &models.User{PasswordResetToken: &tokenHash}); err != nil {
+		return err
+	}
+	return s.deliverToken(ctx, u.Email, u, cjwt.PurposeResetPassword, token)
+}
+
+// ResetPassword - устанавливает новый пароль, завершает все сессии пользователя и отзывает его personal access токены
+func (s *AuthServiceimpl) ResetPassword(ctx context.Context, token, password string) error {
+	userID, err := s.tokens.ConsumeOneTimeToken(ctx, cjwt.PurposeResetPassword, token)
+	if err != nil {
+		return err
+	}
+
+	existedUser, err := s.UserRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if existedUser.PasswordResetToken == nil ||
+		subtle.ConstantTimeCompare([]byte(*existedUser.PasswordResetToken), []byte(cjwt.HashOneTimeToken(token))) != 1 {
+		return cjwt.ErrOneTimeTokenInvalid
+	}
+
+	hashedPass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	err = s.UserRepo.Update(ctx, userID, &models.User{Password: string(hashedPass)})
+	if err != nil {
+		return err
+	}
+
+	return s.revokeAccess(ctx, userID)
+}
+
+// ChangePassword - меняет пароль по текущему паролю.
+// Все сессии и personal access токены пользователя отзываются, для текущего устройства выдается новая пара токенов
+func (s *AuthServiceimpl) ChangePassword(ctx context.Context, userID uint, data *ChangePasswordRequest, meta cjwt.SessionMeta) (*LoginResponse, *fiber.Cookie, error) {
+	existedUser, err := s.checkPassword(ctx, userID, data.CurrentPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashedPass, err := bcrypt.GenerateFromPassword([]byte(data.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.UserRepo.Update(ctx, existedUser.ID, &models.User{Password: string(hashedPass)})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.revokeAccess(ctx, existedUser.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.issueTokens(ctx, existedUser.ID, meta)
+}
+
+// RequestEmailChange - сохраняет новый email как ожидающий и отправляет на него ссылку подтверждения.
+// Текущий email меняется только после перехода по ссылке
+func (s *AuthServiceimpl) RequestEmailChange(ctx context.Context, userID uint, data *ChangeEmailRequest) error {
+	existedUser, err := s.checkPassword(ctx, userID, data.Password)
+	if err != nil {
+		return err
+	}
+
+	if existedUser.Email == data.Email {
+		return errors.New(ErrSameEmail)
+	}
+
+	owner, _ := s.UserRepo.FindByEmail(ctx, data.Email)
+	if owner != nil {
+		return errors.New(ErrUserExisted)
+	}
+
+	// Ссылка привязана к адресу: ее хэш сохраняется вместе с PendingEmail и заменяется при следующем запросе
+	token, err := cjwt.NewOneTimeToken()
+	if err != nil {
+		return err
+	}
+	tokenHash := cjwt.HashOneTimeToken(token)
+	err = s.UserRepo.Update(ctx, existedUser.ID, &models.User{PendingEmail: &data.Email, PendingEmailToken: &tokenHash})
+	if err != nil {
+		return err
+	}
+
+	return s.deliverToken(ctx, data.Email, existedUser, cjwt.PurposeChangeEmail, token)
+}
+
+func (s *AuthServiceimpl) ConfirmEmailChange(ctx context.Context, token string) error {
+	userID, err := s.tokens.ConsumeOneTimeToken(ctx, cjwt.PurposeChangeEmail, token)
+	if err != nil {
+		return err
+	}
+
+	existedUser, err := s.UserRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// Ссылка действительна только для адреса из последнего запроса смены email
+	if existedUser.PendingEmail == nil || existedUser.PendingEmailToken == nil ||
+		subtle.ConstantTimeCompare([]byte(*existedUser.PendingEmailToken), []byte(cjwt.HashOneTimeToken(token))) != 1 {
+		return cjwt.ErrOneTimeTokenInvalid
+	}
+
+	err = s.UserRepo.ChangeEmail(ctx, existedUser.ID, *existedUser.PendingEmail)
+	if err != nil {
+		return err
+	}
+
+	// Уведомляем старый адрес, чтобы владелец заметил смену, если ее сделал не он
+	err = s.mailer.Send(mailer.Message{
+		To:      existedUser.Email,
+		Subject: "Email изменен",
+		Body:    fmt.Sprintf("Здравствуйте, %s!\n\nEmail вашего аккаунта изменен на %s.\n", existedUser.Name, *existedUser.PendingEmail),
+	})
+	if err != nil {
+		logger.Log.Error("Failed to notify about email change", zap.Uint("user_id", existedUser.ID), zap.Error(err))
+	}
+	return nil
+}
+
+// LoginMFA - второй шаг входа: обменивает mfa_token и код на пару токенов.
+// mfa_token одноразовый, поэтому после неверного кода вход нужно начинать заново
+func (s *AuthServiceimpl) LoginMFA(ctx context.Context, data *MFALoginRequest, meta cjwt.SessionMeta) (*LoginResponse, *fiber.Cookie, error) {
+	userID, err := s.tokens.ConsumeOneTimeToken(ctx, cjwt.PurposeMFA, data.MFAToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existedUser, err := s.UserRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !existedUser.TOTPEnabled {
+		return nil, nil, cjwt.ErrOneTimeTokenInvalid
+	}
+	// Аккаунт мог быть заблокирован, пока вводился код
+	if s.lockout != nil {
+		if err = s.lockout.Check(ctx, existedUser.Email, meta.IP); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	step, ok := totp.Match(existedUser.TOTPSecret, data.Code, time.Now())
+	if ok {
+		if err = s.useTOTPStep(ctx, existedUser.ID, step); err != nil {
+			if err.Error() == ErrInvalidMFACode {
+				return nil, nil, s.mfaFailed(ctx, existedUser.Email, meta.IP)
+			}
+			return nil, nil, err
+		}
+	} else {
+		used, err := s.UserRepo.UseRecoveryCode(ctx, existedUser.ID, hashRecoveryCode(data.Code))
+		if err != nil {
+			return nil, nil, err
+		}
+		if !used {
+			return nil, nil, s.mfaFailed(ctx, existedUser.Email, meta.IP)
+		}
+		logger.Log.Info("Recovery code used", zap.Uint("user_id", existedUser.ID))
+	}
+
+	s.loginSucceeded(ctx, existedUser.Email)
+	return s.issueTokens(ctx, existedUser.ID, meta)
+}
+
+// useTOTPStep - отклоняет код TOTP, если код этого или более позднего интервала уже был принят.
+// Иначе перехваченный код можно было бы использовать повторно, пока он действителен
+func (s *AuthServiceimpl) useTOTPStep(ctx context.Context, userID uint, step int64) error {
+	fresh, err := s.UserRepo.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return errors.New(ErrInvalidMFACode)
+	}
+	return nil
+}
+
+// EnrollTOTP - создает секрет TOTP. 2FA заработает только после ConfirmTOTP
+func (s *AuthServiceimpl) EnrollTOTP(ctx context.Context, userID uint) (*TOTPEnrollResponse, error) {
+	existedUser, err := s.UserRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existedUser.TOTPEnabled {
+		return nil, errors.New(ErrTOTPAlreadyEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.UserRepo.Update(ctx, existedUser.ID, &models.User{TOTPSecret: secret})
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := s.cfg.Auth.TOTPIssuer
+	if issuer == "" {
+		issuer = DefaultTOTPIssuer
+	}
+	return &TOTPEnrollResponse{Secret: secret, URI: totp.URI(issuer, existedUser.Email, secret)}, nil
+}
+
+// ConfirmTOTP - включает 2FA после проверки первого кода и возвращает резервные коды.
+// Коды показываются один раз, в базе хранятся только их хэши
+func (s *AuthServiceimpl) ConfirmTOTP(ctx context.Context, userID uint, code string) (*RecoveryCodesResponse, error) {
+	existedUser, err := s.UserRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existedUser.TOTPEnabled {
+		return nil, errors.New(ErrTOTPAlreadyEnabled)
+	}
+	if existedUser.TOTPSecret == "" {
+		return nil, errors.New(ErrTOTPNotEnrolled)
+	}
+	step, ok := totp.Match(existedUser.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, errors.New(ErrInvalidMFACode)
+	}
+	if err = s.useTOTPStep(ctx, existedUser.ID, step); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		recoveryCode, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, recoveryCode)
+		hashes = append(hashes, hashRecoveryCode(recoveryCode))
+	}
+
+	err = s.UserRepo.EnableTOTP(ctx, existedUser.ID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *AuthServiceimpl) ListSessions(ctx context.Context, userID uint, currentSessionID string) ([]SessionResponse, error) {
+	sessions, err := s.jwtAuth.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		output = append(output, SessionResponse{
+			ID:        session.ID,
+			UserAgent: session.UserAgent,
+			IP:        session.IP,
+			CreatedAt: session.CreatedAt,
+			LastSeen:  session.LastSeen,
+			Current:   session.ID == currentSessionID,
+		})
+	}
+	return output, nil
+}
+
+func (s *AuthServiceimpl) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	return s.jwtAuth.RevokeSession(ctx, userID, sessionID)
+}
+
+// RevokeAllSessions - выход со всех устройств, включая текущее. Personal access токены тоже отзываются:
+// иначе доступ к API, полученный через украденный аккаунт, пережил бы выход
+func (s *AuthServiceimpl) RevokeAllSessions(ctx context.Context, userID uint) (*fiber.Cookie, error) {
+	err := s.revokeAccess(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return clearRefreshCookie(), nil
+}
+
+// revokeAccess - завершает все сессии пользователя и отзывает его personal access токены
+func (s *AuthServiceimpl) revokeAccess(ctx context.Context, userID uint) error {
+	if err := s.TokenRepo.DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	return s.jwtAuth.RevokeAllSessions(ctx, userID)
+}
+
+// issueTokens - создает сессию устройства и выдает пару access/refresh и cookie с refresh токеном
+func (s *AuthServiceimpl) issueTokens(ctx context.Context, userID uint, meta cjwt.SessionMeta) (*LoginResponse, *fiber.Cookie, error) {
+	tokens, err := s.jwtAuth.StartSession(ctx, userID, meta)
 	if err != nil {
 		return nil, nil, err
 	}
+
+	output := &LoginResponse{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}
+
+	return output, s.refreshCookie(tokens.RefreshToken), nil
+}
+
+// checkPassword - проверяет текущий пароль пользователя перед изменением учетных данных
+func (s *AuthServiceimpl) checkPassword(ctx context.Context, userID uint, password string) (*models.User, error) {
+	existedUser, err := s.UserRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(existedUser.Password), []byte(password))
+	if err != nil {
+		return nil, errors.New(ErrInvalidCredentials)
+	}
+	return existedUser, nil
+}
+
+func (s *AuthServiceimpl) refreshCookie(refreshToken string) *fiber.Cookie {
 	cookie := new(fiber.Cookie)
 	cookie.Name = "refresh_token"
-	cookie.Value = tokens.RefreshToken
+	cookie.Value = refreshToken
 	cookie.Path = "/"
 	cookie.MaxAge = int(s.cfg.Auth.RefreshTTL.Seconds())
 	cookie.SameSite = fiber.CookieSameSiteLaxMode
 	cookie.HTTPOnly = true
 	cookie.Secure = true
+	return cookie
+}
+
+func clearRefreshCookie() *fiber.Cookie {
+	cookie := new(fiber.Cookie)
+	cookie.Name = "refresh_token"
+	cookie.Value = ""
+	cookie.Path = "/"
+	cookie.MaxAge = -1
+	cookie.SameSite = fiber.CookieSameSiteLaxMode
+	cookie.HTTPOnly = true
+	cookie.Secure = true
+	return cookie
+}
 
-	return tokens, cookie, nil
+// sendToken - создает одноразовый токен и отправляет письмо со ссылкой на адрес to
+func (s *AuthServiceimpl) sendToken(ctx context.Context, to string, u *models.User, purpose cjwt.TokenPurpose) error {
+	token, err := cjwt.NewOneTimeToken()
+	if err != nil {
+		return err
+	}
+	return s.deliverToken(ctx, to, u, purpose, token)
 }
 
-func (s *AuthServiceimpl) Logout(tokenStr string) (*fiber.Cookie, error) {
-	err := s.jwtAuth.Logout(tokenStr)
+// deliverToken - сохраняет готовый одноразовый токен и отправляет письмо со ссылкой на адрес to
+func (s *AuthServiceimpl) deliverToken(ctx context.Context, to string, u *models.User, purpose cjwt.TokenPurpose, token string) error {
+	var (
+		ttl     time.Duration
+		subject string
+		path    string
+	)
+	switch purpose {
+	case cjwt.PurposeVerifyEmail:
+		ttl = ttlOrDefault(s.cfg.Auth.VerifyEmailTTL, DefaultVerifyEmailTTL)
+		subject, path = "Подтверждение email", "verify-email"
+	case cjwt.PurposeResetPassword:
+		ttl = ttlOrDefault(s.cfg.Auth.ResetPasswordTTL, DefaultResetPasswordTTL)
+		subject, path = "Сброс пароля", "reset-password"
+	case cjwt.PurposeChangeEmail:
+		ttl = ttlOrDefault(s.cfg.Auth.VerifyEmailTTL, DefaultVerifyEmailTTL)
+		subject, path = "Подтверждение нового email", "confirm-email"
+	}
+
+	err := s.tokens.SaveOneTimeToken(ctx, purpose, token, u.ID, ttl)
 	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      to,
+		Subject: subject,
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nПерейдите по ссылке: %s/%s?token=%s\nСсылка действительна %s.\n",
+			u.Name, s.cfg.Mail.BaseURL, path, token, ttl),
+	})
+}
+
+func ttlOrDefault(ttl, def time.Duration) time.Duration {
+	if ttl <= 0 {
+		return def
+	}
+	return ttl
+}
+
+// newRecoveryCode - резервный код вида xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode - коды случайные и длинные, поэтому достаточно sha256 без соли.
+// Дефис и регистр не учитываются, чтобы код можно было вводить как угодно
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// JWKS - открытые ключи подписи токенов
+func (s *AuthServiceimpl) JWKS() cjwt.JWKS {
+	return s.jwtAuth.JWKS()
+}
+
+// OAuthStart - начинает вход через внешнего провайдера. Возвращает адрес провайдера и cookie со state
+func (s *AuthServiceimpl) OAuthStart(ctx context.Context, provider string) (string, *fiber.Cookie, error) {
+	if s.oauth == nil {
+		return "", nil, oidc.ErrUnknownProvider
+	}
+	auth, err := s.oauth.Start(ctx, provider)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cookie := oauthStateCookie(auth.State)
+	cookie.MaxAge = int(s.oauth.StateTTL().Seconds())
+	return auth.URL, cookie, nil
+}
+
+// OAuthLogin - завершает вход через внешнего провайдера. Пользователь находится по привязанной учетной записи,
+// иначе по email, подтвержденному провайдером, а если такого нет - регистрируется
+func (s *AuthServiceimpl) OAuthLogin(ctx context.Context, provider, state, code string, meta cjwt.SessionMeta) (*LoginResponse, *fiber.Cookie, error) {
+	if s.oauth == nil {
+		return nil, nil, oidc.ErrUnknownProvider
+	}
+	identity, err := s.oauth.Finish(ctx, provider, state, code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, err := s.UserRepo.FindByIdentity(ctx, identity.Provider, identity.Subject)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		u, err = s.linkIdentity(ctx, identity)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Log.Info("OAuth login", zap.Uint("user_id", u.ID), zap.String("provider", identity.Provider))
+	return s.completeLogin(ctx, u, meta)
+}
+
+// linkIdentity - привязывает учетную запись провайдера к пользователю с тем же email или создает нового
+func (s *AuthServiceimpl) linkIdentity(ctx context.Context, identity *oidc.Identity) (*models.User, error) {
+	if !identity.EmailVerified || identity.Email == "" {
+		return nil, errors.New(ErrProviderEmail)
+	}
+	link := &models.UserIdentity{Provider: identity.Provider, Subject: identity.Subject, Email: identity.Email}
+
+	// Пароль, который никто не знает: вход только через провайдера, пока пользователь не сбросит пароль
+	password, err := unusablePassword()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	existedUser, err := s.UserRepo.FindByEmail(ctx, identity.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		name := identity.Name
+		if name == "" {
+			name = strings.SplitN(identity.Email, "@", 2)[0]
+		}
+		newUser := &models.User{
+			Name:            name,
+			Email:           identity.Email,
+			Password:        password,
+			Role:            models.RoleAuthor,
+			EmailVerifiedAt: &now,
+		}
+		if err = s.UserRepo.CreateWithIdentity(ctx, newUser, link); err != nil {
+			return nil, err
+		}
+		return newUser, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Аккаунт с неподтвержденным email мог зарегистрировать кто угодно. Владение адресом подтвердил провайдер,
+	// поэтому пароль и сессии того, кто регистрировался, больше не действуют
+	if existedUser.EmailVerifiedAt == nil {
+		err = s.UserRepo.Update(ctx, existedUser.ID, &models.User{Password: password, EmailVerifiedAt: &now})
+		if err != nil {
+			return nil, err
+		}
+		if err = s.jwtAuth.RevokeAllSessions(ctx, existedUser.ID); err != nil {
+			return nil, err
+		}
+		existedUser.EmailVerifiedAt = &now
+	}
+
+	link.UserID = existedUser.ID
+	if err = s.UserRepo.LinkIdentity(ctx, link); err != nil {
 		return nil, err
 	}
+	return existedUser, nil
+}
 
+func oauthStateCookie(state string) *fiber.Cookie {
 	cookie := new(fiber.Cookie)
-	cookie.Name = "refresh_token"
-	cookie.Value = ""
-	cookie.Path = "/"
-	cookie.MaxAge = -1
+	cookie.Name = OAuthStateCookie
+	cookie.Value = state
+	cookie.Path = "/auth/oauth"
 	cookie.SameSite = fiber.CookieSameSiteLaxMode
 	cookie.HTTPOnly = true
 	cookie.Secure = true
+	return cookie
+}
 
-	return cookie, nil
+// ClearOAuthStateCookie - удаляет cookie со state после завершения входа
+func ClearOAuthStateCookie() *fiber.Cookie {
+	cookie := oauthStateCookie("")
+	cookie.MaxAge = -1
+	return cookie
+}
+
+func unusablePassword() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(buf)), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// UnlockUser - снимает блокировку входа после неудачных попыток
+func (s *AuthServiceimpl) UnlockUser(ctx context.Context, userID uint) error {
+	existedUser, err := s.UserRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if s.lockout == nil {
+		return nil
+	}
+	return s.lockout.Reset(ctx, existedUser.Email)
 }
@@ -1,18 +1,26 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"github.com/crafty-ezhik/blog-api/internal/config"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	mock_token "github.com/crafty-ezhik/blog-api/mocks/token"
 	mock_user "github.com/crafty-ezhik/blog-api/mocks/user"
 	"github.com/crafty-ezhik/blog-api/pkg/jwt"
 	mock_jwt "github.com/crafty-ezhik/blog-api/pkg/jwt/mock"
+	"github.com/crafty-ezhik/blog-api/pkg/lockout"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/mailer"
+	"github.com/crafty-ezhik/blog-api/pkg/oidc"
+	"github.com/crafty-ezhik/blog-api/pkg/oidc/oidctest"
+	"github.com/crafty-ezhik/blog-api/pkg/totp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 	"testing"
 	"time"
 )
@@ -29,6 +37,7 @@ func TestAuthServiceImpl_Login(t *testing.T) {
 	mockUserRepo := mock_user.NewMockUserRepository(ctrl)
 	mockBlackList := mock_jwt.NewMockBlackListStorage(ctrl)
 	mockTokenVersion := mock_jwt.NewMockTokenVersionStorage(ctrl)
+	mockSessions := mock_jwt.NewMockSessionStorage(ctrl)
 
 	// 3. Создаем экземпляр конфига
 	cfg := &config.Config{
@@ -41,7 +50,7 @@ func TestAuthServiceImpl_Login(t *testing.T) {
 	}
 
 	// 4. Создаем экземпляр jwtService
-	jwtService := jwt.NewJWTService(mockBlackList, mockTokenVersion)
+	jwtService := jwt.NewJWTService(mockBlackList, mockTokenVersion, mockSessions)
 	jwtAuth := jwt.NewJWT(jwtService, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL, cfg.Auth.SigningKey)
 
 	// 5. Создаем экземпляр AuthService
@@ -71,14 +80,15 @@ func TestAuthServiceImpl_Login(t *testing.T) {
 		}
 
 		// 7.3 Выполняем запрос на получения пользователя с переданным email
-		mockUserRepo.EXPECT().FindByEmail(email).Return(user, nil)
-		mockTokenVersion.EXPECT().GetVersion(user.ID).Return(uint(1), nil).Times(2)
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), email).Return(user, nil)
+		mockTokenVersion.EXPECT().GetVersion(gomock.Any(), user.ID).Return(uint(1), nil).Times(2)
+		mockSessions.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
 
 		// 7.4 Создаем имитация запроса
-		resp, _, err := authService.Login(&LoginRequest{
+		resp, _, err := authService.Login(context.Background(), &LoginRequest{
 			Email:    email,
 			Password: password,
-		})
+		}, jwt.SessionMeta{})
 
 		// 7.5 Производит проверку на наличие ошибок и полей в ответе
 		assert.NoError(t, err)
@@ -89,12 +99,12 @@ func TestAuthServiceImpl_Login(t *testing.T) {
 	// 8. Случай, когда пользователь не найден
 	t.Run("User not found", func(t *testing.T) {
 		email := "notfound@example.com"
-		mockUserRepo.EXPECT().FindByEmail(email).Return(nil, errors.New(ErrInvalidCredentials))
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), email).Return(nil, errors.New(ErrInvalidCredentials))
 
-		resp, _, err := authService.Login(&LoginRequest{
+		resp, _, err := authService.Login(context.Background(), &LoginRequest{
 			Email:    email,
 			Password: "any",
-		})
+		}, jwt.SessionMeta{})
 		assert.Error(t, err)
 		assert.EqualError(t, err, ErrInvalidCredentials)
 		assert.Nil(t, resp)
@@ -115,12 +125,12 @@ func TestAuthServiceImpl_Login(t *testing.T) {
 			Password: string(hashedPassword),
 		}
 
-		mockUserRepo.EXPECT().FindByEmail(email).Return(user, nil)
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), email).Return(user, nil)
 
-		resp, _, err := authService.Login(&LoginRequest{
+		resp, _, err := authService.Login(context.Background(), &LoginRequest{
 			Email:    email,
 			Password: wrongPassword,
-		})
+		}, jwt.SessionMeta{})
 		assert.Error(t, err)
 		assert.EqualError(t, err, ErrInvalidCredentials)
 		assert.Nil(t, resp)
@@ -139,6 +149,7 @@ func TestAuthServiceImpl_Register(t *testing.T) {
 	mockUserRepo := mock_user.NewMockUserRepository(ctrl)
 	mockBlackList := mock_jwt.NewMockBlackListStorage(ctrl)
 	mockTokenVersion := mock_jwt.NewMockTokenVersionStorage(ctrl)
+	mockSessions := mock_jwt.NewMockSessionStorage(ctrl)
 
 	// 3. Создаем экземпляр конфига
 	cfg := &config.Config{
@@ -151,14 +162,18 @@ func TestAuthServiceImpl_Register(t *testing.T) {
 	}
 
 	// 4. Создаем экземпляр jwtService
-	jwtService := jwt.NewJWTService(mockBlackList, mockTokenVersion)
+	jwtService := jwt.NewJWTService(mockBlackList, mockTokenVersion, mockSessions)
 	jwtAuth := jwt.NewJWT(jwtService, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL, cfg.Auth.SigningKey)
 
 	// 5. Создаем экземпляр AuthService
+	mockTokens := mock_jwt.NewMockOneTimeTokenStorage(ctrl)
+	sender := mailer.NewMemorySender()
 	authService := &AuthServiceimpl{
 		cfg:      cfg,
 		jwtAuth:  jwtAuth,
 		UserRepo: mockUserRepo,
+		tokens:   mockTokens,
+		mailer:   sender,
 	}
 
 	// 6. Начало тестов
@@ -172,10 +187,10 @@ func TestAuthServiceImpl_Register(t *testing.T) {
 		}
 
 		// Ожидаем, что пользователя нет в базе
-		mockUserRepo.EXPECT().FindByEmail(email).Return(nil, nil)
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), email).Return(nil, nil)
 
 		// Ожидаем вызов Create
-		mockUserRepo.EXPECT().Create(gomock.Any()).DoAndReturn(func(user *models.User) error {
+		mockUserRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user *models.User) error {
 			assert.Equal(t, request.Name, user.Name)
 			assert.Equal(t, request.Age, user.Age)
 			assert.Equal(t, request.Email, user.Email)
@@ -183,9 +198,16 @@ func TestAuthServiceImpl_Register(t *testing.T) {
 			return nil
 		})
 
-		ok, err := authService.Register(request)
+		// Ожидаем сохранение токена подтверждения email
+		mockTokens.EXPECT().SaveOneTimeToken(gomock.Any(), jwt.PurposeVerifyEmail, gomock.Any(), gomock.Any(), DefaultVerifyEmailTTL).Return(nil)
+
+		ok, err := authService.Register(context.Background(), request)
 		assert.NoError(t, err)
 		assert.True(t, ok)
+
+		msg, sent := sender.Last(email)
+		assert.True(t, sent)
+		assert.Contains(t, msg.Body, "/verify-email?token=")
 	})
 
 	t.Run("User already exists", func(t *testing.T) {
@@ -195,11 +217,615 @@ func TestAuthServiceImpl_Register(t *testing.T) {
 			Password: "test",
 		}
 
-		mockUserRepo.EXPECT().FindByEmail(email).Return(&models.User{Email: email}, nil)
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), email).Return(&models.User{Email: email}, nil)
 
-		ok, err := authService.Register(request)
+		ok, err := authService.Register(context.Background(), request)
 		assert.Error(t, err)
 		assert.False(t, ok)
 		assert.Contains(t, err.Error(), ErrUserExisted)
 	})
 }
+
+func TestAuthServiceImpl_PasswordReset(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mock_user.NewMockUserRepository(ctrl)
+	mockTokenVersion := mock_jwt.NewMockTokenVersionStorage(ctrl)
+	mockSessions := mock_jwt.NewMockSessionStorage(ctrl)
+	mockTokens := mock_jwt.NewMockOneTimeTokenStorage(ctrl)
+	mockTokenRepo := mock_token.NewMockTokenRepository(ctrl)
+	sender := mailer.NewMemorySender()
+
+	cfg := &config.Config{
+		Auth: config.AuthConfig{ResetPasswordTTL: 15 * time.Minute},
+		Mail: config.MailConfig{BaseURL: "http://localhost"},
+	}
+	jwtService := jwt.NewJWTService(nil, mockTokenVersion, mockSessions)
+	authService := &AuthServiceimpl{
+		cfg:       cfg,
+		jwtAuth:   jwt.NewJWT(jwtService, time.Minute, time.Hour, "key"),
+		UserRepo:  mockUserRepo,
+		TokenRepo: mockTokenRepo,
+		tokens:    mockTokens,
+		mailer:    sender,
+	}
+
+	t.Run("Forgot password sends link", func(t *testing.T) {
+		email := "test@example.com"
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), email).Return(&models.User{ID: 1, Email: email}, nil)
+		mockUserRepo.EXPECT().Update(gomock.Any(), uint(1), gomock.Any()).DoAndReturn(func(_ context.Context, userID uint, u *models.User) error {
+			require.NotNil(t, u.PasswordResetToken)
+			assert.Len(t, *u.PasswordResetToken, 64)
+			return nil
+		})
+		mockTokens.EXPECT().SaveOneTimeToken(gomock.Any(), jwt.PurposeResetPassword, gomock.Any(), uint(1), 15*time.Minute).Return(nil)
+
+		err := authService.ForgotPassword(context.Background(), email)
+		assert.NoError(t, err)
+
+		// Письмо отправляется в фоне
+		var msg mailer.Message
+		assert.Eventually(t, func() bool {
+			var sent bool
+			msg, sent = sender.Last(email)
+			return sent
+		}, time.Second, 10*time.Millisecond)
+		assert.Contains(t, msg.Body, "http://localhost/reset-password?token=")
+	})
+
+	t.Run("Forgot password for unknown email", func(t *testing.T) {
+		email := "unknown@example.com"
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), email).Return(nil, errors.New("record not found"))
+
+		err := authService.ForgotPassword(context.Background(), email)
+		assert.NoError(t, err)
+
+		_, sent := sender.Last(email)
+		assert.False(t, sent)
+	})
+
+	t.Run("Reset password revokes sessions and personal tokens", func(t *testing.T) {
+		tokenHash := jwt.HashOneTimeToken("token")
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeResetPassword, "token").Return(uint(1), nil)
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, PasswordResetToken: &tokenHash}, nil)
+		mockUserRepo.EXPECT().Update(gomock.Any(), uint(1), gomock.Any()).DoAndReturn(func(_ context.Context, userID uint, u *models.User) error {
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(u.Password), []byte("new_password")))
+			return nil
+		})
+		mockTokenRepo.EXPECT().DeleteByUser(gomock.Any(), uint(1)).Return(nil)
+		mockTokenVersion.EXPECT().IncrementVersion(gomock.Any(), uint(1)).Return(nil)
+		mockSessions.EXPECT().DeleteUserSessions(gomock.Any(), uint(1)).Return(nil)
+
+		err := authService.ResetPassword(context.Background(), "token", "new_password")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Only the last reset link is valid", func(t *testing.T) {
+		email := "test@example.com"
+		stored := &models.User{ID: 1, Email: email}
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), email).Return(stored, nil).Times(2)
+		mockUserRepo.EXPECT().Update(gomock.Any(), uint(1), gomock.Any()).DoAndReturn(func(_ context.Context, userID uint, u *models.User) error {
+			stored.PasswordResetToken = u.PasswordResetToken
+			return nil
+		}).Times(2)
+		sent := make(chan string, 2)
+		mockTokens.EXPECT().SaveOneTimeToken(gomock.Any(), jwt.PurposeResetPassword, gomock.Any(), uint(1), gomock.Any()).
+			DoAndReturn(func(_ context.Context, purpose jwt.TokenPurpose, token string, userID uint, ttl time.Duration) error {
+				sent <- token
+				return nil
+			}).Times(2)
+
+		require.NoError(t, authService.ForgotPassword(context.Background(), email))
+		first := <-sent
+		require.NoError(t, authService.ForgotPassword(context.Background(), email))
+		<-sent
+
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeResetPassword, first).Return(uint(1), nil)
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(stored, nil)
+
+		err := authService.ResetPassword(context.Background(), first, "new_password")
+		assert.ErrorIs(t, err, jwt.ErrOneTimeTokenInvalid)
+	})
+
+	t.Run("Reset password with used token", func(t *testing.T) {
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeResetPassword, "used").Return(uint(0), jwt.ErrOneTimeTokenInvalid)
+
+		err := authService.ResetPassword(context.Background(), "used", "new_password")
+		assert.ErrorIs(t, err, jwt.ErrOneTimeTokenInvalid)
+	})
+}
+
+func TestAuthServiceImpl_VerifyEmail(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mock_user.NewMockUserRepository(ctrl)
+	mockTokens := mock_jwt.NewMockOneTimeTokenStorage(ctrl)
+
+	authService := &AuthServiceimpl{
+		cfg:      &config.Config{},
+		UserRepo: mockUserRepo,
+		tokens:   mockTokens,
+	}
+
+	t.Run("Successful verification", func(t *testing.T) {
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeVerifyEmail, "token").Return(uint(1), nil)
+		mockUserRepo.EXPECT().Update(gomock.Any(), uint(1), gomock.Any()).DoAndReturn(func(_ context.Context, userID uint, u *models.User) error {
+			assert.NotNil(t, u.EmailVerifiedAt)
+			return nil
+		})
+
+		err := authService.VerifyEmail(context.Background(), "token")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeVerifyEmail, "bad").Return(uint(0), jwt.ErrOneTimeTokenInvalid)
+
+		err := authService.VerifyEmail(context.Background(), "bad")
+		assert.ErrorIs(t, err, jwt.ErrOneTimeTokenInvalid)
+	})
+}
+
+func TestAuthServiceImpl_ChangeCredentials(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mock_user.NewMockUserRepository(ctrl)
+	mockBlackList := mock_jwt.NewMockBlackListStorage(ctrl)
+	mockTokenVersion := mock_jwt.NewMockTokenVersionStorage(ctrl)
+	mockSessions := mock_jwt.NewMockSessionStorage(ctrl)
+	mockTokens := mock_jwt.NewMockOneTimeTokenStorage(ctrl)
+	mockTokenRepo := mock_token.NewMockTokenRepository(ctrl)
+	sender := mailer.NewMemorySender()
+
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			SigningKey: "FKI/0XYt3YksmneW8QxCRWdlYbINIzPdp4fpiTqXXqs=",
+			AccessTTL:  time.Duration(30) * time.Minute,
+			RefreshTTL: time.Duration(30) * time.Hour,
+		},
+	}
+	jwtService := jwt.NewJWTService(mockBlackList, mockTokenVersion, mockSessions)
+	jwtAuth := jwt.NewJWT(jwtService, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL, cfg.Auth.SigningKey)
+
+	authService := &AuthServiceimpl{
+		cfg:       cfg,
+		jwtAuth:   jwtAuth,
+		UserRepo:  mockUserRepo,
+		TokenRepo: mockTokenRepo,
+		tokens:    mockTokens,
+		mailer:    sender,
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("current"), bcrypt.DefaultCost)
+	require.NoError(t, err)
+	existedUser := &models.User{ID: 1, Name: "TestUser", Email: "old@example.com", Password: string(hashedPassword)}
+
+	t.Run("Change password", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existedUser, nil)
+		mockUserRepo.EXPECT().Update(gomock.Any(), uint(1), gomock.Any()).DoAndReturn(func(_ context.Context, userID uint, u *models.User) error {
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(u.Password), []byte("new_password")))
+			return nil
+		})
+		mockTokenRepo.EXPECT().DeleteByUser(gomock.Any(), uint(1)).Return(nil)
+		mockTokenVersion.EXPECT().IncrementVersion(gomock.Any(), uint(1)).Return(nil)
+		mockSessions.EXPECT().DeleteUserSessions(gomock.Any(), uint(1)).Return(nil)
+		mockTokenVersion.EXPECT().GetVersion(gomock.Any(), uint(1)).Return(uint(2), nil).Times(2)
+		mockSessions.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+		resp, cookie, err := authService.ChangePassword(context.Background(), 1, &ChangePasswordRequest{CurrentPassword: "current", NewPassword: "new_password"}, jwt.SessionMeta{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+		assert.Equal(t, resp.RefreshToken, cookie.Value)
+	})
+
+	t.Run("Change password with wrong current password", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existedUser, nil)
+
+		resp, _, err := authService.ChangePassword(context.Background(), 1, &ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "new_password"}, jwt.SessionMeta{})
+		assert.EqualError(t, err, ErrInvalidCredentials)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("Request email change", func(t *testing.T) {
+		newEmail := "new@example.com"
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existedUser, nil)
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), newEmail).Return(nil, errors.New("record not found"))
+		var tokenHash string
+		mockUserRepo.EXPECT().Update(gomock.Any(), uint(1), gomock.Any()).DoAndReturn(func(_ context.Context, userID uint, u *models.User) error {
+			require.NotNil(t, u.PendingEmail)
+			assert.Equal(t, newEmail, *u.PendingEmail)
+			require.NotNil(t, u.PendingEmailToken)
+			tokenHash = *u.PendingEmailToken
+			return nil
+		})
+		mockTokens.EXPECT().SaveOneTimeToken(gomock.Any(), jwt.PurposeChangeEmail, gomock.Any(), uint(1), DefaultVerifyEmailTTL).
+			DoAndReturn(func(_ context.Context, _ jwt.TokenPurpose, token string, _ uint, _ time.Duration) error {
+				// Сохраненный хэш соответствует ссылке из письма
+				assert.Equal(t, jwt.HashOneTimeToken(token), tokenHash)
+				return nil
+			})
+
+		err := authService.RequestEmailChange(context.Background(), 1, &ChangeEmailRequest{Email: newEmail, Password: "current"})
+		assert.NoError(t, err)
+
+		msg, sent := sender.Last(newEmail)
+		assert.True(t, sent)
+		assert.Contains(t, msg.Body, "/confirm-email?token=")
+	})
+
+	t.Run("Request email change to taken email", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existedUser, nil)
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), "taken@example.com").Return(&models.User{ID: 2}, nil)
+
+		err := authService.RequestEmailChange(context.Background(), 1, &ChangeEmailRequest{Email: "taken@example.com", Password: "current"})
+		assert.EqualError(t, err, ErrUserExisted)
+	})
+
+	t.Run("Confirm email change", func(t *testing.T) {
+		pending := "new@example.com"
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeChangeEmail, "token").Return(uint(1), nil)
+		tokenHash := jwt.HashOneTimeToken("token")
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Email: "old@example.com", PendingEmail: &pending, PendingEmailToken: &tokenHash}, nil)
+		mockUserRepo.EXPECT().ChangeEmail(gomock.Any(), uint(1), pending).Return(nil)
+
+		err := authService.ConfirmEmailChange(context.Background(), "token")
+		assert.NoError(t, err)
+
+		_, notified := sender.Last("old@example.com")
+		assert.True(t, notified)
+	})
+
+	t.Run("Confirm email change without pending email", func(t *testing.T) {
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeChangeEmail, "stale").Return(uint(1), nil)
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Email: "old@example.com"}, nil)
+
+		err := authService.ConfirmEmailChange(context.Background(), "stale")
+		assert.ErrorIs(t, err, jwt.ErrOneTimeTokenInvalid)
+	})
+
+	t.Run("Confirm email change with link for previous address", func(t *testing.T) {
+		// Запросы на A, затем на B: ссылка из письма на A не должна подтверждать B
+		pending, latestHash := "b@example.com", jwt.HashOneTimeToken("link-for-b")
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeChangeEmail, "link-for-a").Return(uint(1), nil)
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Email: "old@example.com", PendingEmail: &pending, PendingEmailToken: &latestHash}, nil)
+
+		err := authService.ConfirmEmailChange(context.Background(), "link-for-a")
+		assert.ErrorIs(t, err, jwt.ErrOneTimeTokenInvalid)
+	})
+}
+
+func TestAuthServiceImpl_TOTP(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mock_user.NewMockUserRepository(ctrl)
+	mockBlackList := mock_jwt.NewMockBlackListStorage(ctrl)
+	mockTokenVersion := mock_jwt.NewMockTokenVersionStorage(ctrl)
+	mockSessions := mock_jwt.NewMockSessionStorage(ctrl)
+	mockTokens := mock_jwt.NewMockOneTimeTokenStorage(ctrl)
+
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			SigningKey: "FKI/0XYt3YksmneW8QxCRWdlYbINIzPdp4fpiTqXXqs=",
+			AccessTTL:  time.Duration(30) * time.Minute,
+			RefreshTTL: time.Duration(30) * time.Hour,
+		},
+	}
+	jwtService := jwt.NewJWTService(mockBlackList, mockTokenVersion, mockSessions)
+	jwtAuth := jwt.NewJWT(jwtService, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL, cfg.Auth.SigningKey)
+
+	authService := &AuthServiceimpl{
+		cfg:      cfg,
+		jwtAuth:  jwtAuth,
+		UserRepo: mockUserRepo,
+		tokens:   mockTokens,
+	}
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	t.Run("Enroll", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Email: "test@example.com"}, nil)
+		mockUserRepo.EXPECT().Update(gomock.Any(), uint(1), gomock.Any()).DoAndReturn(func(_ context.Context, userID uint, u *models.User) error {
+			assert.NotEmpty(t, u.TOTPSecret)
+			return nil
+		})
+
+		resp, err := authService.EnrollTOTP(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Contains(t, resp.URI, "otpauth://totp/blog-api:test@example.com")
+		assert.Contains(t, resp.URI, "secret="+resp.Secret)
+	})
+
+	t.Run("Enroll when already enabled", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, TOTPEnabled: true}, nil)
+
+		_, err := authService.EnrollTOTP(context.Background(), 1)
+		assert.EqualError(t, err, ErrTOTPAlreadyEnabled)
+	})
+
+	t.Run("Confirm returns recovery codes", func(t *testing.T) {
+		code, err := totp.Code(secret, time.Now())
+		require.NoError(t, err)
+
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, TOTPSecret: secret}, nil)
+		mockUserRepo.EXPECT().UseTOTPStep(gomock.Any(), uint(1), gomock.Any()).Return(true, nil)
+		mockUserRepo.EXPECT().EnableTOTP(gomock.Any(), uint(1), gomock.Any()).DoAndReturn(func(_ context.Context, userID uint, hashes []string) error {
+			assert.Len(t, hashes, recoveryCodesCount)
+			return nil
+		})
+
+		resp, err := authService.ConfirmTOTP(context.Background(), 1, code)
+		assert.NoError(t, err)
+		assert.Len(t, resp.RecoveryCodes, recoveryCodesCount)
+		assert.Len(t, resp.RecoveryCodes[0], 11)
+	})
+
+	t.Run("Confirm with reused code", func(t *testing.T) {
+		code, err := totp.Code(secret, time.Now())
+		require.NoError(t, err)
+
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, TOTPSecret: secret}, nil)
+		mockUserRepo.EXPECT().UseTOTPStep(gomock.Any(), uint(1), gomock.Any()).Return(false, nil)
+
+		_, err = authService.ConfirmTOTP(context.Background(), 1, code)
+		assert.EqualError(t, err, ErrInvalidMFACode)
+	})
+
+	t.Run("Confirm with wrong code", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, TOTPSecret: secret}, nil)
+
+		_, err := authService.ConfirmTOTP(context.Background(), 1, "000000x")
+		assert.EqualError(t, err, ErrInvalidMFACode)
+	})
+
+	t.Run("Login requires second step", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), "test@example.com").Return(&models.User{ID: 1, Password: string(hashedPassword), TOTPEnabled: true}, nil)
+		mockTokens.EXPECT().SaveOneTimeToken(gomock.Any(), jwt.PurposeMFA, gomock.Any(), uint(1), DefaultMFATTL).Return(nil)
+
+		resp, cookie, err := authService.Login(context.Background(), &LoginRequest{Email: "test@example.com", Password: "test"}, jwt.SessionMeta{})
+		assert.NoError(t, err)
+		assert.Nil(t, cookie)
+		assert.True(t, resp.MFARequired)
+		assert.NotEmpty(t, resp.MFAToken)
+		assert.Empty(t, resp.AccessToken)
+	})
+
+	t.Run("MFA login with TOTP code", func(t *testing.T) {
+		code, err := totp.Code(secret, time.Now())
+		require.NoError(t, err)
+
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeMFA, "mfa").Return(uint(1), nil)
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, TOTPSecret: secret, TOTPEnabled: true}, nil)
+		mockUserRepo.EXPECT().UseTOTPStep(gomock.Any(), uint(1), gomock.Any()).DoAndReturn(func(_ context.Context, userID uint, step int64) (bool, error) {
+			assert.InDelta(t, time.Now().Unix()/int64(totp.Period.Seconds()), step, 1)
+			return true, nil
+		})
+		mockTokenVersion.EXPECT().GetVersion(gomock.Any(), uint(1)).Return(uint(1), nil).Times(2)
+		mockSessions.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+		resp, cookie, err := authService.LoginMFA(context.Background(), &MFALoginRequest{MFAToken: "mfa", Code: code}, jwt.SessionMeta{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+		assert.Equal(t, resp.RefreshToken, cookie.Value)
+	})
+
+	t.Run("MFA login with reused TOTP code", func(t *testing.T) {
+		code, err := totp.Code(secret, time.Now())
+		require.NoError(t, err)
+
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeMFA, "mfa").Return(uint(1), nil)
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, TOTPSecret: secret, TOTPEnabled: true}, nil)
+		mockUserRepo.EXPECT().UseTOTPStep(gomock.Any(), uint(1), gomock.Any()).Return(false, nil)
+
+		resp, _, err := authService.LoginMFA(context.Background(), &MFALoginRequest{MFAToken: "mfa", Code: code}, jwt.SessionMeta{})
+		assert.EqualError(t, err, ErrInvalidMFACode)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("MFA login with recovery code", func(t *testing.T) {
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeMFA, "mfa").Return(uint(1), nil)
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, TOTPSecret: secret, TOTPEnabled: true}, nil)
+		mockUserRepo.EXPECT().UseRecoveryCode(gomock.Any(), uint(1), hashRecoveryCode("abcde-fghij")).Return(true, nil)
+		mockTokenVersion.EXPECT().GetVersion(gomock.Any(), uint(1)).Return(uint(1), nil).Times(2)
+		mockSessions.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+		// Регистр и дефис не важны
+		resp, _, err := authService.LoginMFA(context.Background(), &MFALoginRequest{MFAToken: "mfa", Code: "ABCDEFGHIJ"}, jwt.SessionMeta{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+	})
+
+	t.Run("MFA login with used recovery code", func(t *testing.T) {
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeMFA, "mfa").Return(uint(1), nil)
+		mockUserRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, TOTPSecret: secret, TOTPEnabled: true}, nil)
+		mockUserRepo.EXPECT().UseRecoveryCode(gomock.Any(), uint(1), gomock.Any()).Return(false, nil)
+
+		resp, _, err := authService.LoginMFA(context.Background(), &MFALoginRequest{MFAToken: "mfa", Code: "abcde-fghij"}, jwt.SessionMeta{})
+		assert.EqualError(t, err, ErrInvalidMFACode)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("MFA login with expired challenge", func(t *testing.T) {
+		mockTokens.EXPECT().ConsumeOneTimeToken(gomock.Any(), jwt.PurposeMFA, "expired").Return(uint(0), jwt.ErrOneTimeTokenInvalid)
+
+		_, _, err := authService.LoginMFA(context.Background(), &MFALoginRequest{MFAToken: "expired", Code: "123456"}, jwt.SessionMeta{})
+		assert.ErrorIs(t, err, jwt.ErrOneTimeTokenInvalid)
+	})
+}
+
+func TestAuthServiceImpl_MFALockout(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mock_user.NewMockUserRepository(ctrl)
+	oneTimeTokens := jwt.NewMemoryOneTimeTokens(0)
+	defer oneTimeTokens.Close()
+	lockStorage := lockout.NewMemoryStorage(0)
+	defer lockStorage.Close()
+
+	authService := &AuthServiceimpl{
+		cfg:      &config.Config{},
+		UserRepo: mockUserRepo,
+		tokens:   oneTimeTokens,
+		lockout:  lockout.NewGuard(lockStorage, config.LockoutConfig{MaxAttempts: 2, BaseDelay: time.Minute}),
+	}
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.DefaultCost)
+	require.NoError(t, err)
+	user := &models.User{ID: 1, Email: "test@example.com", Password: string(hashedPassword), TOTPSecret: secret, TOTPEnabled: true}
+	mockUserRepo.EXPECT().FindByEmail(gomock.Any(), user.Email).Return(user, nil).Times(2)
+	mockUserRepo.EXPECT().FindByID(gomock.Any(), user.ID).Return(user, nil).Times(2)
+	mockUserRepo.EXPECT().UseRecoveryCode(gomock.Any(), user.ID, gomock.Any()).Return(false, nil).Times(2)
+
+	// Верный пароль перед каждой попыткой не обнуляет счетчик неверных кодов
+	for i := 0; i < 2; i++ {
+		resp, _, err := authService.Login(context.Background(), &LoginRequest{Email: user.Email, Password: "test"}, jwt.SessionMeta{IP: "10.0.0.1"})
+		require.NoError(t, err)
+		require.True(t, resp.MFARequired)
+
+		_, _, err = authService.LoginMFA(context.Background(), &MFALoginRequest{MFAToken: resp.MFAToken, Code: "abcde-fghij"}, jwt.SessionMeta{IP: "10.0.0.1"})
+		assert.EqualError(t, err, ErrInvalidMFACode)
+	}
+
+	_, _, err = authService.Login(context.Background(), &LoginRequest{Email: user.Email, Password: "test"}, jwt.SessionMeta{IP: "10.0.0.1"})
+	var locked *lockout.LockedError
+	assert.ErrorAs(t, err, &locked)
+}
+
+func TestAuthServiceImpl_OAuth(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := mock_user.NewMockUserRepository(ctrl)
+	mockBlackList := mock_jwt.NewMockBlackListStorage(ctrl)
+	mockTokenVersion := mock_jwt.NewMockTokenVersionStorage(ctrl)
+	mockSessions := mock_jwt.NewMockSessionStorage(ctrl)
+
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			SigningKey: "FKI/0XYt3YksmneW8QxCRWdlYbINIzPdp4fpiTqXXqs=",
+			AccessTTL:  time.Duration(30) * time.Minute,
+			RefreshTTL: time.Duration(30) * time.Hour,
+		},
+	}
+	jwtService := jwt.NewJWTService(mockBlackList, mockTokenVersion, mockSessions)
+	jwtAuth := jwt.NewJWT(jwtService, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL, cfg.Auth.SigningKey)
+
+	idp := oidctest.NewServer("blog", "secret")
+	defer idp.Close()
+	oauth := oidc.NewClient(config.OAuthConfig{
+		Providers: map[string]config.OIDCProviderConfig{"test": idp.ProviderConfig("http://localhost/auth/oauth/test/callback")},
+	}, oidc.NewMemoryStates(0), nil)
+
+	authService := &AuthServiceimpl{
+		cfg:      cfg,
+		jwtAuth:  jwtAuth,
+		UserRepo: mockUserRepo,
+		oauth:    oauth,
+	}
+
+	// login - проходит вход у провайдера и возвращается с его кодом
+	login := func(t *testing.T, user oidctest.User) (*LoginResponse, error) {
+		idp.SetUser(user)
+		redirectURL, cookie, err := authService.OAuthStart(context.Background(), "test")
+		require.NoError(t, err)
+		code, state, err := idp.Authorize(redirectURL)
+		require.NoError(t, err)
+		require.Equal(t, cookie.Value, state)
+
+		resp, _, err := authService.OAuthLogin(context.Background(), "test", state, code, jwt.SessionMeta{})
+		return resp, err
+	}
+	expectSession := func(userID uint) {
+		mockTokenVersion.EXPECT().GetVersion(gomock.Any(), userID).Return(uint(1), nil).Times(2)
+		mockSessions.EXPECT().CreateSession(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	}
+
+	t.Run("Linked identity", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByIdentity(gomock.Any(), "test", "42").Return(&models.User{ID: 1}, nil)
+		expectSession(1)
+
+		resp, err := login(t, oidctest.User{Subject: "42", Email: "ivan@example.com", EmailVerified: true})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+	})
+
+	t.Run("New user", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByIdentity(gomock.Any(), "test", "43").Return(nil, gorm.ErrRecordNotFound)
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), "new@example.com").Return(nil, gorm.ErrRecordNotFound)
+		mockUserRepo.EXPECT().CreateWithIdentity(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, u *models.User, identity *models.UserIdentity) error {
+			assert.Equal(t, "new", u.Name)
+			assert.Equal(t, models.RoleAuthor, u.Role)
+			assert.NotNil(t, u.EmailVerifiedAt)
+			assert.NotEmpty(t, u.Password)
+			assert.Equal(t, "43", identity.Subject)
+			u.ID = 2
+			return nil
+		})
+		expectSession(2)
+
+		resp, err := login(t, oidctest.User{Subject: "43", Email: "New@example.com", EmailVerified: true})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+	})
+
+	t.Run("Link to unverified local account", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByIdentity(gomock.Any(), "test", "44").Return(nil, gorm.ErrRecordNotFound)
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), "taken@example.com").Return(&models.User{ID: 3, Password: "old"}, nil)
+		// Пароль того, кто зарегистрировался на чужой email, сбрасывается, а его сессии завершаются
+		mockUserRepo.EXPECT().Update(gomock.Any(), uint(3), gomock.Any()).DoAndReturn(func(_ context.Context, userID uint, u *models.User) error {
+			assert.NotEmpty(t, u.Password)
+			assert.NotNil(t, u.EmailVerifiedAt)
+			return nil
+		})
+		mockTokenVersion.EXPECT().IncrementVersion(gomock.Any(), uint(3)).Return(nil)
+		mockSessions.EXPECT().DeleteUserSessions(gomock.Any(), uint(3)).Return(nil)
+		mockUserRepo.EXPECT().LinkIdentity(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, identity *models.UserIdentity) error {
+			assert.Equal(t, uint(3), identity.UserID)
+			return nil
+		})
+		expectSession(3)
+
+		resp, err := login(t, oidctest.User{Subject: "44", Email: "taken@example.com", EmailVerified: true})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.AccessToken)
+	})
+
+	t.Run("Email not verified by provider", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByIdentity(gomock.Any(), "test", "45").Return(nil, gorm.ErrRecordNotFound)
+
+		_, err := login(t, oidctest.User{Subject: "45", Email: "victim@example.com"})
+		assert.EqualError(t, err, ErrProviderEmail)
+	})
+
+	t.Run("Unknown provider", func(t *testing.T) {
+		_, _, err := authService.OAuthStart(context.Background(), "github")
+		assert.ErrorIs(t, err, oidc.ErrUnknownProvider)
+	})
+}
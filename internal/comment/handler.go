@@ -2,9 +2,13 @@ package comment
 
 import (
 	"errors"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
 	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
 	"github.com/crafty-ezhik/blog-api/pkg/req"
+	"github.com/crafty-ezhik/blog-api/pkg/res"
 	"github.com/crafty-ezhik/blog-api/pkg/validate"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -18,6 +22,8 @@ type CommentHandler interface {
 	CreateComments(c *fiber.Ctx) error
 	UpdateComment(c *fiber.Ctx) error
 	DeleteComment(c *fiber.Ctx) error
+	GetModerationQueue(c *fiber.Ctx) error
+	ModerateComments(c *fiber.Ctx) error
 }
 
 type CommentHandlerImpl struct {
@@ -41,7 +47,18 @@ func (h *CommentHandlerImpl) GetAllCommentsPost(c *fiber.Ctx) error {
 			"error":   "Post ID must be an integer",
 		})
 	}
-	return h.getComments(c, uint(postID), 0)
+
+	switch c.Query("view", ViewFlat) {
+	case ViewFlat:
+		return h.getComments(c, uint(postID), 0)
+	case ViewTree:
+		return h.getCommentTree(c, uint(postID))
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "view must be flat or tree",
+		})
+	}
 }
 
 func (h *CommentHandlerImpl) GetMyComment(c *fiber.Ctx) error {
@@ -76,8 +93,6 @@ func (h *CommentHandlerImpl) GetUserComments(c *fiber.Ctx) error {
 }
 
 func (h *CommentHandlerImpl) CreateComments(c *fiber.Ctx) error {
-	userID := c.Locals(middleware.UserIDKey).(uint)
-
 	postID, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -89,7 +104,25 @@ func (h *CommentHandlerImpl) CreateComments(c *fiber.Ctx) error {
 	if err != nil {
 		return nil
 	}
-	err = h.CommentService.CreateCommentByPostID(uint(postID), userID, body)
+	err = h.CommentService.CreateCommentByPostID(c.UserContext(), uint(postID), policy.ActorFromCtx(c), body)
+	if errors.Is(err, ErrParentNotFound) || errors.Is(err, ErrMaxDepthExceeded) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Post not found",
+		})
+	}
+	if errors.Is(err, ErrPermissionDenied) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"error":   "Permission denied",
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -103,8 +136,6 @@ func (h *CommentHandlerImpl) CreateComments(c *fiber.Ctx) error {
 }
 
 func (h *CommentHandlerImpl) UpdateComment(c *fiber.Ctx) error {
-	userID := c.Locals(middleware.UserIDKey).(uint)
-
 	commentID, err := strconv.Atoi(c.Params("commentId"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -125,7 +156,13 @@ func (h *CommentHandlerImpl) UpdateComment(c *fiber.Ctx) error {
 		return nil
 	}
 
-	err = h.CommentService.UpdateComment(uint(commentID), uint(postID), userID, body)
+	err = h.CommentService.UpdateComment(c.UserContext(), uint(commentID), uint(postID), policy.ActorFromCtx(c), body)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Comment not found",
+		})
+	}
 	if errors.Is(err, ErrPermissionDenied) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"success": false,
@@ -145,8 +182,6 @@ func (h *CommentHandlerImpl) UpdateComment(c *fiber.Ctx) error {
 }
 
 func (h *CommentHandlerImpl) DeleteComment(c *fiber.Ctx) error {
-	userID := c.Locals(middleware.UserIDKey).(uint)
-
 	commentID, err := strconv.Atoi(c.Params("commentId"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -162,7 +197,13 @@ func (h *CommentHandlerImpl) DeleteComment(c *fiber.Ctx) error {
 		})
 	}
 
-	err = h.CommentService.DeleteComment(uint(commentID), uint(postID), userID)
+	err = h.CommentService.DeleteComment(c.UserContext(), uint(commentID), uint(postID), policy.ActorFromCtx(c))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Comment not found",
+		})
+	}
 	if errors.Is(err, ErrPermissionDenied) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"success": false,
@@ -181,8 +222,102 @@ func (h *CommentHandlerImpl) DeleteComment(c *fiber.Ctx) error {
 	})
 }
 
+// GetModerationQueue - ?status=pending|approved|rejected|spam (по умолчанию pending), ?post_id - только одна статья
+func (h *CommentHandlerImpl) GetModerationQueue(c *fiber.Ctx) error {
+	status := models.CommentStatus(c.Query("status", string(models.CommentPending)))
+	switch status {
+	case models.CommentPending, models.CommentApproved, models.CommentRejected, models.CommentSpam:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Unknown comment status",
+		})
+	}
+	postID := c.QueryInt("post_id", 0)
+	if postID < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Post ID must be an integer",
+		})
+	}
+
+	params, err := pagination.ParseQuery(c, DefaultSort, SortFields...)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	data, meta, err := h.CommentService.GetModerationQueue(c.UserContext(), policy.ActorFromCtx(c), status, uint(postID), params)
+	if errors.Is(err, ErrPermissionDenied) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"error":   "Permission denied",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Internal server error",
+		})
+	}
+	return res.PageResponse(c, data, meta)
+}
+
+func (h *CommentHandlerImpl) ModerateComments(c *fiber.Ctx) error {
+	body, err := req.HandleBody[ModerationRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+
+	updated, err := h.CommentService.ModerateComments(c.UserContext(), policy.ActorFromCtx(c), body.IDs, models.CommentStatus(body.Status))
+	if errors.Is(err, ErrPermissionDenied) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"error":   "Permission denied",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Something went wrong",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    fiber.Map{"updated": updated},
+	})
+}
+
 func (h *CommentHandlerImpl) getComments(c *fiber.Ctx, postID, userID uint) error {
-	data, err := h.CommentService.GetCommentsByPostID(postID, userID)
+	params, err := pagination.ParseQuery(c, DefaultSort, SortFields...)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	data, meta, err := h.CommentService.GetCommentsByPostID(c.UserContext(), postID, userID, policy.ActorFromCtx(c).UserID, params)
+	return h.commentsResponse(c, data, meta, err)
+}
+
+// getCommentTree - пагинация в режиме дерева применяется к корневым комментариям
+func (h *CommentHandlerImpl) getCommentTree(c *fiber.Ctx, postID uint) error {
+	params, err := pagination.ParseQuery(c, DefaultSort, SortFields...)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	data, meta, err := h.CommentService.GetCommentTree(c.UserContext(), postID, policy.ActorFromCtx(c).UserID, params)
+	return h.commentsResponse(c, data, meta, err)
+}
+
+func (h *CommentHandlerImpl) commentsResponse(c *fiber.Ctx, data *GetCommentsResponse, meta *pagination.Meta, err error) error {
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
@@ -197,8 +332,5 @@ func (h *CommentHandlerImpl) getComments(c *fiber.Ctx, postID, userID uint) erro
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"success": true,
-		"data":    data,
-	})
+	return res.PageResponse(c, data, meta)
 }
@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/crafty-ezhik/blog-api/internal/comment"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
 	mock_comment "github.com/crafty-ezhik/blog-api/mocks/comment"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
 	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
 	"github.com/crafty-ezhik/blog-api/pkg/validate"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -64,7 +67,7 @@ func TestCommentHandlerImpl_GetMyComment(t *testing.T) {
 			userId: 1,
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().GetCommentsByPostID(uint(1), uint(1)).Return(
+				mock.CommentService.EXPECT().GetCommentsByPostID(gomock.Any(), uint(1), uint(1), gomock.Any(), gomock.Any()).Return(
 					&comment.GetCommentsResponse{
 						Comments: []comment.GetCommentResponseBody{
 							{
@@ -75,7 +78,7 @@ func TestCommentHandlerImpl_GetMyComment(t *testing.T) {
 								PostTitle:  "TestPostTitle",
 							},
 						},
-					}, nil)
+					}, &pagination.Meta{Limit: 20, Total: 1}, nil)
 			},
 			handlerFunc: func(c *fiber.Ctx) error {
 				c.Locals(middleware.UserIDKey, uint(1))
@@ -101,8 +104,8 @@ func TestCommentHandlerImpl_GetMyComment(t *testing.T) {
 			userId: 1,
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().GetCommentsByPostID(uint(1), uint(1)).Return(
-					nil, gorm.ErrRecordNotFound)
+				mock.CommentService.EXPECT().GetCommentsByPostID(gomock.Any(), uint(1), uint(1), gomock.Any(), gomock.Any()).Return(
+					nil, nil, gorm.ErrRecordNotFound)
 			},
 			handlerFunc: func(c *fiber.Ctx) error {
 				c.Locals(middleware.UserIDKey, uint(1))
@@ -116,8 +119,8 @@ func TestCommentHandlerImpl_GetMyComment(t *testing.T) {
 			userId: 1,
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().GetCommentsByPostID(uint(1), uint(1)).Return(
-					nil, gorm.ErrInvalidDB)
+				mock.CommentService.EXPECT().GetCommentsByPostID(gomock.Any(), uint(1), uint(1), gomock.Any(), gomock.Any()).Return(
+					nil, nil, gorm.ErrInvalidDB)
 			},
 			handlerFunc: func(c *fiber.Ctx) error {
 				c.Locals(middleware.UserIDKey, uint(1))
@@ -172,7 +175,7 @@ func TestCommentHandlerImpl_GetUserComments(t *testing.T) {
 			userId: 1,
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().GetCommentsByPostID(uint(1), uint(1)).Return(
+				mock.CommentService.EXPECT().GetCommentsByPostID(gomock.Any(), uint(1), uint(1), gomock.Any(), gomock.Any()).Return(
 					&comment.GetCommentsResponse{
 						Comments: []comment.GetCommentResponseBody{
 							{
@@ -183,7 +186,7 @@ func TestCommentHandlerImpl_GetUserComments(t *testing.T) {
 								PostTitle:  "TestPostTitle",
 							},
 						},
-					}, nil)
+					}, &pagination.Meta{Limit: 20, Total: 1}, nil)
 			},
 			expectedStatusCode: 200,
 			expectedBody:       "\"success\":true",
@@ -209,8 +212,8 @@ func TestCommentHandlerImpl_GetUserComments(t *testing.T) {
 			userId: 1,
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().GetCommentsByPostID(uint(1), uint(1)).Return(
-					nil, gorm.ErrRecordNotFound)
+				mock.CommentService.EXPECT().GetCommentsByPostID(gomock.Any(), uint(1), uint(1), gomock.Any(), gomock.Any()).Return(
+					nil, nil, gorm.ErrRecordNotFound)
 			},
 			expectedStatusCode: 404,
 			expectedBody:       "\"error\":\"Comment not found\"",
@@ -220,8 +223,8 @@ func TestCommentHandlerImpl_GetUserComments(t *testing.T) {
 			userId: 1,
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().GetCommentsByPostID(uint(1), uint(1)).Return(
-					nil, gorm.ErrInvalidDB)
+				mock.CommentService.EXPECT().GetCommentsByPostID(gomock.Any(), uint(1), uint(1), gomock.Any(), gomock.Any()).Return(
+					nil, nil, gorm.ErrInvalidDB)
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "\"error\":\"Internal server error\"",
@@ -262,15 +265,45 @@ func TestCommentHandlerImpl_GetAllCommentsPost(t *testing.T) {
 	tests := []struct {
 		name               string
 		postId             any
+		query              string
 		mockSetup          func(mock *Mocks)
 		expectedStatusCode int
 		expectedBody       string
 	}{
+		{
+			name:   "Tree view",
+			postId: 1,
+			query:  "?view=tree",
+			mockSetup: func(mock *Mocks) {
+				parentID := uint(1)
+				mock.CommentService.EXPECT().GetCommentTree(gomock.Any(), uint(1), gomock.Any(), gomock.Any()).Return(
+					&comment.GetCommentsResponse{
+						Comments: []comment.GetCommentResponseBody{
+							{
+								ID:    1,
+								Title: "Root",
+								Replies: []comment.GetCommentResponseBody{
+									{ID: 2, Title: "Reply", ParentID: &parentID, Depth: 1},
+								},
+							},
+						},
+					}, &pagination.Meta{Limit: 20, Total: 1}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"replies":[{"id":2,"title":"Reply"`,
+		},
+		{
+			name:               "Invalid view",
+			postId:             1,
+			query:              "?view=graph",
+			expectedStatusCode: 400,
+			expectedBody:       "view must be flat or tree",
+		},
 		{
 			name:   "Success",
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().GetCommentsByPostID(uint(1), uint(0)).Return(
+				mock.CommentService.EXPECT().GetCommentsByPostID(gomock.Any(), uint(1), uint(0), gomock.Any(), gomock.Any()).Return(
 					&comment.GetCommentsResponse{
 						Comments: []comment.GetCommentResponseBody{
 							{
@@ -281,7 +314,7 @@ func TestCommentHandlerImpl_GetAllCommentsPost(t *testing.T) {
 								PostTitle:  "TestPostTitle",
 							},
 						},
-					}, nil)
+					}, &pagination.Meta{Limit: 20, Total: 1}, nil)
 			},
 			expectedStatusCode: 200,
 			expectedBody:       "\"success\":true",
@@ -297,8 +330,8 @@ func TestCommentHandlerImpl_GetAllCommentsPost(t *testing.T) {
 			name:   "Comments Not Found",
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().GetCommentsByPostID(uint(1), uint(0)).Return(
-					nil, gorm.ErrRecordNotFound)
+				mock.CommentService.EXPECT().GetCommentsByPostID(gomock.Any(), uint(1), uint(0), gomock.Any(), gomock.Any()).Return(
+					nil, nil, gorm.ErrRecordNotFound)
 			},
 			expectedStatusCode: 404,
 			expectedBody:       "\"error\":\"Comment not found\"",
@@ -307,8 +340,8 @@ func TestCommentHandlerImpl_GetAllCommentsPost(t *testing.T) {
 			name:   "Server internal error",
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().GetCommentsByPostID(uint(1), uint(0)).Return(
-					nil, gorm.ErrInvalidDB)
+				mock.CommentService.EXPECT().GetCommentsByPostID(gomock.Any(), uint(1), uint(0), gomock.Any(), gomock.Any()).Return(
+					nil, nil, gorm.ErrInvalidDB)
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "\"error\":\"Internal server error\"",
@@ -317,7 +350,7 @@ func TestCommentHandlerImpl_GetAllCommentsPost(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%v/comments", tt.postId), nil)
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%v/comments%s", tt.postId, tt.query), nil)
 
 			app := fiber.New()
 			app.Get(path, commentHandlerImpl.GetAllCommentsPost)
@@ -340,6 +373,7 @@ func TestCommentHandlerImpl_CreateComments(t *testing.T) {
 	commentHandlerImpl, mocks := setup(t)
 
 	path := "/posts/:id/comments"
+	parentID := uint(5)
 
 	tests := []struct {
 		name               string
@@ -359,7 +393,7 @@ func TestCommentHandlerImpl_CreateComments(t *testing.T) {
 				Content: "TestContent",
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().CreateCommentByPostID(uint(1), uint(1), gomock.Any()).Return(nil)
+				mock.CommentService.EXPECT().CreateCommentByPostID(gomock.Any(), uint(1), policy.Actor{UserID: 1}, gomock.Any()).Return(nil)
 			},
 			expectedStatusCode: 201,
 			expectedBody:       "Comment created successfully",
@@ -386,6 +420,36 @@ func TestCommentHandlerImpl_CreateComments(t *testing.T) {
 			expectedStatusCode: 400,
 			expectedBody:       "Invalid field or its absence: [Content]",
 		},
+		{
+			name:   "Parent from another post",
+			userId: 1,
+			postId: 1,
+			payload: comment.CreateCommentRequest{
+				Title:    "TestTitle",
+				Content:  "TestContent",
+				ParentID: &parentID,
+			},
+			mockSetup: func(mock *Mocks) {
+				mock.CommentService.EXPECT().CreateCommentByPostID(gomock.Any(), uint(1), policy.Actor{UserID: 1}, gomock.Any()).Return(comment.ErrParentNotFound)
+			},
+			expectedStatusCode: 400,
+			expectedBody:       "parent comment not found in this post",
+		},
+		{
+			name:   "Reply too deep",
+			userId: 1,
+			postId: 1,
+			payload: comment.CreateCommentRequest{
+				Title:    "TestTitle",
+				Content:  "TestContent",
+				ParentID: &parentID,
+			},
+			mockSetup: func(mock *Mocks) {
+				mock.CommentService.EXPECT().CreateCommentByPostID(gomock.Any(), uint(1), policy.Actor{UserID: 1}, gomock.Any()).Return(comment.ErrMaxDepthExceeded)
+			},
+			expectedStatusCode: 400,
+			expectedBody:       "maximum reply depth exceeded",
+		},
 		{
 			name:   "Server internal error",
 			userId: 1,
@@ -395,7 +459,7 @@ func TestCommentHandlerImpl_CreateComments(t *testing.T) {
 				Content: "TestContent",
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().CreateCommentByPostID(uint(1), uint(1), gomock.Any()).Return(errors.New("error"))
+				mock.CommentService.EXPECT().CreateCommentByPostID(gomock.Any(), uint(1), policy.Actor{UserID: 1}, gomock.Any()).Return(errors.New("error"))
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "Something went wrong",
@@ -466,7 +530,7 @@ func TestCommentHandlerImpl_UpdateComment(t *testing.T) {
 				Content: "NewContent",
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().UpdateComment(uint(1), uint(1), uint(1),
+				mock.CommentService.EXPECT().UpdateComment(gomock.Any(), uint(1), uint(1), policy.Actor{UserID: 1},
 					&comment.UpdateCommentRequest{Content: "NewContent"}).Return(nil)
 			},
 			expectedStatusCode: 200,
@@ -494,7 +558,7 @@ func TestCommentHandlerImpl_UpdateComment(t *testing.T) {
 				Content: "NewContent",
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().UpdateComment(uint(1), uint(1), uint(1),
+				mock.CommentService.EXPECT().UpdateComment(gomock.Any(), uint(1), uint(1), policy.Actor{UserID: 1},
 					&comment.UpdateCommentRequest{Content: "NewContent"}).Return(errors.New("error"))
 			},
 			expectedStatusCode: 500,
@@ -508,7 +572,7 @@ func TestCommentHandlerImpl_UpdateComment(t *testing.T) {
 				Content: "NewContent",
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().UpdateComment(uint(1), uint(2), uint(1),
+				mock.CommentService.EXPECT().UpdateComment(gomock.Any(), uint(1), uint(2), policy.Actor{UserID: 1},
 					&comment.UpdateCommentRequest{Content: "NewContent"}).Return(comment.ErrPermissionDenied)
 			},
 			expectedStatusCode: 403,
@@ -578,7 +642,7 @@ func TestCommentHandlerImpl_DeleteComment(t *testing.T) {
 			postId:    1,
 			commentId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().DeleteComment(uint(1), uint(1), uint(1)).Return(nil)
+				mock.CommentService.EXPECT().DeleteComment(gomock.Any(), uint(1), uint(1), policy.Actor{UserID: 1}).Return(nil)
 			},
 			expectedStatusCode: 204,
 			expectedBody:       "",
@@ -602,7 +666,7 @@ func TestCommentHandlerImpl_DeleteComment(t *testing.T) {
 			postId:    1,
 			commentId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().DeleteComment(uint(1), uint(1), uint(1)).Return(errors.New("error"))
+				mock.CommentService.EXPECT().DeleteComment(gomock.Any(), uint(1), uint(1), policy.Actor{UserID: 1}).Return(errors.New("error"))
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "error",
@@ -612,7 +676,7 @@ func TestCommentHandlerImpl_DeleteComment(t *testing.T) {
 			postId:    1,
 			commentId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.CommentService.EXPECT().DeleteComment(uint(1), uint(1), uint(1)).Return(comment.ErrPermissionDenied)
+				mock.CommentService.EXPECT().DeleteComment(gomock.Any(), uint(1), uint(1), policy.Actor{UserID: 1}).Return(comment.ErrPermissionDenied)
 			},
 			expectedStatusCode: 403,
 			expectedBody:       "Permission denied",
@@ -643,3 +707,152 @@ func TestCommentHandlerImpl_DeleteComment(t *testing.T) {
 		})
 	}
 }
+
+func TestCommentHandlerImpl_GetModerationQueue(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	commentHandlerImpl, mocks := setup(t)
+
+	path := "/comments/moderation"
+	moderator := policy.Actor{UserID: 1, Role: models.RoleModerator}
+
+	tests := []struct {
+		name               string
+		query              string
+		mockSetup          func(mock *Mocks)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "Pending by default",
+			mockSetup: func(mock *Mocks) {
+				mock.CommentService.EXPECT().GetModerationQueue(gomock.Any(), moderator, models.CommentPending, uint(0), gomock.Any()).Return(
+					&comment.GetCommentsResponse{
+						Comments: []comment.GetCommentResponseBody{{ID: 3, Status: "pending"}},
+					}, &pagination.Meta{Limit: 20, Total: 1}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"status":"pending"`,
+		},
+		{
+			name:  "Spam of one post",
+			query: "?status=spam&post_id=2",
+			mockSetup: func(mock *Mocks) {
+				mock.CommentService.EXPECT().GetModerationQueue(gomock.Any(), moderator, models.CommentSpam, uint(2), gomock.Any()).Return(
+					&comment.GetCommentsResponse{Comments: []comment.GetCommentResponseBody{}}, &pagination.Meta{Limit: 20}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"success":true`,
+		},
+		{
+			name:               "Unknown status",
+			query:              "?status=deleted",
+			expectedStatusCode: 400,
+			expectedBody:       "Unknown comment status",
+		},
+		{
+			name: "Permission denied",
+			mockSetup: func(mock *Mocks) {
+				mock.CommentService.EXPECT().GetModerationQueue(gomock.Any(), moderator, models.CommentPending, uint(0), gomock.Any()).Return(
+					nil, nil, comment.ErrPermissionDenied)
+			},
+			expectedStatusCode: 403,
+			expectedBody:       "Permission denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mockSetup != nil {
+				tt.mockSetup(mocks)
+			}
+
+			app := fiber.New()
+			app.Get(path,
+				func(c *fiber.Ctx) error {
+					c.Locals(middleware.UserIDKey, moderator.UserID)
+					c.Locals(middleware.RoleKey, string(moderator.Role))
+					return c.Next()
+				},
+				commentHandlerImpl.GetModerationQueue)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, path+tt.query, nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, resp.StatusCode)
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(respBody), tt.expectedBody)
+		})
+	}
+}
+
+func TestCommentHandlerImpl_ModerateComments(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	commentHandlerImpl, mocks := setup(t)
+
+	path := "/comments/moderation"
+	moderator := policy.Actor{UserID: 1, Role: models.RoleModerator}
+
+	tests := []struct {
+		name               string
+		payload            comment.ModerationRequest
+		mockSetup          func(mock *Mocks)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:    "Approve in bulk",
+			payload: comment.ModerationRequest{IDs: []uint{1, 2, 3}, Status: "approved"},
+			mockSetup: func(mock *Mocks) {
+				mock.CommentService.EXPECT().ModerateComments(gomock.Any(), moderator, []uint{1, 2, 3}, models.CommentApproved).Return(int64(3), nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"updated":3`,
+		},
+		{
+			name:               "Empty ids",
+			payload:            comment.ModerationRequest{Status: "approved"},
+			expectedStatusCode: 400,
+			expectedBody:       "Invalid field or its absence: [IDs]",
+		},
+		{
+			name:               "Unknown status",
+			payload:            comment.ModerationRequest{IDs: []uint{1}, Status: "hidden"},
+			expectedStatusCode: 400,
+			expectedBody:       "Invalid field or its absence: [Status]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(tt.payload)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			if tt.mockSetup != nil {
+				tt.mockSetup(mocks)
+			}
+
+			app := fiber.New()
+			app.Post(path,
+				func(c *fiber.Ctx) error {
+					c.Locals(middleware.UserIDKey, moderator.UserID)
+					c.Locals(middleware.RoleKey, string(moderator.Role))
+					return c.Next()
+				},
+				commentHandlerImpl.ModerateComments)
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, resp.StatusCode)
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(respBody), tt.expectedBody)
+		})
+	}
+}
@@ -2,9 +2,28 @@ package comment
 
 import "time"
 
+// Параметры сортировки списков комментариев
+const DefaultSort = "created_at"
+
+var SortFields = []string{"created_at", "updated_at", "title"}
+
+// Способы выдачи комментариев к статье: плоский список с уровнем вложенности или дерево ответов
+const (
+	ViewFlat = "flat"
+	ViewTree = "tree"
+)
+
+// CreateCommentRequest - parent_id задается для ответа на комментарий той же статьи
 type CreateCommentRequest struct {
-	Title   string `json:"title" validate:"required,max=255"`
-	Content string `json:"content" validate:"required,max=255"`
+	Title    string `json:"title" validate:"required,max=255"`
+	Content  string `json:"content" validate:"required,max=255"`
+	ParentID *uint  `json:"parent_id" validate:"omitempty,min=1"`
+}
+
+// ModerationRequest - массовая смена статуса комментариев модератором
+type ModerationRequest struct {
+	IDs    []uint `json:"ids" validate:"required,min=1,max=100,dive,min=1"`
+	Status string `json:"status" validate:"required,oneof=pending approved rejected spam"`
 }
 
 type UpdateCommentRequest struct {
@@ -21,5 +40,11 @@ type GetCommentResponseBody struct {
 	Content    string    `json:"content"`
 	AuthorName string    `json:"author_name"`
 	PostTitle  string    `json:"post_title"`
+	ParentID   *uint     `json:"parent_id,omitempty"`
+	Depth      int       `json:"depth"`
+	Status     string    `json:"status"`
 	CreatedAt  time.Time `json:"created_at"`
+	Deleted    bool      `json:"deleted,omitempty"` // удаленный или скрытый комментарий, оставленный в дереве ради ответов
+
+	Replies []GetCommentResponseBody `json:"replies,omitempty"` // заполняется только при view=tree
 }
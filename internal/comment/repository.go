@@ -1,18 +1,28 @@
 package comment
 
 import (
+	"context"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/uow"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
 	"gorm.io/gorm"
 )
 //go:generate mockgen -source=repository.go -destination=mock/comment_repo_mock.go
 
 
 type CommentRepository interface {
-	FindCommentsByPostID(comment *models.Comment) ([]models.Comment, error)
-	CreateCommentByPostID(comment *models.Comment) error
-	UpdateCommentByCommentAndPostID(comment *models.Comment) error
-	DeleteCommentByCommentAndPostID(comment *models.Comment) error
+	FindCommentsByPostID(ctx context.Context, comment *models.Comment, viewerID uint, params *pagination.Params) (*pagination.Page[models.Comment], error)
+	FindRootComments(ctx context.Context, postID, viewerID uint, params *pagination.Params) (*pagination.Page[models.Comment], error)
+	FindReplies(ctx context.Context, postID, viewerID uint, parentIDs []uint) ([]models.Comment, error)
+	FindCommentsByStatus(ctx context.Context, status models.CommentStatus, postID uint, params *pagination.Params) (*pagination.Page[models.Comment], error)
+	UpdateStatus(ctx context.Context, commentIDs []uint, status models.CommentStatus) (int64, error)
+	FindCommentByID(ctx context.Context, commentID uint) (*models.Comment, error)
+	FindCommentByIDForShare(ctx context.Context, commentID uint) (*models.Comment, error)
+	CreateCommentByPostID(ctx context.Context, comment *models.Comment) error
+	UpdateCommentByCommentAndPostID(ctx context.Context, comment *models.Comment) error
+	DeleteCommentByCommentAndPostID(ctx context.Context, comment *models.Comment) error
+	DeleteByUser(ctx context.Context, userID uint) error
 }
 
 type CommentRepositoryImpl struct {
@@ -26,34 +36,116 @@ func NewCommentRepository(db *gorm.DB) *CommentRepositoryImpl {
 	}
 }
 
-func (r *CommentRepositoryImpl) FindCommentsByPostID(comment *models.Comment) ([]models.Comment, error) {
-	var comments []models.Comment
-	result := r.db.Model(&models.Comment{}).Where(comment).Joins("Author").Joins("Post").Find(&comments)
+// WithTx - репозиторий, работающий через транзакцию tx
+func (r *CommentRepositoryImpl) WithTx(tx *gorm.DB) *CommentRepositoryImpl {
+	return &CommentRepositoryImpl{db: tx}
+}
+
+func (r *CommentRepositoryImpl) FindCommentsByPostID(ctx context.Context, comment *models.Comment, viewerID uint, params *pagination.Params) (*pagination.Page[models.Comment], error) {
+	query := r.visibleTo(ctx, viewerID).Where(comment).Joins("Author").Joins("Post")
+	return pagination.Paginate[models.Comment](query, "comments", params)
+}
+
+// FindRootComments - страница комментариев верхнего уровня, ответы на них загружаются через FindReplies.
+// Как и FindReplies, возвращает удаленные и скрытые от читателя комментарии, если под ними есть видимые ответы
+func (r *CommentRepositoryImpl) FindRootComments(ctx context.Context, postID, viewerID uint, params *pagination.Params) (*pagination.Page[models.Comment], error) {
+	query := r.treeNodes(ctx, postID, viewerID).
+		Where("comments.parent_id IS NULL").
+		Joins("Author").Joins("Post")
+	return pagination.Paginate[models.Comment](query, "comments", params)
+}
+
+// FindReplies - прямые ответы на комментарии parentIDs в хронологическом порядке
+func (r *CommentRepositoryImpl) FindReplies(ctx context.Context, postID, viewerID uint, parentIDs []uint) ([]models.Comment, error) {
+	replies := make([]models.Comment, 0)
+	result := r.treeNodes(ctx, postID, viewerID).
+		Where("comments.parent_id IN ?", parentIDs).
+		Joins("Author").Joins("Post").
+		Order("comments.created_at, comments.id").
+		Find(&replies)
+	return replies, result.Error
+}
+
+// FindCommentsByStatus - очередь модерации: комментарии со статусом status, postID = 0 - по всем статьям
+func (r *CommentRepositoryImpl) FindCommentsByStatus(ctx context.Context, status models.CommentStatus, postID uint, params *pagination.Params) (*pagination.Page[models.Comment], error) {
+	query := r.db.WithContext(ctx).Model(&models.Comment{}).Where("comments.status = ?", status).Joins("Author").Joins("Post")
+	if postID != 0 {
+		query = query.Where("comments.post_id = ?", postID)
+	}
+	return pagination.Paginate[models.Comment](query, "comments", params)
+}
+
+// UpdateStatus - меняет статус сразу у нескольких комментариев, возвращает количество измененных
+func (r *CommentRepositoryImpl) UpdateStatus(ctx context.Context, commentIDs []uint, status models.CommentStatus) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.Comment{}).Where("id IN ?", commentIDs).Update("status", status)
+	return result.RowsAffected, result.Error
+}
+
+func (r *CommentRepositoryImpl) FindCommentByID(ctx context.Context, commentID uint) (*models.Comment, error) {
+	var comment models.Comment
+	result := r.db.WithContext(ctx).First(&comment, commentID)
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	return comments, nil
+	return &comment, nil
 }
 
-func (r *CommentRepositoryImpl) CreateCommentByPostID(comment *models.Comment) error {
-	result := r.db.Create(comment)
+// FindCommentByIDForShare - комментарий, заблокированный от изменения и удаления до конца транзакции
+func (r *CommentRepositoryImpl) FindCommentByIDForShare(ctx context.Context, commentID uint) (*models.Comment, error) {
+	var comment models.Comment
+	result := uow.ForShare(r.db.WithContext(ctx)).First(&comment, commentID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &comment, nil
+}
+
+func (r *CommentRepositoryImpl) CreateCommentByPostID(ctx context.Context, comment *models.Comment) error {
+	result := r.db.WithContext(ctx).Create(comment)
 	if result.Error != nil {
 		return result.Error
 	}
 	return nil
 }
 
-func (r *CommentRepositoryImpl) UpdateCommentByCommentAndPostID(comment *models.Comment) error {
-	result := r.db.Model(&comment).Where("post_id = ?", comment.PostID).Updates(comment)
+func (r *CommentRepositoryImpl) UpdateCommentByCommentAndPostID(ctx context.Context, comment *models.Comment) error {
+	result := r.db.WithContext(ctx).Model(&comment).Where("post_id = ?", comment.PostID).Updates(comment)
 	if result.Error != nil {
 		return result.Error
 	}
 	return nil
 }
-func (r *CommentRepositoryImpl) DeleteCommentByCommentAndPostID(comment *models.Comment) error {
-	result := r.db.Model(&comment).Where("post_id = ?", comment.PostID).Delete(&comment)
+func (r *CommentRepositoryImpl) DeleteCommentByCommentAndPostID(ctx context.Context, comment *models.Comment) error {
+	result := r.db.WithContext(ctx).Model(&comment).Where("post_id = ?", comment.PostID).Delete(&comment)
 	if result.Error != nil {
 		return result.Error
 	}
 	return nil
 }
+
+// DeleteByUser - удаляет комментарии пользователя и все комментарии к его статьям
+func (r *CommentRepositoryImpl) DeleteByUser(ctx context.Context, userID uint) error {
+	authorPosts := r.db.WithContext(ctx).Model(&models.Post{}).Select("id").Where("author_id = ?", userID)
+	return r.db.WithContext(ctx).Where("author_id = ? OR post_id IN (?)", userID, authorPosts).Delete(&models.Comment{}).Error
+}
+
+// treeNodesSQL - видимые комментарии статьи и все их предки, в том числе удаленные и неодобренные
+const treeNodesSQL = `WITH RECURSIVE chain(id, parent_id) AS (
+	SELECT id, parent_id FROM comments
+	WHERE post_id = ? AND deleted_at IS NULL AND (status = ? OR author_id = ?)
+	UNION
+	SELECT comments.id, comments.parent_id FROM comments JOIN chain ON comments.id = chain.parent_id
+) SELECT id FROM chain`
+
+// treeNodes - узлы дерева комментариев: без предков видимые ответы нельзя было бы достичь от корня
+func (r *CommentRepositoryImpl) treeNodes(ctx context.Context, postID, viewerID uint) *gorm.DB {
+	return r.db.WithContext(ctx).Unscoped().Model(&models.Comment{}).
+		Where("comments.post_id = ? AND comments.id IN (?)", postID,
+			gorm.Expr(treeNodesSQL, postID, models.CommentApproved, viewerID))
+}
+
+// visibleTo - читатели видят только одобренные комментарии, автор видит и свои неодобренные
+func (r *CommentRepositoryImpl) visibleTo(ctx context.Context, viewerID uint) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&models.Comment{}).
+		Where("(comments.status = ? OR comments.author_id = ?)", models.CommentApproved, viewerID)
+}
@@ -1,38 +1,70 @@
 package comment
 
 import (
+	"context"
 	"errors"
+	"github.com/crafty-ezhik/blog-api/internal/config"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
 	"github.com/crafty-ezhik/blog-api/internal/post"
+	"github.com/crafty-ezhik/blog-api/internal/uow"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
 	"gorm.io/gorm"
 )
 
 //go:generate mockgen -source=service.go -destination=mocks/comment_service_mock.go
 
-var ErrPermissionDenied = errors.New("permission denied")
+var ErrPermissionDenied = policy.ErrForbidden
+
+var (
+	ErrParentNotFound   = errors.New("parent comment not found in this post")
+	ErrMaxDepthExceeded = errors.New("maximum reply depth exceeded")
+)
+
+// DefaultMaxDepth - максимальная вложенность ответов, если она не задана в конфигурации
+const DefaultMaxDepth = 5
 
 type CommentService interface {
-	GetCommentsByPostID(postID, userID uint) (*GetCommentsResponse, error)
-	CreateCommentByPostID(postID, authorID uint, comment *CreateCommentRequest) error
-	UpdateComment(commentID, PostID, userID uint, updatedFields *UpdateCommentRequest) error
-	DeleteComment(commentID, PostID, userID uint) error
+	GetCommentsByPostID(ctx context.Context, postID, userID, viewerID uint, params *pagination.Params) (*GetCommentsResponse, *pagination.Meta, error)
+	GetCommentTree(ctx context.Context, postID, viewerID uint, params *pagination.Params) (*GetCommentsResponse, *pagination.Meta, error)
+	GetModerationQueue(ctx context.Context, actor policy.Actor, status models.CommentStatus, postID uint, params *pagination.Params) (*GetCommentsResponse, *pagination.Meta, error)
+	ModerateComments(ctx context.Context, actor policy.Actor, commentIDs []uint, status models.CommentStatus) (int64, error)
+	CreateCommentByPostID(ctx context.Context, postID uint, actor policy.Actor, comment *CreateCommentRequest) error
+	UpdateComment(ctx context.Context, commentID, PostID uint, actor policy.Actor, updatedFields *UpdateCommentRequest) error
+	DeleteComment(ctx context.Context, commentID, PostID uint, actor policy.Actor) error
 }
 
 type CommentServiceImpl struct {
-	CommentRepo CommentRepository
-	PostRepo    post.PostRepository
+	CommentRepo     CommentRepository
+	PostRepo        post.PostRepository
+	uow             uow.UnitOfWork[TxRepositories]
+	maxDepth        int
+	requireApproval bool
 }
 
-func NewCommentService(commentRepo CommentRepository, postRepo post.PostRepository) *CommentServiceImpl {
+// NewCommentService - cfg.RequireApproval включает премодерацию для всех статей,
+// иначе она включается отдельно для статьи через Post.RequireCommentApproval
+func NewCommentService(commentRepo CommentRepository, postRepo post.PostRepository, unitOfWork uow.UnitOfWork[TxRepositories], cfg config.CommentsConfig) *CommentServiceImpl {
 	logger.Log.Debug("Init comment service")
+	if cfg.MaxDepth <= 0 {
+		cfg.MaxDepth = DefaultMaxDepth
+	}
 	return &CommentServiceImpl{
-		CommentRepo: commentRepo,
-		PostRepo:    postRepo,
+		CommentRepo:     commentRepo,
+		PostRepo:        postRepo,
+		uow:             unitOfWork,
+		maxDepth:        cfg.MaxDepth,
+		requireApproval: cfg.RequireApproval,
 	}
 }
 
-func (s *CommentServiceImpl) GetCommentsByPostID(postID, userID uint) (*GetCommentsResponse, error) {
+// GetCommentsByPostID - комментарии к статье, userID != 0 ограничивает их автором.
+// Неодобренные комментарии видны только их автору - viewerID
+func (s *CommentServiceImpl) GetCommentsByPostID(ctx context.Context, postID, userID, viewerID uint, params *pagination.Params) (*GetCommentsResponse, *pagination.Meta, error) {
+	if _, err := visiblePost(ctx, s.PostRepo, postID, viewerID); err != nil {
+		return nil, nil, err
+	}
 	findComment := &models.Comment{}
 
 	switch userID {
@@ -43,83 +75,255 @@ func (s *CommentServiceImpl) GetCommentsByPostID(postID, userID uint) (*GetComme
 		findComment.AuthorID = userID
 	}
 
-	commentList, err := s.CommentRepo.FindCommentsByPostID(findComment)
+	page, err := s.CommentRepo.FindCommentsByPostID(ctx, findComment, viewerID, params)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if len(commentList) == 0 {
-		return nil, gorm.ErrRecordNotFound
+	if page.Total == 0 {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+
+	result := &GetCommentsResponse{Comments: make([]GetCommentResponseBody, 0, len(page.Items))}
+	for _, comment := range page.Items {
+		result.Comments = append(result.Comments, toResponseBody(&comment))
+	}
+	return result, page.Meta(), nil
+}
+
+// GetCommentTree - страница корневых комментариев статьи с вложенными ответами.
+// Ответы загружаются по одному запросу на уровень, уровней не больше maxDepth. Удаленные и скрытые
+// комментарии, на которые есть видимые ответы, заменяются заглушкой, чтобы ответы не пропали из дерева
+func (s *CommentServiceImpl) GetCommentTree(ctx context.Context, postID, viewerID uint, params *pagination.Params) (*GetCommentsResponse, *pagination.Meta, error) {
+	if _, err := visiblePost(ctx, s.PostRepo, postID, viewerID); err != nil {
+		return nil, nil, err
+	}
+	page, err := s.CommentRepo.FindRootComments(ctx, postID, viewerID, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if page.Total == 0 {
+		return nil, nil, gorm.ErrRecordNotFound
 	}
 
-	result := &GetCommentsResponse{}
-	for _, comment := range commentList {
-		item := GetCommentResponseBody{
-			ID:         comment.ID,
-			Title:      comment.Title,
-			Content:    comment.Content,
-			AuthorName: comment.Author.Name,
-			PostTitle:  comment.Post.Title,
-			CreatedAt:  comment.CreatedAt,
+	children := make(map[uint][]models.Comment)
+	level := make([]uint, 0, len(page.Items))
+	for _, comment := range page.Items {
+		level = append(level, comment.ID)
+	}
+	for depth := 1; depth <= s.maxDepth && len(level) > 0; depth++ {
+		replies, err := s.CommentRepo.FindReplies(ctx, postID, viewerID, level)
+		if err != nil {
+			return nil, nil, err
+		}
+		level = level[:0]
+		for _, reply := range replies {
+			children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+			level = append(level, reply.ID)
 		}
-		result.Comments = append(result.Comments, item)
 	}
-	return result, nil
+
+	result := &GetCommentsResponse{Comments: make([]GetCommentResponseBody, 0, len(page.Items))}
+	for _, comment := range page.Items {
+		result.Comments = append(result.Comments, buildTree(&comment, children, viewerID))
+	}
+	return result, page.Meta(), nil
 }
 
-func (s *CommentServiceImpl) CreateCommentByPostID(postID, authorID uint, comment *CreateCommentRequest) error {
-	newComment := &models.Comment{
-		PostID:   postID,
-		AuthorID: authorID,
-		Title:    comment.Title,
-		Content:  comment.Content,
+func (s *CommentServiceImpl) CreateCommentByPostID(ctx context.Context, postID uint, actor policy.Actor, comment *CreateCommentRequest) error {
+	if err := policy.CanCreateComment(actor); err != nil {
+		return err
 	}
 
-	err := s.CommentRepo.CreateCommentByPostID(newComment)
+	// Статья и родительский комментарий читаются с блокировкой FOR SHARE в той же транзакции, что и создание,
+	// поэтому удаление или снятие статьи с публикации ждет, пока комментарий будет создан
+	return s.uow.Do(ctx, func(tx *uow.Tx[TxRepositories]) error {
+		existedPost, err := tx.Repos.Posts.FindByIDForShare(ctx, postID)
+		if err != nil {
+			return err
+		}
+		if !existedPost.VisibleTo(actor.UserID) {
+			return gorm.ErrRecordNotFound
+		}
+
+		newComment := &models.Comment{
+			PostID:   postID,
+			AuthorID: actor.UserID,
+			Title:    comment.Title,
+			Content:  comment.Content,
+			Status:   s.initialStatus(actor, existedPost),
+		}
+
+		// Ответ допускается только на комментарий той же статьи и не глубже maxDepth
+		if comment.ParentID != nil {
+			parent, err := tx.Repos.Comments.FindCommentByIDForShare(ctx, *comment.ParentID)
+			if err == nil && parent.PostID != postID {
+				err = gorm.ErrRecordNotFound
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrParentNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if parent.Depth+1 > s.maxDepth {
+				return ErrMaxDepthExceeded
+			}
+			newComment.ParentID = &parent.ID
+			newComment.Depth = parent.Depth + 1
+		}
+
+		return tx.Repos.Comments.CreateCommentByPostID(ctx, newComment)
+	})
+}
+
+// initialStatus - при включенной премодерации комментарий ждет одобрения, если его оставил
+// не модератор и не автор статьи
+func (s *CommentServiceImpl) initialStatus(actor policy.Actor, post *models.Post) models.CommentStatus {
+	if !s.requireApproval && !post.RequireCommentApproval {
+		return models.CommentApproved
+	}
+	if post.AuthorID == actor.UserID || actor.Can(policy.CommentModerate) {
+		return models.CommentApproved
+	}
+	return models.CommentPending
+}
+
+// GetModerationQueue - комментарии с указанным статусом, по умолчанию ожидающие одобрения
+func (s *CommentServiceImpl) GetModerationQueue(ctx context.Context, actor policy.Actor, status models.CommentStatus, postID uint, params *pagination.Params) (*GetCommentsResponse, *pagination.Meta, error) {
+	if err := policy.CanModerateComments(actor); err != nil {
+		return nil, nil, err
+	}
+	page, err := s.CommentRepo.FindCommentsByStatus(ctx, status, postID, params)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return nil
+
+	result := &GetCommentsResponse{Comments: make([]GetCommentResponseBody, 0, len(page.Items))}
+	for _, comment := range page.Items {
+		result.Comments = append(result.Comments, toResponseBody(&comment))
+	}
+	return result, page.Meta(), nil
+}
+
+func (s *CommentServiceImpl) ModerateComments(ctx context.Context, actor policy.Actor, commentIDs []uint, status models.CommentStatus) (int64, error) {
+	if err := policy.CanModerateComments(actor); err != nil {
+		return 0, err
+	}
+	return s.CommentRepo.UpdateStatus(ctx, commentIDs, status)
 }
 
-func (s *CommentServiceImpl) UpdateComment(commentID, postID, userID uint, fields *UpdateCommentRequest) error {
+func (s *CommentServiceImpl) UpdateComment(ctx context.Context, commentID, postID uint, actor policy.Actor, fields *UpdateCommentRequest) error {
+	existedComment, err := findComment(ctx, s.CommentRepo, commentID, postID)
+	if err != nil {
+		return err
+	}
+	if err = policy.CanUpdateComment(actor, existedComment); err != nil {
+		return err
+	}
+
 	comment := &models.Comment{
 		ID:      commentID,
 		PostID:  postID,
 		Content: fields.Content,
 	}
-	if ok, err := s.checkPermission(postID, userID); err != nil || !ok {
-		return ErrPermissionDenied
+
+	// При премодерации измененный текст проверяется заново, иначе одобренный комментарий
+	// можно было бы заменить любым текстом. Отклоненные комментарии правка не возвращает в очередь
+	if existedComment.Status == models.CommentApproved {
+		existedPost, err := s.PostRepo.FindByID(ctx, postID)
+		if err != nil {
+			return err
+		}
+		comment.Status = s.initialStatus(actor, existedPost)
 	}
-	err := s.CommentRepo.UpdateCommentByCommentAndPostID(comment)
+	err = s.CommentRepo.UpdateCommentByCommentAndPostID(ctx, comment)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *CommentServiceImpl) DeleteComment(commentID, PostID, userID uint) error {
-	comment := &models.Comment{
-		ID:     commentID,
-		PostID: PostID,
+func (s *CommentServiceImpl) DeleteComment(ctx context.Context, commentID, postID uint, actor policy.Actor) error {
+	existedComment, err := findComment(ctx, s.CommentRepo, commentID, postID)
+	if err != nil {
+		return err
+	}
+	postCheck, err := s.PostRepo.FindByID(ctx, postID)
+	if err != nil {
+		return err
 	}
-	if ok, err := s.checkPermission(PostID, userID); err != nil || !ok {
-		return ErrPermissionDenied
+	if err = policy.CanDeleteComment(actor, existedComment, postCheck); err != nil {
+		return err
 	}
 
-	err := s.CommentRepo.DeleteCommentByCommentAndPostID(comment)
+	comment := &models.Comment{
+		ID:     commentID,
+		PostID: postID,
+	}
+	err = s.CommentRepo.DeleteCommentByCommentAndPostID(ctx, comment)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *CommentServiceImpl) checkPermission(postID, userID uint) (bool, error) {
-	postCheck, err := s.PostRepo.FindByID(postID)
+// visiblePost - статья, к которой пользователь может читать и писать комментарии.
+// Неопубликованная чужая статья для него не существует, как и в PostService.GetPostById
+func visiblePost(ctx context.Context, repo post.PostRepository, postID, viewerID uint) (*models.Post, error) {
+	existedPost, err := repo.FindByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if !existedPost.VisibleTo(viewerID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return existedPost, nil
+}
+
+// findComment - ищет комментарий и проверяет, что он относится к указанному посту
+func findComment(ctx context.Context, repo CommentRepository, commentID, postID uint) (*models.Comment, error) {
+	existedComment, err := repo.FindCommentByID(ctx, commentID)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	if existedComment.PostID != postID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return existedComment, nil
+}
+
+func toResponseBody(comment *models.Comment) GetCommentResponseBody {
+	return GetCommentResponseBody{
+		ID:         comment.ID,
+		Title:      comment.Title,
+		Content:    comment.Content,
+		AuthorName: comment.Author.Name,
+		PostTitle:  comment.Post.Title,
+		ParentID:   comment.ParentID,
+		Depth:      comment.Depth,
+		Status:     string(comment.Status),
+		CreatedAt:  comment.CreatedAt,
+	}
+}
+
+// DeletedPlaceholder - текст вместо удаленного или скрытого комментария в дереве
+const DeletedPlaceholder = "[deleted]"
+
+func buildTree(comment *models.Comment, children map[uint][]models.Comment, viewerID uint) GetCommentResponseBody {
+	body := toResponseBody(comment)
+	if !comment.VisibleTo(viewerID) {
+		body = GetCommentResponseBody{
+			ID:        comment.ID,
+			Content:   DeletedPlaceholder,
+			PostTitle: comment.Post.Title,
+			ParentID:  comment.ParentID,
+			Depth:     comment.Depth,
+			CreatedAt: comment.CreatedAt,
+			Deleted:   true,
+		}
 	}
-	if postCheck.AuthorID != userID {
-		return false, nil
+	for _, reply := range children[comment.ID] {
+		body.Replies = append(body.Replies, buildTree(&reply, children, viewerID))
 	}
-	return true, nil
+	return body
 }
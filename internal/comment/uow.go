@@ -0,0 +1,24 @@
+package comment
+
+import (
+	"github.com/crafty-ezhik/blog-api/internal/post"
+	"github.com/crafty-ezhik/blog-api/internal/uow"
+	"gorm.io/gorm"
+)
+
+// TxRepositories - репозитории, привязанные к одной транзакции
+type TxRepositories struct {
+	Posts    post.PostRepository
+	Comments CommentRepository
+}
+
+// NewUnitOfWork - транзакции над статьями и комментариями к ним
+func NewUnitOfWork(db *gorm.DB) *uow.TxManager[TxRepositories] {
+	posts, comments := post.NewPostRepository(db), NewCommentRepository(db)
+	return uow.NewTxManager(db, func(tx *gorm.DB) TxRepositories {
+		return TxRepositories{
+			Posts:    posts.WithTx(tx),
+			Comments: comments.WithTx(tx),
+		}
+	})
+}
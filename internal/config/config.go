@@ -1,9 +1,11 @@
 package config
 
 import (
+	"errors"
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
+	"io/fs"
 	"log"
 	"os"
 	"strings"
@@ -11,11 +13,17 @@ import (
 )
 
 type Config struct {
-	Auth   AuthConfig   `mapstructure:"jwt"`
-	DB     DbConfig     `mapstructure:"database"`
-	Server ServerConfig `mapstructure:"server"`
-	Redis  RedisConfig  `mapstructure:"redis"`
-	Log    Log          `mapstructure:"log"`
+	Auth      AuthConfig      `mapstructure:"jwt"`
+	DB        DbConfig        `mapstructure:"database"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Redis     RedisConfig     `mapstructure:"redis"`
+	Log       Log             `mapstructure:"log"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler"`
+	Comments  CommentsConfig  `mapstructure:"comments"`
+	Mail      MailConfig      `mapstructure:"mail"`
+	OAuth     OAuthConfig     `mapstructure:"oauth"`
+	Lockout   LockoutConfig   `mapstructure:"lockout"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
 }
 
 type AuthConfig struct {
@@ -23,26 +31,131 @@ type AuthConfig struct {
 	SecretKey  string        `mapstructure:"secret_key"`
 	AccessTTL  time.Duration `mapstructure:"access_ttl"`
 	RefreshTTL time.Duration `mapstructure:"refresh_ttl"`
+
+	VerifyEmailTTL       time.Duration `mapstructure:"verify_email_ttl"`       // время жизни ссылки подтверждения email
+	ResetPasswordTTL     time.Duration `mapstructure:"reset_password_ttl"`     // время жизни ссылки сброса пароля
+	RequireVerifiedEmail bool          `mapstructure:"require_verified_email"` // запрет входа без подтвержденного email
+	BootstrapAdminEmail  string        `mapstructure:"bootstrap_admin_email"`  // пользователь, которому при запуске выдается роль admin
+	MFATTL               time.Duration `mapstructure:"mfa_ttl"`                // время на ввод кода 2FA после пароля
+	TOTPIssuer           string        `mapstructure:"totp_issuer"`            // имя сервиса в приложении-аутентификаторе
+
+	Algorithm        string                  `mapstructure:"algorithm"`         // HS256 (по умолчанию), RS256, ES256 или EdDSA
+	KeyID            string                  `mapstructure:"key_id"`            // kid текущего ключа подписи
+	PrivateKeyFile   string                  `mapstructure:"private_key_file"`  // PEM с закрытым ключом для RS256, ES256, EdDSA
+	VerificationKeys []VerificationKeyConfig `mapstructure:"verification_keys"` // ключи, токены которых еще принимаются после ротации
+
+	Issuer   string        `mapstructure:"issuer"`   // claim iss
+	Audience string        `mapstructure:"audience"` // claim aud
+	Leeway   time.Duration `mapstructure:"leeway"`   // допустимое расхождение часов при проверке exp, nbf, iat
+}
+
+type VerificationKeyConfig struct {
+	ID        string `mapstructure:"id"`
+	Algorithm string `mapstructure:"algorithm"`
+	File      string `mapstructure:"file"` // PEM с открытым ключом
 }
 
 type DbConfig struct {
+	Driver   string `mapstructure:"driver"` // postgres (по умолчанию) или sqlite
+	Path     string `mapstructure:"path"`   // файл базы SQLite или :memory:
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	Database string `mapstructure:"database"`
 	Username string `mapstructure:"username"`
 	Password string `mapstructure:"password"`
 	SSLMode  string `mapstructure:"sslmode"`
+
+	MaxOpen         int           `mapstructure:"max_open"`           // максимум открытых соединений, 0 - без ограничения
+	MaxIdle         int           `mapstructure:"max_idle"`           // максимум простаивающих соединений в пуле
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`  // соединение закрывается после этого срока, 0 - не закрывается
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"` // простаивающее соединение закрывается после этого срока
+
+	ConnectAttempts int           `mapstructure:"connect_attempts"` // попыток подключения при старте
+	ConnectBackoff  time.Duration `mapstructure:"connect_backoff"`  // пауза после первой неудачной попытки, дальше удваивается
+
+	Replicas []ReplicaConfig `mapstructure:"replicas"` // реплики для чтения списков и поиска
+}
+
+// ReplicaConfig - реплика для чтения. Пользователь, пароль, база и sslmode берутся из основной БД
+type ReplicaConfig struct {
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+	Path string `mapstructure:"path"` // файл базы для SQLite
 }
 
 type ServerConfig struct {
-	Port int `mapstructure:"port"`
+	Port           int           `mapstructure:"port"`
+	HealthTimeout  time.Duration `mapstructure:"health_timeout"`  // время на проверку одной зависимости в /readyz и /health
+	ShutdownDelay  time.Duration `mapstructure:"shutdown_delay"`  // сколько /readyz отвечает 503 перед остановкой сервера
+	RequestTimeout time.Duration `mapstructure:"request_timeout"` // дедлайн запроса к API, по истечении запросы к БД и Redis прерываются. 0 - без ограничения
 }
 
 type RedisConfig struct {
+	Driver   string `mapstructure:"driver"` // redis (по умолчанию) или memory - хранение в памяти процесса без Redis
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	Password string `mapstructure:"password"`
 	Db       int    `mapstructure:"db"`
+
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"` // как часто удаляются истекшие записи в режиме memory
+}
+
+type SchedulerConfig struct {
+	Interval time.Duration `mapstructure:"interval"` // период публикации запланированных статей
+}
+
+type CommentsConfig struct {
+	MaxDepth        int  `mapstructure:"max_depth"`        // максимальная вложенность ответов
+	RequireApproval bool `mapstructure:"require_approval"` // премодерация комментариев ко всем статьям
+}
+
+type MailConfig struct {
+	Driver   string `mapstructure:"driver"` // smtp, file или memory
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+	FilePath string `mapstructure:"file_path"` // файл для драйвера file
+	BaseURL  string `mapstructure:"base_url"`  // адрес фронтенда для ссылок в письмах
+}
+
+type OAuthConfig struct {
+	StateTTL    time.Duration                 `mapstructure:"state_ttl"`    // время на вход у внешнего провайдера
+	HTTPTimeout time.Duration                 `mapstructure:"http_timeout"` // время на один запрос к провайдеру
+	Providers   map[string]OIDCProviderConfig `mapstructure:"providers"`    // ключ - имя провайдера в URL
+}
+
+// OIDCProviderConfig - внешний OpenID Connect провайдер. Адреса endpoints берутся из discovery документа издателя
+type OIDCProviderConfig struct {
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"` // адрес /auth/oauth/:provider/callback
+	Scopes       []string `mapstructure:"scopes"`       // по умолчанию openid, email, profile
+}
+
+// LockoutConfig - защита входа от перебора паролей
+type LockoutConfig struct {
+	MaxAttempts   int           `mapstructure:"max_attempts"`    // неудачных входов в аккаунт до блокировки
+	MaxIPAttempts int           `mapstructure:"max_ip_attempts"` // неудачных входов с одного IP до блокировки
+	Window        time.Duration `mapstructure:"window"`          // сколько помнятся неудачи после последней (плюс max_delay)
+	BaseDelay     time.Duration `mapstructure:"base_delay"`      // первая блокировка, каждая следующая вдвое дольше
+	MaxDelay      time.Duration `mapstructure:"max_delay"`       // самая долгая блокировка
+}
+
+// RateLimitConfig - ограничения частоты запросов по группам маршрутов
+type RateLimitConfig struct {
+	Posts    RateLimitPolicy `mapstructure:"posts"`    // создание статей
+	Comments RateLimitPolicy `mapstructure:"comments"` // создание комментариев
+	Search   RateLimitPolicy `mapstructure:"search"`   // поиск статей
+	Login    RateLimitPolicy `mapstructure:"login"`    // вход по паролю и код 2FA, общий лимит на IP
+}
+
+type RateLimitPolicy struct {
+	Limit     int           `mapstructure:"limit"`     // запросов за window, 0 - без ограничения
+	Window    time.Duration `mapstructure:"window"`    // по умолчанию минута
+	Algorithm string        `mapstructure:"algorithm"` // sliding_window (по умолчанию) или token_bucket
 }
 
 type Log struct {
@@ -53,7 +166,8 @@ type Log struct {
 
 func LoadConfig(path string) (*Config, error) {
 	// Подгрузка переменных окружения
-	if err := godotenv.Load(); err != nil {
+	// .env необязателен: переменные могут быть заданы окружением, а тестам хватает YAML
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatal("Error loading .env file")
 		return nil, err
 	}
@@ -0,0 +1,50 @@
+// Package health - проверки живости и готовности экземпляра: /healthz, /readyz и подробный отчет /health
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/crafty-ezhik/blog-api/pkg/migrate"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Check - проверка одной зависимости. Ошибка означает, что зависимость недоступна
+type Check func(ctx context.Context) error
+
+// Dependency - зависимость, без которой экземпляр не готов принимать запросы
+type Dependency struct {
+	Name  string
+	Check Check
+}
+
+// Database - ping основной БД
+func Database(db *gorm.DB) Dependency {
+	return Dependency{Name: "database", Check: func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}}
+}
+
+// Redis - ping Redis. Если хранилища работают в памяти процесса (rdb == nil), проверять нечего
+func Redis(rdb *redis.Client) Dependency {
+	return Dependency{Name: "redis", Check: func(ctx context.Context) error {
+		if rdb == nil {
+			return nil
+		}
+		return rdb.Ping(ctx).Err()
+	}}
+}
+
+// Migrations - схема БД на версии, которую ожидает сборка
+func Migrations(migrator *migrate.Migrator) Dependency {
+	return Dependency{Name: "migrations", Check: func(ctx context.Context) error {
+		if migrator == nil {
+			return errors.New("migrator is not configured")
+		}
+		return migrator.Verify(ctx)
+	}}
+}
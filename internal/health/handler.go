@@ -0,0 +1,151 @@
+package health
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout - время на проверку одной зависимости, если server.health_timeout не задан
+const DefaultTimeout = 2 * time.Second
+
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+type HealthHandler interface {
+	Live(c *fiber.Ctx) error
+	Ready(c *fiber.Ctx) error
+	Health(c *fiber.Ctx) error
+}
+
+// CheckResult - результат проверки одной зависимости в отчете /health
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Report - подробный отчет /health
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+type HealthHandlerImpl struct {
+	Dependencies []Dependency
+	Timeout      time.Duration
+
+	shuttingDown atomic.Bool
+}
+
+func NewHealthHandler(timeout time.Duration, dependencies ...Dependency) *HealthHandlerImpl {
+	logger.Log.Debug("Init health handler")
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &HealthHandlerImpl{
+		Dependencies: dependencies,
+		Timeout:      timeout,
+	}
+}
+
+// Shutdown - переводит экземпляр в режим остановки: /readyz и /health отвечают 503,
+// чтобы балансировщик перестал присылать новые запросы, пока обрабатываются текущие
+func (h *HealthHandlerImpl) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// Live - процесс жив и обрабатывает запросы. Зависимости не проверяются,
+// чтобы недоступность БД не приводила к перезапуску экземпляра
+func (h *HealthHandlerImpl) Live(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"status":  StatusOK,
+	})
+}
+
+// Ready - экземпляр готов принимать запросы: все зависимости доступны и он не останавливается
+func (h *HealthHandlerImpl) Ready(c *fiber.Ctx) error {
+	report := h.check(c.UserContext())
+	if report.Status != StatusOK {
+		failed := make([]string, 0, len(report.Checks))
+		for _, result := range report.Checks {
+			if result.Status != StatusOK {
+				failed = append(failed, result.Name)
+			}
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"success": false,
+			"status":  report.Status,
+			"failed":  failed,
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"status":  StatusOK,
+	})
+}
+
+// Health - результат и задержка каждой проверки. Маршрут открыт без авторизации,
+// поэтому текст ошибок (адреса, версии схемы) только пишется в лог и наружу не отдается
+func (h *HealthHandlerImpl) Health(c *fiber.Ctx) error {
+	report := h.check(c.UserContext())
+	status := fiber.StatusOK
+	if report.Status != StatusOK {
+		status = fiber.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": report.Status == StatusOK,
+		"data":    report,
+	})
+}
+
+// check - проверяет зависимости параллельно, каждую не дольше Timeout
+func (h *HealthHandlerImpl) check(ctx context.Context) Report {
+	results := make([]CheckResult, len(h.Dependencies))
+	var wg sync.WaitGroup
+	for i, dependency := range h.Dependencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.run(ctx, dependency)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	if h.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+func (h *HealthHandlerImpl) run(ctx context.Context, dependency Dependency) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := dependency.Check(ctx)
+	result := CheckResult{
+		Name:      dependency.Name,
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		logger.Log.Warn("Health check failed", zap.String("dependency", dependency.Name), zap.Error(err))
+		result.Status = StatusFail
+	}
+	return result
+}
@@ -0,0 +1,132 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/crafty-ezhik/blog-api/internal/health"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func setup(dependencies ...health.Dependency) (*fiber.App, *health.HealthHandlerImpl) {
+	logger.Log = zap.NewNop()
+	handler := health.NewHealthHandler(50*time.Millisecond, dependencies...)
+	app := fiber.New()
+	app.Get("/healthz", handler.Live)
+	app.Get("/readyz", handler.Ready)
+	app.Get("/health", handler.Health)
+	return app, handler
+}
+
+func ok(context.Context) error { return nil }
+
+func get(t *testing.T, app *fiber.App, target string) (int, string) {
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestHealthHandlerImpl_Ready(t *testing.T) {
+	tests := []struct {
+		name               string
+		dependencies       []health.Dependency
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "All dependencies available",
+			dependencies:       []health.Dependency{{Name: "database", Check: ok}, {Name: "redis", Check: ok}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"status":"ok"`,
+		},
+		{
+			name: "Dependency unavailable",
+			dependencies: []health.Dependency{{Name: "database", Check: ok}, {Name: "redis", Check: func(context.Context) error {
+				return errors.New("connection refused")
+			}}},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedBody:       `"failed":["redis"]`,
+		},
+		{
+			name: "Dependency timed out",
+			dependencies: []health.Dependency{{Name: "database", Check: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}}},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedBody:       `"failed":["database"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := setup(tt.dependencies...)
+			status, body := get(t, app, "/readyz")
+			assert.Equal(t, tt.expectedStatusCode, status)
+			assert.Contains(t, body, tt.expectedBody)
+		})
+	}
+}
+
+func TestHealthHandlerImpl_Shutdown(t *testing.T) {
+	app, handler := setup(health.Dependency{Name: "database", Check: ok})
+
+	status, _ := get(t, app, "/readyz")
+	assert.Equal(t, http.StatusOK, status)
+
+	handler.Shutdown()
+
+	status, body := get(t, app, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Contains(t, body, `"status":"shutting_down"`)
+
+	// Процесс все еще жив и дорабатывает текущие запросы
+	status, _ = get(t, app, "/healthz")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestHealthHandlerImpl_Health(t *testing.T) {
+	app, _ := setup(
+		health.Dependency{Name: "database", Check: func(context.Context) error {
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}},
+		health.Dependency{Name: "migrations", Check: func(context.Context) error {
+			return errors.New("migration is not applied: 0002_posts_search_vector")
+		}},
+	)
+
+	status, body := get(t, app, "/health")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	var resp struct {
+		Success bool          `json:"success"`
+		Data    health.Report `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &resp))
+	assert.False(t, resp.Success)
+	assert.Equal(t, health.StatusFail, resp.Data.Status)
+	require.Len(t, resp.Data.Checks, 2)
+
+	assert.Equal(t, "database", resp.Data.Checks[0].Name)
+	assert.Equal(t, health.StatusOK, resp.Data.Checks[0].Status)
+	assert.GreaterOrEqual(t, resp.Data.Checks[0].LatencyMS, 5.0)
+
+	assert.Equal(t, "migrations", resp.Data.Checks[1].Name)
+	assert.Equal(t, health.StatusFail, resp.Data.Checks[1].Status)
+	assert.NotContains(t, body, "0002_posts_search_vector")
+}
+
+func TestRedis_Disabled(t *testing.T) {
+	assert.NoError(t, health.Redis(nil).Check(context.Background()))
+}
@@ -5,6 +5,15 @@ import (
 	"time"
 )
 
+type CommentStatus string
+
+const (
+	CommentPending  CommentStatus = "pending"
+	CommentApproved CommentStatus = "approved"
+	CommentRejected CommentStatus = "rejected"
+	CommentSpam     CommentStatus = "spam"
+)
+
 type Comment struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
 	Title     string         `gorm:"size:255" json:"title"`
@@ -13,7 +22,15 @@ type Comment struct {
 	Author    User           `gorm:"foreignKey:AuthorID" json:"author"` // Для получения автора через Preload
 	PostID    uint           `gorm:"index" json:"-"`                    // внешний ключ на Post.ID
 	Post      Post           `gorm:"foreignKey:PostID" json:"post"`     // Для получения поста через Preload
+	ParentID  *uint          `gorm:"index" json:"parent_id,omitempty"`  // комментарий, на который дан ответ
+	Depth     int            `json:"depth"`                             // уровень вложенности, у корневых 0
+	Status    CommentStatus  `gorm:"size:16;default:approved;index" json:"status"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
+
+// VisibleTo - читатели видят только одобренные комментарии, автор видит и свои неодобренные
+func (c *Comment) VisibleTo(viewerID uint) bool {
+	return !c.DeletedAt.Valid && (c.Status == CommentApproved || c.AuthorID == viewerID)
+}
@@ -5,13 +5,36 @@ import (
 	"time"
 )
 
+type PostStatus string
+
+const (
+	PostDraft     PostStatus = "draft"
+	PostPublished PostStatus = "published"
+	PostScheduled PostStatus = "scheduled"
+	PostArchived  PostStatus = "archived"
+)
+
 type Post struct {
 	ID       uint   `gorm:"primarykey" json:"id"`
 	Title    string `gorm:"size:255" json:"title"`
 	Text     string `gorm:"type:text" json:"text"`
 	AuthorID uint   `gorm:"index" json:"author_id"` // внешний ключ на User.ID
+	// Статьи, созданные до появления статусов, считаются опубликованными
+	Status      PostStatus `gorm:"size:16;default:published;index" json:"status"`
+	PublishedAt *time.Time `gorm:"index" json:"published_at,omitempty"` // для scheduled - время будущей публикации
+	// Новые комментарии к статье попадают в очередь модерации
+	RequireCommentApproval bool `json:"require_comment_approval"`
 	//Author    User           `gorm:"foreignKey:AuthorID" json:"author,omitempty"` // загружается через Preload
 	CreatedAt time.Time      `json:"created_at,omitempty"`
 	UpdatedAt time.Time      `json:"updated_at,omitempty"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Многие ко многим
+	Tags       []Tag      `gorm:"many2many:post_tags" json:"tags,omitempty"`
+	Categories []Category `gorm:"many2many:post_categories" json:"categories,omitempty"`
+}
+
+// VisibleTo - опубликованная статья видна всем, черновики, запланированные и архивные - только автору
+func (p *Post) VisibleTo(viewerID uint) bool {
+	return p.Status == PostPublished || p.AuthorID == viewerID
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PostRevision - сохраненная версия заголовка и текста статьи. Revision нумеруется с 1 отдельно для каждой статьи,
+// EditorID - пользователь, после правки которого статья приняла этот вид
+type PostRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	PostID    uint      `gorm:"uniqueIndex:idx_post_revision" json:"post_id"`
+	Revision  int       `gorm:"uniqueIndex:idx_post_revision" json:"revision"`
+	Title     string    `gorm:"size:255" json:"title"`
+	Text      string    `gorm:"type:text" json:"text"`
+	EditorID  uint      `gorm:"index" json:"editor_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
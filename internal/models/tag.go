@@ -0,0 +1,40 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:50" json:"name"`
+	Slug      string    `gorm:"size:50;uniqueIndex" json:"slug"`
+	CreatedAt time.Time `json:"-"`
+}
+
+type Category struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:50" json:"name"`
+	Slug      string    `gorm:"size:50;uniqueIndex" json:"slug"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// Slugify - приводит название тега или категории к slug: нижний регистр, буквы и цифры,
+// остальные символы заменяются одним дефисом ("Go Fiber!" -> "go-fiber")
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
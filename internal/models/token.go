@@ -0,0 +1,23 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// PersonalAccessToken - долгоживущий токен для скриптов и CI. Хранится только хэш секрета
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	TokenHash  string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Hint       string     `gorm:"size:16" json:"hint"` // начало секрета, чтобы отличать токены в списке
+	Scopes     string     `gorm:"not null" json:"-"`   // права через пробел
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (t *PersonalAccessToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
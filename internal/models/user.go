@@ -5,17 +5,53 @@ import (
 	"time"
 )
 
+type Role string
+
+const (
+	RoleReader    Role = "reader"
+	RoleAuthor    Role = "author"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `json:"name"`
-	Email     string         `gorm:"unique" json:"email"`
-	Password  string         `json:"-"`
-	Age       int            `json:"age,omitempty"`
-	CreatedAt time.Time      `json:"created_at,omitempty"`
-	UpdatedAt time.Time      `json:"updated_at,omitempty"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	Name               string         `json:"name"`
+	Email              string         `gorm:"unique" json:"email"`
+	Password           string         `json:"-"`
+	Age                int            `json:"age,omitempty"`
+	Role               Role           `gorm:"size:32;default:author" json:"role"`
+	EmailVerifiedAt    *time.Time     `json:"email_verified_at,omitempty"` // nil - email не подтвержден
+	PendingEmail       *string        `json:"-"`                           // новый email, ожидающий подтверждения
+	PendingEmailToken  *string        `gorm:"size:64" json:"-"`            // хэш последней ссылки подтверждения PendingEmail
+	PasswordResetToken *string        `gorm:"size:64" json:"-"`            // хэш последней ссылки сброса пароля, прежние ссылки недействительны
+	TOTPSecret         string         `json:"-"`                           // секрет TOTP, задается при подключении 2FA
+	TOTPEnabled        bool           `json:"totp_enabled"`                // 2FA включается после подтверждения первым кодом
+	TOTPLastStep       *int64         `json:"-"`                           // интервал последнего принятого кода TOTP, повторно он не принимается
+	CreatedAt          time.Time      `json:"created_at,omitempty"`
+	UpdatedAt          time.Time      `json:"updated_at,omitempty"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Один ко многим
 	Posts    []Post    `gorm:"foreignKey:AuthorID" json:"posts"`    // один пользователь - много постов
 	Comments []Comment `gorm:"foreignKey:AuthorID" json:"comments"` // один пользователь - много комментариев
 }
+
+// RecoveryCode - резервный код для входа без приложения-аутентификатора. Хранится только хэш
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// UserIdentity - учетная запись пользователя у внешнего OpenID Connect провайдера
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Provider  string    `gorm:"size:64;not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject" json:"-"` // claim sub провайдера
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
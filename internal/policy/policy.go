@@ -0,0 +1,203 @@
+package policy
+
+import (
+	"errors"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrForbidden = errors.New("permission denied")
+
+type Permission string
+
+const (
+	PostCreate    Permission = "posts:create"
+	PostUpdateAny Permission = "posts:update:any"
+	PostDeleteAny Permission = "posts:delete:any"
+
+	CommentCreate    Permission = "comments:create"
+	CommentUpdateAny Permission = "comments:update:any"
+	CommentDeleteAny Permission = "comments:delete:any"
+	CommentModerate  Permission = "comments:moderate"
+
+	UserDeleteAny   Permission = "users:delete:any"
+	UserRolesUpdate Permission = "users:roles:update"
+)
+
+// Scope - право personal access токена. Токен ограничивает действия пользователя,
+// но не расширяет их: проверки ролей и владения ресурсами выполняются как обычно
+type Scope string
+
+const (
+	ScopePostsRead     Scope = "posts:read"
+	ScopePostsWrite    Scope = "posts:write"
+	ScopeCommentsRead  Scope = "comments:read"
+	ScopeCommentsWrite Scope = "comments:write"
+	ScopeUsersRead     Scope = "users:read"
+	ScopeUsersWrite    Scope = "users:write"
+)
+
+// Scopes - все права, которые можно выдать personal access токену
+var Scopes = []Scope{
+	ScopePostsRead,
+	ScopePostsWrite,
+	ScopeCommentsRead,
+	ScopeCommentsWrite,
+	ScopeUsersRead,
+	ScopeUsersWrite,
+}
+
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if string(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// rolePermissions - набор прав для каждой роли. Права на собственные ресурсы (свой пост, свой комментарий,
+// свой аккаунт) выдаются владельцу отдельно и здесь не перечисляются
+var rolePermissions = map[models.Role][]Permission{
+	models.RoleReader: {
+		CommentCreate,
+	},
+	models.RoleAuthor: {
+		CommentCreate,
+		PostCreate,
+	},
+	models.RoleModerator: {
+		CommentCreate,
+		PostCreate,
+		PostDeleteAny,
+		CommentDeleteAny,
+		CommentModerate,
+	},
+	models.RoleAdmin: {
+		CommentCreate,
+		PostCreate,
+		PostUpdateAny,
+		PostDeleteAny,
+		CommentUpdateAny,
+		CommentDeleteAny,
+		CommentModerate,
+		UserDeleteAny,
+		UserRolesUpdate,
+	},
+}
+
+// Actor - пользователь, от имени которого выполняется операция
+type Actor struct {
+	UserID uint
+	Role   models.Role
+}
+
+// ActorFromCtx собирает Actor из значений, установленных AuthMiddleware и RoleMiddleware
+func ActorFromCtx(c *fiber.Ctx) Actor {
+	userID, _ := c.Locals(middleware.UserIDKey).(uint)
+	role, _ := c.Locals(middleware.RoleKey).(string)
+	return Actor{
+		UserID: userID,
+		Role:   models.Role(role),
+	}
+}
+
+func IsValidRole(role models.Role) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func HasPermission(role models.Role, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Actor) Can(permission Permission) bool {
+	return HasPermission(a.Role, permission)
+}
+
+// region: Posts
+func CanCreatePost(actor Actor) error {
+	if !actor.Can(PostCreate) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func CanUpdatePost(actor Actor, post *models.Post) error {
+	if post.AuthorID == actor.UserID || actor.Can(PostUpdateAny) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func CanDeletePost(actor Actor, post *models.Post) error {
+	if post.AuthorID == actor.UserID || actor.Can(PostDeleteAny) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// endregion
+
+// region: Comments
+func CanCreateComment(actor Actor) error {
+	if !actor.Can(CommentCreate) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CanUpdateComment - редактировать комментарий может только его автор или пользователь с правом CommentUpdateAny
+func CanUpdateComment(actor Actor, comment *models.Comment) error {
+	if comment.AuthorID == actor.UserID || actor.Can(CommentUpdateAny) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanDeleteComment - удалить комментарий может его автор, автор поста или пользователь с правом CommentDeleteAny
+func CanDeleteComment(actor Actor, comment *models.Comment, post *models.Post) error {
+	if comment.AuthorID == actor.UserID || post.AuthorID == actor.UserID || actor.Can(CommentDeleteAny) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func CanModerateComments(actor Actor) error {
+	if !actor.Can(CommentModerate) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// endregion
+
+// region: Users
+func CanDeleteUser(actor Actor, userID uint) error {
+	if userID == actor.UserID || actor.Can(UserDeleteAny) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func CanUpdateRole(actor Actor) error {
+	if !actor.Can(UserRolesUpdate) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// endregion
+
+// Checker - реализация middleware.PermissionChecker поверх таблицы прав
+type Checker struct{}
+
+func (Checker) HasPermission(role, permission string) bool {
+	return HasPermission(models.Role(role), Permission(permission))
+}
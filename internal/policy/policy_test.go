@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostPolicy(t *testing.T) {
+	post := &models.Post{ID: 1, AuthorID: 1}
+
+	tests := []struct {
+		name        string
+		actor       Actor
+		expectedErr error
+		check       func(actor Actor) error
+	}{
+		{
+			name:  "Author updates own post",
+			actor: Actor{UserID: 1, Role: models.RoleAuthor},
+			check: func(actor Actor) error { return CanUpdatePost(actor, post) },
+		},
+		{
+			name:        "Author updates someone else's post",
+			actor:       Actor{UserID: 2, Role: models.RoleAuthor},
+			check:       func(actor Actor) error { return CanUpdatePost(actor, post) },
+			expectedErr: ErrForbidden,
+		},
+		{
+			name:        "Moderator updates someone else's post",
+			actor:       Actor{UserID: 2, Role: models.RoleModerator},
+			check:       func(actor Actor) error { return CanUpdatePost(actor, post) },
+			expectedErr: ErrForbidden,
+		},
+		{
+			name:  "Moderator deletes someone else's post",
+			actor: Actor{UserID: 2, Role: models.RoleModerator},
+			check: func(actor Actor) error { return CanDeletePost(actor, post) },
+		},
+		{
+			name:  "Admin updates someone else's post",
+			actor: Actor{UserID: 2, Role: models.RoleAdmin},
+			check: func(actor Actor) error { return CanUpdatePost(actor, post) },
+		},
+		{
+			name:        "Reader creates post",
+			actor:       Actor{UserID: 2, Role: models.RoleReader},
+			check:       CanCreatePost,
+			expectedErr: ErrForbidden,
+		},
+		{
+			name:  "Author creates post",
+			actor: Actor{UserID: 2, Role: models.RoleAuthor},
+			check: CanCreatePost,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.check(tt.actor), tt.expectedErr)
+		})
+	}
+}
+
+func TestCommentPolicy(t *testing.T) {
+	post := &models.Post{ID: 1, AuthorID: 1}
+	comment := &models.Comment{ID: 1, PostID: 1, AuthorID: 2}
+
+	tests := []struct {
+		name        string
+		actor       Actor
+		expectedErr error
+		check       func(actor Actor) error
+	}{
+		{
+			name:  "Comment author updates comment",
+			actor: Actor{UserID: 2, Role: models.RoleReader},
+			check: func(actor Actor) error { return CanUpdateComment(actor, comment) },
+		},
+		{
+			name:        "Post author updates comment",
+			actor:       Actor{UserID: 1, Role: models.RoleAuthor},
+			check:       func(actor Actor) error { return CanUpdateComment(actor, comment) },
+			expectedErr: ErrForbidden,
+		},
+		{
+			name:  "Post author deletes comment",
+			actor: Actor{UserID: 1, Role: models.RoleAuthor},
+			check: func(actor Actor) error { return CanDeleteComment(actor, comment, post) },
+		},
+		{
+			name:        "Stranger deletes comment",
+			actor:       Actor{UserID: 3, Role: models.RoleAuthor},
+			check:       func(actor Actor) error { return CanDeleteComment(actor, comment, post) },
+			expectedErr: ErrForbidden,
+		},
+		{
+			name:  "Moderator deletes comment",
+			actor: Actor{UserID: 3, Role: models.RoleModerator},
+			check: func(actor Actor) error { return CanDeleteComment(actor, comment, post) },
+		},
+		{
+			name:  "Moderator moderates comments",
+			actor: Actor{UserID: 3, Role: models.RoleModerator},
+			check: CanModerateComments,
+		},
+		{
+			name:        "Post author moderates comments",
+			actor:       Actor{UserID: 1, Role: models.RoleAuthor},
+			check:       CanModerateComments,
+			expectedErr: ErrForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.check(tt.actor), tt.expectedErr)
+		})
+	}
+}
+
+func TestUserPolicy(t *testing.T) {
+	assert.NoError(t, CanDeleteUser(Actor{UserID: 1, Role: models.RoleReader}, 1))
+	assert.ErrorIs(t, CanDeleteUser(Actor{UserID: 1, Role: models.RoleModerator}, 2), ErrForbidden)
+	assert.NoError(t, CanDeleteUser(Actor{UserID: 1, Role: models.RoleAdmin}, 2))
+
+	assert.ErrorIs(t, CanUpdateRole(Actor{UserID: 1, Role: models.RoleModerator}), ErrForbidden)
+	assert.NoError(t, CanUpdateRole(Actor{UserID: 1, Role: models.RoleAdmin}))
+}
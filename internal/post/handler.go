@@ -1,23 +1,37 @@
 package post
 
 import (
+	"context"
 	"errors"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
+	"github.com/crafty-ezhik/blog-api/pkg/diff"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
-	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
 	"github.com/crafty-ezhik/blog-api/pkg/req"
+	"github.com/crafty-ezhik/blog-api/pkg/res"
 	"github.com/crafty-ezhik/blog-api/pkg/validate"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type PostHandler interface {
 	GetAllPosts(c *fiber.Ctx) error
 	GetPostById(c *fiber.Ctx) error
+	SearchPosts(c *fiber.Ctx) error
 	CreatePost(c *fiber.Ctx) error
 	UpdatePost(c *fiber.Ctx) error
 	DeletePost(c *fiber.Ctx) error
+	PublishPost(c *fiber.Ctx) error
+	UnpublishPost(c *fiber.Ctx) error
+	ArchivePost(c *fiber.Ctx) error
+	GetRevisions(c *fiber.Ctx) error
+	GetRevision(c *fiber.Ctx) error
+	RestoreRevision(c *fiber.Ctx) error
+	SetCommentApproval(c *fiber.Ctx) error
 }
 
 type PostHandlerImpl struct {
@@ -34,7 +48,24 @@ func NewPostHandler(postService PostService, validator *validate.XValidator) *Po
 }
 
 func (h *PostHandlerImpl) GetAllPosts(c *fiber.Ctx) error {
-	data, err := h.PostService.GetAllPosts()
+	params, err := pagination.ParseQuery(c, DefaultSort, SortFields...)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	filter, err := parseFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	filter.ViewerID = policy.ActorFromCtx(c).UserID
+	page, err := h.PostService.GetAllPosts(c.UserContext(), filter, params)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
@@ -47,10 +78,7 @@ func (h *PostHandlerImpl) GetAllPosts(c *fiber.Ctx) error {
 			"message": "Something went wrong",
 		})
 	}
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"success": true,
-		"data":    data,
-	})
+	return res.PageResponse(c, page.Items, page.Meta())
 }
 
 func (h *PostHandlerImpl) GetPostById(c *fiber.Ctx) error {
@@ -61,7 +89,7 @@ func (h *PostHandlerImpl) GetPostById(c *fiber.Ctx) error {
 			"message": "Post Id is invalid",
 		})
 	}
-	data, err := h.PostService.GetPostById(uint(id))
+	data, err := h.PostService.GetPostById(c.UserContext(), uint(id), policy.ActorFromCtx(c).UserID)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
@@ -80,20 +108,67 @@ func (h *PostHandlerImpl) GetPostById(c *fiber.Ctx) error {
 	})
 }
 
+func (h *PostHandlerImpl) SearchPosts(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" || utf8.RuneCountInString(query) > MaxSearchQueryLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Search query is empty or too long",
+		})
+	}
+
+	// Результаты поиска упорядочены по релевантности, поэтому доступна только пагинация через offset
+	params, err := pagination.ParseQuery(c, "-rank", "rank")
+	if err == nil && params.Cursor != nil {
+		err = pagination.ErrInvalidCursor
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	page, err := h.PostService.SearchPosts(c.UserContext(), query, params)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Something went wrong",
+		})
+	}
+	return res.PageResponse(c, page.Items, page.Meta())
+}
+
 func (h *PostHandlerImpl) CreatePost(c *fiber.Ctx) error {
 	body, err := req.HandleBody[CreateRequest](c, h.v)
 	if err != nil {
 		return nil
 	}
 
-	authorID := c.Locals(middleware.UserIDKey).(uint)
+	actor := policy.ActorFromCtx(c)
 
 	newPost := &models.Post{
-		Title:    body.Title,
-		Text:     body.Text,
-		AuthorID: authorID,
+		Title:       body.Title,
+		Text:        body.Text,
+		AuthorID:    actor.UserID,
+		Tags:        toTags(body.Tags),
+		Categories:  toCategories(body.Categories),
+		Status:      models.PostStatus(body.Status),
+		PublishedAt: body.PublishAt,
+	}
+	err = h.PostService.CreatePost(c.UserContext(), actor, newPost)
+	if errors.Is(err, ErrInvalidPublishAt) || errors.Is(err, ErrInvalidStatus) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	if errors.Is(err, policy.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Permission denied",
+		})
 	}
-	err = h.PostService.CreatePost(newPost)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -121,15 +196,14 @@ func (h *PostHandlerImpl) UpdatePost(c *fiber.Ctx) error {
 	}
 
 	updatedPost := &models.Post{
-		Title: body.Title,
-		Text:  body.Text,
+		Title:      body.Title,
+		Text:       body.Text,
+		Tags:       toTags(body.Tags),
+		Categories: toCategories(body.Categories),
 	}
-	err = h.PostService.UpdatePost(uint(postID), updatedPost)
+	err = h.PostService.UpdatePost(c.UserContext(), policy.ActorFromCtx(c), uint(postID), updatedPost)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success": false,
-			"message": "Something went wrong",
-		})
+		return h.mutationError(c, err)
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
@@ -145,15 +219,226 @@ func (h *PostHandlerImpl) DeletePost(c *fiber.Ctx) error {
 			"message": "Post Id is invalid",
 		})
 	}
-	err = h.PostService.DeletePost(uint(postID))
+	err = h.PostService.DeletePost(c.UserContext(), policy.ActorFromCtx(c), uint(postID))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success": false,
-			"message": "Something went wrong",
-		})
+		return h.mutationError(c, err)
 	}
 	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{
 		"success": true,
 		"data":    "post deleted",
 	})
 }
+
+// PublishPost - публикует статью сразу или планирует публикацию на publish_at из тела запроса
+func (h *PostHandlerImpl) PublishPost(c *fiber.Ctx) error {
+	// Тело необязательно: без него статья публикуется немедленно
+	body := &PublishRequest{}
+	if len(c.Body()) > 0 {
+		var err error
+		body, err = req.HandleBody[PublishRequest](c, h.v)
+		if err != nil {
+			return nil
+		}
+	}
+
+	return h.changeStatus(c, func(ctx context.Context, actor policy.Actor, postID uint) (*models.Post, error) {
+		return h.PostService.PublishPost(ctx, actor, postID, body.PublishAt)
+	})
+}
+
+func (h *PostHandlerImpl) UnpublishPost(c *fiber.Ctx) error {
+	return h.changeStatus(c, h.PostService.UnpublishPost)
+}
+
+func (h *PostHandlerImpl) ArchivePost(c *fiber.Ctx) error {
+	return h.changeStatus(c, h.PostService.ArchivePost)
+}
+
+func (h *PostHandlerImpl) changeStatus(c *fiber.Ctx, change func(ctx context.Context, actor policy.Actor, postID uint) (*models.Post, error)) error {
+	postID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Post Id is invalid",
+		})
+	}
+	data, err := change(c.UserContext(), policy.ActorFromCtx(c), uint(postID))
+	if err != nil {
+		return h.mutationError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+func (h *PostHandlerImpl) GetRevisions(c *fiber.Ctx) error {
+	postID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Post Id is invalid",
+		})
+	}
+	data, err := h.PostService.GetRevisions(c.UserContext(), policy.ActorFromCtx(c), uint(postID))
+	if err != nil {
+		return h.mutationError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// GetRevision - ревизия статьи и разница между ее текстом и текущим текстом
+func (h *PostHandlerImpl) GetRevision(c *fiber.Ctx) error {
+	postID, revision, err := revisionParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	data, err := h.PostService.GetRevision(c.UserContext(), policy.ActorFromCtx(c), postID, revision)
+	if err != nil {
+		return h.mutationError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+func (h *PostHandlerImpl) RestoreRevision(c *fiber.Ctx) error {
+	postID, revision, err := revisionParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	err = h.PostService.RestoreRevision(c.UserContext(), policy.ActorFromCtx(c), postID, revision)
+	if err != nil {
+		return h.mutationError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    "post restored",
+	})
+}
+
+func (h *PostHandlerImpl) SetCommentApproval(c *fiber.Ctx) error {
+	postID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Post Id is invalid",
+		})
+	}
+
+	body, err := req.HandleBody[CommentApprovalRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+
+	err = h.PostService.SetCommentApproval(c.UserContext(), policy.ActorFromCtx(c), uint(postID), *body.RequireApproval)
+	if err != nil {
+		return h.mutationError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    "comment approval updated",
+	})
+}
+
+func revisionParams(c *fiber.Ctx) (uint, int, error) {
+	postID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return 0, 0, errors.New("Post Id is invalid")
+	}
+	revision, err := strconv.Atoi(c.Params("rev"))
+	if err != nil || revision < 1 {
+		return 0, 0, errors.New("Revision is invalid")
+	}
+	return uint(postID), revision, nil
+}
+
+// mutationError - формирует ответ на ошибку при изменении или удалении поста
+func (h *PostHandlerImpl) mutationError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Post not found",
+		})
+	case errors.Is(err, ErrRevisionNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Revision not found",
+		})
+	case errors.Is(err, policy.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Permission denied",
+		})
+	case errors.Is(err, diff.ErrTooLarge):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"success": false,
+			"message": "Revision is too different from the current text to diff",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Something went wrong",
+		})
+	}
+}
+
+var ErrInvalidTagMode = errors.New("tag_mode must be any or all")
+
+// parseFilter - разбирает фильтр по тегам и категории: ?tag=go&tag=fiber&tag_mode=all&category=backend.
+// По умолчанию статья должна иметь хотя бы один из тегов (tag_mode=any)
+func parseFilter(c *fiber.Ctx) (*Filter, error) {
+	filter := &Filter{Category: models.Slugify(c.Query("category"))}
+
+	switch c.Query("tag_mode", "any") {
+	case "any":
+	case "all":
+		filter.MatchAll = true
+	default:
+		return nil, ErrInvalidTagMode
+	}
+
+	seen := make(map[string]bool)
+	for _, raw := range c.Context().QueryArgs().PeekMulti("tag") {
+		slug := models.Slugify(string(raw))
+		if slug != "" && !seen[slug] {
+			seen[slug] = true
+			filter.Tags = append(filter.Tags, slug)
+		}
+	}
+	return filter, nil
+}
+
+// toTags - nil сохраняется, чтобы при обновлении отличать "теги не переданы" от пустого списка
+func toTags(names []string) []models.Tag {
+	if names == nil {
+		return nil
+	}
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, models.Tag{Name: strings.TrimSpace(name)})
+	}
+	return tags
+}
+
+func toCategories(names []string) []models.Category {
+	if names == nil {
+		return nil
+	}
+	categories := make([]models.Category, 0, len(names))
+	for _, name := range names {
+		categories = append(categories, models.Category{Name: strings.TrimSpace(name)})
+	}
+	return categories
+}
@@ -1,15 +1,19 @@
 package post_test
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
 	"github.com/crafty-ezhik/blog-api/internal/post"
 	mock_post "github.com/crafty-ezhik/blog-api/mocks/post"
+	"github.com/crafty-ezhik/blog-api/pkg/diff"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
 	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
 	"github.com/crafty-ezhik/blog-api/pkg/validate"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -22,6 +26,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 type Mocks struct {
@@ -63,7 +68,7 @@ func TestPostHandlerImpl_GetPostById(t *testing.T) {
 			name:   "Success",
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().GetPostById(uint(1)).Return(&models.Post{
+				mock.PostService.EXPECT().GetPostById(gomock.Any(), uint(1), gomock.Any()).Return(&models.Post{
 					ID:       1,
 					Title:    "TestTitle",
 					Text:     "TestText",
@@ -83,7 +88,7 @@ func TestPostHandlerImpl_GetPostById(t *testing.T) {
 			name:   "Server Internal Error",
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().GetPostById(uint(1)).Return(nil, errors.New("server Error"))
+				mock.PostService.EXPECT().GetPostById(gomock.Any(), uint(1), gomock.Any()).Return(nil, errors.New("server Error"))
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "Something went wrong",
@@ -92,7 +97,7 @@ func TestPostHandlerImpl_GetPostById(t *testing.T) {
 			name:   "Post Not Found",
 			postId: 99,
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().GetPostById(uint(99)).Return(nil, gorm.ErrRecordNotFound)
+				mock.PostService.EXPECT().GetPostById(gomock.Any(), uint(99), gomock.Any()).Return(nil, gorm.ErrRecordNotFound)
 			},
 			expectedStatusCode: 404,
 			expectedBody:       "Post not found",
@@ -132,6 +137,7 @@ func TestPostHandlerImpl_GetAllPosts(t *testing.T) {
 
 	tests := []struct {
 		name               string
+		query              string
 		mockSetup          func(mock *Mocks)
 		expectedStatusCode int
 		expectedBody       string
@@ -140,17 +146,84 @@ func TestPostHandlerImpl_GetAllPosts(t *testing.T) {
 		{
 			name: "Success",
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().GetAllPosts().Return([]models.Post{
-					models.Post{}, models.Post{}}, nil)
+				mock.PostService.EXPECT().GetAllPosts(gomock.Any(), gomock.Any(), gomock.Any()).Return(&pagination.Page[models.Post]{
+					Items:  []models.Post{models.Post{}, models.Post{}},
+					Total:  2,
+					Params: &pagination.Params{Limit: pagination.DefaultLimit},
+				}, nil)
 			},
 			expectedStatusCode: 200,
-			expectedBody:       "true",
+			expectedBody:       `"total":2`,
 			expectedLengthData: 2,
 		},
+		{
+			name:  "Cursor and sort are passed to service",
+			query: "?limit=1&sort=-title&cursor=" + pagination.EncodeCursor(&pagination.Cursor{Sort: "-title", Value: "B", ID: 2}),
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().GetAllPosts(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, filter *post.Filter, params *pagination.Params) (*pagination.Page[models.Post], error) {
+						assert.Equal(t, 1, params.Limit)
+						assert.Equal(t, pagination.Sort{Field: "title", Desc: true}, params.Sort)
+						require.NotNil(t, params.Cursor)
+						assert.Equal(t, uint(2), params.Cursor.ID)
+						return &pagination.Page[models.Post]{
+							Items:      []models.Post{models.Post{ID: 1}},
+							NextCursor: "next",
+							Total:      2,
+							Params:     params,
+						}, nil
+					})
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"next_cursor":"next"`,
+			expectedLengthData: 1,
+		},
+		{
+			name:  "Tag filter is passed to service",
+			query: "?tag=Go&tag=fiber&tag=go&tag_mode=all&category=Backend",
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().GetAllPosts(gomock.Any(), &post.Filter{
+					Tags:     []string{"go", "fiber"},
+					MatchAll: true,
+					Category: "backend",
+				}, gomock.Any()).Return(&pagination.Page[models.Post]{
+					Items:  []models.Post{{ID: 1}},
+					Total:  1,
+					Params: &pagination.Params{Limit: pagination.DefaultLimit},
+				}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"total":1`,
+			expectedLengthData: 1,
+		},
+		{
+			name:               "Invalid tag mode",
+			query:              "?tag=go&tag_mode=both",
+			expectedStatusCode: 400,
+			expectedBody:       "tag_mode must be any or all",
+		},
+		{
+			name:               "Invalid sort field",
+			query:              "?sort=author_id",
+			expectedStatusCode: 400,
+			expectedBody:       "invalid sort field",
+		},
+		{
+			name:               "Invalid limit",
+			query:              "?limit=1000",
+			expectedStatusCode: 400,
+			expectedBody:       "limit must be between 1 and 100",
+		},
+		{
+			name:               "Cursor for another sort",
+			query:              "?sort=title&cursor=" + pagination.EncodeCursor(&pagination.Cursor{Sort: "-title", Value: "B", ID: 2}),
+			expectedStatusCode: 400,
+			expectedBody:       "invalid cursor",
+		},
 		{
 			name: "Server internal Error",
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().GetAllPosts().Return([]models.Post{}, errors.New("server Error"))
+				mock.PostService.EXPECT().GetAllPosts(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("server Error"))
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "Something went wrong",
@@ -158,7 +231,7 @@ func TestPostHandlerImpl_GetAllPosts(t *testing.T) {
 		{
 			name: "Posts Not Found",
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().GetAllPosts().Return([]models.Post{}, gorm.ErrRecordNotFound)
+				mock.PostService.EXPECT().GetAllPosts(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, gorm.ErrRecordNotFound)
 			},
 			expectedStatusCode: 404,
 			expectedBody:       "Posts not found",
@@ -167,7 +240,7 @@ func TestPostHandlerImpl_GetAllPosts(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req := httptest.NewRequest(http.MethodGet, path+tt.query, nil)
 
 			app := fiber.New()
 			app.Get(path, postHandler.GetAllPosts)
@@ -184,10 +257,13 @@ func TestPostHandlerImpl_GetAllPosts(t *testing.T) {
 			assert.Contains(t, string(respBody), tt.expectedBody)
 
 			if tt.expectedLengthData > 0 {
-				var jsonData map[string]interface{}
+				var jsonData struct {
+					Data []models.Post   `json:"data"`
+					Meta pagination.Meta `json:"meta"`
+				}
 				err = json.Unmarshal(respBody, &jsonData)
 				require.NoError(t, err)
-				assert.Len(t, jsonData, tt.expectedLengthData)
+				assert.Len(t, jsonData.Data, tt.expectedLengthData)
 			}
 
 		})
@@ -216,11 +292,35 @@ func TestPostHandlerImpl_CreatePost(t *testing.T) {
 				Text:  "TestText",
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().CreatePost(gomock.Any()).Return(nil)
+				mock.PostService.EXPECT().CreatePost(gomock.Any(), policy.Actor{UserID: 1}, gomock.Any()).Return(nil)
 			},
 			expectedStatusCode: 201,
 			expectedBody:       "true",
 		},
+		{
+			name: "Scheduled without publish_at",
+			payload: post.CreateRequest{
+				Title:  "TestTitle",
+				Text:   "TestText",
+				Status: "scheduled",
+			},
+			expectedStatusCode: 400,
+			expectedBody:       "Invalid field or its absence: [PublishAt]",
+		},
+		{
+			name: "Publish_at in the past",
+			payload: post.CreateRequest{
+				Title:     "TestTitle",
+				Text:      "TestText",
+				Status:    "scheduled",
+				PublishAt: &time.Time{},
+			},
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().CreatePost(gomock.Any(), policy.Actor{UserID: 1}, gomock.Any()).Return(post.ErrInvalidPublishAt)
+			},
+			expectedStatusCode: 400,
+			expectedBody:       "publish_at must be in the future",
+		},
 		{
 			name: "Empty Title in body",
 			payload: post.CreateRequest{
@@ -244,7 +344,7 @@ func TestPostHandlerImpl_CreatePost(t *testing.T) {
 				Text:  "TestText",
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().CreatePost(gomock.Any()).Return(errors.New("server Error"))
+				mock.PostService.EXPECT().CreatePost(gomock.Any(), policy.Actor{UserID: 1}, gomock.Any()).Return(errors.New("server Error"))
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "Something went wrong",
@@ -302,7 +402,7 @@ func TestPostHandlerImpl_DeletePost(t *testing.T) {
 			name:   "Success",
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().DeletePost(uint(1)).Return(nil)
+				mock.PostService.EXPECT().DeletePost(gomock.Any(), gomock.Any(), uint(1)).Return(nil)
 			},
 			expectedStatusCode: 204,
 			expectedBody:       "",
@@ -317,11 +417,29 @@ func TestPostHandlerImpl_DeletePost(t *testing.T) {
 			name:   "Server internal Error",
 			postId: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().DeletePost(uint(1)).Return(errors.New("server Error"))
+				mock.PostService.EXPECT().DeletePost(gomock.Any(), gomock.Any(), uint(1)).Return(errors.New("server Error"))
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "Something went wrong",
 		},
+		{
+			name:   "Permission denied",
+			postId: 1,
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().DeletePost(gomock.Any(), gomock.Any(), uint(1)).Return(policy.ErrForbidden)
+			},
+			expectedStatusCode: 403,
+			expectedBody:       "Permission denied",
+		},
+		{
+			name:   "Post Not Found",
+			postId: 99,
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().DeletePost(gomock.Any(), gomock.Any(), uint(99)).Return(gorm.ErrRecordNotFound)
+			},
+			expectedStatusCode: 404,
+			expectedBody:       "Post not found",
+		},
 	}
 
 	for _, tt := range tests {
@@ -369,7 +487,7 @@ func TestPostHandlerImpl_UpdatePost(t *testing.T) {
 				Text:  "TestText",
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().UpdatePost(uint(1), gomock.Any()).Return(nil)
+				mock.PostService.EXPECT().UpdatePost(gomock.Any(), gomock.Any(), uint(1), gomock.Any()).Return(nil)
 			},
 			expectedStatusCode: 200,
 			expectedBody:       "post updated",
@@ -388,11 +506,24 @@ func TestPostHandlerImpl_UpdatePost(t *testing.T) {
 				Text:  "TestText",
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.PostService.EXPECT().UpdatePost(uint(1), gomock.Any()).Return(errors.New("server Error"))
+				mock.PostService.EXPECT().UpdatePost(gomock.Any(), gomock.Any(), uint(1), gomock.Any()).Return(errors.New("server Error"))
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "Something went wrong",
 		},
+		{
+			name:   "Permission denied",
+			postId: 1,
+			payload: post.UpdateRequest{
+				Title: "TestTitle",
+				Text:  "TestText",
+			},
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().UpdatePost(gomock.Any(), gomock.Any(), uint(1), gomock.Any()).Return(policy.ErrForbidden)
+			},
+			expectedStatusCode: 403,
+			expectedBody:       "Permission denied",
+		},
 		{
 			name:   "Empty Text in body",
 			postId: 1,
@@ -436,3 +567,279 @@ func TestPostHandlerImpl_UpdatePost(t *testing.T) {
 		})
 	}
 }
+
+func TestPostHandlerImpl_SearchPosts(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	postHandler, mocks := setup(t)
+
+	path := "/api/posts/search"
+
+	tests := []struct {
+		name               string
+		query              string
+		mockSetup          func(mock *Mocks)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:  "Success",
+			query: "?q=fiber&limit=5",
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().SearchPosts(gomock.Any(), "fiber", gomock.Any()).Return(&pagination.Page[post.SearchResult]{
+					Items: []post.SearchResult{
+						{Post: models.Post{ID: 1, Title: "Fiber"}, Rank: 0.5, Snippet: "<mark>Fiber</mark>"},
+					},
+					Total:  1,
+					Params: &pagination.Params{Limit: 5},
+				}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"rank":0.5`,
+		},
+		{
+			name:               "Empty query",
+			query:              "?q=%20",
+			expectedStatusCode: 400,
+			expectedBody:       "Search query is empty or too long",
+		},
+		{
+			name:               "Cursor is not supported",
+			query:              "?q=fiber&cursor=" + pagination.EncodeCursor(&pagination.Cursor{Sort: "-rank", Value: 1, ID: 1}),
+			expectedStatusCode: 400,
+			expectedBody:       "invalid cursor",
+		},
+		{
+			name:  "Server internal error",
+			query: "?q=fiber",
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().SearchPosts(gomock.Any(), "fiber", gomock.Any()).Return(nil, errors.New("server Error"))
+			},
+			expectedStatusCode: 500,
+			expectedBody:       "Something went wrong",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path+tt.query, nil)
+
+			app := fiber.New()
+			app.Get(path, postHandler.SearchPosts)
+
+			if tt.mockSetup != nil {
+				tt.mockSetup(mocks)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, resp.StatusCode)
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(respBody), tt.expectedBody)
+		})
+	}
+}
+
+func TestPostHandlerImpl_PublishPost(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	postHandler, mocks := setup(t)
+
+	path := "/api/posts/:id/publish"
+	publishAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name               string
+		postId             any
+		body               string
+		mockSetup          func(mock *Mocks)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:   "Publish now",
+			postId: 1,
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().PublishPost(gomock.Any(), policy.Actor{UserID: 1}, uint(1), nil).
+					Return(&models.Post{ID: 1, Status: models.PostPublished}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"status":"published"`,
+		},
+		{
+			name:   "Schedule",
+			postId: 1,
+			body:   fmt.Sprintf(`{"publish_at":%q}`, publishAt.Format(time.RFC3339)),
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().PublishPost(gomock.Any(), policy.Actor{UserID: 1}, uint(1), gomock.Any()).DoAndReturn(
+					func(_ context.Context, actor policy.Actor, postID uint, at *time.Time) (*models.Post, error) {
+						require.NotNil(t, at)
+						assert.True(t, publishAt.Equal(*at))
+						return &models.Post{ID: 1, Status: models.PostScheduled, PublishedAt: at}, nil
+					})
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"status":"scheduled"`,
+		},
+		{
+			name:               "Invalid Post Id",
+			postId:             "one",
+			expectedStatusCode: 400,
+			expectedBody:       "Post Id is invalid",
+		},
+		{
+			name:   "Someone else's post",
+			postId: 2,
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().PublishPost(gomock.Any(), policy.Actor{UserID: 1}, uint(2), nil).Return(nil, policy.ErrForbidden)
+			},
+			expectedStatusCode: 403,
+			expectedBody:       "Permission denied",
+		},
+		{
+			name:   "Post not found",
+			postId: 99,
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().PublishPost(gomock.Any(), policy.Actor{UserID: 1}, uint(99), nil).Return(nil, gorm.ErrRecordNotFound)
+			},
+			expectedStatusCode: 404,
+			expectedBody:       "Post not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/posts/%v/publish", tt.postId), bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			if tt.mockSetup != nil {
+				tt.mockSetup(mocks)
+			}
+
+			app := fiber.New()
+			app.Post(path,
+				func(c *fiber.Ctx) error {
+					c.Locals(middleware.UserIDKey, uint(1))
+					return c.Next()
+				},
+				postHandler.PublishPost)
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, resp.StatusCode)
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(respBody), tt.expectedBody)
+		})
+	}
+}
+
+func TestPostHandlerImpl_GetRevision(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	postHandler, mocks := setup(t)
+
+	path := "/api/posts/:id/revisions/:rev"
+
+	tests := []struct {
+		name               string
+		url                string
+		mockSetup          func(mock *Mocks)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "Success",
+			url:  "/api/posts/1/revisions/2",
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().GetRevision(gomock.Any(), policy.Actor{UserID: 1}, uint(1), 2).Return(&post.RevisionDiffResponse{
+					PostRevision: models.PostRevision{PostID: 1, Revision: 2, Text: "old"},
+					Diff:         []diff.Line{{Op: diff.Delete, Text: "old"}, {Op: diff.Insert, Text: "new"}},
+				}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `{"op":"insert","text":"new"}`,
+		},
+		{
+			name:               "Invalid revision",
+			url:                "/api/posts/1/revisions/0",
+			expectedStatusCode: 400,
+			expectedBody:       "Revision is invalid",
+		},
+		{
+			name: "Revision not found",
+			url:  "/api/posts/1/revisions/9",
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().GetRevision(gomock.Any(), policy.Actor{UserID: 1}, uint(1), 9).Return(nil, post.ErrRevisionNotFound)
+			},
+			expectedStatusCode: 404,
+			expectedBody:       "Revision not found",
+		},
+		{
+			name: "Someone else's post",
+			url:  "/api/posts/2/revisions/1",
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().GetRevision(gomock.Any(), policy.Actor{UserID: 1}, uint(2), 1).Return(nil, policy.ErrForbidden)
+			},
+			expectedStatusCode: 403,
+			expectedBody:       "Permission denied",
+		},
+		{
+			name: "Texts are too different",
+			url:  "/api/posts/1/revisions/1",
+			mockSetup: func(mock *Mocks) {
+				mock.PostService.EXPECT().GetRevision(gomock.Any(), policy.Actor{UserID: 1}, uint(1), 1).Return(nil, diff.ErrTooLarge)
+			},
+			expectedStatusCode: 422,
+			expectedBody:       "too different",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.mockSetup != nil {
+				tt.mockSetup(mocks)
+			}
+
+			app := fiber.New()
+			app.Get(path,
+				func(c *fiber.Ctx) error {
+					c.Locals(middleware.UserIDKey, uint(1))
+					return c.Next()
+				},
+				postHandler.GetRevision)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.url, nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, resp.StatusCode)
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(respBody), tt.expectedBody)
+		})
+	}
+}
+
+func TestPostHandlerImpl_RestoreRevision(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	postHandler, mocks := setup(t)
+
+	mocks.PostService.EXPECT().RestoreRevision(gomock.Any(), policy.Actor{UserID: 1}, uint(1), 1).Return(nil)
+
+	app := fiber.New()
+	app.Post("/api/posts/:id/revisions/:rev/restore",
+		func(c *fiber.Ctx) error {
+			c.Locals(middleware.UserIDKey, uint(1))
+			return c.Next()
+		},
+		postHandler.RestoreRevision)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/api/posts/1/revisions/1/restore", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
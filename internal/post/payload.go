@@ -1,11 +1,73 @@
 package post
 
+import (
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/pkg/diff"
+	"time"
+)
+
+// Параметры сортировки списков статей
+const DefaultSort = "-created_at"
+
+var SortFields = []string{"created_at", "updated_at", "title"}
+
+// CreateRequest - по умолчанию статья создается черновиком. Для status=scheduled обязателен publish_at
 type CreateRequest struct {
-	Title string `json:"title" validate:"required,max=255"`
-	Text  string `json:"text" validate:"required"`
+	Title      string     `json:"title" validate:"required,max=255"`
+	Text       string     `json:"text" validate:"required"`
+	Tags       []string   `json:"tags" validate:"max=10,dive,required,max=50"`
+	Categories []string   `json:"categories" validate:"max=3,dive,required,max=50"`
+	Status     string     `json:"status" validate:"omitempty,oneof=draft published scheduled"`
+	PublishAt  *time.Time `json:"publish_at" validate:"required_if=Status scheduled"`
 }
 
+// UpdateRequest - если tags или categories не переданы, то они остаются прежними, пустой список их очищает
 type UpdateRequest struct {
-	Title string `json:"title" validate:"required,max=255"`
-	Text  string `json:"text" validate:"required"`
+	Title      string   `json:"title" validate:"required,max=255"`
+	Text       string   `json:"text" validate:"required"`
+	Tags       []string `json:"tags" validate:"max=10,dive,required,max=50"`
+	Categories []string `json:"categories" validate:"max=3,dive,required,max=50"`
+}
+
+// PublishRequest - если publish_at в будущем, статья планируется к публикации, иначе публикуется сразу
+type PublishRequest struct {
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// CommentApprovalRequest - включает или выключает премодерацию комментариев к статье
+type CommentApprovalRequest struct {
+	RequireApproval *bool `json:"require_approval" validate:"required"`
+}
+
+// Filter - фильтр списка статей. Tags - slug тегов, при MatchAll статья должна иметь все теги,
+// иначе хотя бы один из них. Неопубликованные статьи видны только их автору - ViewerID
+type Filter struct {
+	Tags     []string
+	MatchAll bool
+	Category string
+	ViewerID uint
+}
+
+// MaxSearchQueryLength - ограничение длины поискового запроса
+const MaxSearchQueryLength = 200
+
+type SearchResult struct {
+	models.Post
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// RevisionResponse - элемент истории правок статьи, без текста
+type RevisionResponse struct {
+	Revision  int       `json:"revision"`
+	Title     string    `json:"title"`
+	EditorID  uint      `json:"editor_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RevisionDiffResponse - ревизия и построчная разница между ее текстом и текущим текстом статьи
+type RevisionDiffResponse struct {
+	models.PostRevision
+	CurrentTitle string      `json:"current_title"`
+	Diff         []diff.Line `json:"diff"`
 }
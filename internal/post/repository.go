@@ -1,18 +1,32 @@
 package post
 
 import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/db"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/uow"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type PostRepository interface {
-	FindALL() ([]models.Post, error)
-	FindByID(postID uint) (*models.Post, error)
-	FindByUserID(authorID uint) ([]models.Post, error)
-	Create(post *models.Post) error
-	Update(postID uint, updatedFields *models.Post) error
-	Delete(postID uint) error
+	FindALL(ctx context.Context, filter *Filter, params *pagination.Params) (*pagination.Page[models.Post], error)
+	FindByID(ctx context.Context, postID uint) (*models.Post, error)
+	FindByIDForShare(ctx context.Context, postID uint) (*models.Post, error)
+	FindByUserID(ctx context.Context, authorID, viewerID uint, params *pagination.Params) (*pagination.Page[models.Post], error)
+	SearchPosts(ctx context.Context, query string, params *pagination.Params) (*pagination.Page[SearchResult], error)
+	Create(ctx context.Context, post *models.Post) error
+	Update(ctx context.Context, postID, editorID uint, updatedFields *models.Post) error
+	UpdateStatus(ctx context.Context, postID uint, status models.PostStatus, publishedAt *time.Time) error
+	PublishScheduled(ctx context.Context, now time.Time) (int64, error)
+	UpdateCommentApproval(ctx context.Context, postID uint, required bool) error
+	Delete(ctx context.Context, postID uint) error
+	DeleteByAuthor(ctx context.Context, authorID uint) error
+	FindRevisions(ctx context.Context, postID uint) ([]models.PostRevision, error)
+	FindRevision(ctx context.Context, postID uint, revision int) (*models.PostRevision, error)
 }
 
 type PostRepositoryImpl struct {
@@ -26,32 +40,234 @@ func NewPostRepository(db *gorm.DB) *PostRepositoryImpl {
 	}
 }
 
-func (repo *PostRepositoryImpl) FindALL() ([]models.Post, error) {
-	var posts []models.Post
-	result := repo.db.Model(&models.Post{}).Find(&posts)
-	return posts, result.Error
+// WithTx - репозиторий, работающий через транзакцию tx
+func (repo *PostRepositoryImpl) WithTx(tx *gorm.DB) *PostRepositoryImpl {
+	return &PostRepositoryImpl{db: tx}
 }
 
-func (repo *PostRepositoryImpl) FindByID(postID uint) (*models.Post, error) {
+// FindALL - лента статей читается с реплики, если она настроена
+func (repo *PostRepositoryImpl) FindALL(ctx context.Context, filter *Filter, params *pagination.Params) (*pagination.Page[models.Post], error) {
+	query := repo.applyFilter(db.Replica(repo.withTaxonomy(ctx)), filter)
+	return pagination.Paginate[models.Post](query, "posts", params)
+}
+
+func (repo *PostRepositoryImpl) FindByID(ctx context.Context, postID uint) (*models.Post, error) {
+	var post models.Post
+	result := repo.withTaxonomy(ctx).First(&post, postID)
+	return &post, result.Error
+}
+
+// FindByIDForShare - статья без тегов и категорий, заблокированная от изменения и удаления до конца транзакции
+func (repo *PostRepositoryImpl) FindByIDForShare(ctx context.Context, postID uint) (*models.Post, error) {
 	var post models.Post
-	result := repo.db.First(&post, postID)
+	result := uow.ForShare(repo.db.WithContext(ctx)).First(&post, postID)
 	return &post, result.Error
 }
 
-func (repo *PostRepositoryImpl) FindByUserID(authorID uint) ([]models.Post, error) {
-	var posts []models.Post
-	result := repo.db.Where("author_id = ?", authorID).Find(&posts)
-	return posts, result.Error
+func (repo *PostRepositoryImpl) FindByUserID(ctx context.Context, authorID, viewerID uint, params *pagination.Params) (*pagination.Page[models.Post], error) {
+	query := repo.withTaxonomy(ctx).Where("posts.author_id = ?", authorID)
+	if authorID != viewerID {
+		query = query.Where("posts.status = ?", models.PostPublished)
+	}
+	return pagination.Paginate[models.Post](query, "posts", params)
+}
+
+// Create - создает статью. Отсутствующие в БД теги и категории создаются по slug
+func (repo *PostRepositoryImpl) Create(ctx context.Context, post *models.Post) error {
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := resolveTaxonomy(tx, post); err != nil {
+			return err
+		}
+		return tx.Create(post).Error
+	})
+}
+
+// Update - обновляет поля статьи. Теги и категории заменяются, только если они переданы (не nil).
+// Каждое изменение заголовка или текста сохраняется как новая ревизия от имени editorID
+func (repo *PostRepositoryImpl) Update(ctx context.Context, postID, editorID uint, updatedFields *models.Post) error {
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := resolveTaxonomy(tx, updatedFields); err != nil {
+			return err
+		}
+		// Исходная версия статьи сохраняется перед первой правкой, чтобы к ней можно было вернуться
+		if err := saveInitialRevision(tx, postID); err != nil {
+			return err
+		}
+		post := &models.Post{ID: postID}
+		if err := tx.Model(post).Omit(clause.Associations).Updates(updatedFields).Error; err != nil {
+			return err
+		}
+		if err := saveRevision(tx, postID, editorID); err != nil {
+			return err
+		}
+		if updatedFields.Tags != nil {
+			if err := tx.Model(post).Association("Tags").Replace(updatedFields.Tags); err != nil {
+				return err
+			}
+		}
+		if updatedFields.Categories != nil {
+			return tx.Model(post).Association("Categories").Replace(updatedFields.Categories)
+		}
+		return nil
+	})
+}
+
+// UpdateStatus - меняет статус статьи. publishedAt записывается как есть, nil очищает дату публикации
+func (repo *PostRepositoryImpl) UpdateStatus(ctx context.Context, postID uint, status models.PostStatus, publishedAt *time.Time) error {
+	return repo.db.WithContext(ctx).Model(&models.Post{ID: postID}).Updates(map[string]any{
+		"status":       status,
+		"published_at": publishedAt,
+	}).Error
+}
+
+// PublishScheduled - публикует все запланированные статьи, время публикации которых наступило.
+// Выполняется одним UPDATE, поэтому безопасен при одновременном вызове из нескольких реплик
+func (repo *PostRepositoryImpl) PublishScheduled(ctx context.Context, now time.Time) (int64, error) {
+	result := repo.db.WithContext(ctx).Model(&models.Post{}).
+		Where("status = ? AND published_at <= ?", models.PostScheduled, now).
+		Update("status", models.PostPublished)
+	return result.RowsAffected, result.Error
+}
+
+func (repo *PostRepositoryImpl) UpdateCommentApproval(ctx context.Context, postID uint, required bool) error {
+	return repo.db.WithContext(ctx).Model(&models.Post{ID: postID}).Update("require_comment_approval", required).Error
+}
+
+func (repo *PostRepositoryImpl) Delete(ctx context.Context, postID uint) error {
+	return repo.db.WithContext(ctx).Delete(&models.Post{ID: postID}).Error
+}
+
+func (repo *PostRepositoryImpl) DeleteByAuthor(ctx context.Context, authorID uint) error {
+	return repo.db.WithContext(ctx).Where("author_id = ?", authorID).Delete(&models.Post{}).Error
+}
+
+// FindRevisions - ревизии статьи от новых к старым
+func (repo *PostRepositoryImpl) FindRevisions(ctx context.Context, postID uint) ([]models.PostRevision, error) {
+	revisions := make([]models.PostRevision, 0)
+	result := repo.db.WithContext(ctx).Where("post_id = ?", postID).Order("revision DESC").Find(&revisions)
+	return revisions, result.Error
 }
 
-func (repo *PostRepositoryImpl) Create(post *models.Post) error {
-	return repo.db.Create(post).Error
+func (repo *PostRepositoryImpl) FindRevision(ctx context.Context, postID uint, revision int) (*models.PostRevision, error) {
+	var postRevision models.PostRevision
+	result := repo.db.WithContext(ctx).Where("post_id = ? AND revision = ?", postID, revision).First(&postRevision)
+	return &postRevision, result.Error
 }
 
-func (repo *PostRepositoryImpl) Update(postID uint, updatedFields *models.Post) error {
-	return repo.db.Model(models.Post{ID: postID}).Updates(updatedFields).Error
+func (repo *PostRepositoryImpl) withTaxonomy(ctx context.Context) *gorm.DB {
+	return repo.db.WithContext(ctx).Model(&models.Post{}).Preload("Tags").Preload("Categories")
 }
 
-func (repo *PostRepositoryImpl) Delete(postID uint) error {
-	return repo.db.Delete(&models.Post{ID: postID}).Error
+// applyFilter - ограничивает выборку статей тегами и категорией через подзапросы,
+// чтобы JOIN по связующим таблицам не размножал строки и не ломал пагинацию.
+// Неопубликованные статьи попадают в выборку только для их автора
+func (repo *PostRepositoryImpl) applyFilter(query *gorm.DB, filter *Filter) *gorm.DB {
+	if filter == nil {
+		filter = &Filter{}
+	}
+	query = query.Where("(posts.status = ? OR posts.author_id = ?)", models.PostPublished, filter.ViewerID)
+	if len(filter.Tags) > 0 {
+		sub := repo.db.Table("post_tags").
+			Select("post_tags.post_id").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").
+			Where("tags.slug IN ?", filter.Tags)
+		if filter.MatchAll {
+			sub = sub.Group("post_tags.post_id").Having("COUNT(DISTINCT tags.id) = ?", len(filter.Tags))
+		}
+		query = query.Where("posts.id IN (?)", sub)
+	}
+	if filter.Category != "" {
+		sub := repo.db.Table("post_categories").
+			Select("post_categories.post_id").
+			Joins("JOIN categories ON categories.id = post_categories.category_id").
+			Where("categories.slug = ?", filter.Category)
+		query = query.Where("posts.id IN (?)", sub)
+	}
+	return query
+}
+
+// resolveTaxonomy - заменяет теги и категории статьи на записи из БД, создавая недостающие.
+// Названия с одинаковым slug схлопываются в одну запись
+func resolveTaxonomy(tx *gorm.DB, post *models.Post) error {
+	if post.Tags != nil {
+		tags := make([]models.Tag, 0, len(post.Tags))
+		seen := make(map[string]bool, len(post.Tags))
+		for _, tag := range post.Tags {
+			slug := models.Slugify(tag.Name)
+			if slug == "" || seen[slug] {
+				continue
+			}
+			seen[slug] = true
+			if err := tx.Where(models.Tag{Slug: slug}).Attrs(models.Tag{Name: tag.Name}).FirstOrCreate(&tag).Error; err != nil {
+				return err
+			}
+			tags = append(tags, tag)
+		}
+		post.Tags = tags
+	}
+	if post.Categories != nil {
+		categories := make([]models.Category, 0, len(post.Categories))
+		seen := make(map[string]bool, len(post.Categories))
+		for _, category := range post.Categories {
+			slug := models.Slugify(category.Name)
+			if slug == "" || seen[slug] {
+				continue
+			}
+			seen[slug] = true
+			if err := tx.Where(models.Category{Slug: slug}).Attrs(models.Category{Name: category.Name}).FirstOrCreate(&category).Error; err != nil {
+				return err
+			}
+			categories = append(categories, category)
+		}
+		post.Categories = categories
+	}
+	return nil
+}
+
+func saveInitialRevision(tx *gorm.DB, postID uint) error {
+	var count int64
+	if err := tx.Model(&models.PostRevision{}).Where("post_id = ?", postID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var post models.Post
+	if err := tx.First(&post, postID).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.PostRevision{
+		PostID:    postID,
+		Revision:  1,
+		Title:     post.Title,
+		Text:      post.Text,
+		EditorID:  post.AuthorID,
+		CreatedAt: post.UpdatedAt,
+	}).Error
+}
+
+// saveRevision - сохраняет текущий вид статьи, если он отличается от последней ревизии.
+// Одновременная правка одной статьи упрется в уникальный индекс (post_id, revision) и откатится
+func saveRevision(tx *gorm.DB, postID, editorID uint) error {
+	var post models.Post
+	if err := tx.First(&post, postID).Error; err != nil {
+		return err
+	}
+
+	var last models.PostRevision
+	if err := tx.Where("post_id = ?", postID).Order("revision DESC").First(&last).Error; err != nil {
+		return err
+	}
+	if last.Title == post.Title && last.Text == post.Text {
+		return nil
+	}
+
+	return tx.Create(&models.PostRevision{
+		PostID:   postID,
+		Revision: last.Revision + 1,
+		Title:    post.Title,
+		Text:     post.Text,
+		EditorID: editorID,
+	}).Error
 }
@@ -0,0 +1,56 @@
+package post
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+// DefaultSchedulerInterval - период проверки запланированных статей, если он не задан в конфигурации
+const DefaultSchedulerInterval = time.Minute
+
+// Scheduler - фоновая публикация запланированных статей. Состояние хранится только в БД,
+// поэтому статьи, время которых наступило во время простоя, публикуются при первом же запуске
+type Scheduler struct {
+	PostRepo PostRepository
+	interval time.Duration
+}
+
+func NewScheduler(postRepo PostRepository, interval time.Duration) *Scheduler {
+	logger.Log.Debug("Init post scheduler")
+	if interval <= 0 {
+		interval = DefaultSchedulerInterval
+	}
+	return &Scheduler{
+		PostRepo: postRepo,
+		interval: interval,
+	}
+}
+
+// Run - блокируется до отмены ctx, запускать в отдельной горутине
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.publishDue(ctx)
+		select {
+		case <-ctx.Done():
+			logger.Log.Debug("Post scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) publishDue(ctx context.Context) {
+	published, err := s.PostRepo.PublishScheduled(ctx, time.Now())
+	if err != nil {
+		logger.Log.Error("Failed to publish scheduled posts", zap.Error(err))
+		return
+	}
+	if published > 0 {
+		logger.Log.Info("Scheduled posts published", zap.Int64("count", published))
+	}
+}
@@ -0,0 +1,137 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/db"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
+	"gorm.io/gorm"
+	"strings"
+	"unicode/utf8"
+)
+
+// SearchConfig - конфигурация полнотекстового поиска PostgreSQL. Конфигурация russian обрабатывает
+// кириллицу словарем russian_stem, а латиницу - english_stem, поэтому подходит для смешанного контента.
+// Колонку posts.search_vector с этой конфигурацией создает миграция 0002_posts_search_vector
+const SearchConfig = "russian"
+
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+	snippetRadius  = 80
+)
+
+// SearchPosts - поиск читается с реплики, если она настроена
+func (repo *PostRepositoryImpl) SearchPosts(ctx context.Context, query string, params *pagination.Params) (*pagination.Page[SearchResult], error) {
+	if repo.db.Dialector.Name() != "postgres" {
+		return repo.searchPostsLike(ctx, query, params)
+	}
+
+	tsQuery := fmt.Sprintf("websearch_to_tsquery('%s', ?)", SearchConfig)
+	headlineOptions := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2", highlightStart, highlightStop)
+
+	base := db.Replica(repo.db.WithContext(ctx)).Model(&models.Post{}).
+		Where("status = ?", models.PostPublished).
+		Where("search_vector @@ "+tsQuery, query)
+
+	page := &pagination.Page[SearchResult]{Params: params}
+	if err := base.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]SearchResult, 0, params.Limit)
+	err := base.Session(&gorm.Session{}).
+		Select(
+			fmt.Sprintf("posts.*, ts_rank_cd(search_vector, %[1]s) AS rank, ts_headline('%[2]s', text, %[1]s, ?) AS snippet", tsQuery, SearchConfig),
+			query, query, headlineOptions,
+		).
+		Order("rank DESC, id DESC").
+		Limit(params.Limit).
+		Offset(params.Offset).
+		Scan(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	page.Items = items
+	return page, nil
+}
+
+// searchPostsLike - запасной вариант поиска для БД без tsvector: поиск подстроки без учета регистра.
+// LOWER в SQLite заменен в db на strings.ToLower, поэтому регистр не важен и для кириллицы.
+// Совпадение в заголовке ценится выше совпадения в тексте
+func (repo *PostRepositoryImpl) searchPostsLike(ctx context.Context, query string, params *pagination.Params) (*pagination.Page[SearchResult], error) {
+	pattern := "%" + escapeLike(strings.ToLower(query)) + "%"
+	base := db.Replica(repo.db.WithContext(ctx)).Model(&models.Post{}).
+		Where("status = ?", models.PostPublished).
+		Where("LOWER(title) LIKE ? ESCAPE '\\' OR LOWER(text) LIKE ? ESCAPE '\\'", pattern, pattern)
+
+	page := &pagination.Page[SearchResult]{Params: params}
+	if err := base.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]SearchResult, 0, params.Limit)
+	err := base.Session(&gorm.Session{}).
+		Select("posts.*, CASE WHEN LOWER(title) LIKE ? ESCAPE '\\' THEN 1.0 ELSE 0.5 END AS rank", pattern).
+		Order("rank DESC, id DESC").
+		Limit(params.Limit).
+		Offset(params.Offset).
+		Scan(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		items[i].Snippet = Highlight(items[i].Text, query)
+	}
+	page.Items = items
+	return page, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Highlight - вырезает из текста фрагмент вокруг первого вхождения query и выделяет совпадение
+func Highlight(text, query string) string {
+	lowerText := strings.ToLower(text)
+	needle := strings.ToLower(query)
+	idx := strings.Index(lowerText, needle)
+	// ToLower может изменить длину строки в байтах, в таком случае выделение не делаем
+	if idx < 0 || len(lowerText) != len(text) {
+		return truncate(text, 2*snippetRadius)
+	}
+
+	start := idx
+	for i := 0; i < snippetRadius && start > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := idx + len(needle)
+	for i := 0; i < snippetRadius && end < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	b.WriteString(text[start:idx])
+	b.WriteString(highlightStart)
+	b.WriteString(text[idx : idx+len(needle)])
+	b.WriteString(highlightStop)
+	b.WriteString(text[idx+len(needle) : end])
+	if end < len(text) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+func truncate(text string, runes int) string {
+	if utf8.RuneCountInString(text) <= runes {
+		return text
+	}
+	return string([]rune(text)[:runes]) + "..."
+}
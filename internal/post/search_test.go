@@ -0,0 +1,51 @@
+package post
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "Case insensitive match",
+			text:     "Writing APIs with Fiber",
+			query:    "fiber",
+			expected: "Writing APIs with <mark>Fiber</mark>",
+		},
+		{
+			name:     "Cyrillic match",
+			text:     "Блог на Go и Fiber",
+			query:    "блог",
+			expected: "<mark>Блог</mark> на Go и Fiber",
+		},
+		{
+			name:     "No match",
+			text:     "Writing APIs with Fiber",
+			query:    "gorm",
+			expected: "Writing APIs with Fiber",
+		},
+		{
+			name:     "Long text is cut around the match",
+			text:     strings.Repeat("a", 200) + " needle " + strings.Repeat("b", 200),
+			query:    "needle",
+			expected: "..." + strings.Repeat("a", 79) + " <mark>needle</mark> " + strings.Repeat("b", 79) + "...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Highlight(tt.text, tt.query))
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `100\% \_done\\`, escapeLike(`100% _done\`))
+}
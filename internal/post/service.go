@@ -1,21 +1,43 @@
 package post
 
 import (
+	"context"
+	"errors"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
+	"github.com/crafty-ezhik/blog-api/pkg/diff"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
+	"gorm.io/gorm"
+	"strings"
+	"time"
 )
 //go:generate mockgen -source=service.go -destination=mock/post_service_mock.go
 
 
 type PostService interface {
-	GetAllPosts() ([]models.Post, error)
-	GetPostById(postID uint) (*models.Post, error)
-	GetPostsByAuthorID(authorID uint) ([]models.Post, error)
-	CreatePost(post *models.Post) error
-	UpdatePost(postID uint, updatedFields *models.Post) error
-	DeletePost(postID uint) error
+	GetAllPosts(ctx context.Context, filter *Filter, params *pagination.Params) (*pagination.Page[models.Post], error)
+	GetPostById(ctx context.Context, postID, viewerID uint) (*models.Post, error)
+	GetPostsByAuthorID(ctx context.Context, authorID, viewerID uint, params *pagination.Params) (*pagination.Page[models.Post], error)
+	SearchPosts(ctx context.Context, query string, params *pagination.Params) (*pagination.Page[SearchResult], error)
+	CreatePost(ctx context.Context, actor policy.Actor, post *models.Post) error
+	UpdatePost(ctx context.Context, actor policy.Actor, postID uint, updatedFields *models.Post) error
+	DeletePost(ctx context.Context, actor policy.Actor, postID uint) error
+	PublishPost(ctx context.Context, actor policy.Actor, postID uint, publishAt *time.Time) (*models.Post, error)
+	UnpublishPost(ctx context.Context, actor policy.Actor, postID uint) (*models.Post, error)
+	ArchivePost(ctx context.Context, actor policy.Actor, postID uint) (*models.Post, error)
+	GetRevisions(ctx context.Context, actor policy.Actor, postID uint) ([]RevisionResponse, error)
+	GetRevision(ctx context.Context, actor policy.Actor, postID uint, revision int) (*RevisionDiffResponse, error)
+	RestoreRevision(ctx context.Context, actor policy.Actor, postID uint, revision int) error
+	SetCommentApproval(ctx context.Context, actor policy.Actor, postID uint, required bool) error
 }
 
+var (
+	ErrInvalidStatus    = errors.New("invalid post status")
+	ErrInvalidPublishAt = errors.New("publish_at must be in the future")
+	ErrRevisionNotFound = errors.New("revision not found")
+)
+
 type PostServiceImpl struct {
 	PostRepo PostRepository
 }
@@ -27,26 +49,195 @@ func NewPostService(postRepo PostRepository) *PostServiceImpl {
 	}
 }
 
-func (s *PostServiceImpl) GetAllPosts() ([]models.Post, error) {
-	return s.PostRepo.FindALL()
+func (s *PostServiceImpl) GetAllPosts(ctx context.Context, filter *Filter, params *pagination.Params) (*pagination.Page[models.Post], error) {
+	return s.PostRepo.FindALL(ctx, filter, params)
 }
 
-func (s *PostServiceImpl) GetPostById(postID uint) (*models.Post, error) {
-	return s.PostRepo.FindByID(postID)
+// GetPostById - неопубликованная статья для всех, кроме автора, считается несуществующей
+func (s *PostServiceImpl) GetPostById(ctx context.Context, postID, viewerID uint) (*models.Post, error) {
+	post, err := s.PostRepo.FindByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if !post.VisibleTo(viewerID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return post, nil
 }
 
-func (s *PostServiceImpl) GetPostsByAuthorID(authorID uint) ([]models.Post, error) {
-	return s.PostRepo.FindByUserID(authorID)
+func (s *PostServiceImpl) GetPostsByAuthorID(ctx context.Context, authorID, viewerID uint, params *pagination.Params) (*pagination.Page[models.Post], error) {
+	return s.PostRepo.FindByUserID(ctx, authorID, viewerID, params)
 }
 
-func (s *PostServiceImpl) CreatePost(post *models.Post) error {
-	return s.PostRepo.Create(post)
+func (s *PostServiceImpl) SearchPosts(ctx context.Context, query string, params *pagination.Params) (*pagination.Page[SearchResult], error) {
+	return s.PostRepo.SearchPosts(ctx, strings.TrimSpace(query), params)
 }
 
-func (s *PostServiceImpl) UpdatePost(postID uint, updatedFields *models.Post) error {
-	return s.PostRepo.Update(postID, updatedFields)
+func (s *PostServiceImpl) CreatePost(ctx context.Context, actor policy.Actor, post *models.Post) error {
+	if err := policy.CanCreatePost(actor); err != nil {
+		return err
+	}
+	if err := prepareStatus(post, time.Now()); err != nil {
+		return err
+	}
+	post.AuthorID = actor.UserID
+	return s.PostRepo.Create(ctx, post)
+}
+
+func (s *PostServiceImpl) UpdatePost(ctx context.Context, actor policy.Actor, postID uint, updatedFields *models.Post) error {
+	if _, err := s.editablePost(ctx, actor, postID); err != nil {
+		return err
+	}
+	return s.PostRepo.Update(ctx, postID, actor.UserID, updatedFields)
+}
+
+func (s *PostServiceImpl) DeletePost(ctx context.Context, actor policy.Actor, postID uint) error {
+	existedPost, err := s.PostRepo.FindByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if err = policy.CanDeletePost(actor, existedPost); err != nil {
+		return err
+	}
+	return s.PostRepo.Delete(ctx, postID)
+}
+
+func (s *PostServiceImpl) PublishPost(ctx context.Context, actor policy.Actor, postID uint, publishAt *time.Time) (*models.Post, error) {
+	return s.changeStatus(ctx, actor, postID, func(post *models.Post) {
+		now := time.Now()
+		if publishAt != nil && publishAt.After(now) {
+			post.Status, post.PublishedAt = models.PostScheduled, publishAt
+			return
+		}
+		post.Status, post.PublishedAt = models.PostPublished, &now
+	})
+}
+
+func (s *PostServiceImpl) UnpublishPost(ctx context.Context, actor policy.Actor, postID uint) (*models.Post, error) {
+	return s.changeStatus(ctx, actor, postID, func(post *models.Post) {
+		post.Status, post.PublishedAt = models.PostDraft, nil
+	})
+}
+
+// ArchivePost - статья скрывается из списков, но сохраняет дату публикации
+func (s *PostServiceImpl) ArchivePost(ctx context.Context, actor policy.Actor, postID uint) (*models.Post, error) {
+	return s.changeStatus(ctx, actor, postID, func(post *models.Post) {
+		post.Status = models.PostArchived
+	})
+}
+
+// GetRevisions - история правок доступна тем, кто может редактировать статью:
+// старые ревизии могут содержать удаленный намеренно текст
+func (s *PostServiceImpl) GetRevisions(ctx context.Context, actor policy.Actor, postID uint) ([]RevisionResponse, error) {
+	if _, err := s.editablePost(ctx, actor, postID); err != nil {
+		return nil, err
+	}
+	revisions, err := s.PostRepo.FindRevisions(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]RevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, RevisionResponse{
+			Revision:  revision.Revision,
+			Title:     revision.Title,
+			EditorID:  revision.EditorID,
+			CreatedAt: revision.CreatedAt,
+		})
+	}
+	return result, nil
 }
 
-func (s *PostServiceImpl) DeletePost(postID uint) error {
-	return s.PostRepo.Delete(postID)
+func (s *PostServiceImpl) GetRevision(ctx context.Context, actor policy.Actor, postID uint, revision int) (*RevisionDiffResponse, error) {
+	existedPost, err := s.editablePost(ctx, actor, postID)
+	if err != nil {
+		return nil, err
+	}
+	postRevision, err := s.findRevision(ctx, postID, revision)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := diff.Lines(postRevision.Text, existedPost.Text)
+	if err != nil {
+		return nil, err
+	}
+	return &RevisionDiffResponse{
+		PostRevision: *postRevision,
+		CurrentTitle: existedPost.Title,
+		Diff:         lines,
+	}, nil
+}
+
+// RestoreRevision - возвращает статье заголовок и текст ревизии. Восстановление сохраняется как новая ревизия
+func (s *PostServiceImpl) RestoreRevision(ctx context.Context, actor policy.Actor, postID uint, revision int) error {
+	if _, err := s.editablePost(ctx, actor, postID); err != nil {
+		return err
+	}
+	postRevision, err := s.findRevision(ctx, postID, revision)
+	if err != nil {
+		return err
+	}
+	return s.PostRepo.Update(ctx, postID, actor.UserID, &models.Post{
+		Title: postRevision.Title,
+		Text:  postRevision.Text,
+	})
+}
+
+// SetCommentApproval - автор статьи сам решает, нужна ли премодерация комментариев к ней
+func (s *PostServiceImpl) SetCommentApproval(ctx context.Context, actor policy.Actor, postID uint, required bool) error {
+	if _, err := s.editablePost(ctx, actor, postID); err != nil {
+		return err
+	}
+	return s.PostRepo.UpdateCommentApproval(ctx, postID, required)
+}
+
+func (s *PostServiceImpl) findRevision(ctx context.Context, postID uint, revision int) (*models.PostRevision, error) {
+	postRevision, err := s.PostRepo.FindRevision(ctx, postID, revision)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	return postRevision, err
+}
+
+func (s *PostServiceImpl) editablePost(ctx context.Context, actor policy.Actor, postID uint) (*models.Post, error) {
+	existedPost, err := s.PostRepo.FindByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err = policy.CanUpdatePost(actor, existedPost); err != nil {
+		return nil, err
+	}
+	return existedPost, nil
+}
+
+// changeStatus - проверяет права на изменение статьи, применяет к ней apply и сохраняет статус и дату публикации
+func (s *PostServiceImpl) changeStatus(ctx context.Context, actor policy.Actor, postID uint, apply func(post *models.Post)) (*models.Post, error) {
+	existedPost, err := s.editablePost(ctx, actor, postID)
+	if err != nil {
+		return nil, err
+	}
+	apply(existedPost)
+	if err = s.PostRepo.UpdateStatus(ctx, postID, existedPost.Status, existedPost.PublishedAt); err != nil {
+		return nil, err
+	}
+	return existedPost, nil
+}
+
+// prepareStatus - проверяет статус новой статьи и проставляет дату публикации
+func prepareStatus(post *models.Post, now time.Time) error {
+	switch post.Status {
+	case "", models.PostDraft:
+		post.Status = models.PostDraft
+		post.PublishedAt = nil
+	case models.PostPublished:
+		post.PublishedAt = &now
+	case models.PostScheduled:
+		if post.PublishedAt == nil || !post.PublishedAt.After(now) {
+			return ErrInvalidPublishAt
+		}
+	default:
+		return ErrInvalidStatus
+	}
+	return nil
 }
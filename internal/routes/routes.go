@@ -3,11 +3,18 @@ package routes
 import (
 	"github.com/crafty-ezhik/blog-api/internal/auth"
 	"github.com/crafty-ezhik/blog-api/internal/comment"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"github.com/crafty-ezhik/blog-api/internal/health"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
 	"github.com/crafty-ezhik/blog-api/internal/post"
+	"github.com/crafty-ezhik/blog-api/internal/tag"
+	"github.com/crafty-ezhik/blog-api/internal/token"
 	"github.com/crafty-ezhik/blog-api/internal/user"
 	"github.com/crafty-ezhik/blog-api/pkg/jwt"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
 	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/ratelimit"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -16,45 +23,140 @@ type RouteDeps struct {
 	UserHandler    user.UserHandler
 	PostHandler    post.PostHandler
 	CommentHandler comment.CommentHandler
+	TagHandler     tag.TagHandler
+	TokenHandler   token.TokenHandler
+	HealthHandler  health.HealthHandler
 	JWT            *jwt.JWT
+	RoleProvider   middleware.RoleProvider
+	Permissions    middleware.PermissionChecker
+	PersonalTokens middleware.PersonalTokenVerifier
+	RateLimiter    ratelimit.Limiter // если nil, лимиты считаются в памяти процесса
+	RateLimits     config.RateLimitConfig
 }
 
 func SetupRoutes(app *fiber.App, deps RouteDeps) {
 	logger.Log.Debug("Setting routes...")
+	app.Get("/.well-known/jwks.json", deps.AuthHandler.JWKS)
+
+	// Ограничения частоты запросов
+	limiter := deps.RateLimiter
+	if limiter == nil {
+		// Живет вместе с приложением, janitor не останавливается
+		limiter = ratelimit.NewMemoryLimiter(0)
+	}
+	postsLimit := middleware.RateLimit(limiter, ratelimit.NewPolicy("posts", deps.RateLimits.Posts))
+	commentsLimit := middleware.RateLimit(limiter, ratelimit.NewPolicy("comments", deps.RateLimits.Comments))
+	searchLimit := middleware.RateLimit(limiter, ratelimit.NewPolicy("search", deps.RateLimits.Search))
+	// Пароль и код 2FA считаются одной политикой, чтобы второй шаг нельзя было перебирать отдельно от первого
+	loginLimit := middleware.RateLimit(limiter, ratelimit.NewPolicy("login", deps.RateLimits.Login))
+
+	// Проверки для оркестратора и балансировщика, без авторизации
+	app.Get("/healthz", deps.HealthHandler.Live)  // Процесс жив
+	app.Get("/readyz", deps.HealthHandler.Ready)  // БД, Redis и схема доступны, экземпляр не останавливается
+	app.Get("/health", deps.HealthHandler.Health) // Подробный отчет с задержкой каждой проверки
+
 	// Auth
 	app.Route("/auth", func(router fiber.Router) {
 		router.Post("/register", deps.AuthHandler.Register)
-		router.Post("/login", deps.AuthHandler.Login)
-		router.Post("/logout", middleware.AuthMiddleware(deps.JWT), deps.AuthHandler.Logout)
-		router.Post("/refresh", middleware.AuthMiddleware(deps.JWT), deps.AuthHandler.Refresh)
+		router.Post("/login", loginLimit, deps.AuthHandler.Login)
+		router.Post("/login/mfa", loginLimit, deps.AuthHandler.LoginMFA)
+		router.Post("/logout", middleware.AuthMiddleware(deps.JWT, nil), deps.AuthHandler.Logout)
+		router.Post("/refresh", deps.AuthHandler.Refresh)
+
+		router.Post("/verify-email", deps.AuthHandler.VerifyEmail)
+		router.Post("/forgot-password", deps.AuthHandler.ForgotPassword)
+		router.Post("/reset-password", deps.AuthHandler.ResetPassword)
+		router.Post("/confirm-email", deps.AuthHandler.ConfirmEmailChange)
+
+		router.Get("/oauth/:provider/start", deps.AuthHandler.OAuthStart)       // Перенаправление на вход у провайдера
+		router.Get("/oauth/:provider/callback", deps.AuthHandler.OAuthCallback) // Возврат от провайдера, выдача токенов
 	})
 
-	api := app.Group("/api", middleware.AuthMiddleware(deps.JWT))
+	api := app.Group("/api", middleware.AuthMiddleware(deps.JWT, deps.PersonalTokens), middleware.RoleMiddleware(deps.RoleProvider))
+
+	// Проверки прав на создание ресурсов
+	canCreatePost := middleware.RequirePermission(deps.Permissions, string(policy.PostCreate))
+	canCreateComment := middleware.RequirePermission(deps.Permissions, string(policy.CommentCreate))
+	canModerate := middleware.RequirePermission(deps.Permissions, string(policy.CommentModerate))
+	adminOnly := middleware.RequireRole(string(models.RoleAdmin))
+
+	// Права personal access токенов. Запросы с JWT сессии ими не ограничиваются
+	postsRead := middleware.RequireScope(string(policy.ScopePostsRead))
+	postsWrite := middleware.RequireScope(string(policy.ScopePostsWrite))
+	commentsRead := middleware.RequireScope(string(policy.ScopeCommentsRead))
+	commentsWrite := middleware.RequireScope(string(policy.ScopeCommentsWrite))
+	usersRead := middleware.RequireScope(string(policy.ScopeUsersRead))
+	usersWrite := middleware.RequireScope(string(policy.ScopeUsersWrite))
+	sessionOnly := middleware.RequireSession()
 
 	// Users
 	api.Route("users", func(router fiber.Router) {
-		router.Get("/me", deps.UserHandler.GetMe)
-		router.Get("/:id", deps.UserHandler.GetByID)
-		router.Patch("/me", deps.UserHandler.Update)
-		router.Delete("/:id", deps.UserHandler.Delete)
-		router.Get("/my/posts", deps.UserHandler.GetMyPosts)                           // Получение постов пользователя
-		router.Get("/my/posts/:postId/comments", deps.CommentHandler.GetMyComment)     // Получение всех своих комментариев к статье
-		router.Get("/:id/posts", deps.UserHandler.GetUserPostsByID)                    // Получение постов по id пользователя
-		router.Get("/:id/posts/:postId/comments", deps.CommentHandler.GetUserComments) // Получение всех комментариев к статье по id пользователя
+		router.Get("/me", usersRead, deps.UserHandler.GetMe)
+		router.Get("/:id", usersRead, deps.UserHandler.GetByID)
+		router.Patch("/me", usersWrite, deps.UserHandler.Update)
+		router.Delete("/:id", usersWrite, deps.UserHandler.Delete)
+		router.Get("/my/posts", postsRead, deps.UserHandler.GetMyPosts)                              // Получение постов пользователя
+		router.Get("/my/posts/:postId/comments", commentsRead, deps.CommentHandler.GetMyComment)     // Получение всех своих комментариев к статье
+		router.Get("/:id/posts", postsRead, deps.UserHandler.GetUserPostsByID)                       // Получение постов по id пользователя
+		router.Get("/:id/posts/:postId/comments", commentsRead, deps.CommentHandler.GetUserComments) // Получение всех комментариев к статье по id пользователя
+
+		router.Patch("/:id/role", usersWrite, adminOnly, deps.UserHandler.UpdateRole)     // Смена роли пользователя
+		router.Delete("/:id/lockout", usersWrite, adminOnly, deps.AuthHandler.UnlockUser) // Снятие блокировки входа
+
+		router.Post("/me/password", sessionOnly, deps.AuthHandler.ChangePassword) // Смена пароля
+		router.Post("/me/email", sessionOnly, deps.AuthHandler.ChangeEmail)       // Запрос смены email
+		router.Post("/me/2fa", sessionOnly, deps.AuthHandler.EnrollTOTP)          // Подключение 2FA: секрет и otpauth:// URI
+		router.Post("/me/2fa/confirm", sessionOnly, deps.AuthHandler.ConfirmTOTP) // Включение 2FA первым кодом, выдача резервных кодов
+
+		router.Get("/me/sessions", sessionOnly, deps.AuthHandler.GetSessions)          // Активные сессии по устройствам
+		router.Delete("/me/sessions", sessionOnly, deps.AuthHandler.DeleteAllSessions) // Выход со всех устройств
+		router.Delete("/me/sessions/:id", sessionOnly, deps.AuthHandler.DeleteSession) // Завершение одной сессии
+
+		router.Get("/me/tokens", sessionOnly, deps.TokenHandler.List)          // Personal access токены
+		router.Post("/me/tokens", sessionOnly, deps.TokenHandler.Create)       // Выпуск токена, секрет показывается один раз
+		router.Delete("/me/tokens/:id", sessionOnly, deps.TokenHandler.Revoke) // Отзыв токена
 	})
 
 	// Posts
 	api.Route("posts", func(router fiber.Router) {
-		router.Post("/", deps.PostHandler.CreatePost)      // Создание статьи
-		router.Get("/", deps.PostHandler.GetAllPosts)      // Получение всех статей
-		router.Get("/:id", deps.PostHandler.GetPostById)   // Получение конкретной статьи
-		router.Patch("/:id", deps.PostHandler.UpdatePost)  // Обновление статьи
-		router.Delete("/:id", deps.PostHandler.DeletePost) // Удаление статьи
-
-		router.Get("/:id/comments", deps.CommentHandler.GetAllCommentsPost)          // Получение всех комментариев к статье
-		router.Post("/:id/comments", deps.CommentHandler.CreateComments)             // Создание комментария к посту
-		router.Patch("/:id/comments/:commentId", deps.CommentHandler.UpdateComment)  // Обновление комментария
-		router.Delete("/:id/comments/:commentId", deps.CommentHandler.DeleteComment) // Удаление комментария
+		router.Post("/", postsWrite, postsLimit, canCreatePost, deps.PostHandler.CreatePost) // Создание статьи
+		router.Get("/", postsRead, deps.PostHandler.GetAllPosts)                             // Получение всех статей
+		router.Get("/search", postsRead, searchLimit, deps.PostHandler.SearchPosts)          // Полнотекстовый поиск по статьям
+		router.Get("/:id", postsRead, deps.PostHandler.GetPostById)                          // Получение конкретной статьи
+		router.Patch("/:id", postsWrite, deps.PostHandler.UpdatePost)                        // Обновление статьи
+		router.Delete("/:id", postsWrite, deps.PostHandler.DeletePost)                       // Удаление статьи
+
+		router.Post("/:id/publish", postsWrite, deps.PostHandler.PublishPost)     // Публикация статьи сразу или по расписанию
+		router.Post("/:id/unpublish", postsWrite, deps.PostHandler.UnpublishPost) // Возврат статьи в черновики
+		router.Post("/:id/archive", postsWrite, deps.PostHandler.ArchivePost)     // Архивирование статьи
+
+		router.Get("/:id/revisions", postsRead, deps.PostHandler.GetRevisions)                   // История правок статьи
+		router.Get("/:id/revisions/:rev", postsRead, deps.PostHandler.GetRevision)               // Ревизия и ее отличия от текущего текста
+		router.Post("/:id/revisions/:rev/restore", postsWrite, deps.PostHandler.RestoreRevision) // Восстановление статьи из ревизии
+		router.Patch("/:id/moderation", postsWrite, deps.PostHandler.SetCommentApproval)         // Премодерация комментариев к статье
+
+		router.Get("/:id/comments", commentsRead, deps.CommentHandler.GetAllCommentsPost)                                // Получение всех комментариев к статье
+		router.Post("/:id/comments", commentsWrite, commentsLimit, canCreateComment, deps.CommentHandler.CreateComments) // Создание комментария к посту
+		router.Patch("/:id/comments/:commentId", commentsWrite, deps.CommentHandler.UpdateComment)                       // Обновление комментария
+		router.Delete("/:id/comments/:commentId", commentsWrite, deps.CommentHandler.DeleteComment)                      // Удаление комментария
+	})
+
+	// Comments moderation
+	api.Route("comments", func(router fiber.Router) {
+		router.Get("/moderation", commentsRead, canModerate, deps.CommentHandler.GetModerationQueue) // Очередь модерации
+		router.Post("/moderation", commentsWrite, canModerate, deps.CommentHandler.ModerateComments) // Массовое одобрение или отклонение
+	})
+
+	// Tags
+	api.Route("tags", func(router fiber.Router) {
+		router.Get("/", postsRead, deps.TagHandler.GetAllTags)             // Получение тегов с количеством статей
+		router.Get("/:slug/posts", postsRead, deps.TagHandler.GetTagPosts) // Получение статей с тегом
+	})
+
+	// Categories
+	api.Route("categories", func(router fiber.Router) {
+		router.Get("/", postsRead, deps.TagHandler.GetAllCategories)            // Получение категорий с количеством статей
+		router.Get("/:slug/posts", postsRead, deps.TagHandler.GetCategoryPosts) // Получение статей категории
 	})
 
 	logger.Log.Debug("The installation of routes was successful!")
@@ -0,0 +1,88 @@
+// Package storage - хранилища состояния приложения: токены, сессии, state входа через провайдера,
+// счетчики блокировок и лимитов. Хранятся в Redis или, для одного экземпляра и тестов, в памяти процесса
+package storage
+
+import (
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"github.com/crafty-ezhik/blog-api/pkg/jwt"
+	"github.com/crafty-ezhik/blog-api/pkg/lockout"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/oidc"
+	"github.com/crafty-ezhik/blog-api/pkg/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+)
+
+type Storages struct {
+	BlackList     jwt.BlackListStorage
+	TokenVersions jwt.TokenVersionStorage
+	Sessions      jwt.SessionStorage
+	OneTimeTokens jwt.OneTimeTokenStorage
+	OAuthStates   oidc.StateStorage
+	Lockout       lockout.Storage
+	RateLimiter   ratelimit.Limiter
+
+	// Redis - клиент, если выбран драйвер redis
+	Redis *redis.Client
+
+	closers []func()
+}
+
+// New - создает хранилища для драйвера из redis.driver
+func New(cfg config.RedisConfig) (*Storages, error) {
+	switch cfg.Driver {
+	case DriverRedis, "":
+		logger.Log.Debug("Init Redis Client")
+		rdb := redis.NewClient(&redis.Options{
+			Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+			Password: cfg.Password,
+			DB:       cfg.Db,
+		})
+		blackList, versioner := jwt.NewRedisStorage(rdb)
+		// Лимиты в памяти принимают решения, пока Redis недоступен
+		fallbackLimiter := ratelimit.NewMemoryLimiter(cfg.CleanupInterval)
+		return &Storages{
+			BlackList:     blackList,
+			TokenVersions: versioner,
+			Sessions:      jwt.NewRedisSessions(rdb),
+			OneTimeTokens: jwt.NewRedisOneTimeTokens(rdb),
+			OAuthStates:   oidc.NewRedisStates(rdb),
+			Lockout:       lockout.NewRedisStorage(rdb),
+			RateLimiter:   ratelimit.NewRedisLimiter(rdb, fallbackLimiter),
+			Redis:         rdb,
+			closers:       []func(){func() { _ = rdb.Close() }, fallbackLimiter.Close},
+		}, nil
+	case DriverMemory:
+		logger.Log.Warn("State is stored in memory: it is lost on restart and not shared between instances")
+		blackList, versioner := jwt.NewMemoryStorage(cfg.CleanupInterval)
+		sessions := jwt.NewMemorySessions(cfg.CleanupInterval)
+		oneTimeTokens := jwt.NewMemoryOneTimeTokens(cfg.CleanupInterval)
+		oauthStates := oidc.NewMemoryStates(cfg.CleanupInterval)
+		lockouts := lockout.NewMemoryStorage(cfg.CleanupInterval)
+		limiter := ratelimit.NewMemoryLimiter(cfg.CleanupInterval)
+		return &Storages{
+			BlackList:     blackList,
+			TokenVersions: versioner,
+			Sessions:      sessions,
+			OneTimeTokens: oneTimeTokens,
+			OAuthStates:   oauthStates,
+			Lockout:       lockouts,
+			RateLimiter:   limiter,
+			closers:       []func(){blackList.Close, sessions.Close, oneTimeTokens.Close, oauthStates.Close, lockouts.Close, limiter.Close},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+}
+
+// Close - закрывает соединение с Redis или останавливает очистку хранилищ в памяти
+func (s *Storages) Close() {
+	for _, closeFn := range s.closers {
+		closeFn()
+	}
+}
@@ -0,0 +1,109 @@
+package tag
+
+import (
+	"errors"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
+	"github.com/crafty-ezhik/blog-api/internal/post"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
+	"github.com/crafty-ezhik/blog-api/pkg/res"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type TagHandler interface {
+	GetAllTags(c *fiber.Ctx) error
+	GetTagPosts(c *fiber.Ctx) error
+	GetAllCategories(c *fiber.Ctx) error
+	GetCategoryPosts(c *fiber.Ctx) error
+}
+
+type TagHandlerImpl struct {
+	TagService  TagService
+	PostService post.PostService
+}
+
+func NewTagHandler(tagService TagService, postService post.PostService) *TagHandlerImpl {
+	logger.Log.Debug("Init tag handler")
+	return &TagHandlerImpl{
+		TagService:  tagService,
+		PostService: postService,
+	}
+}
+
+func (h *TagHandlerImpl) GetAllTags(c *fiber.Ctx) error {
+	tags, err := h.TagService.GetAllTags(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Something went wrong",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    tags,
+	})
+}
+
+func (h *TagHandlerImpl) GetTagPosts(c *fiber.Ctx) error {
+	tag, err := h.TagService.GetTagBySlug(c.UserContext(), c.Params("slug"))
+	if err != nil {
+		return h.lookupError(c, err, "Tag not found")
+	}
+	return h.getPosts(c, &post.Filter{Tags: []string{tag.Slug}})
+}
+
+func (h *TagHandlerImpl) GetAllCategories(c *fiber.Ctx) error {
+	categories, err := h.TagService.GetAllCategories(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Something went wrong",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    categories,
+	})
+}
+
+func (h *TagHandlerImpl) GetCategoryPosts(c *fiber.Ctx) error {
+	category, err := h.TagService.GetCategoryBySlug(c.UserContext(), c.Params("slug"))
+	if err != nil {
+		return h.lookupError(c, err, "Category not found")
+	}
+	return h.getPosts(c, &post.Filter{Category: category.Slug})
+}
+
+func (h *TagHandlerImpl) getPosts(c *fiber.Ctx, filter *post.Filter) error {
+	params, err := pagination.ParseQuery(c, post.DefaultSort, post.SortFields...)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	filter.ViewerID = policy.ActorFromCtx(c).UserID
+	page, err := h.PostService.GetAllPosts(c.UserContext(), filter, params)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Something went wrong",
+		})
+	}
+	return res.PageResponse(c, page.Items, page.Meta())
+}
+
+func (h *TagHandlerImpl) lookupError(c *fiber.Ctx, err error, notFound string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": notFound,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"success": false,
+		"message": "Something went wrong",
+	})
+}
@@ -0,0 +1,174 @@
+package tag_test
+
+import (
+	"errors"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/post"
+	"github.com/crafty-ezhik/blog-api/internal/tag"
+	mock_post "github.com/crafty-ezhik/blog-api/mocks/post"
+	mock_tag "github.com/crafty-ezhik/blog-api/mocks/tag"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type Mocks struct {
+	TagService  *mock_tag.MockTagService
+	PostService *mock_post.MockPostService
+}
+
+func setup(t *testing.T) (tag.TagHandler, *Mocks) {
+	ctrl := gomock.NewController(t)
+
+	mocks := &Mocks{
+		TagService:  mock_tag.NewMockTagService(ctrl),
+		PostService: mock_post.NewMockPostService(ctrl),
+	}
+	return tag.NewTagHandler(mocks.TagService, mocks.PostService), mocks
+}
+
+func TestTagHandlerImpl_GetAllTags(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	tagHandler, mocks := setup(t)
+
+	tests := []struct {
+		name               string
+		mockSetup          func(mock *Mocks)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "Success",
+			mockSetup: func(mock *Mocks) {
+				mock.TagService.EXPECT().GetAllTags(gomock.Any()).Return([]tag.CountResponse{
+					{ID: 1, Name: "Go", Slug: "go", PostsCount: 3},
+				}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"posts_count":3`,
+		},
+		{
+			name: "Server internal error",
+			mockSetup: func(mock *Mocks) {
+				mock.TagService.EXPECT().GetAllTags(gomock.Any()).Return(nil, errors.New("server Error"))
+			},
+			expectedStatusCode: 500,
+			expectedBody:       "Something went wrong",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/api/tags", tagHandler.GetAllTags)
+
+			tt.mockSetup(mocks)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/tags", nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, resp.StatusCode)
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(respBody), tt.expectedBody)
+		})
+	}
+}
+
+func TestTagHandlerImpl_GetTagPosts(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	tagHandler, mocks := setup(t)
+
+	path := "/api/tags/:slug/posts"
+
+	tests := []struct {
+		name               string
+		url                string
+		mockSetup          func(mock *Mocks)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "Success",
+			url:  "/api/tags/go/posts",
+			mockSetup: func(mock *Mocks) {
+				mock.TagService.EXPECT().GetTagBySlug(gomock.Any(), "go").Return(&models.Tag{ID: 1, Name: "Go", Slug: "go"}, nil)
+				mock.PostService.EXPECT().GetAllPosts(gomock.Any(), &post.Filter{Tags: []string{"go"}}, gomock.Any()).
+					Return(&pagination.Page[models.Post]{
+						Items:  []models.Post{{ID: 1}},
+						Total:  1,
+						Params: &pagination.Params{Limit: pagination.DefaultLimit},
+					}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       `"total":1`,
+		},
+		{
+			name: "Tag not found",
+			url:  "/api/tags/rust/posts",
+			mockSetup: func(mock *Mocks) {
+				mock.TagService.EXPECT().GetTagBySlug(gomock.Any(), "rust").Return(nil, gorm.ErrRecordNotFound)
+			},
+			expectedStatusCode: 404,
+			expectedBody:       "Tag not found",
+		},
+		{
+			name: "Invalid limit",
+			url:  "/api/tags/go/posts?limit=0",
+			mockSetup: func(mock *Mocks) {
+				mock.TagService.EXPECT().GetTagBySlug(gomock.Any(), "go").Return(&models.Tag{ID: 1, Name: "Go", Slug: "go"}, nil)
+			},
+			expectedStatusCode: 400,
+			expectedBody:       "limit must be between 1 and 100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get(path, tagHandler.GetTagPosts)
+
+			tt.mockSetup(mocks)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.url, nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, resp.StatusCode)
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(respBody), tt.expectedBody)
+		})
+	}
+}
+
+func TestTagHandlerImpl_GetCategoryPosts(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	tagHandler, mocks := setup(t)
+
+	mocks.TagService.EXPECT().GetCategoryBySlug(gomock.Any(), "backend").Return(&models.Category{ID: 1, Name: "Backend", Slug: "backend"}, nil)
+	mocks.PostService.EXPECT().GetAllPosts(gomock.Any(), &post.Filter{Category: "backend"}, gomock.Any()).
+		Return(&pagination.Page[models.Post]{
+			Items:  []models.Post{},
+			Params: &pagination.Params{Limit: pagination.DefaultLimit},
+		}, nil)
+
+	app := fiber.New()
+	app.Get("/api/categories/:slug/posts", tagHandler.GetCategoryPosts)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/categories/backend/posts", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
@@ -0,0 +1,9 @@
+package tag
+
+// CountResponse - тег или категория с количеством опубликованных статей
+type CountResponse struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	PostsCount int64  `json:"posts_count"`
+}
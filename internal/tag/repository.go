@@ -0,0 +1,60 @@
+package tag
+
+import (
+	"context"
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/db"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type TagRepository interface {
+	FindAllTags(ctx context.Context) ([]CountResponse, error)
+	FindTagBySlug(ctx context.Context, slug string) (*models.Tag, error)
+	FindAllCategories(ctx context.Context) ([]CountResponse, error)
+	FindCategoryBySlug(ctx context.Context, slug string) (*models.Category, error)
+}
+
+type TagRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) *TagRepositoryImpl {
+	logger.Log.Debug("Init tag repository")
+	return &TagRepositoryImpl{db: db}
+}
+
+func (repo *TagRepositoryImpl) FindAllTags(ctx context.Context) ([]CountResponse, error) {
+	return repo.countPosts(ctx, "tags", "post_tags", "tag_id")
+}
+
+func (repo *TagRepositoryImpl) FindTagBySlug(ctx context.Context, slug string) (*models.Tag, error) {
+	var tag models.Tag
+	result := repo.db.WithContext(ctx).Where("slug = ?", slug).First(&tag)
+	return &tag, result.Error
+}
+
+func (repo *TagRepositoryImpl) FindAllCategories(ctx context.Context) ([]CountResponse, error) {
+	return repo.countPosts(ctx, "categories", "post_categories", "category_id")
+}
+
+func (repo *TagRepositoryImpl) FindCategoryBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	var category models.Category
+	result := repo.db.WithContext(ctx).Where("slug = ?", slug).First(&category)
+	return &category, result.Error
+}
+
+// countPosts - список записей table с количеством опубликованных статей, связанных через joinTable.
+// Счетчики читаются с реплики, если она настроена
+func (repo *TagRepositoryImpl) countPosts(ctx context.Context, table, joinTable, foreignKey string) ([]CountResponse, error) {
+	result := make([]CountResponse, 0)
+	err := db.Replica(repo.db.WithContext(ctx)).Table(table).
+		Select(fmt.Sprintf("%[1]s.id, %[1]s.name, %[1]s.slug, COUNT(posts.id) AS posts_count", table)).
+		Joins(fmt.Sprintf("LEFT JOIN %[1]s ON %[1]s.%[2]s = %[3]s.id", joinTable, foreignKey, table)).
+		Joins(fmt.Sprintf("LEFT JOIN posts ON posts.id = %s.post_id AND posts.deleted_at IS NULL AND posts.status = ?", joinTable), models.PostPublished).
+		Group(fmt.Sprintf("%[1]s.id, %[1]s.name, %[1]s.slug", table)).
+		Order(fmt.Sprintf("posts_count DESC, %s.slug", table)).
+		Scan(&result).Error
+	return result, err
+}
@@ -0,0 +1,43 @@
+package tag
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+)
+
+//go:generate mockgen -source=service.go -destination=mock/tag_service_mock.go
+
+type TagService interface {
+	GetAllTags(ctx context.Context) ([]CountResponse, error)
+	GetTagBySlug(ctx context.Context, slug string) (*models.Tag, error)
+	GetAllCategories(ctx context.Context) ([]CountResponse, error)
+	GetCategoryBySlug(ctx context.Context, slug string) (*models.Category, error)
+}
+
+type TagServiceImpl struct {
+	TagRepo TagRepository
+}
+
+func NewTagService(tagRepo TagRepository) *TagServiceImpl {
+	logger.Log.Debug("Init tag service")
+	return &TagServiceImpl{
+		TagRepo: tagRepo,
+	}
+}
+
+func (s *TagServiceImpl) GetAllTags(ctx context.Context) ([]CountResponse, error) {
+	return s.TagRepo.FindAllTags(ctx)
+}
+
+func (s *TagServiceImpl) GetTagBySlug(ctx context.Context, slug string) (*models.Tag, error) {
+	return s.TagRepo.FindTagBySlug(ctx, models.Slugify(slug))
+}
+
+func (s *TagServiceImpl) GetAllCategories(ctx context.Context) ([]CountResponse, error) {
+	return s.TagRepo.FindAllCategories(ctx)
+}
+
+func (s *TagServiceImpl) GetCategoryBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	return s.TagRepo.FindCategoryBySlug(ctx, models.Slugify(slug))
+}
@@ -0,0 +1,125 @@
+package token
+
+import (
+	"errors"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/req"
+	"github.com/crafty-ezhik/blog-api/pkg/validate"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"strconv"
+)
+
+type TokenHandler interface {
+	Create(c *fiber.Ctx) error
+	List(c *fiber.Ctx) error
+	Revoke(c *fiber.Ctx) error
+}
+
+type TokenHandlerImpl struct {
+	TokenService TokenService
+	v            *validate.XValidator
+}
+
+func NewTokenHandler(tokenService TokenService, validator *validate.XValidator) *TokenHandlerImpl {
+	logger.Log.Debug("Init token handler")
+	return &TokenHandlerImpl{
+		TokenService: tokenService,
+		v:            validator,
+	}
+}
+
+func (h *TokenHandlerImpl) Create(c *fiber.Ctx) error {
+	ctxUserID, ok := c.Locals(middleware.UserIDKey).(uint)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "user id must be a uint",
+		})
+	}
+
+	body, err := req.HandleBody[CreateRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
+
+	token, err := h.TokenService.Create(c.UserContext(), ctxUserID, body)
+	if errors.Is(err, ErrUnknownScope) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+	if err != nil {
+		logger.Log.Error("Failed to create token", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Something went wrong",
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    token,
+	})
+}
+
+func (h *TokenHandlerImpl) List(c *fiber.Ctx) error {
+	ctxUserID, ok := c.Locals(middleware.UserIDKey).(uint)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "user id must be a uint",
+		})
+	}
+
+	tokens, err := h.TokenService.List(c.UserContext(), ctxUserID)
+	if err != nil {
+		logger.Log.Error("Failed to list tokens", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Something went wrong",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    tokens,
+	})
+}
+
+func (h *TokenHandlerImpl) Revoke(c *fiber.Ctx) error {
+	ctxUserID, ok := c.Locals(middleware.UserIDKey).(uint)
+	if !ok {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "user id must be a uint",
+		})
+	}
+
+	tokenID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Token Id is invalid",
+		})
+	}
+
+	err = h.TokenService.Revoke(c.UserContext(), ctxUserID, uint(tokenID))
+	if errors.Is(err, ErrTokenNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Token not found",
+		})
+	}
+	if err != nil {
+		logger.Log.Error("Failed to revoke token", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Something went wrong",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"message": "Token revoked",
+	})
+}
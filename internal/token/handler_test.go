@@ -0,0 +1,172 @@
+package token_test
+
+import (
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/internal/token"
+	mock_token "github.com/crafty-ezhik/blog-api/mocks/token"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/validate"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type Mocks struct {
+	TokenService *mock_token.MockTokenService
+}
+
+func setup(t *testing.T) (token.TokenHandler, *Mocks) {
+	ctrl := gomock.NewController(t)
+
+	mocks := &Mocks{
+		TokenService: mock_token.NewMockTokenService(ctrl),
+	}
+	v := &validate.XValidator{Validator: validator.New()}
+	return token.NewTokenHandler(mocks.TokenService, v), mocks
+}
+
+// withUser - имитация AuthMiddleware
+func withUser(c *fiber.Ctx) error {
+	c.Locals(middleware.UserIDKey, uint(1))
+	return c.Next()
+}
+
+func TestTokenHandlerImpl_Create(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	tokenHandler, mocks := setup(t)
+
+	tests := []struct {
+		name               string
+		body               string
+		mockSetup          func(mock *Mocks)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "Success",
+			body: `{"name":"ci","expires_in_days":30,"scopes":["posts:write"]}`,
+			mockSetup: func(mock *Mocks) {
+				mock.TokenService.EXPECT().Create(gomock.Any(), uint(1), &token.CreateRequest{
+					Name:          "ci",
+					ExpiresInDays: 30,
+					Scopes:        []string{"posts:write"},
+				}).Return(&token.CreateResponse{
+					TokenResponse: token.TokenResponse{ID: 1, Name: "ci", Scopes: []string{"posts:write"}},
+					Token:         middleware.PersonalTokenPrefix + "secret",
+				}, nil)
+			},
+			expectedStatusCode: 201,
+			expectedBody:       `"token":"` + middleware.PersonalTokenPrefix + `secret"`,
+		},
+		{
+			name: "Unknown scope",
+			body: `{"name":"ci","expires_in_days":30,"scopes":["posts:delete"]}`,
+			mockSetup: func(mock *Mocks) {
+				mock.TokenService.EXPECT().Create(gomock.Any(), uint(1), gomock.Any()).
+					Return(nil, fmt.Errorf("%w: posts:delete", token.ErrUnknownScope))
+			},
+			expectedStatusCode: 400,
+			expectedBody:       "unknown scope: posts:delete",
+		},
+		{
+			name:               "Missing expiry",
+			body:               `{"name":"ci","scopes":["posts:write"]}`,
+			mockSetup:          func(mock *Mocks) {},
+			expectedStatusCode: 400,
+			expectedBody:       "Validation error",
+		},
+		{
+			name:               "Empty scopes",
+			body:               `{"name":"ci","expires_in_days":30,"scopes":[]}`,
+			mockSetup:          func(mock *Mocks) {},
+			expectedStatusCode: 400,
+			expectedBody:       "Validation error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Post("/api/users/me/tokens", withUser, tokenHandler.Create)
+
+			tt.mockSetup(mocks)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/users/me/tokens", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, resp.StatusCode)
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(respBody), tt.expectedBody)
+		})
+	}
+}
+
+func TestTokenHandlerImpl_Revoke(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	tokenHandler, mocks := setup(t)
+
+	tests := []struct {
+		name               string
+		url                string
+		mockSetup          func(mock *Mocks)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "Success",
+			url:  "/api/users/me/tokens/3",
+			mockSetup: func(mock *Mocks) {
+				mock.TokenService.EXPECT().Revoke(gomock.Any(), uint(1), uint(3)).Return(nil)
+			},
+			expectedStatusCode: 200,
+			expectedBody:       "Token revoked",
+		},
+		{
+			name: "Not found",
+			url:  "/api/users/me/tokens/4",
+			mockSetup: func(mock *Mocks) {
+				mock.TokenService.EXPECT().Revoke(gomock.Any(), uint(1), uint(4)).Return(token.ErrTokenNotFound)
+			},
+			expectedStatusCode: 404,
+			expectedBody:       "Token not found",
+		},
+		{
+			name:               "Invalid id",
+			url:                "/api/users/me/tokens/abc",
+			mockSetup:          func(mock *Mocks) {},
+			expectedStatusCode: 400,
+			expectedBody:       "Token Id is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Delete("/api/users/me/tokens/:id", withUser, tokenHandler.Revoke)
+
+			tt.mockSetup(mocks)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodDelete, tt.url, nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatusCode, resp.StatusCode)
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(respBody), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package token
+
+import (
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"time"
+)
+
+type CreateRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"required,min=1,max=366"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,required"`
+}
+
+// TokenResponse - токен без секрета
+type TokenResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateResponse - созданный токен. Секрет показывается только в этом ответе
+type CreateResponse struct {
+	TokenResponse
+	Token string `json:"token"`
+}
+
+func toResponse(t *models.PersonalAccessToken) TokenResponse {
+	return TokenResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Hint:       t.Hint,
+		Scopes:     t.ScopeList(),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
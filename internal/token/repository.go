@@ -0,0 +1,66 @@
+package token
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"gorm.io/gorm"
+	"time"
+)
+
+//go:generate mockgen -source=repository.go -destination=mock/token_repo_mock.go
+
+type TokenRepository interface {
+	Create(ctx context.Context, token *models.PersonalAccessToken) error
+	FindByUser(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error)
+	FindByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error)
+	Delete(ctx context.Context, userID, tokenID uint) error
+	DeleteByUser(ctx context.Context, userID uint) error
+	UpdateLastUsed(ctx context.Context, tokenID uint, usedAt time.Time) error
+}
+
+type TokenRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepositoryImpl {
+	logger.Log.Debug("Init token repository")
+	return &TokenRepositoryImpl{db: db}
+}
+
+func (repo *TokenRepositoryImpl) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	return repo.db.WithContext(ctx).Create(token).Error
+}
+
+func (repo *TokenRepositoryImpl) FindByUser(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error) {
+	tokens := make([]models.PersonalAccessToken, 0)
+	result := repo.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens)
+	return tokens, result.Error
+}
+
+func (repo *TokenRepositoryImpl) FindByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	result := repo.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token)
+	return &token, result.Error
+}
+
+// Delete - отзывает токен пользователя. Чужой или несуществующий токен - ErrTokenNotFound
+func (repo *TokenRepositoryImpl) Delete(ctx context.Context, userID, tokenID uint) error {
+	result := repo.db.WithContext(ctx).Where("id = ? AND user_id = ?", tokenID, userID).Delete(&models.PersonalAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// DeleteByUser - отзывает все токены пользователя
+func (repo *TokenRepositoryImpl) DeleteByUser(ctx context.Context, userID uint) error {
+	return repo.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.PersonalAccessToken{}).Error
+}
+
+func (repo *TokenRepositoryImpl) UpdateLastUsed(ctx context.Context, tokenID uint, usedAt time.Time) error {
+	return repo.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).Where("id = ?", tokenID).Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,144 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+//go:generate mockgen -source=service.go -destination=mock/token_service_mock.go
+
+var (
+	ErrTokenNotFound = errors.New("token not found")
+	ErrUnknownScope  = errors.New("unknown scope")
+)
+
+const (
+	secretBytes = 32
+	hintLength  = len(middleware.PersonalTokenPrefix) + 4
+
+	// lastUsedInterval - как часто обновляется время последнего использования токена,
+	// чтобы не писать в базу на каждый запрос
+	lastUsedInterval = time.Minute
+)
+
+type TokenService interface {
+	Create(ctx context.Context, userID uint, data *CreateRequest) (*CreateResponse, error)
+	List(ctx context.Context, userID uint) ([]TokenResponse, error)
+	Revoke(ctx context.Context, userID, tokenID uint) error
+	VerifyPersonalToken(ctx context.Context, token string) (uint, []string, error)
+}
+
+type TokenServiceImpl struct {
+	TokenRepo TokenRepository
+}
+
+func NewTokenService(tokenRepo TokenRepository) *TokenServiceImpl {
+	logger.Log.Debug("Init token service")
+	return &TokenServiceImpl{
+		TokenRepo: tokenRepo,
+	}
+}
+
+// Create - выпускает personal access токен. В базе сохраняется только хэш секрета
+func (s *TokenServiceImpl) Create(ctx context.Context, userID uint, data *CreateRequest) (*CreateResponse, error) {
+	scopes := make([]string, 0, len(data.Scopes))
+	for _, scope := range data.Scopes {
+		if !policy.IsValidScope(scope) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+		if !contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      data.Name,
+		TokenHash: hashSecret(secret),
+		Hint:      secret[:hintLength],
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: time.Now().AddDate(0, 0, data.ExpiresInDays),
+	}
+	if err = s.TokenRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+	logger.Log.Info("Personal access token created", zap.Uint("user_id", userID), zap.Uint("token_id", token.ID))
+
+	return &CreateResponse{TokenResponse: toResponse(token), Token: secret}, nil
+}
+
+func (s *TokenServiceImpl) List(ctx context.Context, userID uint) ([]TokenResponse, error) {
+	tokens, err := s.TokenRepo.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]TokenResponse, 0, len(tokens))
+	for i := range tokens {
+		result = append(result, toResponse(&tokens[i]))
+	}
+	return result, nil
+}
+
+func (s *TokenServiceImpl) Revoke(ctx context.Context, userID, tokenID uint) error {
+	return s.TokenRepo.Delete(ctx, userID, tokenID)
+}
+
+// VerifyPersonalToken - реализация middleware.PersonalTokenVerifier
+func (s *TokenServiceImpl) VerifyPersonalToken(ctx context.Context, secret string) (uint, []string, error) {
+	token, err := s.TokenRepo.FindByHash(ctx, hashSecret(secret))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil, middleware.ErrPersonalTokenInvalid
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return 0, nil, middleware.ErrPersonalTokenInvalid
+	}
+
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > lastUsedInterval {
+		if err = s.TokenRepo.UpdateLastUsed(ctx, token.ID, time.Now()); err != nil {
+			logger.Log.Warn("Error updating token last used", zap.Error(err))
+		}
+	}
+	return token.UserID, token.ScopeList(), nil
+}
+
+func newSecret() (string, error) {
+	buf := make([]byte, secretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return middleware.PersonalTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
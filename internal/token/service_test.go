@@ -0,0 +1,80 @@
+package token_test
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/token"
+	mock_token "github.com/crafty-ezhik/blog-api/mocks/token"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenServiceImpl(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	ctrl := gomock.NewController(t)
+	repo := mock_token.NewMockTokenRepository(ctrl)
+	service := token.NewTokenService(repo)
+
+	var stored *models.PersonalAccessToken
+	repo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, t *models.PersonalAccessToken) error {
+		t.ID = 1
+		stored = t
+		return nil
+	})
+
+	created, err := service.Create(context.Background(), 1, &token.CreateRequest{
+		Name:          "ci",
+		ExpiresInDays: 30,
+		Scopes:        []string{"posts:write", "comments:read", "posts:write"},
+	})
+	require.NoError(t, err)
+
+	t.Run("Only hash is stored", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(created.Token, middleware.PersonalTokenPrefix))
+		assert.NotContains(t, stored.TokenHash, created.Token)
+		assert.Len(t, stored.TokenHash, 64)
+		assert.True(t, strings.HasPrefix(created.Token, stored.Hint))
+		assert.Equal(t, []string{"posts:write", "comments:read"}, created.Scopes)
+	})
+
+	t.Run("Unknown scope", func(t *testing.T) {
+		_, err := service.Create(context.Background(), 1, &token.CreateRequest{Name: "ci", ExpiresInDays: 1, Scopes: []string{"admin"}})
+		assert.ErrorIs(t, err, token.ErrUnknownScope)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		repo.EXPECT().FindByHash(gomock.Any(), stored.TokenHash).Return(stored, nil)
+		repo.EXPECT().UpdateLastUsed(gomock.Any(), uint(1), gomock.Any()).Return(nil)
+
+		userID, scopes, err := service.VerifyPersonalToken(context.Background(), created.Token)
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), userID)
+		assert.Equal(t, []string{"posts:write", "comments:read"}, scopes)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired := *stored
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		repo.EXPECT().FindByHash(gomock.Any(), stored.TokenHash).Return(&expired, nil)
+
+		_, _, err := service.VerifyPersonalToken(context.Background(), created.Token)
+		assert.ErrorIs(t, err, middleware.ErrPersonalTokenInvalid)
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		repo.EXPECT().FindByHash(gomock.Any(), gomock.Any()).Return(nil, gorm.ErrRecordNotFound)
+
+		_, _, err := service.VerifyPersonalToken(context.Background(), middleware.PersonalTokenPrefix + "unknown")
+		assert.ErrorIs(t, err, middleware.ErrPersonalTokenInvalid)
+	})
+}
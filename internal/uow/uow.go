@@ -0,0 +1,57 @@
+// Package uow - unit of work: несколько операций над репозиториями в одной транзакции
+package uow
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UnitOfWork - выполняет fn в транзакции. Ошибка или паника в fn откатывает все ее изменения,
+// иначе транзакция фиксируется. Отмена ctx прерывает запросы транзакции. R - набор репозиториев, который получает fn
+type UnitOfWork[R any] interface {
+	Do(ctx context.Context, fn func(tx *Tx[R]) error) error
+}
+
+// Tx - открытая транзакция и репозитории, привязанные к ней
+type Tx[R any] struct {
+	Repos R
+
+	db   *gorm.DB
+	bind func(tx *gorm.DB) R
+}
+
+// Savepoint - выполняет fn во вложенной транзакции (SAVEPOINT). Ошибка fn откатывает только изменения,
+// сделанные внутри Savepoint, и возвращается вызывающему, который решает, продолжать ли внешнюю транзакцию
+func (t *Tx[R]) Savepoint(fn func(tx *Tx[R]) error) error {
+	return t.db.Transaction(func(db *gorm.DB) error {
+		return fn(&Tx[R]{Repos: t.bind(db), db: db, bind: t.bind})
+	})
+}
+
+// TxManager - UnitOfWork поверх gorm. bind создает репозитории, работающие через переданную транзакцию
+type TxManager[R any] struct {
+	db   *gorm.DB
+	bind func(tx *gorm.DB) R
+}
+
+func NewTxManager[R any](db *gorm.DB, bind func(tx *gorm.DB) R) *TxManager[R] {
+	return &TxManager[R]{db: db, bind: bind}
+}
+
+func (m *TxManager[R]) Do(ctx context.Context, fn func(tx *Tx[R]) error) error {
+	// gorm откатывает транзакцию и при панике, после чего паника продолжается
+	return m.db.WithContext(ctx).Transaction(func(db *gorm.DB) error {
+		return fn(&Tx[R]{Repos: m.bind(db), db: db, bind: m.bind})
+	})
+}
+
+// ForShare - читает строки с блокировкой FOR SHARE: до конца транзакции их нельзя изменить или удалить,
+// но другие транзакции могут их читать. В SQLite такой блокировки нет, а пишущие транзакции там
+// и так не выполняются параллельно, поэтому запрос остается без изменений
+func ForShare(db *gorm.DB) *gorm.DB {
+	if db.Dialector.Name() != "postgres" {
+		return db
+	}
+	return db.Clauses(clause.Locking{Strength: clause.LockingStrengthShare})
+}
@@ -0,0 +1,42 @@
+package uow
+
+import (
+	"database/sql"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestForShare(t *testing.T) {
+	pgConn, err := sql.Open("pgx", "host=localhost")
+	require.NoError(t, err)
+	defer pgConn.Close()
+
+	tests := []struct {
+		name      string
+		dialector gorm.Dialector
+		locked    bool
+	}{
+		// sql.Open не подключается к серверу, а в DryRun запросы только собираются
+		{name: "Postgres", dialector: postgres.New(postgres.Config{Conn: pgConn}), locked: true},
+		{name: "SQLite", dialector: sqlite.Open(":memory:"), locked: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := gorm.Open(tt.dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+			require.NoError(t, err)
+
+			stmt := ForShare(db).First(&models.Post{}, 1).Statement
+			if tt.locked {
+				assert.Contains(t, stmt.SQL.String(), "FOR SHARE")
+			} else {
+				assert.NotContains(t, stmt.SQL.String(), "FOR")
+			}
+		})
+	}
+}
@@ -3,10 +3,13 @@ package user
 import (
 	"errors"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
 	"github.com/crafty-ezhik/blog-api/internal/post"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
 	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/pagination"
 	"github.com/crafty-ezhik/blog-api/pkg/req"
+	"github.com/crafty-ezhik/blog-api/pkg/res"
 	"github.com/crafty-ezhik/blog-api/pkg/validate"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -18,6 +21,7 @@ type UserHandler interface {
 	GetMe(c *fiber.Ctx) error
 	Update(c *fiber.Ctx) error
 	Delete(c *fiber.Ctx) error
+	UpdateRole(c *fiber.Ctx) error
 
 	GetMyPosts(c *fiber.Ctx) error
 	GetUserPostsByID(c *fiber.Ctx) error
@@ -48,7 +52,7 @@ func (h *UserHandlerImpl) GetByID(c *fiber.Ctx) error {
 			"error":   "id must be an integer",
 		})
 	}
-	result, err := h.UserService.GetByID(uint(id))
+	result, err := h.UserService.GetByID(c.UserContext(), uint(id))
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
@@ -66,6 +70,7 @@ func (h *UserHandlerImpl) GetByID(c *fiber.Ctx) error {
 		Name:      result.Name,
 		Age:       result.Age,
 		Email:     result.Email,
+		Role:      string(result.Role),
 		CreatedAt: result.CreatedAt,
 	}
 
@@ -83,7 +88,7 @@ func (h *UserHandlerImpl) GetMe(c *fiber.Ctx) error {
 			"error":   "user id must be a uint",
 		})
 	}
-	result, err := h.UserService.GetByID(ctxUserID)
+	result, err := h.UserService.GetByID(c.UserContext(), ctxUserID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
@@ -95,6 +100,7 @@ func (h *UserHandlerImpl) GetMe(c *fiber.Ctx) error {
 		Name:      result.Name,
 		Age:       result.Age,
 		Email:     result.Email,
+		Role:      string(result.Role),
 		CreatedAt: result.CreatedAt,
 	}
 
@@ -122,7 +128,7 @@ func (h *UserHandlerImpl) Update(c *fiber.Ctx) error {
 		Name: body.Name,
 		Age:  body.Age,
 	}
-	err = h.UserService.Update(ctxUserID, updated)
+	err = h.UserService.Update(c.UserContext(), ctxUserID, updated)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -145,7 +151,13 @@ func (h *UserHandlerImpl) Delete(c *fiber.Ctx) error {
 		})
 	}
 
-	err = h.UserService.Delete(uint(userID))
+	err = h.UserService.Delete(c.UserContext(), policy.ActorFromCtx(c), uint(userID))
+	if errors.Is(err, policy.ErrForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"error":   "Permission denied",
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -159,31 +171,53 @@ func (h *UserHandlerImpl) Delete(c *fiber.Ctx) error {
 	})
 }
 
-// endregion
+func (h *UserHandlerImpl) UpdateRole(c *fiber.Ctx) error {
+	userID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "id must be an integer",
+		})
+	}
 
-// region: Операции с пользователем, постами и комментариями
-func (h *UserHandlerImpl) GetMyPosts(c *fiber.Ctx) error {
-	userID := c.Locals(middleware.UserIDKey).(uint)
+	body, err := req.HandleBody[UpdateRoleRequest](c, h.v)
+	if err != nil {
+		return nil
+	}
 
-	data, err := h.PostService.GetPostsByAuthorID(userID)
-	if len(data) < 1 {
+	err = h.UserService.UpdateRole(c.UserContext(), policy.ActorFromCtx(c), uint(userID), models.Role(body.Role))
+	switch {
+	case errors.Is(err, policy.ErrForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"error":   "Permission denied",
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
-			"error":   "Posts not found",
+			"error":   "user not found",
 		})
-	}
-	if err != nil {
+	case err != nil:
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
-			"error":   err.Error(),
+			"error":   "Something went wrong",
 		})
 	}
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+
+	return c.JSON(fiber.Map{
 		"success": true,
-		"data":    data,
+		"message": "role updated",
 	})
 }
 
+// endregion
+
+// region: Операции с пользователем, постами и комментариями
+func (h *UserHandlerImpl) GetMyPosts(c *fiber.Ctx) error {
+	userID := c.Locals(middleware.UserIDKey).(uint)
+	return h.getPosts(c, userID)
+}
+
 func (h *UserHandlerImpl) GetUserPostsByID(c *fiber.Ctx) error {
 	userId, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
@@ -192,25 +226,32 @@ func (h *UserHandlerImpl) GetUserPostsByID(c *fiber.Ctx) error {
 			"error":   "id must be an integer",
 		})
 	}
+	return h.getPosts(c, uint(userId))
+}
 
-	data, err := h.PostService.GetPostsByAuthorID(uint(userId))
-	if len(data) < 1 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+func (h *UserHandlerImpl) getPosts(c *fiber.Ctx, authorID uint) error {
+	params, err := pagination.ParseQuery(c, post.DefaultSort, post.SortFields...)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"error":   "Posts not found",
+			"error":   err.Error(),
 		})
 	}
+
+	page, err := h.PostService.GetPostsByAuthorID(c.UserContext(), authorID, policy.ActorFromCtx(c).UserID, params)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
 			"error":   err.Error(),
 		})
 	}
-
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"success": true,
-		"data":    data,
-	})
+	if page.Total < 1 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "Posts not found",
+		})
+	}
+	return res.PageResponse(c, page.Items, page.Meta())
 }
 
 // endregion
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
 	"github.com/crafty-ezhik/blog-api/internal/user"
 	mock_post "github.com/crafty-ezhik/blog-api/mocks/post"
 	mock_user "github.com/crafty-ezhik/blog-api/mocks/user"
@@ -70,7 +71,7 @@ func TestUserHandlerImpl_GetByID(t *testing.T) {
 			name:   "Success",
 			userID: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.UserService.EXPECT().GetByID(uint(1)).Return(&models.User{
+				mock.UserService.EXPECT().GetByID(gomock.Any(), uint(1)).Return(&models.User{
 					Name:      "Test Name",
 					Email:     "some@email.com",
 					Age:       42,
@@ -90,7 +91,7 @@ func TestUserHandlerImpl_GetByID(t *testing.T) {
 			name:   "User not found",
 			userID: 5123,
 			mockSetup: func(mock *Mocks) {
-				mock.UserService.EXPECT().GetByID(uint(5123)).Return(nil, gorm.ErrRecordNotFound)
+				mock.UserService.EXPECT().GetByID(gomock.Any(), uint(5123)).Return(nil, gorm.ErrRecordNotFound)
 			},
 			expectedStatusCode: 404,
 			expectedBody:       "user not found",
@@ -99,7 +100,7 @@ func TestUserHandlerImpl_GetByID(t *testing.T) {
 			name:   "Server internal error",
 			userID: 5123,
 			mockSetup: func(mock *Mocks) {
-				mock.UserService.EXPECT().GetByID(uint(5123)).Return(nil, errors.New("server error"))
+				mock.UserService.EXPECT().GetByID(gomock.Any(), uint(5123)).Return(nil, errors.New("server error"))
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "Something went wrong",
@@ -151,7 +152,7 @@ func TestUserHandlerImpl_Update(t *testing.T) {
 				Age:  53,
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.UserService.EXPECT().Update(uint(1), &models.User{Name: "Test Name", Age: 53}).Return(nil)
+				mock.UserService.EXPECT().Update(gomock.Any(), uint(1), &models.User{Name: "Test Name", Age: 53}).Return(nil)
 			},
 			expectedStatusCode: 200,
 			expectedBody:       "user updated",
@@ -204,7 +205,7 @@ func TestUserHandlerImpl_Update(t *testing.T) {
 				Age:  53,
 			},
 			mockSetup: func(mock *Mocks) {
-				mock.UserService.EXPECT().Update(uint(1), &models.User{Name: "Test Name", Age: 53}).Return(errors.New("server error"))
+				mock.UserService.EXPECT().Update(gomock.Any(), uint(1), &models.User{Name: "Test Name", Age: 53}).Return(errors.New("server error"))
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "Something went wrong",
@@ -258,7 +259,7 @@ func TestUserHandlerImpl_Delete(t *testing.T) {
 			name:   "Success",
 			userID: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.UserService.EXPECT().Delete(uint(1)).Return(nil)
+				mock.UserService.EXPECT().Delete(gomock.Any(), gomock.Any(), uint(1)).Return(nil)
 			},
 			expectedStatusCode: 204,
 			expectedBody:       "",
@@ -273,11 +274,20 @@ func TestUserHandlerImpl_Delete(t *testing.T) {
 			name:   "Server internal error",
 			userID: 1,
 			mockSetup: func(mock *Mocks) {
-				mock.UserService.EXPECT().Delete(uint(1)).Return(errors.New("server error"))
+				mock.UserService.EXPECT().Delete(gomock.Any(), gomock.Any(), uint(1)).Return(errors.New("server error"))
 			},
 			expectedStatusCode: 500,
 			expectedBody:       "Something went wrong",
 		},
+		{
+			name:   "Permission denied",
+			userID: 2,
+			mockSetup: func(mock *Mocks) {
+				mock.UserService.EXPECT().Delete(gomock.Any(), gomock.Any(), uint(2)).Return(policy.ErrForbidden)
+			},
+			expectedStatusCode: 403,
+			expectedBody:       "Permission denied",
+		},
 	}
 
 	for _, tt := range tests {
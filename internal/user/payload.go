@@ -6,6 +6,7 @@ type GetByIDResponse struct {
 	Email     string    `json:"email"`
 	Name      string    `json:"name"`
 	Age       int       `json:"age"`
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -13,3 +14,7 @@ type UpdateUserRequest struct {
 	Name string `json:"name" validate:"gte=1,lte=255"`
 	Age  int    `json:"age" validate:"gte=1,lte=120"`
 }
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=reader author moderator admin"`
+}
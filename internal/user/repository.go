@@ -1,21 +1,33 @@
 package user
 
 import (
+	"context"
+	"errors"
 	"github.com/crafty-ezhik/blog-api/internal/models"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
 	"gorm.io/gorm"
+	"time"
 )
 
 //go:generate mockgen -source=repository.go -destination=mock/user_repo_mock.go
 
 type UserRepository interface {
-	FindByID(userId uint) (*models.User, error)
-	FindByEmail(email string) (*models.User, error)
-	Create(user *models.User) error
-	Update(userID uint, updateField *models.User) error
-	Delete(userID uint) error
+	FindByID(ctx context.Context, userId uint) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	Update(ctx context.Context, userID uint, updateField *models.User) error
+	Delete(ctx context.Context, userID uint) error
+	ChangeEmail(ctx context.Context, userID uint, email string) error
+	EnableTOTP(ctx context.Context, userID uint, recoveryCodeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error)
+	UseTOTPStep(ctx context.Context, userID uint, step int64) (bool, error)
+	FindByIdentity(ctx context.Context, provider, subject string) (*models.User, error)
+	LinkIdentity(ctx context.Context, identity *models.UserIdentity) error
+	CreateWithIdentity(ctx context.Context, user *models.User, identity *models.UserIdentity) error
 }
 
+var ErrEmailTaken = errors.New("email is already taken")
+
 type UserRepositoryImpl struct {
 	db *gorm.DB
 }
@@ -25,32 +37,135 @@ func NewUserRepository(db *gorm.DB) *UserRepositoryImpl {
 	return &UserRepositoryImpl{db: db}
 }
 
-func (repo *UserRepositoryImpl) FindByID(userId uint) (*models.User, error) {
+// WithTx - репозиторий, работающий через транзакцию tx
+func (repo *UserRepositoryImpl) WithTx(tx *gorm.DB) *UserRepositoryImpl {
+	return &UserRepositoryImpl{db: tx}
+}
+
+func (repo *UserRepositoryImpl) FindByID(ctx context.Context, userId uint) (*models.User, error) {
 	var user *models.User
-	result := repo.db.Where("id = ?", userId).First(&user)
+	result := repo.db.WithContext(ctx).Where("id = ?", userId).First(&user)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return user, nil
 }
 
-func (repo *UserRepositoryImpl) FindByEmail(email string) (*models.User, error) {
+func (repo *UserRepositoryImpl) FindByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user *models.User
-	result := repo.db.Where("email = ?", email).First(&user)
+	result := repo.db.WithContext(ctx).Where("email = ?", email).First(&user)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return user, nil
 }
 
-func (repo *UserRepositoryImpl) Create(user *models.User) error {
-	return repo.db.Create(user).Error
+func (repo *UserRepositoryImpl) Create(ctx context.Context, user *models.User) error {
+	return repo.db.WithContext(ctx).Create(user).Error
+}
+
+func (repo *UserRepositoryImpl) Update(ctx context.Context, userID uint, updateField *models.User) error {
+	return repo.db.WithContext(ctx).Model(&models.User{ID: userID}).Updates(updateField).Error
+}
+
+func (repo *UserRepositoryImpl) Delete(ctx context.Context, userID uint) error {
+	return repo.db.WithContext(ctx).Delete(&models.User{}, &userID).Error
+}
+
+// ChangeEmail - применяет подтвержденный email. Удаленные пользователи тоже учитываются,
+// так как уникальный индекс по email действует и на них
+func (repo *UserRepositoryImpl) ChangeEmail(ctx context.Context, userID uint, email string) error {
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Unscoped().Model(&models.User{}).
+			Where("email = ? AND id <> ?", email, userID).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrEmailTaken
+		}
+
+		return tx.Model(&models.User{ID: userID}).Updates(map[string]any{
+			"email":               email,
+			"pending_email":       nil,
+			"pending_email_token": nil,
+			"email_verified_at":   time.Now(),
+		}).Error
+	})
+}
+
+// EnableTOTP - включает 2FA и заменяет резервные коды пользователя новыми
+func (repo *UserRepositoryImpl) EnableTOTP(ctx context.Context, userID uint, recoveryCodeHashes []string) error {
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+		if err != nil {
+			return err
+		}
+
+		codes := make([]models.RecoveryCode, 0, len(recoveryCodeHashes))
+		for _, hash := range recoveryCodeHashes {
+			codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		if len(codes) > 0 {
+			if err = tx.Create(&codes).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.User{ID: userID}).Update("totp_enabled", true).Error
+	})
+}
+
+// UseRecoveryCode - помечает резервный код использованным.
+// Условие used_at IS NULL в самом UPDATE не дает использовать код дважды параллельными запросами
+func (repo *UserRepositoryImpl) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	result := repo.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UseTOTPStep - запоминает интервал принятого кода TOTP. Возвращает false, если уже был принят код
+// этого или более позднего интервала: условие в самом UPDATE не дает использовать код дважды параллельными запросами
+func (repo *UserRepositoryImpl) UseTOTPStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	result := repo.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)", userID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// FindByIdentity - пользователь, привязанный к учетной записи внешнего провайдера
+func (repo *UserRepositoryImpl) FindByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	var user *models.User
+	result := repo.db.WithContext(ctx).
+		Joins("JOIN user_identities ON user_identities.user_id = users.id").
+		Where("user_identities.provider = ? AND user_identities.subject = ?", provider, subject).
+		First(&user)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return user, nil
 }
 
-func (repo *UserRepositoryImpl) Update(userID uint, updateField *models.User) error {
-	return repo.db.Model(&models.User{ID: userID}).Updates(updateField).Error
+func (repo *UserRepositoryImpl) LinkIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	return repo.db.WithContext(ctx).Create(identity).Error
 }
 
-func (repo *UserRepositoryImpl) Delete(userID uint) error {
-	return repo.db.Delete(&models.User{}, &userID).Error
+// CreateWithIdentity - регистрирует пользователя, впервые вошедшего через внешнего провайдера
+func (repo *UserRepositoryImpl) CreateWithIdentity(ctx context.Context, user *models.User, identity *models.UserIdentity) error {
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
 }
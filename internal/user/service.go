@@ -1,45 +1,102 @@
 package user
 
 import (
+	"context"
+	"errors"
 	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
+	"github.com/crafty-ezhik/blog-api/internal/uow"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
 )
 //go:generate mockgen -source=service.go -destination=mock/user_service_mock.go
 
 
+var ErrUnknownRole = errors.New("unknown role")
+
 type UserService interface {
-	GetByID(userID uint) (*models.User, error)
-	GetByEmail(email string) (*models.User, error)
-	Create(user *models.User) error
-	Update(userID uint, updatedFields *models.User) error
-	Delete(userID uint) error
+	GetByID(ctx context.Context, userID uint) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	Update(ctx context.Context, userID uint, updatedFields *models.User) error
+	Delete(ctx context.Context, actor policy.Actor, userID uint) error
+	UpdateRole(ctx context.Context, actor policy.Actor, userID uint, role models.Role) error
+	GetRole(ctx context.Context, userID uint) (string, error)
+	BootstrapAdmin(ctx context.Context, email string) error
 }
 
 type UserServiceImpl struct {
 	UserRepo UserRepository
+	uow      uow.UnitOfWork[TxRepositories]
 }
 
-func NewUserService(UserRepo UserRepository) *UserServiceImpl {
+func NewUserService(UserRepo UserRepository, unitOfWork uow.UnitOfWork[TxRepositories]) *UserServiceImpl {
 	logger.Log.Debug("Init user service")
-	return &UserServiceImpl{UserRepo: UserRepo}
+	return &UserServiceImpl{UserRepo: UserRepo, uow: unitOfWork}
+}
+
+func (s *UserServiceImpl) GetByID(ctx context.Context, userID uint) (*models.User, error) {
+	return s.UserRepo.FindByID(ctx, userID)
+}
+
+func (s *UserServiceImpl) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.UserRepo.FindByEmail(ctx, email)
+}
+
+func (s *UserServiceImpl) Create(ctx context.Context, user *models.User) error {
+	return s.UserRepo.Create(ctx, user)
 }
 
-func (s *UserServiceImpl) GetByID(userID uint) (*models.User, error) {
-	return s.UserRepo.FindByID(userID)
+func (s *UserServiceImpl) Update(ctx context.Context, userID uint, updatedFields *models.User) error {
+	return s.UserRepo.Update(ctx, userID, updatedFields)
 }
 
-func (s *UserServiceImpl) GetByEmail(email string) (*models.User, error) {
-	return s.UserRepo.FindByEmail(email)
+func (s *UserServiceImpl) Delete(ctx context.Context, actor policy.Actor, userID uint) error {
+	if err := policy.CanDeleteUser(actor, userID); err != nil {
+		return err
+	}
+	// Вместе с пользователем удаляются его статьи и все комментарии к ним, а также его комментарии к чужим статьям
+	return s.uow.Do(ctx, func(tx *uow.Tx[TxRepositories]) error {
+		if err := tx.Repos.Comments.DeleteByUser(ctx, userID); err != nil {
+			return err
+		}
+		if err := tx.Repos.Posts.DeleteByAuthor(ctx, userID); err != nil {
+			return err
+		}
+		return tx.Repos.Users.Delete(ctx, userID)
+	})
 }
 
-func (s *UserServiceImpl) Create(user *models.User) error {
-	return s.UserRepo.Create(user)
+func (s *UserServiceImpl) UpdateRole(ctx context.Context, actor policy.Actor, userID uint, role models.Role) error {
+	if err := policy.CanUpdateRole(actor); err != nil {
+		return err
+	}
+	if !policy.IsValidRole(role) {
+		return ErrUnknownRole
+	}
+	if _, err := s.UserRepo.FindByID(ctx, userID); err != nil {
+		return err
+	}
+	return s.UserRepo.Update(ctx, userID, &models.User{Role: role})
 }
 
-func (s *UserServiceImpl) Update(userID uint, updatedFields *models.User) error {
-	return s.UserRepo.Update(userID, updatedFields)
+// GetRole - реализует middleware.RoleProvider
+func (s *UserServiceImpl) GetRole(ctx context.Context, userID uint) (string, error) {
+	existedUser, err := s.UserRepo.FindByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return string(existedUser.Role), nil
 }
 
-func (s *UserServiceImpl) Delete(userID uint) error {
-	return s.UserRepo.Delete(userID)
+// BootstrapAdmin - выдает роль admin зарегистрированному пользователю с указанным email.
+// Нужна для первого администратора: назначать роли через API может только admin
+func (s *UserServiceImpl) BootstrapAdmin(ctx context.Context, email string) error {
+	existedUser, err := s.UserRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if existedUser.Role == models.RoleAdmin {
+		return nil
+	}
+	return s.UserRepo.Update(ctx, existedUser.ID, &models.User{Role: models.RoleAdmin})
 }
@@ -0,0 +1,40 @@
+package user_test
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/user"
+	mock_user "github.com/crafty-ezhik/blog-api/mocks/user"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestUserServiceImpl_BootstrapAdmin(t *testing.T) {
+	logger.Log = zap.NewNop()
+	ctrl := gomock.NewController(t)
+	mockUserRepo := mock_user.NewMockUserRepository(ctrl)
+	userService := user.NewUserService(mockUserRepo, nil)
+
+	t.Run("Registered author becomes admin", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), "admin@example.com").Return(&models.User{ID: 1, Role: models.RoleAuthor}, nil)
+		mockUserRepo.EXPECT().Update(gomock.Any(), uint(1), &models.User{Role: models.RoleAdmin}).Return(nil)
+
+		assert.NoError(t, userService.BootstrapAdmin(context.Background(), "admin@example.com"))
+	})
+
+	t.Run("Admin is left as is", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), "admin@example.com").Return(&models.User{ID: 1, Role: models.RoleAdmin}, nil)
+
+		assert.NoError(t, userService.BootstrapAdmin(context.Background(), "admin@example.com"))
+	})
+
+	t.Run("User is not registered", func(t *testing.T) {
+		mockUserRepo.EXPECT().FindByEmail(gomock.Any(), "admin@example.com").Return(nil, gorm.ErrRecordNotFound)
+
+		assert.ErrorIs(t, userService.BootstrapAdmin(context.Background(), "admin@example.com"), gorm.ErrRecordNotFound)
+	})
+}
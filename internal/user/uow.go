@@ -0,0 +1,27 @@
+package user
+
+import (
+	"github.com/crafty-ezhik/blog-api/internal/comment"
+	"github.com/crafty-ezhik/blog-api/internal/post"
+	"github.com/crafty-ezhik/blog-api/internal/uow"
+	"gorm.io/gorm"
+)
+
+// TxRepositories - репозитории, привязанные к одной транзакции
+type TxRepositories struct {
+	Users    UserRepository
+	Posts    post.PostRepository
+	Comments comment.CommentRepository
+}
+
+// NewUnitOfWork - транзакции над пользователями, их статьями и комментариями
+func NewUnitOfWork(db *gorm.DB) *uow.TxManager[TxRepositories] {
+	users, posts, comments := NewUserRepository(db), post.NewPostRepository(db), comment.NewCommentRepository(db)
+	return uow.NewTxManager(db, func(tx *gorm.DB) TxRepositories {
+		return TxRepositories{
+			Users:    users.WithTx(tx),
+			Posts:    posts.WithTx(tx),
+			Comments: comments.WithTx(tx),
+		}
+	})
+}
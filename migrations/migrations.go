@@ -0,0 +1,46 @@
+// Package migrations - SQL миграции схемы блога, встроенные в бинарник. Для каждой СУБД свой каталог
+// с одинаковыми версиями. Новые миграции создаются командой `go run ./cmd/migrate create <имя>`
+// и применяются `go run ./cmd/migrate up`
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/db"
+	"github.com/crafty-ezhik/blog-api/pkg/migrate"
+	"sort"
+)
+
+// Dir - каталог миграций относительно корня репозитория, в нем create создает новые файлы
+const Dir = "migrations"
+
+// Drivers - СУБД, для которых есть миграции
+var Drivers = []string{db.DriverPostgres, db.DriverSQLite}
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// goMigrations - миграции на Go, общие для всех СУБД. Их версии продолжают нумерацию SQL файлов
+var goMigrations = []migrate.Migration{upgradeAutoMigrated}
+
+// All - все миграции схемы для драйвера БД по возрастанию версий, пустой driver - PostgreSQL
+func All(driver string) ([]migrate.Migration, error) {
+	if driver == "" {
+		driver = db.DriverPostgres
+	}
+	for _, known := range Drivers {
+		if driver != known {
+			continue
+		}
+		all, err := migrate.Load(files, driver)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, goMigrations...)
+		sort.Slice(all, func(i, j int) bool {
+			return all[i].Version < all[j].Version
+		})
+		return all, nil
+	}
+	return nil, fmt.Errorf("no migrations for database driver %q", driver)
+}
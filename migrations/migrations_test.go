@@ -0,0 +1,145 @@
+package migrations_test
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/db"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/migrations"
+	"github.com/crafty-ezhik/blog-api/pkg/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestAll(t *testing.T) {
+	postgres, err := migrations.All("postgres")
+	require.NoError(t, err)
+	require.NotEmpty(t, postgres)
+
+	for _, driver := range migrations.Drivers {
+		all, err := migrations.All(driver)
+		require.NoError(t, err)
+
+		// Версии идут подряд и совпадают для всех СУБД, каждую миграцию можно откатить
+		require.Len(t, all, len(postgres), driver)
+		for i, m := range all {
+			assert.Equal(t, uint64(i+1), m.Version, m.Name)
+			assert.Equal(t, postgres[i].Name, m.Name, driver)
+			assert.NotNil(t, m.Down, m.Name)
+		}
+	}
+
+	_, err = migrations.All("mysql")
+	assert.Error(t, err)
+}
+
+// Модели из прежнего migrations/auto.go, до ролей, статусов, ответов и двухфакторной аутентификации
+type baselineUser struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	Email     string `gorm:"unique"`
+	Password  string
+	Age       int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	Posts    []baselinePost    `gorm:"foreignKey:AuthorID"`
+	Comments []baselineComment `gorm:"foreignKey:AuthorID"`
+}
+
+type baselinePost struct {
+	ID        uint   `gorm:"primarykey"`
+	Title     string `gorm:"size:255"`
+	Text      string `gorm:"type:text"`
+	AuthorID  uint   `gorm:"index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+type baselineComment struct {
+	ID        uint         `gorm:"primaryKey"`
+	Title     string       `gorm:"size:255"`
+	Content   string       `gorm:"type:text"`
+	AuthorID  uint         `gorm:"index"`
+	Author    baselineUser `gorm:"foreignKey:AuthorID"`
+	PostID    uint         `gorm:"index"`
+	Post      baselinePost `gorm:"foreignKey:PostID"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineUser) TableName() string    { return "users" }
+func (baselinePost) TableName() string    { return "posts" }
+func (baselineComment) TableName() string { return "comments" }
+
+func TestAll_UpgradesAutoMigratedSchema(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open("file::memory:?_foreign_keys=on"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := conn.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, conn.AutoMigrate(&baselineUser{}, &baselinePost{}, &baselineComment{}))
+	user := baselineUser{Name: "Иван", Email: "ivan@example.com"}
+	require.NoError(t, conn.Create(&user).Error)
+	post := baselinePost{Title: "Старая статья", AuthorID: user.ID}
+	require.NoError(t, conn.Create(&post).Error)
+	require.NoError(t, conn.Create(&baselineComment{Content: "Старый комментарий", AuthorID: user.ID, PostID: post.ID}).Error)
+
+	all, err := migrations.All(db.DriverSQLite)
+	require.NoError(t, err)
+	migrator, err := migrate.New(conn, all)
+	require.NoError(t, err)
+	_, err = migrator.Up(0)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Verify(context.Background()))
+
+	for table, columns := range map[string][]string{
+		"users":    {"role", "email_verified_at", "pending_email", "pending_email_token", "password_reset_token", "totp_secret", "totp_enabled", "totp_last_step"},
+		"posts":    {"status", "published_at", "require_comment_approval"},
+		"comments": {"parent_id", "depth", "status"},
+	} {
+		for _, column := range columns {
+			assert.True(t, conn.Migrator().HasColumn(table, column), table+"."+column)
+		}
+	}
+	assert.True(t, conn.Migrator().HasIndex("posts", "idx_posts_status"))
+	assert.True(t, conn.Migrator().HasIndex("comments", "idx_comments_parent_id"))
+
+	// Повторное применение ничего не ломает
+	var after []migrate.Migration
+	for _, migration := range all {
+		if migration.Version >= 5 {
+			after = append(after, migration)
+		}
+	}
+	reverted, err := migrator.Down(len(after))
+	require.NoError(t, err)
+	assert.Equal(t, "upgrade_auto_migrated_schema", reverted[len(reverted)-1].Name)
+	_, err = migrator.Up(0)
+	require.NoError(t, err)
+	assert.True(t, conn.Migrator().HasIndex("posts", "idx_posts_status"))
+
+	// Старые данные читаются текущими моделями: статьи опубликованы, комментарии одобрены
+	var storedUser models.User
+	require.NoError(t, conn.First(&storedUser, user.ID).Error)
+	assert.Equal(t, models.RoleAuthor, storedUser.Role)
+	assert.False(t, storedUser.TOTPEnabled)
+
+	var storedPost models.Post
+	require.NoError(t, conn.First(&storedPost, post.ID).Error)
+	assert.Equal(t, models.PostPublished, storedPost.Status)
+	require.NotNil(t, storedPost.PublishedAt)
+
+	var storedComment models.Comment
+	require.NoError(t, conn.Where("post_id = ?", post.ID).First(&storedComment).Error)
+	assert.Equal(t, models.CommentApproved, storedComment.Status)
+	assert.Nil(t, storedComment.ParentID)
+}
@@ -0,0 +1,134 @@
+-- Схема, которую раньше создавал AutoMigrate. На базе, созданной старым migrations/auto.go, IF NOT EXISTS
+-- пропускает существующие users, posts и comments целиком: недостающие в них колонки и индексы по ним
+-- добавляет 0005_upgrade_auto_migrated_schema (migrations/upgrade.go)
+
+CREATE TABLE IF NOT EXISTS users (
+    id                BIGSERIAL PRIMARY KEY,
+    name              TEXT,
+    email             TEXT,
+    password          TEXT,
+    age               BIGINT,
+    role              VARCHAR(32) DEFAULT 'author',
+    email_verified_at TIMESTAMPTZ,
+    pending_email     TEXT,
+    totp_secret       TEXT,
+    totp_enabled      BOOLEAN,
+    created_at        TIMESTAMPTZ,
+    updated_at        TIMESTAMPTZ,
+    deleted_at        TIMESTAMPTZ,
+    CONSTRAINT uni_users_email UNIQUE (email)
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id                       BIGSERIAL PRIMARY KEY,
+    title                    VARCHAR(255),
+    text                     TEXT,
+    author_id                BIGINT,
+    status                   VARCHAR(16) DEFAULT 'published',
+    published_at             TIMESTAMPTZ,
+    require_comment_approval BOOLEAN,
+    created_at               TIMESTAMPTZ,
+    updated_at               TIMESTAMPTZ,
+    deleted_at               TIMESTAMPTZ,
+    CONSTRAINT fk_users_posts FOREIGN KEY (author_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_posts_author_id ON posts (author_id);
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id         BIGSERIAL PRIMARY KEY,
+    title      VARCHAR(255),
+    content    TEXT,
+    author_id  BIGINT,
+    post_id    BIGINT,
+    parent_id  BIGINT,
+    depth      BIGINT,
+    status     VARCHAR(16) DEFAULT 'approved',
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    CONSTRAINT fk_users_comments FOREIGN KEY (author_id) REFERENCES users (id),
+    CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts (id)
+);
+CREATE INDEX IF NOT EXISTS idx_comments_author_id ON comments (author_id);
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id         BIGSERIAL PRIMARY KEY,
+    name       VARCHAR(50),
+    slug       VARCHAR(50),
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_slug ON tags (slug);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id         BIGSERIAL PRIMARY KEY,
+    name       VARCHAR(50),
+    slug       VARCHAR(50),
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id BIGINT,
+    tag_id  BIGINT,
+    PRIMARY KEY (post_id, tag_id),
+    CONSTRAINT fk_post_tags_post FOREIGN KEY (post_id) REFERENCES posts (id),
+    CONSTRAINT fk_post_tags_tag FOREIGN KEY (tag_id) REFERENCES tags (id)
+);
+
+CREATE TABLE IF NOT EXISTS post_categories (
+    post_id     BIGINT,
+    category_id BIGINT,
+    PRIMARY KEY (post_id, category_id),
+    CONSTRAINT fk_post_categories_post FOREIGN KEY (post_id) REFERENCES posts (id),
+    CONSTRAINT fk_post_categories_category FOREIGN KEY (category_id) REFERENCES categories (id)
+);
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id         BIGSERIAL PRIMARY KEY,
+    post_id    BIGINT,
+    revision   BIGINT,
+    title      VARCHAR(255),
+    text       TEXT,
+    editor_id  BIGINT,
+    created_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_revision ON post_revisions (post_id, revision);
+CREATE INDEX IF NOT EXISTS idx_post_revisions_editor_id ON post_revisions (editor_id);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT      NOT NULL,
+    code_hash  VARCHAR(64) NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT       NOT NULL,
+    name         VARCHAR(100) NOT NULL,
+    token_hash   VARCHAR(64)  NOT NULL,
+    hint         VARCHAR(16),
+    scopes       TEXT         NOT NULL,
+    expires_at   TIMESTAMPTZ  NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_tokens_token_hash ON personal_access_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT       NOT NULL,
+    provider   VARCHAR(64)  NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    email      TEXT,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_provider_subject ON user_identities (provider, subject);
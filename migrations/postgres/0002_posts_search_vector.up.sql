@@ -0,0 +1,9 @@
+-- Генерируемая колонка для полнотекстового поиска и GIN индекс по ней.
+-- Заголовок имеет больший вес (A), чем текст статьи (B). Конфигурация совпадает с post.SearchConfig
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(text, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING GIN (search_vector);
@@ -0,0 +1,2 @@
+-- Интервал последнего принятого кода TOTP: код действителен несколько интервалов, но принимается только один раз
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
@@ -0,0 +1,2 @@
+-- Хэш последней ссылки сброса пароля: новый запрос делает прежние ссылки недействительными
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_reset_token VARCHAR(64);
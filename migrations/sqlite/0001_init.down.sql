@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS personal_access_tokens;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS post_revisions;
+DROP TABLE IF EXISTS post_categories;
+DROP TABLE IF EXISTS post_tags;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,133 @@
+-- Схема SQLite для разработки и тестов. Совпадает с migrations/postgres/0001_init.up.sql
+-- с поправкой на типы SQLite
+
+CREATE TABLE IF NOT EXISTS users (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    name              TEXT,
+    email             TEXT,
+    password          TEXT,
+    age               INTEGER,
+    role              VARCHAR(32) DEFAULT 'author',
+    email_verified_at DATETIME,
+    pending_email     TEXT,
+    totp_secret       TEXT,
+    totp_enabled      NUMERIC,
+    created_at        DATETIME,
+    updated_at        DATETIME,
+    deleted_at        DATETIME,
+    CONSTRAINT uni_users_email UNIQUE (email)
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id                       INTEGER PRIMARY KEY AUTOINCREMENT,
+    title                    VARCHAR(255),
+    text                     TEXT,
+    author_id                INTEGER,
+    status                   VARCHAR(16) DEFAULT 'published',
+    published_at             DATETIME,
+    require_comment_approval NUMERIC,
+    created_at               DATETIME,
+    updated_at               DATETIME,
+    deleted_at               DATETIME,
+    CONSTRAINT fk_users_posts FOREIGN KEY (author_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_posts_author_id ON posts (author_id);
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    title      VARCHAR(255),
+    content    TEXT,
+    author_id  INTEGER,
+    post_id    INTEGER,
+    parent_id  INTEGER,
+    depth      INTEGER,
+    status     VARCHAR(16) DEFAULT 'approved',
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    CONSTRAINT fk_users_comments FOREIGN KEY (author_id) REFERENCES users (id),
+    CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts (id)
+);
+CREATE INDEX IF NOT EXISTS idx_comments_author_id ON comments (author_id);
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       VARCHAR(50),
+    slug       VARCHAR(50),
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_slug ON tags (slug);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       VARCHAR(50),
+    slug       VARCHAR(50),
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id INTEGER,
+    tag_id  INTEGER,
+    PRIMARY KEY (post_id, tag_id),
+    CONSTRAINT fk_post_tags_post FOREIGN KEY (post_id) REFERENCES posts (id),
+    CONSTRAINT fk_post_tags_tag FOREIGN KEY (tag_id) REFERENCES tags (id)
+);
+
+CREATE TABLE IF NOT EXISTS post_categories (
+    post_id     INTEGER,
+    category_id INTEGER,
+    PRIMARY KEY (post_id, category_id),
+    CONSTRAINT fk_post_categories_post FOREIGN KEY (post_id) REFERENCES posts (id),
+    CONSTRAINT fk_post_categories_category FOREIGN KEY (category_id) REFERENCES categories (id)
+);
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id    INTEGER,
+    revision   INTEGER,
+    title      VARCHAR(255),
+    text       TEXT,
+    editor_id  INTEGER,
+    created_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_revision ON post_revisions (post_id, revision);
+CREATE INDEX IF NOT EXISTS idx_post_revisions_editor_id ON post_revisions (editor_id);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER     NOT NULL,
+    code_hash  VARCHAR(64) NOT NULL,
+    used_at    DATETIME,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id      INTEGER      NOT NULL,
+    name         VARCHAR(100) NOT NULL,
+    token_hash   VARCHAR(64)  NOT NULL,
+    hint         VARCHAR(16),
+    scopes       TEXT         NOT NULL,
+    expires_at   DATETIME     NOT NULL,
+    last_used_at DATETIME,
+    created_at   DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_tokens_token_hash ON personal_access_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER      NOT NULL,
+    provider   VARCHAR(64)  NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    email      TEXT,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_provider_subject ON user_identities (provider, subject);
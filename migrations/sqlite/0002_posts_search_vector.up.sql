@@ -0,0 +1,3 @@
+-- В SQLite нет tsvector: поиск по статьям выполняется через LIKE без дополнительных колонок.
+-- Миграция оставлена, чтобы версии схемы совпадали с PostgreSQL
+SELECT 1;
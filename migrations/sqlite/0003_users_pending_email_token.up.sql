@@ -0,0 +1,2 @@
+-- Хэш последней ссылки подтверждения нового email: ссылка действительна только для адреса из последнего запроса
+ALTER TABLE users ADD COLUMN pending_email_token VARCHAR(64);
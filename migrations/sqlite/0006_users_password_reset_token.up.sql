@@ -0,0 +1,2 @@
+-- Хэш последней ссылки сброса пароля: новый запрос делает прежние ссылки недействительными
+ALTER TABLE users ADD COLUMN password_reset_token VARCHAR(64);
@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"github.com/crafty-ezhik/blog-api/db"
+	"github.com/crafty-ezhik/blog-api/pkg/migrate"
+	"gorm.io/gorm"
+)
+
+// addedColumn - колонка users, posts или comments, которой не было в схеме прежнего migrations/auto.go
+type addedColumn struct {
+	table, name      string
+	postgres, sqlite string // тип и значение по умолчанию для каждой СУБД
+	fill             string // значение для уже существующих строк, пустое - оставить NULL или DEFAULT
+}
+
+var addedColumns = []addedColumn{
+	{table: "users", name: "role", postgres: "VARCHAR(32) DEFAULT 'author'", sqlite: "VARCHAR(32) DEFAULT 'author'"},
+	{table: "users", name: "email_verified_at", postgres: "TIMESTAMPTZ", sqlite: "DATETIME"},
+	{table: "users", name: "pending_email", postgres: "TEXT", sqlite: "TEXT"},
+	{table: "users", name: "totp_secret", postgres: "TEXT", sqlite: "TEXT"},
+	{table: "users", name: "totp_enabled", postgres: "BOOLEAN", sqlite: "NUMERIC", fill: "false"},
+	{table: "posts", name: "status", postgres: "VARCHAR(16) DEFAULT 'published'", sqlite: "VARCHAR(16) DEFAULT 'published'"},
+	{table: "posts", name: "published_at", postgres: "TIMESTAMPTZ", sqlite: "DATETIME", fill: "created_at"},
+	{table: "posts", name: "require_comment_approval", postgres: "BOOLEAN", sqlite: "NUMERIC", fill: "false"},
+	{table: "comments", name: "parent_id", postgres: "BIGINT", sqlite: "INTEGER"},
+	{table: "comments", name: "depth", postgres: "BIGINT", sqlite: "INTEGER", fill: "0"},
+	{table: "comments", name: "status", postgres: "VARCHAR(16) DEFAULT 'approved'", sqlite: "VARCHAR(16) DEFAULT 'approved'"},
+}
+
+// addedIndexes - индексы по добавленным колонкам: имя, таблица и колонка
+var addedIndexes = [][3]string{
+	{"idx_posts_status", "posts", "status"},
+	{"idx_posts_published_at", "posts", "published_at"},
+	{"idx_comments_parent_id", "comments", "parent_id"},
+	{"idx_comments_status", "comments", "status"},
+}
+
+// upgradeAutoMigrated - дополняет схему базы, созданной прежним migrations/auto.go. На такой базе
+// 0001_init пропускает уже существующие users, posts и comments, и в них нет колонок, добавленных позже.
+// Миграция на Go, потому что SQLite не умеет ADD COLUMN IF NOT EXISTS. На базе, созданной 0001_init, колонки уже есть,
+// и миграция только создает индексы по ним: в 0001_init их нет, иначе она падала бы на базе auto.go
+var upgradeAutoMigrated = migrate.Go(5, "upgrade_auto_migrated_schema", func(tx *gorm.DB) error {
+	for _, column := range addedColumns {
+		if tx.Migrator().HasColumn(column.table, column.name) {
+			continue
+		}
+		definition := column.postgres
+		if tx.Dialector.Name() == db.DriverSQLite {
+			definition = column.sqlite
+		}
+		if err := tx.Exec("ALTER TABLE " + column.table + " ADD COLUMN " + column.name + " " + definition).Error; err != nil {
+			return err
+		}
+		if column.fill == "" {
+			continue
+		}
+		if err := tx.Exec("UPDATE " + column.table + " SET " + column.name + " = " + column.fill).Error; err != nil {
+			return err
+		}
+	}
+	for _, index := range addedIndexes {
+		if err := tx.Exec("CREATE INDEX IF NOT EXISTS " + index[0] + " ON " + index[1] + " (" + index[2] + ")").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}, func(tx *gorm.DB) error {
+	// Колонки остаются: на базе, созданной 0001_init, они входят в ее таблицы и удаляются вместе с ними
+	for _, index := range addedIndexes {
+		if err := tx.Exec("DROP INDEX IF EXISTS " + index[0]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}, "v1")
@@ -10,9 +10,13 @@
 package mock_comment
 
 import (
+	context "context"
 	reflect "reflect"
 
 	comment "github.com/crafty-ezhik/blog-api/internal/comment"
+	models "github.com/crafty-ezhik/blog-api/internal/models"
+	policy "github.com/crafty-ezhik/blog-api/internal/policy"
+	pagination "github.com/crafty-ezhik/blog-api/pkg/pagination"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -41,58 +45,106 @@ func (m *MockCommentService) EXPECT() *MockCommentServiceMockRecorder {
 }
 
 // CreateCommentByPostID mocks base method.
-func (m *MockCommentService) CreateCommentByPostID(postID, authorID uint, arg2 *comment.CreateCommentRequest) error {
+func (m *MockCommentService) CreateCommentByPostID(ctx context.Context, postID uint, actor policy.Actor, arg3 *comment.CreateCommentRequest) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCommentByPostID", postID, authorID, arg2)
+	ret := m.ctrl.Call(m, "CreateCommentByPostID", ctx, postID, actor, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateCommentByPostID indicates an expected call of CreateCommentByPostID.
-func (mr *MockCommentServiceMockRecorder) CreateCommentByPostID(postID, authorID, arg2 any) *gomock.Call {
+func (mr *MockCommentServiceMockRecorder) CreateCommentByPostID(ctx, postID, actor, arg3 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCommentByPostID", reflect.TypeOf((*MockCommentService)(nil).CreateCommentByPostID), postID, authorID, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCommentByPostID", reflect.TypeOf((*MockCommentService)(nil).CreateCommentByPostID), ctx, postID, actor, arg3)
 }
 
 // DeleteComment mocks base method.
-func (m *MockCommentService) DeleteComment(commentID, PostID, userID uint) error {
+func (m *MockCommentService) DeleteComment(ctx context.Context, commentID, PostID uint, actor policy.Actor) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteComment", commentID, PostID, userID)
+	ret := m.ctrl.Call(m, "DeleteComment", ctx, commentID, PostID, actor)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteComment indicates an expected call of DeleteComment.
-func (mr *MockCommentServiceMockRecorder) DeleteComment(commentID, PostID, userID any) *gomock.Call {
+func (mr *MockCommentServiceMockRecorder) DeleteComment(ctx, commentID, PostID, actor any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockCommentService)(nil).DeleteComment), commentID, PostID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockCommentService)(nil).DeleteComment), ctx, commentID, PostID, actor)
+}
+
+// GetCommentTree mocks base method.
+func (m *MockCommentService) GetCommentTree(ctx context.Context, postID, viewerID uint, params *pagination.Params) (*comment.GetCommentsResponse, *pagination.Meta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentTree", ctx, postID, viewerID, params)
+	ret0, _ := ret[0].(*comment.GetCommentsResponse)
+	ret1, _ := ret[1].(*pagination.Meta)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetCommentTree indicates an expected call of GetCommentTree.
+func (mr *MockCommentServiceMockRecorder) GetCommentTree(ctx, postID, viewerID, params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentTree", reflect.TypeOf((*MockCommentService)(nil).GetCommentTree), ctx, postID, viewerID, params)
 }
 
 // GetCommentsByPostID mocks base method.
-func (m *MockCommentService) GetCommentsByPostID(postID, userID uint) (*comment.GetCommentsResponse, error) {
+func (m *MockCommentService) GetCommentsByPostID(ctx context.Context, postID, userID, viewerID uint, params *pagination.Params) (*comment.GetCommentsResponse, *pagination.Meta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommentsByPostID", ctx, postID, userID, viewerID, params)
+	ret0, _ := ret[0].(*comment.GetCommentsResponse)
+	ret1, _ := ret[1].(*pagination.Meta)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetCommentsByPostID indicates an expected call of GetCommentsByPostID.
+func (mr *MockCommentServiceMockRecorder) GetCommentsByPostID(ctx, postID, userID, viewerID, params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentsByPostID", reflect.TypeOf((*MockCommentService)(nil).GetCommentsByPostID), ctx, postID, userID, viewerID, params)
+}
+
+// GetModerationQueue mocks base method.
+func (m *MockCommentService) GetModerationQueue(ctx context.Context, actor policy.Actor, status models.CommentStatus, postID uint, params *pagination.Params) (*comment.GetCommentsResponse, *pagination.Meta, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCommentsByPostID", postID, userID)
+	ret := m.ctrl.Call(m, "GetModerationQueue", ctx, actor, status, postID, params)
 	ret0, _ := ret[0].(*comment.GetCommentsResponse)
+	ret1, _ := ret[1].(*pagination.Meta)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetModerationQueue indicates an expected call of GetModerationQueue.
+func (mr *MockCommentServiceMockRecorder) GetModerationQueue(ctx, actor, status, postID, params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetModerationQueue", reflect.TypeOf((*MockCommentService)(nil).GetModerationQueue), ctx, actor, status, postID, params)
+}
+
+// ModerateComments mocks base method.
+func (m *MockCommentService) ModerateComments(ctx context.Context, actor policy.Actor, commentIDs []uint, status models.CommentStatus) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ModerateComments", ctx, actor, commentIDs, status)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetCommentsByPostID indicates an expected call of GetCommentsByPostID.
-func (mr *MockCommentServiceMockRecorder) GetCommentsByPostID(postID, userID any) *gomock.Call {
+// ModerateComments indicates an expected call of ModerateComments.
+func (mr *MockCommentServiceMockRecorder) ModerateComments(ctx, actor, commentIDs, status any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommentsByPostID", reflect.TypeOf((*MockCommentService)(nil).GetCommentsByPostID), postID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModerateComments", reflect.TypeOf((*MockCommentService)(nil).ModerateComments), ctx, actor, commentIDs, status)
 }
 
 // UpdateComment mocks base method.
-func (m *MockCommentService) UpdateComment(commentID, PostID, userID uint, updatedFields *comment.UpdateCommentRequest) error {
+func (m *MockCommentService) UpdateComment(ctx context.Context, commentID, PostID uint, actor policy.Actor, updatedFields *comment.UpdateCommentRequest) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateComment", commentID, PostID, userID, updatedFields)
+	ret := m.ctrl.Call(m, "UpdateComment", ctx, commentID, PostID, actor, updatedFields)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateComment indicates an expected call of UpdateComment.
-func (mr *MockCommentServiceMockRecorder) UpdateComment(commentID, PostID, userID, updatedFields any) *gomock.Call {
+func (mr *MockCommentServiceMockRecorder) UpdateComment(ctx, commentID, PostID, actor, updatedFields any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateComment", reflect.TypeOf((*MockCommentService)(nil).UpdateComment), commentID, PostID, userID, updatedFields)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateComment", reflect.TypeOf((*MockCommentService)(nil).UpdateComment), ctx, commentID, PostID, actor, updatedFields)
 }
@@ -10,9 +10,14 @@
 package mock_post
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/crafty-ezhik/blog-api/internal/models"
+	policy "github.com/crafty-ezhik/blog-api/internal/policy"
+	post "github.com/crafty-ezhik/blog-api/internal/post"
+	pagination "github.com/crafty-ezhik/blog-api/pkg/pagination"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,89 +45,207 @@ func (m *MockPostService) EXPECT() *MockPostServiceMockRecorder {
 	return m.recorder
 }
 
+// ArchivePost mocks base method.
+func (m *MockPostService) ArchivePost(ctx context.Context, actor policy.Actor, postID uint) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchivePost", ctx, actor, postID)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ArchivePost indicates an expected call of ArchivePost.
+func (mr *MockPostServiceMockRecorder) ArchivePost(ctx, actor, postID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchivePost", reflect.TypeOf((*MockPostService)(nil).ArchivePost), ctx, actor, postID)
+}
+
 // CreatePost mocks base method.
-func (m *MockPostService) CreatePost(post *models.Post) error {
+func (m *MockPostService) CreatePost(ctx context.Context, actor policy.Actor, arg2 *models.Post) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreatePost", post)
+	ret := m.ctrl.Call(m, "CreatePost", ctx, actor, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreatePost indicates an expected call of CreatePost.
-func (mr *MockPostServiceMockRecorder) CreatePost(post any) *gomock.Call {
+func (mr *MockPostServiceMockRecorder) CreatePost(ctx, actor, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePost", reflect.TypeOf((*MockPostService)(nil).CreatePost), post)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePost", reflect.TypeOf((*MockPostService)(nil).CreatePost), ctx, actor, arg2)
 }
 
 // DeletePost mocks base method.
-func (m *MockPostService) DeletePost(postID uint) error {
+func (m *MockPostService) DeletePost(ctx context.Context, actor policy.Actor, postID uint) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeletePost", postID)
+	ret := m.ctrl.Call(m, "DeletePost", ctx, actor, postID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeletePost indicates an expected call of DeletePost.
-func (mr *MockPostServiceMockRecorder) DeletePost(postID any) *gomock.Call {
+func (mr *MockPostServiceMockRecorder) DeletePost(ctx, actor, postID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePost", reflect.TypeOf((*MockPostService)(nil).DeletePost), postID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePost", reflect.TypeOf((*MockPostService)(nil).DeletePost), ctx, actor, postID)
 }
 
 // GetAllPosts mocks base method.
-func (m *MockPostService) GetAllPosts() ([]models.Post, error) {
+func (m *MockPostService) GetAllPosts(ctx context.Context, filter *post.Filter, params *pagination.Params) (*pagination.Page[models.Post], error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllPosts")
-	ret0, _ := ret[0].([]models.Post)
+	ret := m.ctrl.Call(m, "GetAllPosts", ctx, filter, params)
+	ret0, _ := ret[0].(*pagination.Page[models.Post])
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllPosts indicates an expected call of GetAllPosts.
-func (mr *MockPostServiceMockRecorder) GetAllPosts() *gomock.Call {
+func (mr *MockPostServiceMockRecorder) GetAllPosts(ctx, filter, params any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPosts", reflect.TypeOf((*MockPostService)(nil).GetAllPosts))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPosts", reflect.TypeOf((*MockPostService)(nil).GetAllPosts), ctx, filter, params)
 }
 
 // GetPostById mocks base method.
-func (m *MockPostService) GetPostById(postID uint) (*models.Post, error) {
+func (m *MockPostService) GetPostById(ctx context.Context, postID, viewerID uint) (*models.Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPostById", postID)
+	ret := m.ctrl.Call(m, "GetPostById", ctx, postID, viewerID)
 	ret0, _ := ret[0].(*models.Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPostById indicates an expected call of GetPostById.
-func (mr *MockPostServiceMockRecorder) GetPostById(postID any) *gomock.Call {
+func (mr *MockPostServiceMockRecorder) GetPostById(ctx, postID, viewerID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostById", reflect.TypeOf((*MockPostService)(nil).GetPostById), postID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostById", reflect.TypeOf((*MockPostService)(nil).GetPostById), ctx, postID, viewerID)
 }
 
 // GetPostsByAuthorID mocks base method.
-func (m *MockPostService) GetPostsByAuthorID(authorID uint) ([]models.Post, error) {
+func (m *MockPostService) GetPostsByAuthorID(ctx context.Context, authorID, viewerID uint, params *pagination.Params) (*pagination.Page[models.Post], error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPostsByAuthorID", authorID)
-	ret0, _ := ret[0].([]models.Post)
+	ret := m.ctrl.Call(m, "GetPostsByAuthorID", ctx, authorID, viewerID, params)
+	ret0, _ := ret[0].(*pagination.Page[models.Post])
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPostsByAuthorID indicates an expected call of GetPostsByAuthorID.
-func (mr *MockPostServiceMockRecorder) GetPostsByAuthorID(authorID any) *gomock.Call {
+func (mr *MockPostServiceMockRecorder) GetPostsByAuthorID(ctx, authorID, viewerID, params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByAuthorID", reflect.TypeOf((*MockPostService)(nil).GetPostsByAuthorID), ctx, authorID, viewerID, params)
+}
+
+// GetRevision mocks base method.
+func (m *MockPostService) GetRevision(ctx context.Context, actor policy.Actor, postID uint, revision int) (*post.RevisionDiffResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevision", ctx, actor, postID, revision)
+	ret0, _ := ret[0].(*post.RevisionDiffResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevision indicates an expected call of GetRevision.
+func (mr *MockPostServiceMockRecorder) GetRevision(ctx, actor, postID, revision any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevision", reflect.TypeOf((*MockPostService)(nil).GetRevision), ctx, actor, postID, revision)
+}
+
+// GetRevisions mocks base method.
+func (m *MockPostService) GetRevisions(ctx context.Context, actor policy.Actor, postID uint) ([]post.RevisionResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevisions", ctx, actor, postID)
+	ret0, _ := ret[0].([]post.RevisionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevisions indicates an expected call of GetRevisions.
+func (mr *MockPostServiceMockRecorder) GetRevisions(ctx, actor, postID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevisions", reflect.TypeOf((*MockPostService)(nil).GetRevisions), ctx, actor, postID)
+}
+
+// PublishPost mocks base method.
+func (m *MockPostService) PublishPost(ctx context.Context, actor policy.Actor, postID uint, publishAt *time.Time) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublishPost", ctx, actor, postID, publishAt)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PublishPost indicates an expected call of PublishPost.
+func (mr *MockPostServiceMockRecorder) PublishPost(ctx, actor, postID, publishAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishPost", reflect.TypeOf((*MockPostService)(nil).PublishPost), ctx, actor, postID, publishAt)
+}
+
+// RestoreRevision mocks base method.
+func (m *MockPostService) RestoreRevision(ctx context.Context, actor policy.Actor, postID uint, revision int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreRevision", ctx, actor, postID, revision)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreRevision indicates an expected call of RestoreRevision.
+func (mr *MockPostServiceMockRecorder) RestoreRevision(ctx, actor, postID, revision any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreRevision", reflect.TypeOf((*MockPostService)(nil).RestoreRevision), ctx, actor, postID, revision)
+}
+
+// SearchPosts mocks base method.
+func (m *MockPostService) SearchPosts(ctx context.Context, query string, params *pagination.Params) (*pagination.Page[post.SearchResult], error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchPosts", ctx, query, params)
+	ret0, _ := ret[0].(*pagination.Page[post.SearchResult])
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchPosts indicates an expected call of SearchPosts.
+func (mr *MockPostServiceMockRecorder) SearchPosts(ctx, query, params any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchPosts", reflect.TypeOf((*MockPostService)(nil).SearchPosts), ctx, query, params)
+}
+
+// SetCommentApproval mocks base method.
+func (m *MockPostService) SetCommentApproval(ctx context.Context, actor policy.Actor, postID uint, required bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCommentApproval", ctx, actor, postID, required)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetCommentApproval indicates an expected call of SetCommentApproval.
+func (mr *MockPostServiceMockRecorder) SetCommentApproval(ctx, actor, postID, required any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCommentApproval", reflect.TypeOf((*MockPostService)(nil).SetCommentApproval), ctx, actor, postID, required)
+}
+
+// UnpublishPost mocks base method.
+func (m *MockPostService) UnpublishPost(ctx context.Context, actor policy.Actor, postID uint) (*models.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnpublishPost", ctx, actor, postID)
+	ret0, _ := ret[0].(*models.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnpublishPost indicates an expected call of UnpublishPost.
+func (mr *MockPostServiceMockRecorder) UnpublishPost(ctx, actor, postID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByAuthorID", reflect.TypeOf((*MockPostService)(nil).GetPostsByAuthorID), authorID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnpublishPost", reflect.TypeOf((*MockPostService)(nil).UnpublishPost), ctx, actor, postID)
 }
 
 // UpdatePost mocks base method.
-func (m *MockPostService) UpdatePost(postID uint, updatedFields *models.Post) error {
+func (m *MockPostService) UpdatePost(ctx context.Context, actor policy.Actor, postID uint, updatedFields *models.Post) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdatePost", postID, updatedFields)
+	ret := m.ctrl.Call(m, "UpdatePost", ctx, actor, postID, updatedFields)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdatePost indicates an expected call of UpdatePost.
-func (mr *MockPostServiceMockRecorder) UpdatePost(postID, updatedFields any) *gomock.Call {
+func (mr *MockPostServiceMockRecorder) UpdatePost(ctx, actor, postID, updatedFields any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePost", reflect.TypeOf((*MockPostService)(nil).UpdatePost), postID, updatedFields)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePost", reflect.TypeOf((*MockPostService)(nil).UpdatePost), ctx, actor, postID, updatedFields)
 }
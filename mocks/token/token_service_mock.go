@@ -0,0 +1,102 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: service.go
+//
+// Generated by this command:
+//
+//	mockgen -source=service.go -destination=mock/token_service_mock.go
+//
+
+// Package mock_token is a generated GoMock package.
+package mock_token
+
+import (
+	context "context"
+	reflect "reflect"
+
+	token "github.com/crafty-ezhik/blog-api/internal/token"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockTokenService is a mock of TokenService interface.
+type MockTokenService struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockTokenServiceMockRecorder is the mock recorder for MockTokenService.
+type MockTokenServiceMockRecorder struct {
+	mock *MockTokenService
+}
+
+// NewMockTokenService creates a new mock instance.
+func NewMockTokenService(ctrl *gomock.Controller) *MockTokenService {
+	mock := &MockTokenService{ctrl: ctrl}
+	mock.recorder = &MockTokenServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenService) EXPECT() *MockTokenServiceMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockTokenService) Create(ctx context.Context, userID uint, data *token.CreateRequest) (*token.CreateResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, userID, data)
+	ret0, _ := ret[0].(*token.CreateResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockTokenServiceMockRecorder) Create(ctx, userID, data any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockTokenService)(nil).Create), ctx, userID, data)
+}
+
+// List mocks base method.
+func (m *MockTokenService) List(ctx context.Context, userID uint) ([]token.TokenResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, userID)
+	ret0, _ := ret[0].([]token.TokenResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockTokenServiceMockRecorder) List(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockTokenService)(nil).List), ctx, userID)
+}
+
+// Revoke mocks base method.
+func (m *MockTokenService) Revoke(ctx context.Context, userID, tokenID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, userID, tokenID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockTokenServiceMockRecorder) Revoke(ctx, userID, tokenID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockTokenService)(nil).Revoke), ctx, userID, tokenID)
+}
+
+// VerifyPersonalToken mocks base method.
+func (m *MockTokenService) VerifyPersonalToken(ctx context.Context, arg1 string) (uint, []string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyPersonalToken", ctx, arg1)
+	ret0, _ := ret[0].(uint)
+	ret1, _ := ret[1].([]string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// VerifyPersonalToken indicates an expected call of VerifyPersonalToken.
+func (mr *MockTokenServiceMockRecorder) VerifyPersonalToken(ctx, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyPersonalToken", reflect.TypeOf((*MockTokenService)(nil).VerifyPersonalToken), ctx, arg1)
+}
@@ -10,9 +10,11 @@
 package mock_user
 
 import (
+	context "context"
 	reflect "reflect"
 
 	models "github.com/crafty-ezhik/blog-api/internal/models"
+	policy "github.com/crafty-ezhik/blog-api/internal/policy"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,74 +42,117 @@ func (m *MockUserService) EXPECT() *MockUserServiceMockRecorder {
 	return m.recorder
 }
 
+// BootstrapAdmin mocks base method.
+func (m *MockUserService) BootstrapAdmin(ctx context.Context, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BootstrapAdmin", ctx, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BootstrapAdmin indicates an expected call of BootstrapAdmin.
+func (mr *MockUserServiceMockRecorder) BootstrapAdmin(ctx, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BootstrapAdmin", reflect.TypeOf((*MockUserService)(nil).BootstrapAdmin), ctx, email)
+}
+
 // Create mocks base method.
-func (m *MockUserService) Create(user *models.User) error {
+func (m *MockUserService) Create(ctx context.Context, user *models.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", user)
+	ret := m.ctrl.Call(m, "Create", ctx, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockUserServiceMockRecorder) Create(user any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) Create(ctx, user any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserService)(nil).Create), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserService)(nil).Create), ctx, user)
 }
 
 // Delete mocks base method.
-func (m *MockUserService) Delete(userID uint) error {
+func (m *MockUserService) Delete(ctx context.Context, actor policy.Actor, userID uint) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", userID)
+	ret := m.ctrl.Call(m, "Delete", ctx, actor, userID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockUserServiceMockRecorder) Delete(userID any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) Delete(ctx, actor, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserService)(nil).Delete), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserService)(nil).Delete), ctx, actor, userID)
 }
 
 // GetByEmail mocks base method.
-func (m *MockUserService) GetByEmail(email string) (*models.User, error) {
+func (m *MockUserService) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByEmail", email)
+	ret := m.ctrl.Call(m, "GetByEmail", ctx, email)
 	ret0, _ := ret[0].(*models.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetByEmail indicates an expected call of GetByEmail.
-func (mr *MockUserServiceMockRecorder) GetByEmail(email any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) GetByEmail(ctx, email any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByEmail", reflect.TypeOf((*MockUserService)(nil).GetByEmail), email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByEmail", reflect.TypeOf((*MockUserService)(nil).GetByEmail), ctx, email)
 }
 
 // GetByID mocks base method.
-func (m *MockUserService) GetByID(userID uint) (*models.User, error) {
+func (m *MockUserService) GetByID(ctx context.Context, userID uint) (*models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByID", userID)
+	ret := m.ctrl.Call(m, "GetByID", ctx, userID)
 	ret0, _ := ret[0].(*models.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetByID indicates an expected call of GetByID.
-func (mr *MockUserServiceMockRecorder) GetByID(userID any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) GetByID(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockUserService)(nil).GetByID), ctx, userID)
+}
+
+// GetRole mocks base method.
+func (m *MockUserService) GetRole(ctx context.Context, userID uint) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRole", ctx, userID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRole indicates an expected call of GetRole.
+func (mr *MockUserServiceMockRecorder) GetRole(ctx, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockUserService)(nil).GetByID), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRole", reflect.TypeOf((*MockUserService)(nil).GetRole), ctx, userID)
 }
 
 // Update mocks base method.
-func (m *MockUserService) Update(userID uint, updatedFields *models.User) error {
+func (m *MockUserService) Update(ctx context.Context, userID uint, updatedFields *models.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", userID, updatedFields)
+	ret := m.ctrl.Call(m, "Update", ctx, userID, updatedFields)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Update indicates an expected call of Update.
-func (mr *MockUserServiceMockRecorder) Update(userID, updatedFields any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) Update(ctx, userID, updatedFields any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUserService)(nil).Update), ctx, userID, updatedFields)
+}
+
+// UpdateRole mocks base method.
+func (m *MockUserService) UpdateRole(ctx context.Context, actor policy.Actor, userID uint, role models.Role) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRole", ctx, actor, userID, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRole indicates an expected call of UpdateRole.
+func (mr *MockUserServiceMockRecorder) UpdateRole(ctx, actor, userID, role any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUserService)(nil).Update), userID, updatedFields)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRole", reflect.TypeOf((*MockUserService)(nil).UpdateRole), ctx, actor, userID, role)
 }
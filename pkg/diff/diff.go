@@ -0,0 +1,170 @@
+package diff
+
+import (
+	"errors"
+	"strings"
+)
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// MaxEdits - предел количества изменений. Время сравнения растет как (N+M)*D,
+// поэтому тексты, которые отличаются сильнее, не сравниваются
+const MaxEdits = 10000
+
+var ErrTooLarge = errors.New("texts are too different to diff")
+
+// Line - строка результата сравнения: без изменений, добавлена или удалена
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines - построчное сравнение текстов from и to
+func Lines(from, to string) ([]Line, error) {
+	return Diff(splitLines(from), splitLines(to))
+}
+
+// Diff - кратчайший набор вставок и удалений, превращающий a в b (алгоритм Майерса в линейной памяти).
+// Память O(N+M), время O((N+M)*D), где D - количество изменений. При D > MaxEdits возвращает ErrTooLarge
+func Diff(a, b []string) ([]Line, error) {
+	size := len(a) + len(b)
+	d := &differ{
+		a:     a,
+		b:     b,
+		lines: make([]Line, 0, size),
+		vf:    make([]int, 2*size+3),
+		vb:    make([]int, 2*size+3),
+	}
+	if err := d.compare(0, len(a), 0, len(b)); err != nil {
+		return nil, err
+	}
+	return d.lines, nil
+}
+
+type differ struct {
+	a, b   []string
+	lines  []Line
+	vf, vb []int // самый дальний x на каждой диагонали для прямого и обратного поиска
+}
+
+// compare - сравнивает a[a0:a1] и b[b0:b1]: отбрасывает общие начало и конец, делит остаток
+// средней змейкой и сравнивает половины рекурсивно
+func (d *differ) compare(a0, a1, b0, b1 int) error {
+	prefix := 0
+	for a0+prefix < a1 && b0+prefix < b1 && d.a[a0+prefix] == d.b[b0+prefix] {
+		prefix++
+	}
+	d.equal(a0, a0+prefix)
+	a0, b0 = a0+prefix, b0+prefix
+
+	suffix := 0
+	for a1-suffix > a0 && b1-suffix > b0 && d.a[a1-suffix-1] == d.b[b1-suffix-1] {
+		suffix++
+	}
+	a1, b1 = a1-suffix, b1-suffix
+
+	switch {
+	case a0 == a1:
+		for i := b0; i < b1; i++ {
+			d.lines = append(d.lines, Line{Op: Insert, Text: d.b[i]})
+		}
+	case b0 == b1:
+		for i := a0; i < a1; i++ {
+			d.lines = append(d.lines, Line{Op: Delete, Text: d.a[i]})
+		}
+	default:
+		x, y, u, v, err := d.middleSnake(a0, a1, b0, b1)
+		if err != nil {
+			return err
+		}
+		if err = d.compare(a0, x, b0, y); err != nil {
+			return err
+		}
+		d.equal(x, u)
+		if err = d.compare(u, a1, v, b1); err != nil {
+			return err
+		}
+	}
+
+	d.equal(a1, a1+suffix)
+	return nil
+}
+
+func (d *differ) equal(from, to int) {
+	for i := from; i < to; i++ {
+		d.lines = append(d.lines, Line{Op: Equal, Text: d.a[i]})
+	}
+}
+
+// middleSnake - поиск кратчайшего пути одновременно с начала и с конца. Возвращает участок совпадений
+// (x, y) - (u, v) в середине кратчайшего пути, он делит сравнение на две независимые половины
+func (d *differ) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int, err error) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta%2 != 0
+	offset := (n+m+1)/2 + 1
+	vf, vb := d.vf, d.vb
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	for step := 0; step <= (n+m+1)/2; step++ {
+		if 2*step > MaxEdits {
+			return 0, 0, 0, 0, ErrTooLarge
+		}
+
+		// Прямой поиск: x - количество строк a, пройденных от начала
+		for k := -step; k <= step; k += 2 {
+			var px int
+			if k == -step || (k != step && vf[offset+k-1] < vf[offset+k+1]) {
+				px = vf[offset+k+1]
+			} else {
+				px = vf[offset+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < m && d.a[a0+px] == d.b[b0+py] {
+				px++
+				py++
+			}
+			vf[offset+k] = px
+			// Диагональ k прямого поиска - диагональ delta-k обратного
+			if odd && delta-k >= -(step-1) && delta-k <= step-1 && px+vb[offset+delta-k] >= n {
+				return a0 + sx, b0 + sy, a0 + px, b0 + py, nil
+			}
+		}
+
+		// Обратный поиск: x - количество строк a, пройденных от конца
+		for k := -step; k <= step; k += 2 {
+			var px int
+			if k == -step || (k != step && vb[offset+k-1] < vb[offset+k+1]) {
+				px = vb[offset+k+1]
+			} else {
+				px = vb[offset+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < m && d.a[a1-1-px] == d.b[b1-1-py] {
+				px++
+				py++
+			}
+			vb[offset+k] = px
+			if !odd && delta-k >= -step && delta-k <= step && px+vf[offset+delta-k] >= n {
+				return a1 - px, b1 - py, a1 - sx, b1 - sy, nil
+			}
+		}
+	}
+	// Пути всегда встречаются не позже чем через (n+m+1)/2 шагов
+	panic("diff: middle snake not found")
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,139 @@
+package diff
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		expected []Line
+	}{
+		{
+			name:     "Equal texts",
+			from:     "a\nb",
+			to:       "a\nb",
+			expected: []Line{{Equal, "a"}, {Equal, "b"}},
+		},
+		{
+			name:     "Line replaced",
+			from:     "a\nb\nc",
+			to:       "a\nx\nc",
+			expected: []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"}},
+		},
+		{
+			name:     "Lines appended",
+			from:     "a",
+			to:       "a\r\nb\r\nc",
+			expected: []Line{{Equal, "a"}, {Insert, "b"}, {Insert, "c"}},
+		},
+		{
+			name:     "From empty",
+			from:     "",
+			to:       "a",
+			expected: []Line{{Insert, "a"}},
+		},
+		{
+			name:     "To empty",
+			from:     "a\nb",
+			to:       "",
+			expected: []Line{{Delete, "a"}, {Delete, "b"}},
+		},
+		{
+			name:     "Both empty",
+			expected: []Line{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := Lines(tt.from, tt.to)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, lines)
+		})
+	}
+}
+
+func TestDiffIsMinimal(t *testing.T) {
+	a := []string{"a", "b", "c", "a", "b", "b", "a"}
+	b := []string{"c", "b", "a", "b", "a", "c"}
+	assert.Equal(t, 5, checkDiff(t, a, b))
+
+	// Сравнение с количеством изменений, посчитанным через наибольшую общую подпоследовательность
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		a, b := randomLines(random), randomLines(random)
+		assert.Equal(t, len(a)+len(b)-2*lcs(a, b), checkDiff(t, a, b))
+	}
+}
+
+func TestDiffTooLarge(t *testing.T) {
+	a, b := make([]string, MaxEdits), make([]string, MaxEdits)
+	for i := range a {
+		a[i], b[i] = "old "+strconv.Itoa(i), "new "+strconv.Itoa(i)
+	}
+	_, err := Diff(a, b)
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	// Небольшая правка большого текста сравнивается
+	b = append([]string{"new"}, a...)
+	lines, err := Diff(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, Line{Insert, "new"}, lines[0])
+	assert.Len(t, lines, MaxEdits+1)
+}
+
+// checkDiff - проверяет, что результат Diff восстанавливает a и b, и возвращает количество изменений
+func checkDiff(t *testing.T, a, b []string) int {
+	lines, err := Diff(a, b)
+	require.NoError(t, err)
+
+	changes := 0
+	var gotA, gotB []string
+	for _, line := range lines {
+		switch line.Op {
+		case Equal:
+			gotA, gotB = append(gotA, line.Text), append(gotB, line.Text)
+		case Delete:
+			gotA = append(gotA, line.Text)
+			changes++
+		case Insert:
+			gotB = append(gotB, line.Text)
+			changes++
+		}
+	}
+	assert.Equal(t, a, gotA)
+	assert.Equal(t, b, gotB)
+	return changes
+}
+
+func randomLines(random *rand.Rand) []string {
+	var lines []string
+	for i := random.Intn(12); i > 0; i-- {
+		lines = append(lines, string(rune('a'+random.Intn(3))))
+	}
+	return lines
+}
+
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
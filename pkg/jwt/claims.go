@@ -0,0 +1,65 @@
+package jwt
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"strconv"
+	"time"
+)
+
+// Значения claim typ
+const (
+	typAccess  = "access"
+	typRefresh = "refresh"
+)
+
+// Claims - содержимое токена. sub - ID пользователя, typ - тип токена (access или refresh)
+type Claims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid"`
+	Version   uint   `json:"version"`
+	Type      string `json:"typ"`
+}
+
+// ClaimsOptions - параметры зарегистрированных claims и их проверки
+type ClaimsOptions struct {
+	Issuer   string        // iss выпускаемых токенов; если задан, токены другого издателя отклоняются
+	Audience string        // aud выпускаемых токенов; если задан, токены для другой аудитории отклоняются
+	Leeway   time.Duration // допустимое расхождение часов при проверке exp, nbf и iat
+}
+
+// String - значение claim typ для типа токена
+func (t TokenType) String() string {
+	switch t {
+	case Access:
+		return typAccess
+	case Refresh:
+		return typRefresh
+	default:
+		return "unknown"
+	}
+}
+
+// parserOptions - проверки, выполняемые при разборе токена
+func (o ClaimsOptions) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithLeeway(o.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if o.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(o.Issuer))
+	}
+	if o.Audience != "" {
+		opts = append(opts, jwt.WithAudience(o.Audience))
+	}
+	return opts
+}
+
+// userID - ID пользователя из claim sub
+func (c *Claims) userID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 0)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
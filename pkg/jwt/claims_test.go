@@ -0,0 +1,86 @@
+package jwt_test
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/jwt"
+	mock_jwt "github.com/crafty-ezhik/blog-api/pkg/jwt/mock"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestJWT_Claims(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	versioner := mock_jwt.NewMockTokenVersionStorage(ctrl)
+	sessions := mock_jwt.NewMockSessionStorage(ctrl)
+	service := jwt.NewJWTService(nil, versioner, sessions)
+	versioner.EXPECT().GetVersion(gomock.Any(), uint(1)).Return(uint(0), nil).AnyTimes()
+	sessions.EXPECT().GetSession(gomock.Any(), "sid").Return(&jwt.Session{ID: "sid", UserID: 1, LastSeen: time.Now()}, nil).AnyTimes()
+
+	keys, err := jwt.NewKeySet(jwt.NewHMACKey("", "key"))
+	require.NoError(t, err)
+	options := jwt.ClaimsOptions{Issuer: "blog-api", Audience: "blog-api"}
+	auth := jwt.NewJWTWithKeys(service, time.Minute, time.Hour, keys, options)
+
+	t.Run("Access token", func(t *testing.T) {
+		token, err := auth.GenerateToken(context.Background(), 1, "sid", jwt.Access)
+		require.NoError(t, err)
+
+		data, err := auth.VerifyToken(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), data.UserId)
+		assert.NotEmpty(t, data.ID)
+	})
+
+	t.Run("Refresh token is not an access token", func(t *testing.T) {
+		token, err := auth.GenerateToken(context.Background(), 1, "sid", jwt.Refresh)
+		require.NoError(t, err)
+
+		_, err = auth.VerifyToken(context.Background(), token)
+		assert.ErrorIs(t, err, jwt.ErrWrongTokenType)
+	})
+
+	t.Run("Access token is not a refresh token", func(t *testing.T) {
+		token, err := auth.GenerateToken(context.Background(), 1, "sid", jwt.Access)
+		require.NoError(t, err)
+
+		_, err = auth.Refresh(context.Background(), token, jwt.SessionMeta{})
+		assert.ErrorIs(t, err, jwt.ErrWrongTokenType)
+	})
+
+	t.Run("Foreign issuer and audience", func(t *testing.T) {
+		for _, foreign := range []jwt.ClaimsOptions{
+			{Issuer: "other", Audience: "blog-api"},
+			{Issuer: "blog-api", Audience: "other"},
+			{},
+		} {
+			token, err := jwt.NewJWTWithKeys(service, time.Minute, time.Hour, keys, foreign).GenerateToken(context.Background(), 1, "sid", jwt.Access)
+			require.NoError(t, err)
+
+			_, err = auth.VerifyToken(context.Background(), token)
+			assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+		}
+	})
+
+	t.Run("Leeway", func(t *testing.T) {
+		expired, err := jwt.NewJWTWithKeys(service, -10*time.Second, time.Hour, keys, options).GenerateToken(context.Background(), 1, "sid", jwt.Access)
+		require.NoError(t, err)
+
+		_, err = auth.VerifyToken(context.Background(), expired)
+		assert.ErrorIs(t, err, jwt.ErrSessionExpired)
+
+		options.Leeway = 30 * time.Second
+		tolerant := jwt.NewJWTWithKeys(service, time.Minute, time.Hour, keys, options)
+		_, err = tolerant.VerifyToken(context.Background(), expired)
+		assert.NoError(t, err)
+	})
+}
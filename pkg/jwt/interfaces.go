@@ -1,15 +1,43 @@
 package jwt
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 //go:generate mockgen -source=interfaces.go -destination=mock/interfaces_mock.go
 
+// BlackListStorage - замененные и отозванные refresh токены. Хранятся только их jti
 type BlackListStorage interface {
-	IsBlackListed(token string) bool
-	AddToBlackList(token string, ttl time.Duration) error
+	IsBlackListed(ctx context.Context, tokenID string) bool
+	AddToBlackList(ctx context.Context, tokenID string, ttl time.Duration) error
 }
 
+// TokenVersionStorage - версия токенов пользователя. Увеличение версии завершает все его сессии
 type TokenVersionStorage interface {
-	IncrementVersion(userID uint) error
-	GetVersion(userID uint) (uint, error)
+	IncrementVersion(ctx context.Context, userID uint) error
+	GetVersion(ctx context.Context, userID uint) (uint, error)
+}
+
+// OneTimeTokenStorage - одноразовые токены (подтверждение email, сброс пароля)
+type OneTimeTokenStorage interface {
+	SaveOneTimeToken(ctx context.Context, purpose TokenPurpose, token string, userID uint, ttl time.Duration) error
+	// ConsumeOneTimeToken - возвращает владельца токена и удаляет токен, повторно использовать его нельзя
+	ConsumeOneTimeToken(ctx context.Context, purpose TokenPurpose, token string) (uint, error)
+}
+
+// SessionStorage - сессии пользователей по устройствам
+type SessionStorage interface {
+	CreateSession(ctx context.Context, session *Session, ttl time.Duration) error
+	// RotateSession - заменяет сессию и продлевает ее на ttl, только если в ней все еще записан jti previousJTI.
+	// Проверка и замена атомарны: из параллельных обновлений одним refresh токеном проходит одно,
+	// остальные получают ErrSessionRotated. Удаленную сессию не восстанавливает
+	RotateSession(ctx context.Context, session *Session, previousJTI string, ttl time.Duration) error
+	// TouchSession - обновляет только время последнего запроса. Остальные поля сессии, в том числе jti
+	// refresh токена, не перезаписываются, поэтому касание не может отменить параллельную замену токена
+	TouchSession(ctx context.Context, sessionID string, lastSeen time.Time) error
+	GetSession(ctx context.Context, sessionID string) (*Session, error)
+	ListSessions(ctx context.Context, userID uint) ([]Session, error)
+	DeleteSession(ctx context.Context, userID uint, sessionID string) error
+	DeleteUserSessions(ctx context.Context, userID uint) error
 }
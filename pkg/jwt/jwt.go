@@ -1,20 +1,27 @@
 package jwt
 
 import (
+	"context"
 	"errors"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
+	"sort"
+	"strconv"
 	"time"
 )
 
 //go:generate mockgen -source=jwt.go -destination=mock/jwt_mock.go
 
 type JWTInterface interface {
-	GenerateToken(userID uint, tokenType TokenType) (string, error)
-	VerifyToken(tokenString string) (*JWTData, error)
-	Refresh(refreshToken string) (*Tokens, error)
-	Logout(refreshToken string) error
+	StartSession(ctx context.Context, userID uint, meta SessionMeta) (*Tokens, error)
+	GenerateToken(ctx context.Context, userID uint, sessionID string, tokenType TokenType) (string, error)
+	VerifyToken(ctx context.Context, tokenString string) (*JWTData, error)
+	Refresh(ctx context.Context, refreshToken string, meta SessionMeta) (*Tokens, error)
+	Logout(ctx context.Context, refreshToken string) error
+	ListSessions(ctx context.Context, userID uint) ([]Session, error)
+	RevokeSession(ctx context.Context, userID uint, sessionID string) error
+	RevokeAllSessions(ctx context.Context, userID uint) error
 }
 
 type TokenType int
@@ -32,29 +39,47 @@ var (
 	ErrRefreshExpired          = errors.New("refresh token expired due to logout / password change")
 	ErrUnknownTokenType        = errors.New("unknown token type")
 	ErrInBlackList             = errors.New("refresh token revoked or not found")
+	ErrRefreshReuse            = errors.New("refresh token reuse detected, session revoked")
+	ErrWrongTokenType          = errors.New("unexpected token type")
 )
 
 type JWT struct {
 	jwtService *JWTService
 	accessTTL  time.Duration
 	refreshTTL time.Duration
-	signingKey string
+	keys       *KeySet
+	claims     ClaimsOptions
 }
 
+// NewJWT - токены с подписью HS256 одним секретом, без заголовка kid
 func NewJWT(jwtService *JWTService, accessTTL, refreshTTL time.Duration, signingKey string) *JWT {
+	keys, _ := NewKeySet(NewHMACKey("", signingKey))
+	return NewJWTWithKeys(jwtService, accessTTL, refreshTTL, keys, ClaimsOptions{})
+}
+
+// NewJWTWithKeys - токены подписываются текущим ключом набора, проверяются любым ключом набора по kid
+func NewJWTWithKeys(jwtService *JWTService, accessTTL, refreshTTL time.Duration, keys *KeySet, claims ClaimsOptions) *JWT {
 	logger.Log.Debug("Init JWT module")
 	return &JWT{
 		jwtService: jwtService,
 		accessTTL:  accessTTL,
 		refreshTTL: refreshTTL,
-		signingKey: signingKey,
+		keys:       keys,
+		claims:     claims,
 	}
 }
 
+// JWKS - открытые ключи для проверки токенов другими сервисами
+func (j *JWT) JWKS() JWKS {
+	return j.keys.JWKS()
+}
+
 type JWTData struct {
-	UserId  uint  `json:"user_id"`
-	Exp     int64 `json:"exp"`
-	Version uint  `json:"version"`
+	ID        string `json:"jti"`
+	UserId    uint   `json:"user_id"`
+	SessionID string `json:"sid"`
+	Exp       int64  `json:"exp"`
+	Version   uint   `json:"version"`
 }
 
 type Tokens struct {
@@ -62,169 +87,341 @@ type Tokens struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
-func (j *JWT) GenerateToken(userID uint, tokenType TokenType) (string, error) {
+// StartSession - создает сессию для нового входа и выдает пару токенов, привязанных к ней.
+// Сессия является семейством refresh токенов: в ней хранится jti единственного действующего refresh токена
+func (j *JWT) StartSession(ctx context.Context, userID uint, meta SessionMeta) (*Tokens, error) {
+	logger.Log.Info("Calling the StartSession function")
+	sessionID, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, refreshID, err := j.generatePair(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:         sessionID,
+		UserID:     userID,
+		UserAgent:  meta.UserAgent,
+		IP:         meta.IP,
+		RefreshJTI: refreshID,
+		CreatedAt:  now,
+		LastSeen:   now,
+	}
+	err = j.jwtService.sessions.CreateSession(ctx, session, j.refreshTTL)
+	if err != nil {
+		logger.Log.Error("Error creating session", zap.Error(err))
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// generatePair - выдает пару токенов и возвращает jti refresh токена
+func (j *JWT) generatePair(ctx context.Context, userID uint, sessionID string) (*Tokens, string, error) {
+	accessToken, _, err := j.generateToken(ctx, userID, sessionID, Access)
+	if err != nil {
+		return nil, "", err
+	}
+	refreshToken, refreshID, err := j.generateToken(ctx, userID, sessionID, Refresh)
+	if err != nil {
+		return nil, "", err
+	}
+	return &Tokens{AccessToken: accessToken, RefreshToken: refreshToken}, refreshID, nil
+}
+
+func (j *JWT) GenerateToken(ctx context.Context, userID uint, sessionID string, tokenType TokenType) (string, error) {
+	token, _, err := j.generateToken(ctx, userID, sessionID, tokenType)
+	return token, err
+}
+
+func (j *JWT) generateToken(ctx context.Context, userID uint, sessionID string, tokenType TokenType) (string, string, error) {
 	logger.Log.Info("Calling the GenerateToken function")
 	logger.Log.Debug("Generating new token", zap.Uint("user_id", userID))
-	currentVersion, err := j.jwtService.versioner.GetVersion(userID)
+	currentVersion, err := j.jwtService.versioner.GetVersion(ctx, userID)
 	if err != nil {
 		logger.Log.Error("Error generating token: ", zap.Error(err))
-		return "", err
+		return "", "", err
 	}
 
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"iat":     time.Now().Unix(),
-		"version": currentVersion,
+	tokenID, err := randomID()
+	if err != nil {
+		return "", "", err
 	}
 
 	logger.Log.Debug("Check token type")
+	var ttl time.Duration
 	switch tokenType {
 	case Access:
-		claims["exp"] = time.Now().Add(j.accessTTL).Unix()
+		ttl = j.accessTTL
 	case Refresh:
-		claims["exp"] = time.Now().Add(j.refreshTTL).Unix()
+		ttl = j.refreshTTL
 	default:
-		return "", ErrUnknownTokenType
+		return "", "", ErrUnknownTokenType
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(j.signingKey))
+	now := time.Now()
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Issuer:    j.claims.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		SessionID: sessionID,
+		Version:   currentVersion,
+		Type:      tokenType.String(),
+	}
+	if j.claims.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{j.claims.Audience}
+	}
+
+	signing := j.keys.signing
+	token := jwt.NewWithClaims(signing.Method, claims)
+	if signing.ID != "" {
+		token.Header["kid"] = signing.ID
+	}
+	signedToken, err := token.SignedString(signing.Private)
 	if err != nil {
 		logger.Log.Error("Error when signing the token: ", zap.Error(err))
-		return "", err
+		return "", "", err
 	}
 	logger.Log.Info("Generated new token successfully")
-	return signedToken, nil
+	return signedToken, tokenID, nil
 }
 
-func (j *JWT) VerifyToken(tokenString string) (*JWTData, error) {
+// VerifyToken - проверяет access токен. Refresh токены отклоняются
+func (j *JWT) VerifyToken(ctx context.Context, tokenString string) (*JWTData, error) {
 	logger.Log.Info("Calling the VerifyToken function")
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrUnexpectedSigningMethod
-		}
-		return []byte(j.signingKey), nil
-	})
+	data, _, err := j.verify(ctx, tokenString, Access)
+	return data, err
+}
+
+// verify - проверяет подпись, зарегистрированные claims, тип, версию и сессию токена
+func (j *JWT) verify(ctx context.Context, tokenString string, tokenType TokenType) (*JWTData, *Session, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, j.keys.lookup, j.claims.parserOptions()...)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		logger.Log.Debug("The token expired")
+		return nil, nil, ErrSessionExpired
+	}
 	if err != nil {
 		logger.Log.Error("Error parsing token", zap.Error(err))
-		return nil, ErrInvalidToken
+		return nil, nil, ErrInvalidToken
 	}
 
-	logger.Log.Debug("Conversion to jwt.MapClaims")
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		logger.Log.Error("Invalid token")
-		return nil, ErrInvalidToken
+	// Проверка типа, чтобы refresh токен нельзя было использовать как access и наоборот
+	logger.Log.Debug("Check token type")
+	if claims.Type != tokenType.String() {
+		logger.Log.Debug("Unexpected token type", zap.String("typ", claims.Type))
+		return nil, nil, ErrWrongTokenType
 	}
 
 	// Получение UserID
 	logger.Log.Debug("Get user_id")
-	userID := uint(claims["user_id"].(float64))
+	userID, err := claims.userID()
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Получение и проверка версии
 	logger.Log.Debug("Get and check version")
-	version := uint(claims["version"].(float64))
-	currentVersion, err := j.jwtService.versioner.GetVersion(userID)
+	version := claims.Version
+	currentVersion, err := j.jwtService.versioner.GetVersion(ctx, userID)
 	if err != nil {
 		logger.Log.Error("Error generating token", zap.Error(err))
-		return nil, ErrInternalServer
+		return nil, nil, ErrInternalServer
 	}
 	if version < currentVersion {
 		logger.Log.Debug("The token version does not match:", zap.Error(err))
-		return nil, ErrRefreshExpired
+		return nil, nil, ErrRefreshExpired
 	}
 
-	// Получение и проверка exp
-	logger.Log.Debug("Get and check exp")
-	exp := int64(claims["exp"].(float64))
-	if time.Now().Unix() > exp {
-		logger.Log.Debug("The token expired")
-		return nil, ErrSessionExpired
+	// Проверка сессии устройства
+	logger.Log.Debug("Get and check session")
+	sessionID := claims.SessionID
+	if sessionID == "" {
+		return nil, nil, ErrInvalidToken
+	}
+	session, err := j.jwtService.sessions.GetSession(ctx, sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		logger.Log.Debug("The session was revoked", zap.String("sid", sessionID))
+		return nil, nil, ErrSessionRevoked
+	}
+	if err != nil {
+		logger.Log.Error("Error getting session", zap.Error(err))
+		return nil, nil, ErrInternalServer
+	}
+	if session.UserID != userID {
+		return nil, nil, ErrInvalidToken
+	}
+	if time.Since(session.LastSeen) > sessionTouchInterval {
+		session.LastSeen = time.Now()
+		if err = j.jwtService.sessions.TouchSession(ctx, sessionID, session.LastSeen); err != nil {
+			logger.Log.Warn("Error updating session last seen", zap.Error(err))
+		}
 	}
 
 	return &JWTData{
-		UserId:  userID,
-		Exp:     exp,
-		Version: version,
-	}, nil
+		ID:        claims.ID,
+		UserId:    userID,
+		SessionID: sessionID,
+		Exp:       claims.ExpiresAt.Unix(),
+		Version:   version,
+	}, session, nil
 }
 
-func (j *JWT) Refresh(refreshToken string) (*Tokens, error) {
+// Refresh - выдает новую пару токенов в рамках той же сессии. Другие устройства пользователя не затрагиваются.
+// Повторное предъявление уже замененного refresh токена означает его кражу: сессия завершается целиком
+func (j *JWT) Refresh(ctx context.Context, refreshToken string, meta SessionMeta) (*Tokens, error) {
 	logger.Log.Info("Calling the Refresh function")
-	// Надо проверить не черном ли списке токен
-	if j.jwtService.blackLister.IsBlackListed(refreshToken) {
-		logger.Log.Debug("Token is blacklisted")
-		return nil, ErrInBlackList
-	}
 
 	// Парсинг токена
-	tokenData, err := j.VerifyToken(refreshToken)
+	tokenData, session, err := j.verify(ctx, refreshToken, Refresh)
 	if err != nil {
 		logger.Log.Error("Error verifying refresh token", zap.Error(err))
 		return nil, err
 	}
 
-	// Увеличение версии токена
-	logger.Log.Debug("Increment token version")
-	err = j.jwtService.versioner.IncrementVersion(tokenData.UserId)
+	// Надо проверить, не был ли токен уже заменен
+	err = j.checkReuse(ctx, tokenData, session)
 	if err != nil {
-		logger.Log.Error("Error incrementing version", zap.Error(err))
 		return nil, err
 	}
 
 	// Генерация новой пары ключей
-	newAccessToken, err := j.GenerateToken(tokenData.UserId, Access)
+	tokens, refreshID, err := j.generatePair(ctx, tokenData.UserId, tokenData.SessionID)
 	if err != nil {
-		logger.Log.Error("Error generating new access token", zap.Error(err))
+		logger.Log.Error("Error generating new tokens", zap.Error(err))
 		return nil, err
 	}
-	newRefreshToken, err := j.GenerateToken(tokenData.UserId, Refresh)
+
+	// Продление сессии. Замена проходит, только если сессия не была обновлена этим же токеном параллельно
+	logger.Log.Debug("Extend session")
+	rotated := *session
+	rotated.UserAgent = meta.UserAgent
+	rotated.IP = meta.IP
+	rotated.LastSeen = time.Now()
+	rotated.RefreshJTI = refreshID
+	err = j.jwtService.sessions.RotateSession(ctx, &rotated, tokenData.ID, j.refreshTTL)
+	if errors.Is(err, ErrSessionRotated) {
+		return nil, j.revokeFamily(ctx, tokenData)
+	}
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, ErrSessionRevoked
+	}
 	if err != nil {
-		logger.Log.Error("Error generating new refresh token", zap.Error(err))
+		logger.Log.Error("Error extending session", zap.Error(err))
 		return nil, err
 	}
 
 	// Добавление старого refresh токена в BlackList
 	logger.Log.Debug("Add old token into blacklist")
-	err = j.jwtService.blackLister.AddToBlackList(refreshToken, time.Until(time.Unix(tokenData.Exp, 0)))
+	err = j.jwtService.blackLister.AddToBlackList(ctx, tokenData.ID, time.Until(time.Unix(tokenData.Exp, 0)))
 	if err != nil {
 		logger.Log.Error("Error adding token to blacklist", zap.Error(err))
 		return nil, err
 	}
 
 	// Возврат значений
-	return &Tokens{
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
-	}, nil
+	return tokens, nil
 }
 
-func (j *JWT) Logout(refreshToken string) error {
+func (j *JWT) Logout(ctx context.Context, refreshToken string) error {
 	logger.Log.Info("Calling the Logout function")
-	if j.jwtService.blackLister.IsBlackListed(refreshToken) {
-		logger.Log.Debug("Token is blacklisted")
-		return ErrInBlackList
-	}
 
 	// Парсинг токена
-	tokenData, err := j.VerifyToken(refreshToken)
+	tokenData, session, err := j.verify(ctx, refreshToken, Refresh)
 	if err != nil {
 		logger.Log.Error("Error verifying refresh token", zap.Error(err))
 		return err
 	}
 
-	logger.Log.Debug("Increment token version")
-	err = j.jwtService.versioner.IncrementVersion(tokenData.UserId)
+	err = j.checkReuse(ctx, tokenData, session)
 	if err != nil {
-		logger.Log.Error("Error incrementing version", zap.Error(err))
+		return err
+	}
+
+	logger.Log.Debug("Delete session")
+	err = j.jwtService.sessions.DeleteSession(ctx, tokenData.UserId, tokenData.SessionID)
+	if err != nil {
+		logger.Log.Error("Error deleting session", zap.Error(err))
 		return ErrInternalServer
 	}
 
 	logger.Log.Debug("Add old token into blacklist")
-	err = j.jwtService.blackLister.AddToBlackList(refreshToken, time.Until(time.Unix(tokenData.Exp, 0)))
+	err = j.jwtService.blackLister.AddToBlackList(ctx, tokenData.ID, time.Until(time.Unix(tokenData.Exp, 0)))
 	if err != nil {
 		logger.Log.Error("Error adding token to blacklist", zap.Error(err))
 		return ErrInternalServer
 	}
 	return nil
 }
+
+// checkReuse - проверяет, что refresh токен последний в своем семействе.
+// Если нет - токен уже был заменен и его предъявляет кто-то другой, поэтому семейство отзывается
+func (j *JWT) checkReuse(ctx context.Context, tokenData *JWTData, session *Session) error {
+	if tokenData.ID == "" {
+		return ErrInvalidToken
+	}
+	if !j.jwtService.blackLister.IsBlackListed(ctx, tokenData.ID) && session.RefreshJTI == tokenData.ID {
+		return nil
+	}
+	return j.revokeFamily(ctx, tokenData)
+}
+
+// revokeFamily - завершает сессию, refresh токен которой предъявлен повторно
+func (j *JWT) revokeFamily(ctx context.Context, tokenData *JWTData) error {
+	logger.Log.Warn("Security event: refresh token reuse detected",
+		zap.String("event", "refresh_token_reuse"),
+		zap.Uint("user_id", tokenData.UserId),
+		zap.String("sid", tokenData.SessionID),
+		zap.String("jti", tokenData.ID),
+	)
+	err := j.jwtService.sessions.DeleteSession(ctx, tokenData.UserId, tokenData.SessionID)
+	if err != nil {
+		logger.Log.Error("Error revoking token family", zap.Error(err))
+		return ErrInternalServer
+	}
+	return ErrRefreshReuse
+}
+
+// ListSessions - активные сессии пользователя, недавно использованные первыми
+func (j *JWT) ListSessions(ctx context.Context, userID uint) ([]Session, error) {
+	sessions, err := j.jwtService.sessions.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(sessions, func(a, b int) bool {
+		return sessions[a].LastSeen.After(sessions[b].LastSeen)
+	})
+	return sessions, nil
+}
+
+// RevokeSession - завершает одну сессию пользователя
+func (j *JWT) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	session, err := j.jwtService.sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	// Чужая сессия для пользователя не существует
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	return j.jwtService.sessions.DeleteSession(ctx, userID, sessionID)
+}
+
+// RevokeAllSessions - выход со всех устройств. Увеличение версии отзывает и токены,
+// сессии которых по какой-то причине остались в хранилище
+func (j *JWT) RevokeAllSessions(ctx context.Context, userID uint) error {
+	err := j.jwtService.versioner.IncrementVersion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return j.jwtService.sessions.DeleteUserSessions(ctx, userID)
+}
@@ -3,11 +3,13 @@ package jwt
 type JWTService struct {
 	blackLister BlackListStorage
 	versioner   TokenVersionStorage
+	sessions    SessionStorage
 }
 
-func NewJWTService(bl BlackListStorage, tv TokenVersionStorage) *JWTService {
+func NewJWTService(bl BlackListStorage, tv TokenVersionStorage, ss SessionStorage) *JWTService {
 	return &JWTService{
 		blackLister: bl,
 		versioner:   tv,
+		sessions:    ss,
 	}
 }
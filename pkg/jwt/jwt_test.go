@@ -0,0 +1,174 @@
+package jwt_test
+
+import (
+	"context"
+	"errors"
+	"github.com/crafty-ezhik/blog-api/pkg/jwt"
+	mock_jwt "github.com/crafty-ezhik/blog-api/pkg/jwt/mock"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJWT_Sessions(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	blackList := mock_jwt.NewMockBlackListStorage(ctrl)
+	versioner := mock_jwt.NewMockTokenVersionStorage(ctrl)
+	sessions := mock_jwt.NewMockSessionStorage(ctrl)
+	auth := jwt.NewJWT(jwt.NewJWTService(blackList, versioner, sessions), time.Minute, time.Hour, "key")
+
+	versioner.EXPECT().GetVersion(gomock.Any(), uint(1)).Return(uint(0), nil).AnyTimes()
+
+	var created *jwt.Session
+	sessions.EXPECT().CreateSession(gomock.Any(), gomock.Any(), time.Hour).DoAndReturn(func(_ context.Context, s *jwt.Session, ttl time.Duration) error {
+		created = s
+		return nil
+	})
+	tokens, err := auth.StartSession(context.Background(), 1, jwt.SessionMeta{UserAgent: "Firefox", IP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.NotNil(t, created)
+	assert.Equal(t, "Firefox", created.UserAgent)
+
+	t.Run("Token carries session", func(t *testing.T) {
+		sessions.EXPECT().GetSession(gomock.Any(), created.ID).Return(created, nil)
+
+		data, err := auth.VerifyToken(context.Background(), tokens.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), data.UserId)
+		assert.Equal(t, created.ID, data.SessionID)
+	})
+
+	t.Run("Stale session is touched", func(t *testing.T) {
+		stale := *created
+		stale.LastSeen = time.Now().Add(-time.Hour)
+		sessions.EXPECT().GetSession(gomock.Any(), created.ID).Return(&stale, nil)
+		// Касание пишет только время запроса, refresh_jti не перезаписывается
+		sessions.EXPECT().TouchSession(gomock.Any(), created.ID, gomock.Any()).Return(nil)
+
+		_, err := auth.VerifyToken(context.Background(), tokens.AccessToken)
+		assert.NoError(t, err)
+	})
+
+	var rotated *jwt.Tokens
+	var stored *jwt.Session
+	t.Run("Refresh keeps session", func(t *testing.T) {
+		oldJTI := created.RefreshJTI
+		require.NotEmpty(t, oldJTI)
+
+		sessions.EXPECT().GetSession(gomock.Any(), created.ID).Return(created, nil)
+		blackList.EXPECT().IsBlackListed(gomock.Any(), oldJTI).Return(false)
+		sessions.EXPECT().RotateSession(gomock.Any(), gomock.Any(), oldJTI, time.Hour).DoAndReturn(func(_ context.Context, s *jwt.Session, previousJTI string, ttl time.Duration) error {
+			assert.Equal(t, created.ID, s.ID)
+			assert.Equal(t, "10.0.0.2", s.IP)
+			assert.NotEqual(t, oldJTI, s.RefreshJTI)
+			stored = s
+			return nil
+		})
+		blackList.EXPECT().AddToBlackList(gomock.Any(), oldJTI, gomock.Any()).Return(nil)
+
+		var err error
+		rotated, err = auth.Refresh(context.Background(), tokens.RefreshToken, jwt.SessionMeta{UserAgent: "Firefox", IP: "10.0.0.2"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, rotated.AccessToken)
+	})
+
+	t.Run("Reused refresh token revokes family", func(t *testing.T) {
+		require.NotNil(t, rotated)
+		sessions.EXPECT().GetSession(gomock.Any(), created.ID).Return(created, nil)
+		blackList.EXPECT().IsBlackListed(gomock.Any(), gomock.Any()).Return(true)
+		sessions.EXPECT().DeleteSession(gomock.Any(), uint(1), created.ID).Return(nil)
+
+		_, err := auth.Refresh(context.Background(), tokens.RefreshToken, jwt.SessionMeta{})
+		assert.ErrorIs(t, err, jwt.ErrRefreshReuse)
+	})
+
+	t.Run("Superseded refresh token revokes family", func(t *testing.T) {
+		// Токен не попал в BlackList, но в сессии уже записан другой jti
+		require.NotNil(t, stored)
+		sessions.EXPECT().GetSession(gomock.Any(), created.ID).Return(stored, nil)
+		blackList.EXPECT().IsBlackListed(gomock.Any(), gomock.Any()).Return(false)
+		sessions.EXPECT().DeleteSession(gomock.Any(), uint(1), created.ID).Return(nil)
+
+		err := auth.Logout(context.Background(), tokens.RefreshToken)
+		assert.ErrorIs(t, err, jwt.ErrRefreshReuse)
+	})
+
+	t.Run("Lost rotation race revokes family", func(t *testing.T) {
+		// Проверка прошла, но параллельный запрос с тем же токеном успел заменить его первым
+		sessions.EXPECT().GetSession(gomock.Any(), created.ID).Return(created, nil)
+		blackList.EXPECT().IsBlackListed(gomock.Any(), created.RefreshJTI).Return(false)
+		sessions.EXPECT().RotateSession(gomock.Any(), gomock.Any(), created.RefreshJTI, time.Hour).Return(jwt.ErrSessionRotated)
+		sessions.EXPECT().DeleteSession(gomock.Any(), uint(1), created.ID).Return(nil)
+
+		_, err := auth.Refresh(context.Background(), tokens.RefreshToken, jwt.SessionMeta{})
+		assert.ErrorIs(t, err, jwt.ErrRefreshReuse)
+	})
+
+	t.Run("Revoked session", func(t *testing.T) {
+		sessions.EXPECT().GetSession(gomock.Any(), created.ID).Return(nil, jwt.ErrSessionNotFound)
+
+		_, err := auth.VerifyToken(context.Background(), tokens.AccessToken)
+		assert.ErrorIs(t, err, jwt.ErrSessionRevoked)
+	})
+}
+
+// TestJWT_ConcurrentRefresh - из параллельных обновлений одним refresh токеном проходит только одно,
+// остальные считаются повторным использованием и завершают сессию
+func TestJWT_ConcurrentRefresh(t *testing.T) {
+	logger.Log = zap.NewNop()
+
+	redisSessions, _ := newRedisSessions(t)
+	memorySessions := jwt.NewMemorySessions(0)
+	defer memorySessions.Close()
+
+	for name, sessions := range map[string]jwt.SessionStorage{"memory": memorySessions, "redis": redisSessions} {
+		t.Run(name, func(t *testing.T) {
+			blackList, versioner := jwt.NewMemoryStorage(0)
+			defer blackList.Close()
+			auth := jwt.NewJWT(jwt.NewJWTService(blackList, versioner, sessions), time.Minute, time.Hour, "key")
+
+			tokens, err := auth.StartSession(context.Background(), 1, jwt.SessionMeta{})
+			require.NoError(t, err)
+			data, err := auth.VerifyToken(context.Background(), tokens.AccessToken)
+			require.NoError(t, err)
+
+			const workers = 16
+			errs := make([]error, workers)
+			var start, wg sync.WaitGroup
+			start.Add(1)
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					start.Wait()
+					_, errs[i] = auth.Refresh(context.Background(), tokens.RefreshToken, jwt.SessionMeta{})
+				}()
+			}
+			start.Done()
+			wg.Wait()
+
+			succeeded := 0
+			for _, err := range errs {
+				if err == nil {
+					succeeded++
+					continue
+				}
+				assert.True(t, errors.Is(err, jwt.ErrRefreshReuse) || errors.Is(err, jwt.ErrSessionRevoked), err)
+			}
+			assert.Equal(t, 1, succeeded)
+
+			_, err = sessions.GetSession(context.Background(), data.SessionID)
+			assert.ErrorIs(t, err, jwt.ErrSessionNotFound)
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"sort"
+)
+
+// Поддерживаемые алгоритмы подписи
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownKeyID        = errors.New("unknown key id")
+	ErrUnsupportedAlg      = errors.New("unsupported signing algorithm")
+	ErrSigningKeyRequired  = errors.New("signing key is required")
+	ErrKeyAlgMismatch      = errors.New("key does not match signing algorithm")
+	ErrVerificationKeyOnly = errors.New("key can only be used for verification")
+	ErrUnsupportedJWK      = errors.New("unsupported JSON web key")
+)
+
+// Key - ключ подписи токенов. Для асимметричных алгоритмов Private может отсутствовать,
+// тогда ключ используется только для проверки подписи (например, после ротации)
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private interface{}
+	Public  interface{}
+}
+
+// KeySet - текущий ключ подписи и все ключи, подписи которых еще принимаются. Ключи ищутся по kid
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewHMACKey - симметричный ключ HS256
+func NewHMACKey(id, secret string) *Key {
+	return &Key{
+		ID:      id,
+		Method:  jwt.SigningMethodHS256,
+		Private: []byte(secret),
+		Public:  []byte(secret),
+	}
+}
+
+// LoadKeyFromPEM - загружает ключ RS256, ES256 или EdDSA из PEM файла.
+// Файл может содержать закрытый ключ (подпись и проверка) или только открытый (проверка)
+func LoadKeyFromPEM(id, alg, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyPEM(id, alg, data)
+}
+
+// ParseKeyPEM - то же, что LoadKeyFromPEM, но из содержимого файла
+func ParseKeyPEM(id, alg string, data []byte) (*Key, error) {
+	key := &Key{ID: id}
+	var err error
+
+	switch alg {
+	case AlgRS256:
+		key.Method = jwt.SigningMethodRS256
+		var private *rsa.PrivateKey
+		if private, err = jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+			key.Private, key.Public = private, &private.PublicKey
+			break
+		}
+		key.Public, err = jwt.ParseRSAPublicKeyFromPEM(data)
+	case AlgES256:
+		key.Method = jwt.SigningMethodES256
+		var private *ecdsa.PrivateKey
+		if private, err = jwt.ParseECPrivateKeyFromPEM(data); err == nil {
+			key.Private, key.Public = private, &private.PublicKey
+			break
+		}
+		key.Public, err = jwt.ParseECPublicKeyFromPEM(data)
+	case AlgEdDSA:
+		key.Method = jwt.SigningMethodEdDSA
+		var private crypto.PrivateKey
+		if private, err = jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+			key.Private, key.Public = private, private.(ed25519.PrivateKey).Public()
+			break
+		}
+		key.Public, err = jwt.ParseEdPublicKeyFromPEM(data)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlg, alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	if alg == AlgES256 && key.Public.(*ecdsa.PublicKey).Curve.Params().BitSize != 256 {
+		return nil, fmt.Errorf("key %q: %w", id, ErrKeyAlgMismatch)
+	}
+	return key, nil
+}
+
+// NewKeySet - набор ключей. signing используется для выпуска токенов, verification - только для проверки.
+// Ключи должны иметь разные kid; пустой kid допустим для токенов, выпущенных без заголовка kid
+func NewKeySet(signing *Key, verification ...*Key) (*KeySet, error) {
+	if signing == nil {
+		return nil, ErrSigningKeyRequired
+	}
+	if signing.Private == nil {
+		return nil, fmt.Errorf("key %q: %w", signing.ID, ErrVerificationKeyOnly)
+	}
+
+	set := &KeySet{signing: signing, keys: map[string]*Key{signing.ID: signing}}
+	for _, key := range verification {
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+	return set, nil
+}
+
+// NewKeySetFromConfig - собирает набор ключей из конфигурации.
+// Для HS256 используется signing_key. Для асимметричных алгоритмов signing_key, если он задан,
+// остается ключом проверки, чтобы ранее выданные HS256 токены продолжали приниматься
+func NewKeySetFromConfig(cfg config.AuthConfig) (*KeySet, error) {
+	alg := cfg.Algorithm
+	if alg == "" {
+		alg = AlgHS256
+	}
+
+	var signing *Key
+	var verification []*Key
+	if alg == AlgHS256 {
+		if cfg.SigningKey == "" {
+			return nil, ErrSigningKeyRequired
+		}
+		signing = NewHMACKey(cfg.KeyID, cfg.SigningKey)
+	} else {
+		if cfg.PrivateKeyFile == "" {
+			return nil, ErrSigningKeyRequired
+		}
+		key, err := LoadKeyFromPEM(cfg.KeyID, alg, cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signing = key
+		if cfg.SigningKey != "" {
+			verification = append(verification, NewHMACKey("", cfg.SigningKey))
+		}
+	}
+
+	for _, vk := range cfg.VerificationKeys {
+		key, err := LoadKeyFromPEM(vk.ID, vk.Algorithm, vk.File)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+
+	return NewKeySet(signing, verification...)
+}
+
+// lookup - ключ для проверки подписи токена по его kid и алгоритму
+func (s *KeySet) lookup(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedSigningMethod
+	}
+	return key.Public, nil
+}
+
+// JWK - открытый ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS - набор открытых ключей, по которому другие сервисы проверяют наши токены
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS - открытые ключи набора. Симметричные ключи не публикуются
+func (s *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		if id != s.signing.ID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	// Сначала текущий ключ подписи, затем остальные
+	set := JWKS{Keys: []JWK{}}
+	if jwk, ok := toJWK(s.signing); ok {
+		set.Keys = append(set.Keys, jwk)
+	}
+	for _, id := range ids {
+		if jwk, ok := toJWK(s.keys[id]); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func toJWK(key *Key) (JWK, bool) {
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBase64(pub.N.Bytes())
+		jwk.E = encodeBase64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = encodeBase64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeBase64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeBase64(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+// PublicKey - открытый ключ из JWK, например для проверки токенов внешнего провайдера
+func (k JWK) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, errN := decodeBase64(k.N)
+		e, errE := decodeBase64(k.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedJWK
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedJWK
+		}
+		x, errX := decodeBase64(k.X)
+		y, errY := decodeBase64(k.Y)
+		if errX != nil || errY != nil {
+			return nil, ErrUnsupportedJWK
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, ErrUnsupportedJWK
+		}
+		return pub, nil
+	case "OKP":
+		x, err := decodeBase64(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedJWK
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, ErrUnsupportedJWK
+	}
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
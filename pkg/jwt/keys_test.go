@@ -0,0 +1,167 @@
+package jwt_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/crafty-ezhik/blog-api/pkg/jwt"
+	mock_jwt "github.com/crafty-ezhik/blog-api/pkg/jwt/mock"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func privatePEM(t *testing.T, key crypto.PrivateKey) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicPEM(t *testing.T, key crypto.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecP384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		alg         string
+		data        []byte
+		wantPrivate bool
+		wantErr     bool
+	}{
+		{name: "RS256 private", alg: jwt.AlgRS256, data: privatePEM(t, rsaKey), wantPrivate: true},
+		{name: "RS256 public", alg: jwt.AlgRS256, data: publicPEM(t, &rsaKey.PublicKey)},
+		{name: "ES256 private", alg: jwt.AlgES256, data: privatePEM(t, ecKey), wantPrivate: true},
+		{name: "EdDSA private", alg: jwt.AlgEdDSA, data: privatePEM(t, edKey), wantPrivate: true},
+		{name: "EdDSA public", alg: jwt.AlgEdDSA, data: publicPEM(t, edKey.Public())},
+		{name: "ES256 with P-384 curve", alg: jwt.AlgES256, data: privatePEM(t, ecP384), wantErr: true},
+		{name: "Wrong algorithm", alg: jwt.AlgES256, data: privatePEM(t, rsaKey), wantErr: true},
+		{name: "Unsupported algorithm", alg: "PS512", data: privatePEM(t, rsaKey), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := jwt.ParseKeyPEM("kid", tt.alg, tt.data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.alg, key.Method.Alg())
+			assert.NotNil(t, key.Public)
+			assert.Equal(t, tt.wantPrivate, key.Private != nil)
+		})
+	}
+}
+
+func TestJWT_KeyRotation(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	versioner := mock_jwt.NewMockTokenVersionStorage(ctrl)
+	sessions := mock_jwt.NewMockSessionStorage(ctrl)
+	service := jwt.NewJWTService(nil, versioner, sessions)
+	versioner.EXPECT().GetVersion(gomock.Any(), uint(1)).Return(uint(0), nil).AnyTimes()
+	sessions.EXPECT().GetSession(gomock.Any(), "sid").Return(&jwt.Session{ID: "sid", UserID: 1, LastSeen: time.Now()}, nil).AnyTimes()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	oldKey, err := jwt.ParseKeyPEM("2025-01", jwt.AlgRS256, privatePEM(t, rsaKey))
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newKey, err := jwt.ParseKeyPEM("2025-02", jwt.AlgES256, privatePEM(t, ecKey))
+	require.NoError(t, err)
+	oldPublic, err := jwt.ParseKeyPEM("2025-01", jwt.AlgRS256, publicPEM(t, &rsaKey.PublicKey))
+	require.NoError(t, err)
+
+	oldSet, err := jwt.NewKeySet(oldKey)
+	require.NoError(t, err)
+	oldAuth := jwt.NewJWTWithKeys(service, time.Minute, time.Hour, oldSet, jwt.ClaimsOptions{})
+	oldToken, err := oldAuth.GenerateToken(context.Background(), 1, "sid", jwt.Access)
+	require.NoError(t, err)
+
+	legacyToken, err := jwt.NewJWT(service, time.Minute, time.Hour, "secret").GenerateToken(context.Background(), 1, "sid", jwt.Access)
+	require.NoError(t, err)
+
+	// После ротации подписываем новым ключом, старый остается только для проверки
+	newSet, err := jwt.NewKeySet(newKey, oldPublic, jwt.NewHMACKey("", "secret"))
+	require.NoError(t, err)
+	auth := jwt.NewJWTWithKeys(service, time.Minute, time.Hour, newSet, jwt.ClaimsOptions{})
+
+	t.Run("New token", func(t *testing.T) {
+		token, err := auth.GenerateToken(context.Background(), 1, "sid", jwt.Access)
+		require.NoError(t, err)
+
+		data, err := auth.VerifyToken(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), data.UserId)
+
+		_, err = oldAuth.VerifyToken(context.Background(), token)
+		assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+	})
+
+	t.Run("Token signed by rotated key", func(t *testing.T) {
+		_, err := auth.VerifyToken(context.Background(), oldToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("HS256 token when configured", func(t *testing.T) {
+		_, err := auth.VerifyToken(context.Background(), legacyToken)
+		assert.NoError(t, err)
+
+		_, err = oldAuth.VerifyToken(context.Background(), legacyToken)
+		assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+	})
+
+	t.Run("Verification key can not sign", func(t *testing.T) {
+		_, err := jwt.NewKeySet(oldPublic)
+		assert.ErrorIs(t, err, jwt.ErrVerificationKeyOnly)
+	})
+
+	t.Run("JWKS", func(t *testing.T) {
+		jwks := auth.JWKS()
+		require.Len(t, jwks.Keys, 2)
+
+		assert.Equal(t, "2025-02", jwks.Keys[0].Kid)
+		assert.Equal(t, "EC", jwks.Keys[0].Kty)
+		assert.Equal(t, "P-256", jwks.Keys[0].Crv)
+		assert.Len(t, jwks.Keys[0].X, 43)
+
+		assert.Equal(t, "2025-01", jwks.Keys[1].Kid)
+		assert.Equal(t, "RSA", jwks.Keys[1].Kty)
+		assert.Equal(t, "AQAB", jwks.Keys[1].E)
+
+		// Опубликованные ключи восстанавливаются обратно
+		ecPublic, err := jwks.Keys[0].PublicKey()
+		require.NoError(t, err)
+		assert.True(t, ecKey.PublicKey.Equal(ecPublic))
+		rsaPublic, err := jwks.Keys[1].PublicKey()
+		require.NoError(t, err)
+		assert.True(t, rsaKey.PublicKey.Equal(rsaPublic))
+	})
+}
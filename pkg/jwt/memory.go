@@ -0,0 +1,165 @@
+package jwt
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/ttlmap"
+	"sync"
+	"time"
+)
+
+// DefaultCleanupInterval - как часто janitor удаляет истекшие записи из хранилищ в памяти
+const DefaultCleanupInterval = ttlmap.DefaultCleanupInterval
+
+// MemoryBlackList - BlackListStorage в памяти процесса
+type MemoryBlackList struct {
+	items *ttlmap.Map[struct{}]
+}
+
+// MemoryVersioner - TokenVersionStorage в памяти процесса. Версии не истекают, как и в Redis
+type MemoryVersioner struct {
+	mu       sync.Mutex
+	versions map[uint]uint
+}
+
+// MemoryOneTimeTokens - OneTimeTokenStorage в памяти процесса
+type MemoryOneTimeTokens struct {
+	items *ttlmap.Map[uint]
+}
+
+// MemorySessions - SessionStorage в памяти процесса
+type MemorySessions struct {
+	items *ttlmap.Map[Session]
+}
+
+// NewMemoryStorage - хранилища в памяти для запуска в одном экземпляре и тестов без Redis.
+// Истекшие записи удаляются janitor раз в cleanupInterval, остановить его можно через Close
+func NewMemoryStorage(cleanupInterval time.Duration) (*MemoryBlackList, *MemoryVersioner) {
+	return &MemoryBlackList{items: ttlmap.New[struct{}](cleanupInterval)},
+		&MemoryVersioner{versions: make(map[uint]uint)}
+}
+
+func NewMemoryOneTimeTokens(cleanupInterval time.Duration) *MemoryOneTimeTokens {
+	return &MemoryOneTimeTokens{items: ttlmap.New[uint](cleanupInterval)}
+}
+
+func NewMemorySessions(cleanupInterval time.Duration) *MemorySessions {
+	return &MemorySessions{items: ttlmap.New[Session](cleanupInterval)}
+}
+
+func (m *MemoryBlackList) IsBlackListed(_ context.Context, tokenID string) bool {
+	_, ok := m.items.Get(tokenID)
+	return ok
+}
+
+func (m *MemoryBlackList) AddToBlackList(_ context.Context, tokenID string, ttl time.Duration) error {
+	m.items.Set(tokenID, struct{}{}, ttl)
+	return nil
+}
+
+func (m *MemoryBlackList) Close() {
+	m.items.Close()
+}
+
+func (m *MemoryVersioner) IncrementVersion(_ context.Context, userID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.versions[userID]++
+	return nil
+}
+
+func (m *MemoryVersioner) GetVersion(_ context.Context, userID uint) (uint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.versions[userID], nil
+}
+
+func (m *MemoryOneTimeTokens) SaveOneTimeToken(_ context.Context, purpose TokenPurpose, token string, userID uint, ttl time.Duration) error {
+	m.items.Set(oneTimeKey(purpose, token), userID, ttl)
+	return nil
+}
+
+func (m *MemoryOneTimeTokens) ConsumeOneTimeToken(_ context.Context, purpose TokenPurpose, token string) (uint, error) {
+	userID, ok := m.items.Take(oneTimeKey(purpose, token))
+	if !ok {
+		return 0, ErrOneTimeTokenInvalid
+	}
+	return userID, nil
+}
+
+func (m *MemoryOneTimeTokens) Close() {
+	m.items.Close()
+}
+
+func (m *MemorySessions) CreateSession(_ context.Context, session *Session, ttl time.Duration) error {
+	m.items.Set(session.ID, *session, ttl)
+	return nil
+}
+
+func (m *MemorySessions) RotateSession(_ context.Context, session *Session, previousJTI string, ttl time.Duration) error {
+	found, err := m.items.Update(session.ID, ttl, func(stored *Session) error {
+		if stored.RefreshJTI != previousJTI {
+			return ErrSessionRotated
+		}
+		*stored = *session
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (m *MemorySessions) TouchSession(_ context.Context, sessionID string, lastSeen time.Time) error {
+	found, err := m.items.Update(sessionID, 0, func(session *Session) error {
+		if lastSeen.After(session.LastSeen) {
+			session.LastSeen = lastSeen
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (m *MemorySessions) GetSession(_ context.Context, sessionID string) (*Session, error) {
+	session, ok := m.items.Get(sessionID)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (m *MemorySessions) ListSessions(_ context.Context, userID uint) ([]Session, error) {
+	var sessions []Session
+	m.items.Each(func(_ string, session Session) {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	})
+	return sessions, nil
+}
+
+func (m *MemorySessions) DeleteSession(_ context.Context, userID uint, sessionID string) error {
+	m.items.DeleteIf(func(id string, session Session) bool {
+		return id == sessionID && session.UserID == userID
+	})
+	return nil
+}
+
+func (m *MemorySessions) DeleteUserSessions(_ context.Context, userID uint) error {
+	m.items.DeleteIf(func(_ string, session Session) bool {
+		return session.UserID == userID
+	})
+	return nil
+}
+
+func (m *MemorySessions) Close() {
+	m.items.Close()
+}
@@ -0,0 +1,106 @@
+package jwt_test
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	t.Run("Black list entries expire", func(t *testing.T) {
+		blackList, _ := jwt.NewMemoryStorage(10 * time.Millisecond)
+		defer blackList.Close()
+
+		require.NoError(t, blackList.AddToBlackList(context.Background(), "jti", 50*time.Millisecond))
+		assert.True(t, blackList.IsBlackListed(context.Background(), "jti"))
+		assert.False(t, blackList.IsBlackListed(context.Background(), "other"))
+
+		assert.Eventually(t, func() bool {
+			return !blackList.IsBlackListed(context.Background(), "jti")
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Concurrent version increments", func(t *testing.T) {
+		_, versioner := jwt.NewMemoryStorage(0)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = versioner.IncrementVersion(context.Background(), 1)
+			}()
+		}
+		wg.Wait()
+
+		version, err := versioner.GetVersion(context.Background(), 1)
+		require.NoError(t, err)
+		assert.Equal(t, uint(100), version)
+		version, err = versioner.GetVersion(context.Background(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, uint(0), version)
+	})
+
+	t.Run("One-time token is consumed once", func(t *testing.T) {
+		tokens := jwt.NewMemoryOneTimeTokens(0)
+		defer tokens.Close()
+
+		require.NoError(t, tokens.SaveOneTimeToken(context.Background(), jwt.PurposeMFA, "token", 1, time.Minute))
+		_, err := tokens.ConsumeOneTimeToken(context.Background(), jwt.PurposeVerifyEmail, "token")
+		assert.ErrorIs(t, err, jwt.ErrOneTimeTokenInvalid)
+
+		userID, err := tokens.ConsumeOneTimeToken(context.Background(), jwt.PurposeMFA, "token")
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), userID)
+		_, err = tokens.ConsumeOneTimeToken(context.Background(), jwt.PurposeMFA, "token")
+		assert.ErrorIs(t, err, jwt.ErrOneTimeTokenInvalid)
+	})
+
+	t.Run("Sessions", func(t *testing.T) {
+		sessions := jwt.NewMemorySessions(0)
+		defer sessions.Close()
+
+		for _, s := range []jwt.Session{{ID: "a", UserID: 1}, {ID: "b", UserID: 1}, {ID: "c", UserID: 2}} {
+			require.NoError(t, sessions.CreateSession(context.Background(), &s, time.Minute))
+		}
+		list, err := sessions.ListSessions(context.Background(), 1)
+		require.NoError(t, err)
+		assert.Len(t, list, 2)
+
+		require.NoError(t, sessions.RotateSession(context.Background(), &jwt.Session{ID: "a", UserID: 1, RefreshJTI: "jti"}, "", time.Minute))
+		session, err := sessions.GetSession(context.Background(), "a")
+		require.NoError(t, err)
+		assert.Equal(t, "jti", session.RefreshJTI)
+
+		// Замена уже замененным токеном не проходит
+		err = sessions.RotateSession(context.Background(), &jwt.Session{ID: "a", UserID: 1, RefreshJTI: "other"}, "", time.Minute)
+		assert.ErrorIs(t, err, jwt.ErrSessionRotated)
+		session, err = sessions.GetSession(context.Background(), "a")
+		require.NoError(t, err)
+		assert.Equal(t, "jti", session.RefreshJTI)
+
+		lastSeen := time.Now().Add(time.Minute)
+		require.NoError(t, sessions.TouchSession(context.Background(), "a", lastSeen))
+		session, err = sessions.GetSession(context.Background(), "a")
+		require.NoError(t, err)
+		assert.True(t, lastSeen.Equal(session.LastSeen))
+		assert.Equal(t, "jti", session.RefreshJTI)
+
+		// Чужую сессию удалить нельзя
+		require.NoError(t, sessions.DeleteSession(context.Background(), 2, "a"))
+		_, err = sessions.GetSession(context.Background(), "a")
+		assert.NoError(t, err)
+
+		require.NoError(t, sessions.DeleteUserSessions(context.Background(), 1))
+		_, err = sessions.GetSession(context.Background(), "a")
+		assert.ErrorIs(t, err, jwt.ErrSessionNotFound)
+		assert.ErrorIs(t, sessions.RotateSession(context.Background(), &jwt.Session{ID: "b", UserID: 1}, "", time.Minute), jwt.ErrSessionNotFound)
+		assert.ErrorIs(t, sessions.TouchSession(context.Background(), "b", time.Now()), jwt.ErrSessionNotFound)
+		_, err = sessions.GetSession(context.Background(), "c")
+		assert.NoError(t, err)
+	})
+}
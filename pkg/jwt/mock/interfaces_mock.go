@@ -10,9 +10,11 @@
 package mock_jwt
 
 import (
+	context "context"
 	reflect "reflect"
 	time "time"
 
+	jwt "github.com/crafty-ezhik/blog-api/pkg/jwt"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -41,31 +43,31 @@ func (m *MockBlackListStorage) EXPECT() *MockBlackListStorageMockRecorder {
 }
 
 // AddToBlackList mocks base method.
-func (m *MockBlackListStorage) AddToBlackList(token string, ttl time.Duration) error {
+func (m *MockBlackListStorage) AddToBlackList(ctx context.Context, tokenID string, ttl time.Duration) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddToBlackList", token, ttl)
+	ret := m.ctrl.Call(m, "AddToBlackList", ctx, tokenID, ttl)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddToBlackList indicates an expected call of AddToBlackList.
-func (mr *MockBlackListStorageMockRecorder) AddToBlackList(token, ttl any) *gomock.Call {
+func (mr *MockBlackListStorageMockRecorder) AddToBlackList(ctx, tokenID, ttl any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddToBlackList", reflect.TypeOf((*MockBlackListStorage)(nil).AddToBlackList), token, ttl)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddToBlackList", reflect.TypeOf((*MockBlackListStorage)(nil).AddToBlackList), ctx, tokenID, ttl)
 }
 
 // IsBlackListed mocks base method.
-func (m *MockBlackListStorage) IsBlackListed(token string) bool {
+func (m *MockBlackListStorage) IsBlackListed(ctx context.Context, tokenID string) bool {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IsBlackListed", token)
+	ret := m.ctrl.Call(m, "IsBlackListed", ctx, tokenID)
 	ret0, _ := ret[0].(bool)
 	return ret0
 }
 
 // IsBlackListed indicates an expected call of IsBlackListed.
-func (mr *MockBlackListStorageMockRecorder) IsBlackListed(token any) *gomock.Call {
+func (mr *MockBlackListStorageMockRecorder) IsBlackListed(ctx, tokenID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBlackListed", reflect.TypeOf((*MockBlackListStorage)(nil).IsBlackListed), token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBlackListed", reflect.TypeOf((*MockBlackListStorage)(nil).IsBlackListed), ctx, tokenID)
 }
 
 // MockTokenVersionStorage is a mock of TokenVersionStorage interface.
@@ -93,30 +95,207 @@ func (m *MockTokenVersionStorage) EXPECT() *MockTokenVersionStorageMockRecorder
 }
 
 // GetVersion mocks base method.
-func (m *MockTokenVersionStorage) GetVersion(userID uint) (uint, error) {
+func (m *MockTokenVersionStorage) GetVersion(ctx context.Context, userID uint) (uint, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetVersion", userID)
+	ret := m.ctrl.Call(m, "GetVersion", ctx, userID)
 	ret0, _ := ret[0].(uint)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetVersion indicates an expected call of GetVersion.
-func (mr *MockTokenVersionStorageMockRecorder) GetVersion(userID any) *gomock.Call {
+func (mr *MockTokenVersionStorageMockRecorder) GetVersion(ctx, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersion", reflect.TypeOf((*MockTokenVersionStorage)(nil).GetVersion), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersion", reflect.TypeOf((*MockTokenVersionStorage)(nil).GetVersion), ctx, userID)
 }
 
 // IncrementVersion mocks base method.
-func (m *MockTokenVersionStorage) IncrementVersion(userID uint) error {
+func (m *MockTokenVersionStorage) IncrementVersion(ctx context.Context, userID uint) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IncrementVersion", userID)
+	ret := m.ctrl.Call(m, "IncrementVersion", ctx, userID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // IncrementVersion indicates an expected call of IncrementVersion.
-func (mr *MockTokenVersionStorageMockRecorder) IncrementVersion(userID any) *gomock.Call {
+func (mr *MockTokenVersionStorageMockRecorder) IncrementVersion(ctx, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementVersion", reflect.TypeOf((*MockTokenVersionStorage)(nil).IncrementVersion), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementVersion", reflect.TypeOf((*MockTokenVersionStorage)(nil).IncrementVersion), ctx, userID)
+}
+
+// MockOneTimeTokenStorage is a mock of OneTimeTokenStorage interface.
+type MockOneTimeTokenStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockOneTimeTokenStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockOneTimeTokenStorageMockRecorder is the mock recorder for MockOneTimeTokenStorage.
+type MockOneTimeTokenStorageMockRecorder struct {
+	mock *MockOneTimeTokenStorage
+}
+
+// NewMockOneTimeTokenStorage creates a new mock instance.
+func NewMockOneTimeTokenStorage(ctrl *gomock.Controller) *MockOneTimeTokenStorage {
+	mock := &MockOneTimeTokenStorage{ctrl: ctrl}
+	mock.recorder = &MockOneTimeTokenStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOneTimeTokenStorage) EXPECT() *MockOneTimeTokenStorageMockRecorder {
+	return m.recorder
+}
+
+// ConsumeOneTimeToken mocks base method.
+func (m *MockOneTimeTokenStorage) ConsumeOneTimeToken(ctx context.Context, purpose jwt.TokenPurpose, token string) (uint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeOneTimeToken", ctx, purpose, token)
+	ret0, _ := ret[0].(uint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeOneTimeToken indicates an expected call of ConsumeOneTimeToken.
+func (mr *MockOneTimeTokenStorageMockRecorder) ConsumeOneTimeToken(ctx, purpose, token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeOneTimeToken", reflect.TypeOf((*MockOneTimeTokenStorage)(nil).ConsumeOneTimeToken), ctx, purpose, token)
+}
+
+// SaveOneTimeToken mocks base method.
+func (m *MockOneTimeTokenStorage) SaveOneTimeToken(ctx context.Context, purpose jwt.TokenPurpose, token string, userID uint, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOneTimeToken", ctx, purpose, token, userID, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveOneTimeToken indicates an expected call of SaveOneTimeToken.
+func (mr *MockOneTimeTokenStorageMockRecorder) SaveOneTimeToken(ctx, purpose, token, userID, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOneTimeToken", reflect.TypeOf((*MockOneTimeTokenStorage)(nil).SaveOneTimeToken), ctx, purpose, token, userID, ttl)
+}
+
+// MockSessionStorage is a mock of SessionStorage interface.
+type MockSessionStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockSessionStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockSessionStorageMockRecorder is the mock recorder for MockSessionStorage.
+type MockSessionStorageMockRecorder struct {
+	mock *MockSessionStorage
+}
+
+// NewMockSessionStorage creates a new mock instance.
+func NewMockSessionStorage(ctrl *gomock.Controller) *MockSessionStorage {
+	mock := &MockSessionStorage{ctrl: ctrl}
+	mock.recorder = &MockSessionStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSessionStorage) EXPECT() *MockSessionStorageMockRecorder {
+	return m.recorder
+}
+
+// CreateSession mocks base method.
+func (m *MockSessionStorage) CreateSession(ctx context.Context, session *jwt.Session, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx, session, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockSessionStorageMockRecorder) CreateSession(ctx, session, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockSessionStorage)(nil).CreateSession), ctx, session, ttl)
+}
+
+// DeleteSession mocks base method.
+func (m *MockSessionStorage) DeleteSession(ctx context.Context, userID uint, sessionID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSession", ctx, userID, sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSession indicates an expected call of DeleteSession.
+func (mr *MockSessionStorageMockRecorder) DeleteSession(ctx, userID, sessionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSession", reflect.TypeOf((*MockSessionStorage)(nil).DeleteSession), ctx, userID, sessionID)
+}
+
+// DeleteUserSessions mocks base method.
+func (m *MockSessionStorage) DeleteUserSessions(ctx context.Context, userID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserSessions", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserSessions indicates an expected call of DeleteUserSessions.
+func (mr *MockSessionStorageMockRecorder) DeleteUserSessions(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserSessions", reflect.TypeOf((*MockSessionStorage)(nil).DeleteUserSessions), ctx, userID)
+}
+
+// GetSession mocks base method.
+func (m *MockSessionStorage) GetSession(ctx context.Context, sessionID string) (*jwt.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSession", ctx, sessionID)
+	ret0, _ := ret[0].(*jwt.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSession indicates an expected call of GetSession.
+func (mr *MockSessionStorageMockRecorder) GetSession(ctx, sessionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockSessionStorage)(nil).GetSession), ctx, sessionID)
+}
+
+// ListSessions mocks base method.
+func (m *MockSessionStorage) ListSessions(ctx context.Context, userID uint) ([]jwt.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessions", ctx, userID)
+	ret0, _ := ret[0].([]jwt.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessions indicates an expected call of ListSessions.
+func (mr *MockSessionStorageMockRecorder) ListSessions(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessions", reflect.TypeOf((*MockSessionStorage)(nil).ListSessions), ctx, userID)
+}
+
+// RotateSession mocks base method.
+func (m *MockSessionStorage) RotateSession(ctx context.Context, session *jwt.Session, previousJTI string, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateSession", ctx, session, previousJTI, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RotateSession indicates an expected call of RotateSession.
+func (mr *MockSessionStorageMockRecorder) RotateSession(ctx, session, previousJTI, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateSession", reflect.TypeOf((*MockSessionStorage)(nil).RotateSession), ctx, session, previousJTI, ttl)
+}
+
+// TouchSession mocks base method.
+func (m *MockSessionStorage) TouchSession(ctx context.Context, sessionID string, lastSeen time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchSession", ctx, sessionID, lastSeen)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchSession indicates an expected call of TouchSession.
+func (mr *MockSessionStorageMockRecorder) TouchSession(ctx, sessionID, lastSeen any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchSession", reflect.TypeOf((*MockSessionStorage)(nil).TouchSession), ctx, sessionID, lastSeen)
 }
@@ -10,6 +10,7 @@
 package mock_jwt
 
 import (
+	context "context"
 	reflect "reflect"
 
 	jwt "github.com/crafty-ezhik/blog-api/pkg/jwt"
@@ -41,60 +42,118 @@ func (m *MockJWTInterface) EXPECT() *MockJWTInterfaceMockRecorder {
 }
 
 // GenerateToken mocks base method.
-func (m *MockJWTInterface) GenerateToken(userID uint, tokenType jwt.TokenType) (string, error) {
+func (m *MockJWTInterface) GenerateToken(ctx context.Context, userID uint, sessionID string, tokenType jwt.TokenType) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GenerateToken", userID, tokenType)
+	ret := m.ctrl.Call(m, "GenerateToken", ctx, userID, sessionID, tokenType)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GenerateToken indicates an expected call of GenerateToken.
-func (mr *MockJWTInterfaceMockRecorder) GenerateToken(userID, tokenType any) *gomock.Call {
+func (mr *MockJWTInterfaceMockRecorder) GenerateToken(ctx, userID, sessionID, tokenType any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateToken", reflect.TypeOf((*MockJWTInterface)(nil).GenerateToken), userID, tokenType)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateToken", reflect.TypeOf((*MockJWTInterface)(nil).GenerateToken), ctx, userID, sessionID, tokenType)
+}
+
+// ListSessions mocks base method.
+func (m *MockJWTInterface) ListSessions(ctx context.Context, userID uint) ([]jwt.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessions", ctx, userID)
+	ret0, _ := ret[0].([]jwt.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessions indicates an expected call of ListSessions.
+func (mr *MockJWTInterfaceMockRecorder) ListSessions(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessions", reflect.TypeOf((*MockJWTInterface)(nil).ListSessions), ctx, userID)
 }
 
 // Logout mocks base method.
-func (m *MockJWTInterface) Logout(refreshToken string) error {
+func (m *MockJWTInterface) Logout(ctx context.Context, refreshToken string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Logout", refreshToken)
+	ret := m.ctrl.Call(m, "Logout", ctx, refreshToken)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Logout indicates an expected call of Logout.
-func (mr *MockJWTInterfaceMockRecorder) Logout(refreshToken any) *gomock.Call {
+func (mr *MockJWTInterfaceMockRecorder) Logout(ctx, refreshToken any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockJWTInterface)(nil).Logout), refreshToken)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockJWTInterface)(nil).Logout), ctx, refreshToken)
 }
 
 // Refresh mocks base method.
-func (m *MockJWTInterface) Refresh(refreshToken string) (*jwt.Tokens, error) {
+func (m *MockJWTInterface) Refresh(ctx context.Context, refreshToken string, meta jwt.SessionMeta) (*jwt.Tokens, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Refresh", refreshToken)
+	ret := m.ctrl.Call(m, "Refresh", ctx, refreshToken, meta)
 	ret0, _ := ret[0].(*jwt.Tokens)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Refresh indicates an expected call of Refresh.
-func (mr *MockJWTInterfaceMockRecorder) Refresh(refreshToken any) *gomock.Call {
+func (mr *MockJWTInterfaceMockRecorder) Refresh(ctx, refreshToken, meta any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockJWTInterface)(nil).Refresh), ctx, refreshToken, meta)
+}
+
+// RevokeAllSessions mocks base method.
+func (m *MockJWTInterface) RevokeAllSessions(ctx context.Context, userID uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAllSessions", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAllSessions indicates an expected call of RevokeAllSessions.
+func (mr *MockJWTInterfaceMockRecorder) RevokeAllSessions(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAllSessions", reflect.TypeOf((*MockJWTInterface)(nil).RevokeAllSessions), ctx, userID)
+}
+
+// RevokeSession mocks base method.
+func (m *MockJWTInterface) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, userID, sessionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockJWTInterfaceMockRecorder) RevokeSession(ctx, userID, sessionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockJWTInterface)(nil).RevokeSession), ctx, userID, sessionID)
+}
+
+// StartSession mocks base method.
+func (m *MockJWTInterface) StartSession(ctx context.Context, userID uint, meta jwt.SessionMeta) (*jwt.Tokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartSession", ctx, userID, meta)
+	ret0, _ := ret[0].(*jwt.Tokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartSession indicates an expected call of StartSession.
+func (mr *MockJWTInterfaceMockRecorder) StartSession(ctx, userID, meta any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockJWTInterface)(nil).Refresh), refreshToken)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartSession", reflect.TypeOf((*MockJWTInterface)(nil).StartSession), ctx, userID, meta)
 }
 
 // VerifyToken mocks base method.
-func (m *MockJWTInterface) VerifyToken(tokenString string) (*jwt.JWTData, error) {
+func (m *MockJWTInterface) VerifyToken(ctx context.Context, tokenString string) (*jwt.JWTData, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "VerifyToken", tokenString)
+	ret := m.ctrl.Call(m, "VerifyToken", ctx, tokenString)
 	ret0, _ := ret[0].(*jwt.JWTData)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // VerifyToken indicates an expected call of VerifyToken.
-func (mr *MockJWTInterfaceMockRecorder) VerifyToken(tokenString any) *gomock.Call {
+func (mr *MockJWTInterfaceMockRecorder) VerifyToken(ctx, tokenString any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyToken", reflect.TypeOf((*MockJWTInterface)(nil).VerifyToken), tokenString)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyToken", reflect.TypeOf((*MockJWTInterface)(nil).VerifyToken), ctx, tokenString)
 }
@@ -0,0 +1,40 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+)
+
+type TokenPurpose string
+
+const (
+	PurposeVerifyEmail   TokenPurpose = "verify_email"
+	PurposeResetPassword TokenPurpose = "reset_password"
+	PurposeChangeEmail   TokenPurpose = "change_email"
+	PurposeMFA           TokenPurpose = "mfa"
+)
+
+var ErrOneTimeTokenInvalid = errors.New("token is invalid or expired")
+
+// NewOneTimeToken - генерирует случайный токен для ссылки в письме
+func NewOneTimeToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashOneTimeToken - хэш токена для хранения: по нему нельзя восстановить ссылку из письма
+func HashOneTimeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// oneTimeKey - ключ в хранилище. Храним хэш, чтобы токен нельзя было достать из Redis
+func oneTimeKey(purpose TokenPurpose, token string) string {
+	return string(purpose) + ":" + HashOneTimeToken(token)
+}
@@ -2,6 +2,8 @@ package jwt
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"github.com/redis/go-redis/v9"
 	"strconv"
 	"time"
@@ -15,25 +17,41 @@ type RedisVersioner struct {
 	client *redis.Client
 }
 
+type RedisOneTimeTokens struct {
+	client *redis.Client
+}
+
+type RedisSessions struct {
+	client *redis.Client
+}
+
 func NewRedisStorage(client *redis.Client) (*RedisBlackList, *RedisVersioner) {
 	return &RedisBlackList{client: client}, &RedisVersioner{client: client}
 }
 
-func (r *RedisBlackList) IsBlackListed(token string) bool {
-	val, _ := r.client.Get(context.Background(), "jwt_refresh:"+token).Result()
+func NewRedisOneTimeTokens(client *redis.Client) *RedisOneTimeTokens {
+	return &RedisOneTimeTokens{client: client}
+}
+
+func NewRedisSessions(client *redis.Client) *RedisSessions {
+	return &RedisSessions{client: client}
+}
+
+func (r *RedisBlackList) IsBlackListed(ctx context.Context, tokenID string) bool {
+	val, _ := r.client.Get(ctx, "jwt_refresh:"+tokenID).Result()
 	return val == "revoked"
 }
 
-func (r *RedisBlackList) AddToBlackList(token string, ttl time.Duration) error {
-	return r.client.Set(context.Background(), "jwt_refresh:"+token, "revoked", ttl).Err()
+func (r *RedisBlackList) AddToBlackList(ctx context.Context, tokenID string, ttl time.Duration) error {
+	return r.client.Set(ctx, "jwt_refresh:"+tokenID, "revoked", ttl).Err()
 }
 
-func (r *RedisVersioner) IncrementVersion(userID uint) error {
-	return r.client.Incr(context.Background(), "user_version:"+strconv.Itoa(int(userID))).Err()
+func (r *RedisVersioner) IncrementVersion(ctx context.Context, userID uint) error {
+	return r.client.Incr(ctx, "user_version:"+strconv.Itoa(int(userID))).Err()
 }
 
-func (r *RedisVersioner) GetVersion(userID uint) (uint, error) {
-	val, err := r.client.Get(context.Background(), "user_version:"+strconv.Itoa(int(userID))).Result()
+func (r *RedisVersioner) GetVersion(ctx context.Context, userID uint) (uint, error) {
+	val, err := r.client.Get(ctx, "user_version:"+strconv.Itoa(int(userID))).Result()
 	if val == "" || err != nil {
 		// TODO: Стоит добавить чтобы добавлялась запись с этим пользователем
 		return 0, nil
@@ -41,3 +59,197 @@ func (r *RedisVersioner) GetVersion(userID uint) (uint, error) {
 	version, err := strconv.ParseUint(val, 10, 32)
 	return uint(version), err
 }
+
+func (r *RedisOneTimeTokens) SaveOneTimeToken(ctx context.Context, purpose TokenPurpose, token string, userID uint, ttl time.Duration) error {
+	return r.client.Set(ctx, oneTimeKey(purpose, token), userID, ttl).Err()
+}
+
+func (r *RedisOneTimeTokens) ConsumeOneTimeToken(ctx context.Context, purpose TokenPurpose, token string) (uint, error) {
+	// GETDEL атомарен: два параллельных запроса не смогут использовать токен дважды
+	val, err := r.client.GetDel(ctx, oneTimeKey(purpose, token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrOneTimeTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	userID, err := strconv.ParseUint(val, 10, 32)
+	if err != nil {
+		return 0, ErrOneTimeTokenInvalid
+	}
+	return uint(userID), nil
+}
+
+func sessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+// sessionSeenKey - время последнего запроса сессии. Хранится отдельно от самой сессии,
+// чтобы частое обновление не перезаписывало jti refresh токена
+func sessionSeenKey(sessionID string) string {
+	return "session_seen:" + sessionID
+}
+
+func userSessionsKey(userID uint) string {
+	return "user_sessions:" + strconv.Itoa(int(userID))
+}
+
+// touchSessionScript - записывает время последнего запроса, только если сессия существует.
+// Ключ живет столько же, сколько сессия
+var touchSessionScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+	return 0
+end
+if ttl > 0 then
+	redis.call('SET', KEYS[2], ARGV[1], 'PX', ttl)
+else
+	redis.call('SET', KEYS[2], ARGV[1])
+end
+return 1
+`)
+
+// rotateSessionScript - compare-and-swap сессии по jti refresh токена. Возвращает 0, если сессии нет,
+// -1, если токен уже заменен, и 1 после замены
+var rotateSessionScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return 0
+end
+if cjson.decode(current).refresh_jti ~= ARGV[1] then
+	return -1
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+redis.call('PEXPIRE', KEYS[2], ARGV[3])
+redis.call('PEXPIRE', KEYS[3], ARGV[3])
+return 1
+`)
+
+func (r *RedisSessions) CreateSession(ctx context.Context, session *Session, ttl time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKey(session.ID), data, ttl)
+		pipe.SAdd(ctx, userSessionsKey(session.UserID), session.ID)
+		// Все сессии живут одинаково, поэтому индекс переживет самую новую из них
+		pipe.Expire(ctx, userSessionsKey(session.UserID), ttl)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisSessions) RotateSession(ctx context.Context, session *Session, previousJTI string, ttl time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	keys := []string{sessionKey(session.ID), sessionSeenKey(session.ID), userSessionsKey(session.UserID)}
+	result, err := rotateSessionScript.Run(ctx, r.client, keys, previousJTI, data, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	switch result {
+	case 0:
+		return ErrSessionNotFound
+	case -1:
+		return ErrSessionRotated
+	}
+	return nil
+}
+
+func (r *RedisSessions) TouchSession(ctx context.Context, sessionID string, lastSeen time.Time) error {
+	keys := []string{sessionKey(sessionID), sessionSeenKey(sessionID)}
+	found, err := touchSessionScript.Run(ctx, r.client, keys, lastSeen.Format(time.RFC3339Nano)).Int()
+	if err != nil {
+		return err
+	}
+	if found == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *RedisSessions) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	values, err := r.client.MGet(ctx, sessionKey(sessionID), sessionSeenKey(sessionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeSession(values[0], values[1])
+}
+
+func (r *RedisSessions) ListSessions(ctx context.Context, userID uint) ([]Session, error) {
+	ids, err := r.client.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, 0, 2*len(ids))
+	for _, id := range ids {
+		keys = append(keys, sessionKey(id), sessionSeenKey(id))
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(ids))
+	var stale []any
+	for i, id := range ids {
+		session, err := decodeSession(values[2*i], values[2*i+1])
+		if errors.Is(err, ErrSessionNotFound) {
+			// Сессия истекла, а ее ID остался в индексе
+			stale = append(stale, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	if len(stale) > 0 {
+		r.client.SRem(ctx, userSessionsKey(userID), stale...)
+	}
+	return sessions, nil
+}
+
+// decodeSession - сессия из значений MGET ключей sessionKey и sessionSeenKey
+func decodeSession(value, seen any) (*Session, error) {
+	str, ok := value.(string)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	var session Session
+	if err := json.Unmarshal([]byte(str), &session); err != nil {
+		return nil, err
+	}
+	if str, ok = seen.(string); ok {
+		lastSeen, err := time.Parse(time.RFC3339Nano, str)
+		if err == nil && lastSeen.After(session.LastSeen) {
+			session.LastSeen = lastSeen
+		}
+	}
+	return &session, nil
+}
+
+func (r *RedisSessions) DeleteSession(ctx context.Context, userID uint, sessionID string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(sessionID), sessionSeenKey(sessionID))
+		pipe.SRem(ctx, userSessionsKey(userID), sessionID)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisSessions) DeleteUserSessions(ctx context.Context, userID uint) error {
+	ids, err := r.client.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{userSessionsKey(userID)}
+	for _, id := range ids {
+		keys = append(keys, sessionKey(id), sessionSeenKey(id))
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
@@ -0,0 +1,89 @@
+package jwt_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/crafty-ezhik/blog-api/pkg/jwt"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newRedisSessions(t *testing.T) (*jwt.RedisSessions, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return jwt.NewRedisSessions(client), server
+}
+
+func TestRedisSessions(t *testing.T) {
+	sessions, server := newRedisSessions(t)
+
+	created := time.Now().Add(-time.Hour).UTC()
+	for _, s := range []jwt.Session{{ID: "a", UserID: 1, LastSeen: created}, {ID: "b", UserID: 1}, {ID: "c", UserID: 2}} {
+		require.NoError(t, sessions.CreateSession(context.Background(), &s, time.Hour))
+	}
+
+	t.Run("Touch keeps refresh jti", func(t *testing.T) {
+		require.NoError(t, sessions.RotateSession(context.Background(), &jwt.Session{ID: "a", UserID: 1, LastSeen: created, RefreshJTI: "jti"}, "", time.Hour))
+
+		lastSeen := time.Now().UTC()
+		require.NoError(t, sessions.TouchSession(context.Background(), "a", lastSeen))
+		assert.Equal(t, time.Hour, server.TTL("session_seen:a"))
+
+		session, err := sessions.GetSession(context.Background(), "a")
+		require.NoError(t, err)
+		assert.Equal(t, "jti", session.RefreshJTI)
+		assert.True(t, lastSeen.Equal(session.LastSeen))
+
+		list, err := sessions.ListSessions(context.Background(), 1)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		for _, s := range list {
+			if s.ID == "a" {
+				assert.True(t, lastSeen.Equal(s.LastSeen))
+			}
+		}
+	})
+
+	t.Run("Rotate compares refresh jti", func(t *testing.T) {
+		server.FastForward(time.Minute)
+
+		err := sessions.RotateSession(context.Background(), &jwt.Session{ID: "a", UserID: 1, RefreshJTI: "other"}, "stale", time.Hour)
+		assert.ErrorIs(t, err, jwt.ErrSessionRotated)
+		session, err := sessions.GetSession(context.Background(), "a")
+		require.NoError(t, err)
+		assert.Equal(t, "jti", session.RefreshJTI)
+
+		require.NoError(t, sessions.RotateSession(context.Background(), &jwt.Session{ID: "a", UserID: 1, RefreshJTI: "next"}, "jti", time.Hour))
+		session, err = sessions.GetSession(context.Background(), "a")
+		require.NoError(t, err)
+		assert.Equal(t, "next", session.RefreshJTI)
+		// Сессия, время последнего запроса и индекс продлены вместе
+		assert.Equal(t, time.Hour, server.TTL("session:a"))
+		assert.Equal(t, time.Hour, server.TTL("session_seen:a"))
+		assert.Equal(t, time.Hour, server.TTL("user_sessions:1"))
+	})
+
+	t.Run("Touch does not restore deleted session", func(t *testing.T) {
+		require.NoError(t, sessions.DeleteSession(context.Background(), 1, "a"))
+		assert.False(t, server.Exists("session_seen:a"))
+
+		assert.ErrorIs(t, sessions.TouchSession(context.Background(), "a", time.Now()), jwt.ErrSessionNotFound)
+		assert.ErrorIs(t, sessions.RotateSession(context.Background(), &jwt.Session{ID: "a", UserID: 1}, "next", time.Hour), jwt.ErrSessionNotFound)
+		assert.False(t, server.Exists("session:a"))
+		assert.False(t, server.Exists("session_seen:a"))
+	})
+
+	t.Run("Expired sessions leave the index", func(t *testing.T) {
+		server.FastForward(2 * time.Hour)
+
+		list, err := sessions.ListSessions(context.Background(), 1)
+		require.NoError(t, err)
+		assert.Empty(t, list)
+		_, err = sessions.GetSession(context.Background(), "b")
+		assert.ErrorIs(t, err, jwt.ErrSessionNotFound)
+	})
+}
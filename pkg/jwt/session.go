@@ -0,0 +1,46 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session revoked")
+	ErrSessionRotated  = errors.New("session refresh token already rotated")
+)
+
+// sessionTouchInterval - как часто обновлять LastSeen при проверке access токена,
+// чтобы не писать в хранилище на каждый запрос
+const sessionTouchInterval = time.Minute
+
+// Session - вход пользователя с конкретного устройства. ID сессии записывается в claim sid.
+// Все refresh токены одной сессии образуют семейство
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    uint      `json:"user_id"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	RefreshJTI string `json:"refresh_jti"` // jti последнего выданного refresh токена сессии
+}
+
+// SessionMeta - данные об устройстве, с которого выполнен запрос
+type SessionMeta struct {
+	UserAgent string
+	IP        string
+}
+
+// randomID - случайный идентификатор для sid и jti
+func randomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,153 @@
+// Package lockout - защита входа от перебора паролей: счетчики неудачных попыток по аккаунту и IP
+// и временная блокировка, которая удваивается с каждой следующей неудачей
+package lockout
+
+import (
+	"context"
+	"errors"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"strings"
+	"time"
+)
+
+var ErrLocked = errors.New("too many failed login attempts")
+
+const (
+	DefaultMaxAttempts   = 5
+	DefaultMaxIPAttempts = 20
+	DefaultWindow        = 15 * time.Minute
+	DefaultBaseDelay     = time.Minute
+	DefaultMaxDelay      = time.Hour
+)
+
+// LockedError - вход заблокирован, повторить можно через RetryAfter. errors.Is(err, ErrLocked) для нее истинно
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return ErrLocked.Error()
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// Storage - счетчики неудачных попыток и блокировки
+type Storage interface {
+	// AddFailure - увеличивает счетчик ключа и возвращает новое значение. Счетчик удаляется через ttl после последней неудачи
+	AddFailure(ctx context.Context, key string, ttl time.Duration) (int, error)
+	Lock(ctx context.Context, key string, ttl time.Duration) error
+	// LockedFor - оставшееся время блокировки ключа, 0 если ключ не заблокирован
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// Reset - удаляет счетчик и блокировку ключа
+	Reset(ctx context.Context, key string) error
+}
+
+// Guard - проверяет и учитывает попытки входа по email и IP
+type Guard struct {
+	storage       Storage
+	maxAttempts   int
+	maxIPAttempts int
+	window        time.Duration
+	baseDelay     time.Duration
+	maxDelay      time.Duration
+}
+
+func NewGuard(storage Storage, cfg config.LockoutConfig) *Guard {
+	g := &Guard{
+		storage:       storage,
+		maxAttempts:   cfg.MaxAttempts,
+		maxIPAttempts: cfg.MaxIPAttempts,
+		window:        cfg.Window,
+		baseDelay:     cfg.BaseDelay,
+		maxDelay:      cfg.MaxDelay,
+	}
+	if g.maxAttempts <= 0 {
+		g.maxAttempts = DefaultMaxAttempts
+	}
+	if g.maxIPAttempts <= 0 {
+		g.maxIPAttempts = DefaultMaxIPAttempts
+	}
+	if g.window <= 0 {
+		g.window = DefaultWindow
+	}
+	if g.baseDelay <= 0 {
+		g.baseDelay = DefaultBaseDelay
+	}
+	if g.maxDelay < g.baseDelay {
+		g.maxDelay = max(DefaultMaxDelay, g.baseDelay)
+	}
+	return g
+}
+
+// Check - возвращает *LockedError, если заблокирован аккаунт или IP
+func (g *Guard) Check(ctx context.Context, email, ip string) error {
+	var retryAfter time.Duration
+	for _, key := range keys(email, ip) {
+		ttl, err := g.storage.LockedFor(ctx, key)
+		if err != nil {
+			return err
+		}
+		retryAfter = max(retryAfter, ttl)
+	}
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Fail - учитывает неудачную попытку. Начиная с лимита каждая неудача блокирует ключ,
+// и каждая следующая блокировка вдвое дольше предыдущей
+func (g *Guard) Fail(ctx context.Context, email, ip string) error {
+	for _, key := range keys(email, ip) {
+		limit := g.maxAttempts
+		if strings.HasPrefix(key, ipPrefix) {
+			limit = g.maxIPAttempts
+		}
+
+		// Счетчик должен пережить самую долгую блокировку, иначе после нее задержка начнется сначала
+		failures, err := g.storage.AddFailure(ctx, key, g.window+g.maxDelay)
+		if err != nil {
+			return err
+		}
+		if failures < limit {
+			continue
+		}
+		if err = g.storage.Lock(ctx, key, g.delay(failures-limit)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset - сбрасывает счетчик и блокировку аккаунта: после успешного входа или разблокировки администратором.
+// Счетчик IP не сбрасывается, иначе, входя в свой аккаунт, можно было бы бесконечно перебирать чужие
+func (g *Guard) Reset(ctx context.Context, email string) error {
+	return g.storage.Reset(ctx, accountKey(email))
+}
+
+func (g *Guard) delay(step int) time.Duration {
+	delay := g.baseDelay
+	for i := 0; i < step && delay < g.maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, g.maxDelay)
+}
+
+const (
+	accountPrefix = "account:"
+	ipPrefix      = "ip:"
+)
+
+func accountKey(email string) string {
+	return accountPrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+func keys(email, ip string) []string {
+	result := []string{accountKey(email)}
+	if ip != "" {
+		result = append(result, ipPrefix+ip)
+	}
+	return result
+}
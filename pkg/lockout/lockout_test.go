@@ -0,0 +1,96 @@
+package lockout_test
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"github.com/crafty-ezhik/blog-api/pkg/lockout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestGuard(t *testing.T) {
+	cfg := config.LockoutConfig{MaxAttempts: 3, MaxIPAttempts: 5, BaseDelay: time.Minute, MaxDelay: 3 * time.Minute}
+
+	t.Run("Account is locked after max attempts", func(t *testing.T) {
+		guard := lockout.NewGuard(lockout.NewMemoryStorage(0), cfg)
+
+		for i := 0; i < 2; i++ {
+			require.NoError(t, guard.Fail(context.Background(), "ivan@example.com", ""))
+			require.NoError(t, guard.Check(context.Background(), "ivan@example.com", ""))
+		}
+		require.NoError(t, guard.Fail(context.Background(), "Ivan@Example.com", ""))
+
+		err := guard.Check(context.Background(), "ivan@example.com", "")
+		assert.ErrorIs(t, err, lockout.ErrLocked)
+		var locked *lockout.LockedError
+		require.ErrorAs(t, err, &locked)
+		assert.InDelta(t, time.Minute, locked.RetryAfter, float64(time.Second))
+
+		// Другие аккаунты не затронуты
+		assert.NoError(t, guard.Check(context.Background(), "other@example.com", ""))
+	})
+
+	t.Run("Delay doubles up to max delay", func(t *testing.T) {
+		guard := lockout.NewGuard(lockout.NewMemoryStorage(0), cfg)
+
+		expected := []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+		for i := 0; i < 2; i++ {
+			require.NoError(t, guard.Fail(context.Background(), "ivan@example.com", ""))
+		}
+		for _, delay := range expected {
+			require.NoError(t, guard.Fail(context.Background(), "ivan@example.com", ""))
+
+			var locked *lockout.LockedError
+			require.ErrorAs(t, guard.Check(context.Background(), "ivan@example.com", ""), &locked)
+			assert.InDelta(t, delay, locked.RetryAfter, float64(time.Second))
+		}
+	})
+
+	t.Run("IP is locked across accounts", func(t *testing.T) {
+		guard := lockout.NewGuard(lockout.NewMemoryStorage(0), cfg)
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, guard.Fail(context.Background(), string(rune('a'+i))+"@example.com", "10.0.0.1"))
+		}
+		assert.ErrorIs(t, guard.Check(context.Background(), "new@example.com", "10.0.0.1"), lockout.ErrLocked)
+		assert.NoError(t, guard.Check(context.Background(), "new@example.com", "10.0.0.2"))
+	})
+
+	t.Run("Reset unlocks account but not IP", func(t *testing.T) {
+		guard := lockout.NewGuard(lockout.NewMemoryStorage(0), config.LockoutConfig{MaxAttempts: 3, MaxIPAttempts: 3})
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, guard.Fail(context.Background(), "ivan@example.com", "10.0.0.1"))
+		}
+		require.NoError(t, guard.Reset(context.Background(), "ivan@example.com"))
+
+		assert.NoError(t, guard.Check(context.Background(), "ivan@example.com", ""))
+		assert.ErrorIs(t, guard.Check(context.Background(), "ivan@example.com", "10.0.0.1"), lockout.ErrLocked)
+	})
+}
+
+func TestMemoryStorage_Expiry(t *testing.T) {
+	storage := lockout.NewMemoryStorage(10 * time.Millisecond)
+	defer storage.Close()
+
+	count, err := storage.AddFailure(context.Background(), "ivan@example.com", 30*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	require.NoError(t, storage.Lock(context.Background(), "ivan@example.com", 30*time.Millisecond))
+
+	locked, err := storage.LockedFor(context.Background(), "ivan@example.com")
+	require.NoError(t, err)
+	assert.Positive(t, locked)
+
+	time.Sleep(50 * time.Millisecond)
+	locked, err = storage.LockedFor(context.Background(), "ivan@example.com")
+	require.NoError(t, err)
+	assert.Zero(t, locked)
+
+	// Счетчик истек вместе с окном и начинается заново
+	count, err = storage.AddFailure(context.Background(), "ivan@example.com", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,52 @@
+package lockout
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/ttlmap"
+	"time"
+)
+
+// MemoryStorage - Storage в памяти процесса. Подходит для тестов и запуска в одном экземпляре.
+// Истекшие счетчики и блокировки удаляются janitor раз в cleanupInterval, остановить его можно через Close
+type MemoryStorage struct {
+	failures *ttlmap.Map[int]
+	locks    *ttlmap.Map[time.Time]
+}
+
+func NewMemoryStorage(cleanupInterval time.Duration) *MemoryStorage {
+	return &MemoryStorage{
+		failures: ttlmap.New[int](cleanupInterval),
+		locks:    ttlmap.New[time.Time](cleanupInterval),
+	}
+}
+
+func (m *MemoryStorage) AddFailure(_ context.Context, key string, ttl time.Duration) (int, error) {
+	count := m.failures.Upsert(key, ttl, func(count *int) {
+		*count++
+	})
+	return count, nil
+}
+
+func (m *MemoryStorage) Lock(_ context.Context, key string, ttl time.Duration) error {
+	m.locks.Set(key, time.Now().Add(ttl), ttl)
+	return nil
+}
+
+func (m *MemoryStorage) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	until, ok := m.locks.Get(key)
+	if !ok {
+		return 0, nil
+	}
+	return max(time.Until(until), 0), nil
+}
+
+func (m *MemoryStorage) Reset(_ context.Context, key string) error {
+	m.failures.Delete(key)
+	m.locks.Delete(key)
+	return nil
+}
+
+func (m *MemoryStorage) Close() {
+	m.failures.Close()
+	m.locks.Close()
+}
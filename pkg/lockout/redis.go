@@ -0,0 +1,43 @@
+package lockout
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// RedisStorage - Storage в Redis, общий для всех экземпляров приложения
+type RedisStorage struct {
+	client *redis.Client
+}
+
+func NewRedisStorage(client *redis.Client) *RedisStorage {
+	return &RedisStorage{client: client}
+}
+
+func (r *RedisStorage) AddFailure(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, "login_failures:"+key)
+	pipe.PExpire(ctx, "login_failures:"+key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(incr.Val()), nil
+}
+
+func (r *RedisStorage) Lock(ctx context.Context, key string, ttl time.Duration) error {
+	return r.client.Set(ctx, "login_lock:"+key, 1, ttl).Err()
+}
+
+func (r *RedisStorage) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, "login_lock:"+key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// Для отсутствующего ключа PTTL возвращает отрицательное значение
+	return max(ttl, 0), nil
+}
+
+func (r *RedisStorage) Reset(ctx context.Context, key string) error {
+	return r.client.Del(ctx, "login_failures:"+key, "login_lock:"+key).Err()
+}
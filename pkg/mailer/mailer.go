@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+)
+
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverMemory = "memory"
+)
+
+// Message - письмо, отправляемое пользователю
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender - способ доставки писем
+type Sender interface {
+	Send(msg Message) error
+}
+
+// NewSender - создает Sender по настройкам из секции mail
+func NewSender(cfg config.MailConfig) (Sender, error) {
+	switch cfg.Driver {
+	case DriverSMTP:
+		return NewSMTPSender(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From), nil
+	case DriverFile:
+		return NewFileSender(cfg.FilePath), nil
+	case DriverMemory, "":
+		return NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.Driver)
+	}
+}
@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemorySender(t *testing.T) {
+	s := NewMemorySender()
+	require.NoError(t, s.Send(Message{To: "a@example.com", Subject: "first"}))
+	require.NoError(t, s.Send(Message{To: "b@example.com", Subject: "other"}))
+	require.NoError(t, s.Send(Message{To: "a@example.com", Subject: "second"}))
+
+	assert.Len(t, s.Messages(), 3)
+
+	msg, ok := s.Last("a@example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "second", msg.Subject)
+
+	_, ok = s.Last("c@example.com")
+	assert.False(t, ok)
+}
+
+func TestFileSender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	s := NewFileSender(path)
+	require.NoError(t, s.Send(Message{To: "a@example.com", Subject: "Hello", Body: "token: abc"}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "To: a@example.com")
+	assert.Contains(t, string(data), "Subject: Hello")
+	assert.Contains(t, string(data), "token: abc")
+}
+
+func TestNewSender(t *testing.T) {
+	s, err := NewSender(config.MailConfig{Driver: DriverSMTP, Host: "localhost", Port: 25})
+	require.NoError(t, err)
+	assert.IsType(t, &SMTPSender{}, s)
+
+	s, err = NewSender(config.MailConfig{})
+	require.NoError(t, err)
+	assert.IsType(t, &MemorySender{}, s)
+
+	_, err = NewSender(config.MailConfig{Driver: "pigeon"})
+	assert.Error(t, err)
+}
+
+func TestBuildMessage(t *testing.T) {
+	data := string(buildMessage("blog@example.com", Message{To: "a@example.com", Subject: "Сброс пароля", Body: "Ссылка"}))
+
+	assert.Contains(t, data, "Subject: =?utf-8?q?")
+	assert.NotContains(t, data, "Subject: Сброс пароля")
+	assert.Contains(t, data, "MIME-Version: 1.0\r\n")
+	assert.Contains(t, data, "Content-Type: text/plain; charset=UTF-8\r\n")
+	assert.True(t, strings.HasSuffix(data, "\r\n\r\nСсылка"))
+
+	// ASCII тема не кодируется
+	data = string(buildMessage("blog@example.com", Message{To: "a@example.com", Subject: "Hello"}))
+	assert.Contains(t, data, "Subject: Hello\r\n")
+}
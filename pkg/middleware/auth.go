@@ -1,18 +1,36 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	jwt2 "github.com/crafty-ezhik/blog-api/pkg/jwt"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
 	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
 	"strings"
 )
 
 type KeyType string
 
-var UserIDKey KeyType = "user_id"
+var (
+	UserIDKey    KeyType = "user_id"
+	SessionIDKey KeyType = "session_id"
+	ScopesKey    KeyType = "scopes" // права personal access токена; для JWT не устанавливается
+)
+
+// PersonalTokenPrefix - начало секрета personal access токена, по нему токен отличается от JWT
+const PersonalTokenPrefix = "bpat_"
+
+var ErrPersonalTokenInvalid = errors.New("personal access token is invalid or expired")
 
-func AuthMiddleware(jwt jwt2.JWTInterface) fiber.Handler {
+// PersonalTokenVerifier - проверка personal access токенов. Для неизвестного, отозванного
+// или просроченного токена возвращает ErrPersonalTokenInvalid
+type PersonalTokenVerifier interface {
+	VerifyPersonalToken(ctx context.Context, token string) (userID uint, scopes []string, err error)
+}
+
+// AuthMiddleware - принимает Bearer JWT access токены, а если tokens не nil, то и personal access токены
+func AuthMiddleware(jwt jwt2.JWTInterface, tokens PersonalTokenVerifier) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		logger.Log.Info("Check access token")
 		logger.Log.Debug("Check Authorization header")
@@ -32,7 +50,11 @@ func AuthMiddleware(jwt jwt2.JWTInterface) fiber.Handler {
 			})
 		}
 		tokenString := strings.TrimPrefix(rawToken, "Bearer ")
-		tokenData, err := jwt.VerifyToken(tokenString)
+		if tokens != nil && strings.HasPrefix(tokenString, PersonalTokenPrefix) {
+			return personalTokenAuth(c, tokens, tokenString)
+		}
+
+		tokenData, err := jwt.VerifyToken(c.UserContext(), tokenString)
 		if errors.Is(err, jwt2.ErrInternalServer) {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"err":     "Unauthorized",
@@ -47,7 +69,63 @@ func AuthMiddleware(jwt jwt2.JWTInterface) fiber.Handler {
 			})
 		}
 		c.Locals(UserIDKey, tokenData.UserId)
+		c.Locals(SessionIDKey, tokenData.SessionID)
 		logger.Log.Info("Token verification completed successfully")
 		return c.Next()
 	}
 }
+
+func personalTokenAuth(c *fiber.Ctx, tokens PersonalTokenVerifier, token string) error {
+	logger.Log.Debug("Check personal access token")
+	userID, scopes, err := tokens.VerifyPersonalToken(c.UserContext(), token)
+	if errors.Is(err, ErrPersonalTokenInvalid) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"err":     "Unauthorized",
+			"details": err.Error(),
+		})
+	}
+	if err != nil {
+		logger.Log.Error("Error verifying personal access token", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"err":     "Unauthorized",
+			"details": jwt2.ErrInternalServer.Error(),
+		})
+	}
+	c.Locals(UserIDKey, userID)
+	c.Locals(ScopesKey, scopes)
+	logger.Log.Info("Personal access token verification completed successfully")
+	return c.Next()
+}
+
+// RequireScope - для personal access токена проверяет наличие права scope. Запросы с JWT пропускаются
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, ok := c.Locals(ScopesKey).([]string)
+		if !ok {
+			return c.Next()
+		}
+		for _, s := range scopes {
+			if s == scope {
+				return c.Next()
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"err":     "Forbidden",
+			"details": "Token scope " + scope + " is required",
+		})
+	}
+}
+
+// RequireSession - маршрут доступен только с JWT сессии: управление учетной записью
+// и токенами через personal access токен запрещено
+func RequireSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals(ScopesKey).([]string); ok {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"err":     "Forbidden",
+				"details": "Personal access tokens are not allowed here",
+			})
+		}
+		return c.Next()
+	}
+}
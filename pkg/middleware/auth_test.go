@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"github.com/crafty-ezhik/blog-api/pkg/jwt"
 	mock_jwt "github.com/crafty-ezhik/blog-api/pkg/jwt/mock"
@@ -33,6 +34,17 @@ func setup(t *testing.T) *Mocks {
 	return mocks
 }
 
+// personalTokenStub - personal access токены и их права, все принадлежат пользователю 7
+type personalTokenStub map[string][]string
+
+func (s personalTokenStub) VerifyPersonalToken(_ context.Context, token string) (uint, []string, error) {
+	scopes, ok := s[token]
+	if !ok {
+		return 0, nil, ErrPersonalTokenInvalid
+	}
+	return 7, scopes, nil
+}
+
 func TestAuthMiddleware(t *testing.T) {
 	logger.Log, _ = zap.NewDevelopment()
 	defer logger.Log.Sync()
@@ -57,7 +69,7 @@ func TestAuthMiddleware(t *testing.T) {
 			headerValue: "Bearer token",
 			token:       token,
 			mockSetup: func(mocks *Mocks) {
-				mocks.JWT.EXPECT().VerifyToken(token).Return(&jwt.JWTData{UserId: 1}, nil)
+				mocks.JWT.EXPECT().VerifyToken(gomock.Any(), token).Return(&jwt.JWTData{UserId: 1}, nil)
 			},
 			secondHandler: func(c *fiber.Ctx) error {
 				userID := c.Locals(UserIDKey)
@@ -66,6 +78,23 @@ func TestAuthMiddleware(t *testing.T) {
 			expectedCode: 200,
 			expectedBody: "1",
 		},
+		{
+			Name:        "Personal access token",
+			headerName:  "Authorization",
+			headerValue: "Bearer " + PersonalTokenPrefix + "valid",
+			secondHandler: func(c *fiber.Ctx) error {
+				return c.Status(200).SendString(fmt.Sprintf("%d %v", c.Locals(UserIDKey), c.Locals(ScopesKey)))
+			},
+			expectedCode: 200,
+			expectedBody: "7 [posts:read]",
+		},
+		{
+			Name:         "Invalid personal access token",
+			headerName:   "Authorization",
+			headerValue:  "Bearer " + PersonalTokenPrefix + "revoked",
+			expectedCode: 401,
+			expectedBody: ErrPersonalTokenInvalid.Error(),
+		},
 		{
 			Name:         "Empty Header",
 			headerName:   "",
@@ -84,7 +113,7 @@ func TestAuthMiddleware(t *testing.T) {
 			headerName:  "Authorization",
 			headerValue: "Bearer token",
 			mockSetup: func(mocks *Mocks) {
-				mocks.JWT.EXPECT().VerifyToken(token).Return(nil, jwt.ErrInvalidToken)
+				mocks.JWT.EXPECT().VerifyToken(gomock.Any(), token).Return(nil, jwt.ErrInvalidToken)
 			},
 			expectedCode: 401,
 			expectedBody: `{"details":"invalid token","err":"Unauthorized"}`,
@@ -94,7 +123,7 @@ func TestAuthMiddleware(t *testing.T) {
 			headerName:  "Authorization",
 			headerValue: "Bearer token",
 			mockSetup: func(mocks *Mocks) {
-				mocks.JWT.EXPECT().VerifyToken(token).Return(nil, jwt.ErrInvalidToken)
+				mocks.JWT.EXPECT().VerifyToken(gomock.Any(), token).Return(nil, jwt.ErrInvalidToken)
 			},
 			expectedCode: 401,
 			expectedBody: `{"details":"invalid token","err":"Unauthorized"}`,
@@ -104,7 +133,7 @@ func TestAuthMiddleware(t *testing.T) {
 			headerName:  "Authorization",
 			headerValue: "Bearer token",
 			mockSetup: func(mocks *Mocks) {
-				mocks.JWT.EXPECT().VerifyToken(token).Return(nil, jwt.ErrInternalServer)
+				mocks.JWT.EXPECT().VerifyToken(gomock.Any(), token).Return(nil, jwt.ErrInternalServer)
 			},
 			expectedCode: 500,
 			expectedBody: `{"details":"internal server error","err":"Unauthorized"}`,
@@ -114,7 +143,7 @@ func TestAuthMiddleware(t *testing.T) {
 			headerName:  "Authorization",
 			headerValue: "Bearer token",
 			mockSetup: func(mocks *Mocks) {
-				mocks.JWT.EXPECT().VerifyToken(token).Return(nil, jwt.ErrRefreshExpired)
+				mocks.JWT.EXPECT().VerifyToken(gomock.Any(), token).Return(nil, jwt.ErrRefreshExpired)
 			},
 			expectedCode: 401,
 			expectedBody: `{"details":"refresh token expired due to logout / password change","err":"Unauthorized"}`,
@@ -124,7 +153,7 @@ func TestAuthMiddleware(t *testing.T) {
 			headerName:  "Authorization",
 			headerValue: "Bearer token",
 			mockSetup: func(mocks *Mocks) {
-				mocks.JWT.EXPECT().VerifyToken(token).Return(nil, jwt.ErrSessionExpired)
+				mocks.JWT.EXPECT().VerifyToken(gomock.Any(), token).Return(nil, jwt.ErrSessionExpired)
 			},
 			expectedCode: 401,
 			expectedBody: `{"details":"session expired","err":"Unauthorized"}`,
@@ -137,7 +166,8 @@ func TestAuthMiddleware(t *testing.T) {
 			// Тут мы сделали 2 обработчика. Первый middleware, а второй просто возвращает userID для проверки
 			// корректности работы, что действительно в контекст установлено значение и его можно использовать
 			// в других хендлерах
-			app.Post(path, AuthMiddleware(mocks.JWT), tt.secondHandler)
+			tokens := personalTokenStub{PersonalTokenPrefix + "valid": {"posts:read"}}
+			app.Post(path, AuthMiddleware(mocks.JWT, tokens), tt.secondHandler)
 
 			req := httptest.NewRequest(http.MethodPost, path, nil)
 			req.Header.Set(tt.headerName, tt.headerValue)
@@ -156,3 +186,39 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestRequireScope(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	tests := []struct {
+		name         string
+		scopes       []string
+		handler      fiber.Handler
+		expectedCode int
+	}{
+		{name: "JWT request", scopes: nil, handler: RequireScope("posts:write"), expectedCode: 200},
+		{name: "Scope granted", scopes: []string{"posts:read", "posts:write"}, handler: RequireScope("posts:write"), expectedCode: 200},
+		{name: "Scope missing", scopes: []string{"posts:read"}, handler: RequireScope("posts:write"), expectedCode: 403},
+		{name: "Session with JWT", scopes: nil, handler: RequireSession(), expectedCode: 200},
+		{name: "Session with personal token", scopes: []string{"posts:read"}, handler: RequireSession(), expectedCode: 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				if tt.scopes != nil {
+					c.Locals(ScopesKey, tt.scopes)
+				}
+				return c.Next()
+			}, tt.handler, func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+		})
+	}
+}
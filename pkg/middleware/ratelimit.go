@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/ratelimit"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"math"
+	"strconv"
+	"time"
+)
+
+// RateLimit - ограничивает частоту запросов по политике policy. Ключ - ID пользователя из UserIDKey,
+// поэтому для авторизованных маршрутов middleware ставится после AuthMiddleware; без пользователя - IP клиента.
+// В ответ добавляются заголовки RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset и RateLimit-Policy,
+// превышение лимита отклоняется с 429 и Retry-After
+func RateLimit(limiter ratelimit.Limiter, policy ratelimit.Policy) fiber.Handler {
+	if !policy.Enabled() {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	policyHeader := fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Window.Seconds()))
+
+	return func(c *fiber.Ctx) error {
+		key := policy.Name + ":ip:" + c.IP()
+		if userID, ok := c.Locals(UserIDKey).(uint); ok {
+			key = policy.Name + ":user:" + strconv.FormatUint(uint64(userID), 10)
+		}
+
+		result, err := limiter.Allow(c.UserContext(), key, policy)
+		if err != nil {
+			// Сбой хранилища лимитов не должен останавливать работу API
+			logger.Log.Error("Rate limit check failed", zap.Error(err), zap.String("policy", policy.Name))
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+		c.Set("RateLimit-Policy", policyHeader)
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds(result.RetryAfter)))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"err":     "Too Many Requests",
+				"details": "Rate limit exceeded, retry later",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// seconds - длительность в целых секундах с округлением вверх, как требуют заголовки
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/ratelimit"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	limiter := ratelimit.NewMemoryLimiter(0)
+	defer limiter.Close()
+	policy := ratelimit.Policy{Name: "comments", Limit: 2, Window: time.Minute, Algorithm: ratelimit.TokenBucket}
+
+	app := fiber.New()
+	withUser := func(c *fiber.Ctx) error {
+		if id := c.Get("X-User"); id != "" {
+			c.Locals(UserIDKey, uint(len(id)))
+		}
+		return c.Next()
+	}
+	ok := func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	}
+	app.Post("/limited", withUser, RateLimit(limiter, policy), ok)
+	app.Post("/unlimited", RateLimit(limiter, ratelimit.Policy{Name: "off"}), ok)
+
+	send := func(path, user string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Headers and limit per user", func(t *testing.T) {
+		resp := send("/limited", "a")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("RateLimit-Limit"))
+		assert.Equal(t, "1", resp.Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", resp.Header.Get("RateLimit-Reset"))
+		assert.Equal(t, "2;w=60", resp.Header.Get("RateLimit-Policy"))
+
+		assert.Equal(t, http.StatusOK, send("/limited", "a").StatusCode)
+		resp = send("/limited", "a")
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "0", resp.Header.Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", resp.Header.Get(fiber.HeaderRetryAfter))
+
+		// У другого пользователя свой лимит
+		assert.Equal(t, http.StatusOK, send("/limited", "bb").StatusCode)
+	})
+
+	t.Run("Anonymous requests are limited by IP", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, send("/limited", "").StatusCode)
+		assert.Equal(t, http.StatusOK, send("/limited", "").StatusCode)
+		assert.Equal(t, http.StatusTooManyRequests, send("/limited", "").StatusCode)
+	})
+
+	t.Run("Disabled policy", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			resp := send("/unlimited", "")
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get("RateLimit-Limit"))
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+var RoleKey KeyType = "role"
+
+type RoleProvider interface {
+	GetRole(ctx context.Context, userID uint) (string, error)
+}
+
+type PermissionChecker interface {
+	HasPermission(role, permission string) bool
+}
+
+// RoleMiddleware - загружает роль пользователя и кладет ее в контекст.
+// Должен вызываться после AuthMiddleware
+func RoleMiddleware(provider RoleProvider) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals(UserIDKey).(uint)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"err":     "Unauthorized",
+				"details": "User ID not found",
+			})
+		}
+
+		role, err := provider.GetRole(c.UserContext(), userID)
+		if err != nil {
+			logger.Log.Debug("Error getting user role", zap.Uint("user_id", userID), zap.Error(err))
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"err":     "Unauthorized",
+				"details": "User not found",
+			})
+		}
+		c.Locals(RoleKey, role)
+		return c.Next()
+	}
+}
+
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals(RoleKey).(string)
+		for _, r := range roles {
+			if r == role {
+				return c.Next()
+			}
+		}
+		return forbidden(c)
+	}
+}
+
+func RequirePermission(checker PermissionChecker, permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals(RoleKey).(string)
+		if !checker.HasPermission(role, permission) {
+			return forbidden(c)
+		}
+		return c.Next()
+	}
+}
+
+func forbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"err":     "Forbidden",
+		"details": "Permission denied",
+	})
+}
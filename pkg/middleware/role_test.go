@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type roleProviderStub map[uint]string
+
+func (s roleProviderStub) GetRole(_ context.Context, userID uint) (string, error) {
+	role, ok := s[userID]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return role, nil
+}
+
+type permissionCheckerStub map[string][]string
+
+func (s permissionCheckerStub) HasPermission(role, permission string) bool {
+	for _, p := range s[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRoleMiddlewares(t *testing.T) {
+	logger.Log, _ = zap.NewDevelopment()
+	defer logger.Log.Sync()
+
+	provider := roleProviderStub{1: "admin", 2: "reader"}
+	checker := permissionCheckerStub{"admin": {"posts:create"}}
+
+	tests := []struct {
+		name         string
+		userID       any
+		guard        fiber.Handler
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Role allowed",
+			userID:       uint(1),
+			guard:        RequireRole("moderator", "admin"),
+			expectedCode: 200,
+			expectedBody: "admin",
+		},
+		{
+			name:         "Role forbidden",
+			userID:       uint(2),
+			guard:        RequireRole("admin"),
+			expectedCode: 403,
+			expectedBody: `{"details":"Permission denied","err":"Forbidden"}`,
+		},
+		{
+			name:         "Permission granted",
+			userID:       uint(1),
+			guard:        RequirePermission(checker, "posts:create"),
+			expectedCode: 200,
+			expectedBody: "admin",
+		},
+		{
+			name:         "Permission missing",
+			userID:       uint(2),
+			guard:        RequirePermission(checker, "posts:create"),
+			expectedCode: 403,
+			expectedBody: `{"details":"Permission denied","err":"Forbidden"}`,
+		},
+		{
+			name:         "Unknown user",
+			userID:       uint(3),
+			guard:        RequireRole("admin"),
+			expectedCode: 401,
+			expectedBody: `{"details":"User not found","err":"Unauthorized"}`,
+		},
+		{
+			name:         "User ID is not set",
+			guard:        RequireRole("admin"),
+			expectedCode: 401,
+			expectedBody: `{"details":"User ID not found","err":"Unauthorized"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/",
+				func(c *fiber.Ctx) error {
+					if tt.userID != nil {
+						c.Locals(UserIDKey, tt.userID)
+					}
+					return c.Next()
+				},
+				RoleMiddleware(provider),
+				tt.guard,
+				func(c *fiber.Ctx) error {
+					return c.SendString(c.Locals(RoleKey).(string))
+				})
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedCode, resp.StatusCode)
+
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(respBody), tt.expectedBody)
+		})
+	}
+}
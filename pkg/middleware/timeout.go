@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+// RequestTimeout - ограничивает контекст запроса (c.UserContext()) дедлайном timeout. Обработчики передают
+// этот контекст в сервисы, поэтому по истечении дедлайна запросы к БД и Redis прерываются. 0 - без ограничения
+func RequestTimeout(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if timeout <= 0 {
+			return c.Next()
+		}
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
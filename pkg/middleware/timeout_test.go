@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	// deadline - сколько осталось до дедлайна контекста запроса, 0 - дедлайна нет
+	deadline := func(c *fiber.Ctx) error {
+		until, ok := c.UserContext().Deadline()
+		if !ok {
+			return c.SendString("0")
+		}
+		return c.SendString(time.Until(until).Round(time.Minute).String())
+	}
+
+	app := fiber.New()
+	app.Get("/limited", RequestTimeout(time.Minute), deadline)
+	app.Get("/unlimited", RequestTimeout(0), deadline)
+
+	send := func(path string) string {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	assert.Equal(t, "1m0s", send("/limited"))
+	assert.Equal(t, "0", send("/unlimited"))
+}
@@ -0,0 +1,70 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Create - пустые up и down скрипты новой миграции в каталоге dir. Версия - следующая после максимальной в каталоге
+// или после last, если она больше: так учитываются миграции на Go, у которых нет файлов
+func Create(dir, name string, last uint64) (up, down string, err error) {
+	name = fileSafeName(name)
+	if name == "" {
+		return "", "", fmt.Errorf("%w: empty name", ErrInvalidFileName)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for _, entry := range entries {
+		version, _, _, err := ParseFileName(entry.Name())
+		if err == nil && version > last {
+			last = version
+		}
+	}
+
+	base := fmt.Sprintf("%04d_%s", last+1, name)
+	up = filepath.Join(dir, base+".up.sql")
+	down = filepath.Join(dir, base+".down.sql")
+	if err = writeNew(up, "-- "+base+": применение\n"); err != nil {
+		return "", "", err
+	}
+	if err = writeNew(down, "-- "+base+": откат\n"); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+func writeNew(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// fileSafeName - имя миграции в snake_case из латиницы и цифр
+func fileSafeName(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			underscore = false
+			continue
+		}
+		if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
@@ -0,0 +1,95 @@
+package migrate_test
+
+import (
+	"github.com/crafty-ezhik/blog-api/pkg/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Sorted by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"sql/0010_add_index.up.sql":   {Data: []byte("CREATE INDEX a ON t (a)")},
+			"sql/0010_add_index.down.sql": {Data: []byte("DROP INDEX a")},
+			"sql/0002_init.up.sql":        {Data: []byte("CREATE TABLE t (a INT)")},
+		}
+		migrations, err := migrate.Load(fsys, "sql")
+		require.NoError(t, err)
+		require.Len(t, migrations, 2)
+
+		assert.Equal(t, uint64(2), migrations[0].Version)
+		assert.Equal(t, "init", migrations[0].Name)
+		assert.Nil(t, migrations[0].Down)
+		assert.Equal(t, uint64(10), migrations[1].Version)
+		assert.NotNil(t, migrations[1].Down)
+		assert.NotEqual(t, migrations[0].Checksum, migrations[1].Checksum)
+	})
+
+	t.Run("Checksum covers both scripts", func(t *testing.T) {
+		a := migrate.SQL(1, "init", "CREATE TABLE t (a INT)", "DROP TABLE t")
+		b := migrate.SQL(1, "init", "CREATE TABLE t (a INT)", "DROP TABLE IF EXISTS t")
+		assert.NotEqual(t, a.Checksum, b.Checksum)
+	})
+
+	t.Run("Down without up", func(t *testing.T) {
+		fsys := fstest.MapFS{"sql/0001_init.down.sql": {Data: []byte("DROP TABLE t")}}
+		_, err := migrate.Load(fsys, "sql")
+		assert.ErrorIs(t, err, migrate.ErrInvalidFileName)
+	})
+
+	t.Run("Same version with different names", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"sql/0001_init.up.sql":  {Data: []byte("SELECT 1")},
+			"sql/0001_other.up.sql": {Data: []byte("SELECT 2")},
+		}
+		_, err := migrate.Load(fsys, "sql")
+		assert.ErrorIs(t, err, migrate.ErrDuplicateVersion)
+	})
+}
+
+func TestParseFileName(t *testing.T) {
+	version, name, direction, err := migrate.ParseFileName("0003_posts_search.down.sql")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), version)
+	assert.Equal(t, "posts_search", name)
+	assert.Equal(t, "down", direction)
+
+	for _, invalid := range []string{"init.up.sql", "0000_init.up.sql", "0001_init.sql", "0001_Init.up.sql", "0001_init.up.txt"} {
+		_, _, _, err = migrate.ParseFileName(invalid)
+		assert.ErrorIs(t, err, migrate.ErrInvalidFileName, invalid)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0007_init.up.sql"), nil, 0o644))
+
+	up, down, err := migrate.Create(dir, "Add users.Bio column", 3)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0008_add_users_bio_column.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "0008_add_users_bio_column.down.sql"), down)
+
+	migrations, err := migrate.Load(os.DirFS(dir), ".")
+	require.NoError(t, err)
+	assert.Len(t, migrations, 2)
+
+	// Миграция на Go с версией старше файлов
+	up, _, err = migrate.Create(dir, "seed", 12)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0013_seed.up.sql"), up)
+
+	_, _, err = migrate.Create(dir, "!!!", 0)
+	assert.ErrorIs(t, err, migrate.ErrInvalidFileName)
+}
+
+func TestNew_DuplicateVersion(t *testing.T) {
+	_, err := migrate.New(nil, []migrate.Migration{
+		migrate.SQL(1, "a", "SELECT 1", ""),
+		migrate.SQL(1, "b", "SELECT 2", ""),
+	})
+	assert.ErrorIs(t, err, migrate.ErrDuplicateVersion)
+}
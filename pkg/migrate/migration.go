@@ -0,0 +1,136 @@
+// Package migrate - версионированные миграции схемы БД. Примененные версии и контрольные суммы
+// хранятся в таблице schema_migrations, конкурирующие запуски сериализуются advisory lock'ом PostgreSQL
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrIrreversible     = errors.New("migration has no down step")
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+	ErrInvalidFileName  = errors.New("invalid migration file name")
+)
+
+// fileNamePattern - <версия>_<имя>.up.sql и <версия>_<имя>.down.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Step - шаг миграции, выполняется в транзакции
+type Step func(tx *gorm.DB) error
+
+// Migration - одна версия схемы. Up обязателен, без Down миграцию нельзя откатить.
+// Checksum фиксирует содержимое миграции: примененную миграцию менять нельзя
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       Step
+	Down     Step
+	Checksum string
+}
+
+// SQL - миграция из SQL скриптов. down может быть пустым
+func SQL(version uint64, name, up, down string) Migration {
+	m := Migration{Version: version, Name: name, Up: execSQL(up), Checksum: checksum(up, down)}
+	if strings.TrimSpace(down) != "" {
+		m.Down = execSQL(down)
+	}
+	return m
+}
+
+// Go - миграция на Go для изменений, которые неудобно выразить в SQL (перенос данных и т.п.).
+// Контрольная сумма задается вручную: ее смена означает, что миграция изменилась
+func Go(version uint64, name string, up, down Step, sum string) Migration {
+	return Migration{Version: version, Name: name, Up: up, Down: down, Checksum: checksum("go:"+sum, "")}
+}
+
+// Load - SQL миграции из fsys, файлы ищутся в каталоге dir
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type scripts struct {
+		name     string
+		up, down string
+		hasUp    bool
+	}
+	files := make(map[uint64]*scripts)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		version, name, direction, err := ParseFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		s, ok := files[version]
+		if !ok {
+			s = &scripts{name: name}
+			files[version] = s
+		}
+		if s.name != name {
+			return nil, fmt.Errorf("%w: %d (%s, %s)", ErrDuplicateVersion, version, s.name, name)
+		}
+		if direction == "up" {
+			s.up, s.hasUp = string(content), true
+		} else {
+			s.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	for version, s := range files {
+		if !s.hasUp {
+			return nil, fmt.Errorf("%w: %d_%s has no up script", ErrInvalidFileName, version, s.name)
+		}
+		migrations = append(migrations, SQL(version, s.name, s.up, s.down))
+	}
+	sortMigrations(migrations)
+	return migrations, nil
+}
+
+// ParseFileName - версия, имя и направление (up или down) из имени файла миграции
+func ParseFileName(fileName string) (version uint64, name, direction string, err error) {
+	match := fileNamePattern.FindStringSubmatch(fileName)
+	if match == nil {
+		return 0, "", "", fmt.Errorf("%w: %s", ErrInvalidFileName, fileName)
+	}
+	version, err = strconv.ParseUint(match[1], 10, 64)
+	if err != nil || version == 0 {
+		return 0, "", "", fmt.Errorf("%w: %s", ErrInvalidFileName, fileName)
+	}
+	return version, match[2], match[3], nil
+}
+
+func execSQL(script string) Step {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(script).Error
+	}
+}
+
+func checksum(up, down string) string {
+	sum := sha256.Sum256([]byte(up + "\x00" + down))
+	return hex.EncodeToString(sum[:])
+}
+
+func sortMigrations(migrations []Migration) {
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
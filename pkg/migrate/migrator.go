@@ -0,0 +1,308 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+	ErrPending          = errors.New("migration is not applied")
+	ErrUnknownVersion   = errors.New("applied migration is not in this build")
+)
+
+const (
+	// DefaultTable - таблица примененных миграций
+	DefaultTable = "schema_migrations"
+	// DefaultLockID - ключ pg_advisory_lock, общий для всех экземпляров приложения
+	DefaultLockID int64 = 7_346_920_118
+)
+
+// Record - строка schema_migrations
+type Record struct {
+	Version   uint64    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	Checksum  string    `gorm:"size:64;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// Status - состояние одной версии. Migration == nil - версия применена, но ее миграции нет в сборке
+type Status struct {
+	Version   uint64
+	Name      string
+	Migration *Migration
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // контрольная сумма изменилась после применения
+}
+
+// Migrator - применяет и откатывает миграции
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	table      string
+	lockID     int64
+}
+
+func New(db *gorm.DB, migrations []Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sortMigrations(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateVersion, sorted[i].Version)
+		}
+	}
+	return &Migrator{db: db, migrations: sorted, table: DefaultTable, lockID: DefaultLockID}, nil
+}
+
+// Up - применяет не больше limit ожидающих миграций по возрастанию версий, limit <= 0 - все.
+// Если примененная миграция была изменена, ничего не применяется
+func (m *Migrator) Up(limit int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		if err = m.verify(applied); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if limit > 0 && len(done) == limit {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err = m.apply(db, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down - откатывает steps последних примененных миграций, steps <= 0 - одну.
+// Если среди них есть измененная, необратимая или неизвестная сборке миграция, ничего не откатывается
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	var done []Migration
+	err := m.locked(func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		targets, err := m.lastApplied(applied, steps)
+		if err != nil {
+			return err
+		}
+		for _, migration := range targets {
+			if err = m.revert(db, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Redo - откатывает и заново применяет последнюю примененную миграцию
+func (m *Migrator) Redo() (*Migration, error) {
+	var redone *Migration
+	err := m.locked(func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		targets, err := m.lastApplied(applied, 1)
+		if err != nil || len(targets) == 0 {
+			return err
+		}
+		migration := targets[0]
+		if err = m.revert(db, migration); err != nil {
+			return err
+		}
+		if err = m.apply(db, migration); err != nil {
+			return err
+		}
+		redone = &migration
+		return nil
+	})
+	return redone, err
+}
+
+// lastApplied - steps последних примененных миграций от новых к старым. Откатывать можно только то,
+// что сборка знает и что не изменилось после применения: иначе Down выполнился бы не для той схемы,
+// а неизвестная новая версия осталась бы записанной поверх откаченных
+func (m *Migrator) lastApplied(applied map[uint64]Record, steps int) ([]Migration, error) {
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+	versions := make([]uint64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	known := make(map[uint64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	var targets []Migration
+	for _, version := range versions[:min(steps, len(versions))] {
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d_%s", ErrUnknownVersion, version, applied[version].Name)
+		}
+		if migration.Down == nil {
+			return nil, fmt.Errorf("%w: %d_%s", ErrIrreversible, migration.Version, migration.Name)
+		}
+		targets = append(targets, migration)
+	}
+	return targets, nil
+}
+
+// Status - все известные и примененные версии по возрастанию
+func (m *Migrator) Status() ([]Status, error) {
+	var result []Status
+	err := m.locked(func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		known := make(map[uint64]bool, len(m.migrations))
+		for i := range m.migrations {
+			migration := &m.migrations[i]
+			known[migration.Version] = true
+			status := Status{Version: migration.Version, Name: migration.Name, Migration: migration}
+			if record, ok := applied[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = record.AppliedAt
+				status.Modified = record.Checksum != migration.Checksum
+			}
+			result = append(result, status)
+		}
+		for _, record := range applied {
+			if !known[record.Version] {
+				result = append(result, Status{Version: record.Version, Name: record.Name, Applied: true, AppliedAt: record.AppliedAt})
+			}
+		}
+		return nil
+	})
+	sortStatus(result)
+	return result, err
+}
+
+// Verify - проверяет, что схема на ожидаемой версии: все миграции сборки применены и не изменены.
+// В отличие от Status не берет блокировку и не создает таблицу миграций, поэтому подходит для проверок готовности
+func (m *Migrator) Verify(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	applied := make(map[uint64]Record)
+	if db.Migrator().HasTable(m.table) {
+		var records []Record
+		if err := db.Table(m.table).Find(&records).Error; err != nil {
+			return err
+		}
+		for _, record := range records {
+			applied[record.Version] = record
+		}
+	}
+	if err := m.verify(applied); err != nil {
+		return err
+	}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			return fmt.Errorf("%w: %d_%s", ErrPending, migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) verify(applied map[uint64]Record) error {
+	for _, migration := range m.migrations {
+		if record, ok := applied[migration.Version]; ok && record.Checksum != migration.Checksum {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// apply - миграция и запись о ней в одной транзакции
+func (m *Migrator) apply(db *gorm.DB, migration Migration) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Table(m.table).Create(&Record{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Checksum:  migration.Checksum,
+			AppliedAt: time.Now().UTC(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) revert(db *gorm.DB, migration Migration) error {
+	if migration.Down == nil {
+		return fmt.Errorf("%w: %d_%s", ErrIrreversible, migration.Version, migration.Name)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Table(m.table).Where("version = ?", migration.Version).Delete(&Record{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(db *gorm.DB) (map[uint64]Record, error) {
+	if err := db.Table(m.table).AutoMigrate(&Record{}); err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := db.Table(m.table).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint64]Record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// locked - выполняет fn под advisory lock'ом на выделенном соединении, чтобы несколько экземпляров,
+// запущенных одновременно, не применяли миграции параллельно. Для других СУБД блокировки нет
+func (m *Migrator) locked(fn func(db *gorm.DB) error) error {
+	if m.db.Dialector.Name() != "postgres" {
+		return fn(m.db)
+	}
+	return m.db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", m.lockID).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", m.lockID)
+		return fn(conn)
+	})
+}
+
+func sortStatus(statuses []Status) {
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+}
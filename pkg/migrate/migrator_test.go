@@ -0,0 +1,175 @@
+package migrate_test
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func testMigrations() []migrate.Migration {
+	return []migrate.Migration{
+		migrate.SQL(1, "create_notes", "CREATE TABLE notes (id INTEGER PRIMARY KEY, text TEXT)", "DROP TABLE notes"),
+		migrate.SQL(2, "add_notes_title", "ALTER TABLE notes ADD COLUMN title TEXT", "ALTER TABLE notes DROP COLUMN title"),
+		migrate.Go(3, "seed_notes", func(tx *gorm.DB) error {
+			return tx.Exec("INSERT INTO notes (text, title) VALUES ('text', 'title')").Error
+		}, func(tx *gorm.DB) error {
+			return tx.Exec("DELETE FROM notes").Error
+		}, "v1"),
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	t.Run("Up, down and redo", func(t *testing.T) {
+		db := openDB(t)
+		migrator, err := migrate.New(db, testMigrations())
+		require.NoError(t, err)
+
+		done, err := migrator.Up(2)
+		require.NoError(t, err)
+		assert.Len(t, done, 2)
+		assert.True(t, db.Migrator().HasColumn("notes", "title"))
+
+		done, err = migrator.Up(0)
+		require.NoError(t, err)
+		require.Len(t, done, 1)
+		assert.Equal(t, uint64(3), done[0].Version)
+
+		done, err = migrator.Up(0)
+		require.NoError(t, err)
+		assert.Empty(t, done)
+
+		redone, err := migrator.Redo()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), redone.Version)
+		var count int64
+		db.Table("notes").Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		done, err = migrator.Down(2)
+		require.NoError(t, err)
+		assert.Len(t, done, 2)
+		assert.False(t, db.Migrator().HasColumn("notes", "title"))
+
+		statuses, err := migrator.Status()
+		require.NoError(t, err)
+		require.Len(t, statuses, 3)
+		assert.True(t, statuses[0].Applied)
+		assert.False(t, statuses[1].Applied)
+		assert.False(t, statuses[2].Applied)
+	})
+
+	t.Run("Failed migration is rolled back", func(t *testing.T) {
+		db := openDB(t)
+		migrator, err := migrate.New(db, []migrate.Migration{
+			migrate.SQL(1, "create_notes", "CREATE TABLE notes (id INTEGER PRIMARY KEY)", "DROP TABLE notes"),
+			migrate.SQL(2, "broken", "CREATE TABLE other (id INTEGER); SELECT * FROM missing", ""),
+		})
+		require.NoError(t, err)
+
+		done, err := migrator.Up(0)
+		assert.Error(t, err)
+		assert.Len(t, done, 1)
+		assert.False(t, db.Migrator().HasTable("other"))
+
+		statuses, err := migrator.Status()
+		require.NoError(t, err)
+		assert.True(t, statuses[0].Applied)
+		assert.False(t, statuses[1].Applied)
+	})
+
+	t.Run("Modified migration", func(t *testing.T) {
+		db := openDB(t)
+		migrator, err := migrate.New(db, testMigrations())
+		require.NoError(t, err)
+		_, err = migrator.Up(1)
+		require.NoError(t, err)
+
+		modified := testMigrations()
+		modified[0] = migrate.SQL(1, "create_notes", "CREATE TABLE notes (id INTEGER PRIMARY KEY, text TEXT NOT NULL)", "DROP TABLE notes")
+		migrator, err = migrate.New(db, modified)
+		require.NoError(t, err)
+
+		_, err = migrator.Up(0)
+		assert.ErrorIs(t, err, migrate.ErrChecksumMismatch)
+
+		statuses, err := migrator.Status()
+		require.NoError(t, err)
+		assert.True(t, statuses[0].Modified)
+		assert.False(t, statuses[1].Applied)
+	})
+
+	t.Run("Irreversible migration", func(t *testing.T) {
+		db := openDB(t)
+		migrator, err := migrate.New(db, []migrate.Migration{migrate.SQL(1, "create_notes", "CREATE TABLE notes (id INTEGER)", "")})
+		require.NoError(t, err)
+		_, err = migrator.Up(0)
+		require.NoError(t, err)
+
+		_, err = migrator.Down(1)
+		assert.ErrorIs(t, err, migrate.ErrIrreversible)
+	})
+
+	t.Run("Down refuses unknown and modified migrations", func(t *testing.T) {
+		db := openDB(t)
+		migrator, err := migrate.New(db, testMigrations())
+		require.NoError(t, err)
+		_, err = migrator.Up(0)
+		require.NoError(t, err)
+
+		// Сборка без последней миграции не должна откатывать вторую под ней
+		older, err := migrate.New(db, testMigrations()[:2])
+		require.NoError(t, err)
+		_, err = older.Down(1)
+		assert.ErrorIs(t, err, migrate.ErrUnknownVersion)
+		_, err = older.Redo()
+		assert.ErrorIs(t, err, migrate.ErrUnknownVersion)
+		assert.True(t, db.Migrator().HasColumn("notes", "title"))
+
+		modified := testMigrations()
+		modified[1] = migrate.SQL(2, "add_notes_title", "ALTER TABLE notes ADD COLUMN title TEXT NOT NULL DEFAULT ''", "ALTER TABLE notes DROP COLUMN title")
+		migrator, err = migrate.New(db, modified)
+		require.NoError(t, err)
+		done, err := migrator.Down(3)
+		assert.ErrorIs(t, err, migrate.ErrChecksumMismatch)
+		assert.Empty(t, done)
+		assert.True(t, db.Migrator().HasTable("notes"))
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		db := openDB(t)
+		migrator, err := migrate.New(db, testMigrations())
+		require.NoError(t, err)
+
+		// Пустая база: таблицы миграций еще нет, и Verify ее не создает
+		assert.ErrorIs(t, migrator.Verify(context.Background()), migrate.ErrPending)
+		assert.False(t, db.Migrator().HasTable(migrate.DefaultTable))
+
+		_, err = migrator.Up(2)
+		require.NoError(t, err)
+		assert.ErrorIs(t, migrator.Verify(context.Background()), migrate.ErrPending)
+
+		_, err = migrator.Up(0)
+		require.NoError(t, err)
+		assert.NoError(t, migrator.Verify(context.Background()))
+
+		modified := testMigrations()
+		modified[2] = migrate.Go(3, "seed_notes", modified[2].Up, modified[2].Down, "v2")
+		migrator, err = migrate.New(db, modified)
+		require.NoError(t, err)
+		assert.ErrorIs(t, migrator.Verify(context.Background()), migrate.ErrChecksumMismatch)
+	})
+}
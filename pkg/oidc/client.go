@@ -0,0 +1,124 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown oauth provider")
+	ErrInvalidState    = errors.New("oauth state is invalid or expired")
+)
+
+// DefaultStateTTL - время на вход у провайдера, если oauth.state_ttl не задан
+const DefaultStateTTL = 10 * time.Minute
+
+// State - данные начатого входа, которые нужны для его завершения
+type State struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// StateStorage - одноразовое хранилище начатых входов. ConsumeState удаляет состояние,
+// а для неизвестного или истекшего возвращает ErrInvalidState
+type StateStorage interface {
+	SaveState(ctx context.Context, state string, data *State, ttl time.Duration) error
+	ConsumeState(ctx context.Context, state string) (*State, error)
+}
+
+// Authorization - начатый вход: адрес провайдера и state, который нужно привязать к браузеру
+type Authorization struct {
+	URL   string
+	State string
+}
+
+// Client - все настроенные провайдеры
+type Client struct {
+	providers map[string]*Provider
+	states    StateStorage
+	stateTTL  time.Duration
+}
+
+func NewClient(cfg config.OAuthConfig, states StateStorage, httpClient *http.Client) *Client {
+	providers := make(map[string]*Provider, len(cfg.Providers))
+	for name, providerCfg := range cfg.Providers {
+		providers[name] = NewProvider(name, providerCfg, httpClient)
+	}
+	stateTTL := cfg.StateTTL
+	if stateTTL <= 0 {
+		stateTTL = DefaultStateTTL
+	}
+	return &Client{providers: providers, states: states, stateTTL: stateTTL}
+}
+
+// StateTTL - сколько живет начатый вход
+func (c *Client) StateTTL() time.Duration {
+	return c.stateTTL
+}
+
+// Start - начинает вход через провайдера: создает state, nonce и PKCE verifier и возвращает адрес провайдера
+func (c *Client) Start(ctx context.Context, provider string) (*Authorization, error) {
+	p, ok := c.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	data := &State{Provider: provider}
+	if data.Nonce, err = randomString(); err != nil {
+		return nil, err
+	}
+	if data.CodeVerifier, err = randomString(); err != nil {
+		return nil, err
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, data.Nonce, data.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.states.SaveState(ctx, state, data, c.stateTTL); err != nil {
+		return nil, err
+	}
+	return &Authorization{URL: authURL, State: state}, nil
+}
+
+// Finish - завершает вход: проверяет state и обменивает code на подтвержденного пользователя
+func (c *Client) Finish(ctx context.Context, provider, state, code string) (*Identity, error) {
+	p, ok := c.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	data, err := c.states.ConsumeState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if data.Provider != provider {
+		return nil, ErrInvalidState
+	}
+	return p.Exchange(ctx, code, data.CodeVerifier, data.Nonce)
+}
+
+// CodeChallenge - PKCE code_challenge для метода S256
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,34 @@
+package oidc
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/ttlmap"
+	"time"
+)
+
+// MemoryStates - StateStorage в памяти процесса. Подходит для тестов и запуска в одном экземпляре.
+// Брошенные входы удаляются janitor раз в cleanupInterval, остановить его можно через Close
+type MemoryStates struct {
+	states *ttlmap.Map[State]
+}
+
+func NewMemoryStates(cleanupInterval time.Duration) *MemoryStates {
+	return &MemoryStates{states: ttlmap.New[State](cleanupInterval)}
+}
+
+func (m *MemoryStates) SaveState(_ context.Context, state string, data *State, ttl time.Duration) error {
+	m.states.Set(state, *data, ttl)
+	return nil
+}
+
+func (m *MemoryStates) ConsumeState(_ context.Context, state string) (*State, error) {
+	data, ok := m.states.Take(state)
+	if !ok {
+		return nil, ErrInvalidState
+	}
+	return &data, nil
+}
+
+func (m *MemoryStates) Close() {
+	m.states.Close()
+}
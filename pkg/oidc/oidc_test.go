@@ -0,0 +1,119 @@
+package oidc_test
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"github.com/crafty-ezhik/blog-api/pkg/oidc"
+	"github.com/crafty-ezhik/blog-api/pkg/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const redirectURL = "http://localhost/auth/oauth/test/callback"
+
+func TestClient(t *testing.T) {
+	idp := oidctest.NewServer("blog", "secret")
+	defer idp.Close()
+	idp.SetUser(oidctest.User{Subject: "42", Email: "Ivan@Example.com", EmailVerified: true, Name: "Ivan"})
+
+	client := oidc.NewClient(config.OAuthConfig{
+		Providers: map[string]config.OIDCProviderConfig{"test": idp.ProviderConfig(redirectURL)},
+	}, oidc.NewMemoryStates(0), nil)
+
+	t.Run("Authorization code flow", func(t *testing.T) {
+		auth, err := client.Start(context.Background(), "test")
+		require.NoError(t, err)
+
+		u, err := url.Parse(auth.URL)
+		require.NoError(t, err)
+		assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+		assert.Equal(t, redirectURL, u.Query().Get("redirect_uri"))
+		assert.Equal(t, auth.State, u.Query().Get("state"))
+
+		code, state, err := idp.Authorize(auth.URL)
+		require.NoError(t, err)
+
+		identity, err := client.Finish(context.Background(), "test", state, code)
+		require.NoError(t, err)
+		assert.Equal(t, "test", identity.Provider)
+		assert.Equal(t, "42", identity.Subject)
+		assert.Equal(t, "ivan@example.com", identity.Email)
+		assert.True(t, identity.EmailVerified)
+
+		// state одноразовый
+		_, err = client.Finish(context.Background(), "test", state, code)
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+	})
+
+	t.Run("Unknown provider", func(t *testing.T) {
+		_, err := client.Start(context.Background(), "github")
+		assert.ErrorIs(t, err, oidc.ErrUnknownProvider)
+	})
+
+	t.Run("Forged state", func(t *testing.T) {
+		auth, err := client.Start(context.Background(), "test")
+		require.NoError(t, err)
+		code, _, err := idp.Authorize(auth.URL)
+		require.NoError(t, err)
+
+		_, err = client.Finish(context.Background(), "test", "forged", code)
+		assert.ErrorIs(t, err, oidc.ErrInvalidState)
+	})
+
+	t.Run("Wrong client secret", func(t *testing.T) {
+		cfg := idp.ProviderConfig(redirectURL)
+		cfg.ClientSecret = "wrong"
+		other := oidc.NewClient(config.OAuthConfig{
+			Providers: map[string]config.OIDCProviderConfig{"test": cfg},
+		}, oidc.NewMemoryStates(0), nil)
+
+		auth, err := other.Start(context.Background(), "test")
+		require.NoError(t, err)
+		code, state, err := idp.Authorize(auth.URL)
+		require.NoError(t, err)
+
+		_, err = other.Finish(context.Background(), "test", state, code)
+		assert.ErrorIs(t, err, oidc.ErrExchange)
+	})
+}
+
+func TestProvider_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer idp.Close()
+	defer close(release)
+
+	client := oidc.NewClient(config.OAuthConfig{
+		Providers: map[string]config.OIDCProviderConfig{"test": {Issuer: idp.URL, ClientID: "blog", RedirectURL: redirectURL}},
+	}, oidc.NewMemoryStates(0), oidc.NewHTTPClient(50*time.Millisecond))
+
+	start := time.Now()
+	_, err := client.Start(context.Background(), "test")
+	assert.ErrorIs(t, err, oidc.ErrDiscovery)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestMemoryStates(t *testing.T) {
+	states := oidc.NewMemoryStates(10 * time.Millisecond)
+	defer states.Close()
+
+	require.NoError(t, states.SaveState(context.Background(), "expired", &oidc.State{Provider: "test"}, 20*time.Millisecond))
+	require.NoError(t, states.SaveState(context.Background(), "active", &oidc.State{Provider: "test"}, time.Minute))
+
+	time.Sleep(50 * time.Millisecond)
+	_, err := states.ConsumeState(context.Background(), "expired")
+	assert.ErrorIs(t, err, oidc.ErrInvalidState)
+
+	state, err := states.ConsumeState(context.Background(), "active")
+	require.NoError(t, err)
+	assert.Equal(t, "test", state.Provider)
+	_, err = states.ConsumeState(context.Background(), "active")
+	assert.ErrorIs(t, err, oidc.ErrInvalidState)
+}
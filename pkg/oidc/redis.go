@@ -0,0 +1,41 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// RedisStates - StateStorage в Redis
+type RedisStates struct {
+	client *redis.Client
+}
+
+func NewRedisStates(client *redis.Client) *RedisStates {
+	return &RedisStates{client: client}
+}
+
+func (r *RedisStates) SaveState(ctx context.Context, state string, data *State, ttl time.Duration) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, "oauth_state:"+state, value, ttl).Err()
+}
+
+func (r *RedisStates) ConsumeState(ctx context.Context, state string) (*State, error) {
+	value, err := r.client.GetDel(ctx, "oauth_state:"+state).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := &State{}
+	if err = json.Unmarshal(value, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,221 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidLimit  = errors.New("limit must be between 1 and 100")
+	ErrInvalidOffset = errors.New("offset must not be negative")
+	ErrInvalidSort   = errors.New("invalid sort field")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Sort - поле сортировки. В строковом виде "title" - по возрастанию, "-title" - по убыванию
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// Cursor - позиция последнего элемента страницы. Клиенту отдается в виде непрозрачной base64-строки
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value any    `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// Params - параметры запроса страницы. Если передан Cursor, то Offset игнорируется
+type Params struct {
+	Limit  int
+	Offset int
+	Cursor *Cursor
+	Sort   Sort
+}
+
+// Meta - метаданные страницы, отдаются в поле meta ответа
+type Meta struct {
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	Sort       string `json:"sort"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int64  `json:"total"`
+}
+
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+	Total      int64
+	Params     *Params
+}
+
+func (p *Page[T]) Meta() *Meta {
+	meta := &Meta{
+		NextCursor: p.NextCursor,
+		Total:      p.Total,
+	}
+	if p.Params != nil {
+		meta.Limit = p.Params.Limit
+		meta.Sort = p.Params.Sort.String()
+		if p.Params.Cursor == nil {
+			meta.Offset = p.Params.Offset
+		}
+	}
+	return meta
+}
+
+// ParseQuery - разбирает параметры limit, offset, cursor и sort из строки запроса.
+// allowed - список полей, по которым разрешена сортировка
+func ParseQuery(c *fiber.Ctx, defaultSort string, allowed ...string) (*Params, error) {
+	params := &Params{
+		Limit:  c.QueryInt("limit", DefaultLimit),
+		Offset: c.QueryInt("offset", 0),
+	}
+	if params.Limit < 1 || params.Limit > MaxLimit {
+		return nil, ErrInvalidLimit
+	}
+	if params.Offset < 0 {
+		return nil, ErrInvalidOffset
+	}
+
+	sort, err := ParseSort(c.Query("sort", defaultSort), allowed...)
+	if err != nil {
+		return nil, err
+	}
+	params.Sort = sort
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := DecodeCursor(raw)
+		if err != nil {
+			return nil, err
+		}
+		// Курсор имеет смысл только для той сортировки, с которой он был получен
+		if cursor.Sort != sort.String() {
+			return nil, ErrInvalidCursor
+		}
+		params.Cursor = cursor
+	}
+	return params, nil
+}
+
+func ParseSort(raw string, allowed ...string) (Sort, error) {
+	sort := Sort{Field: strings.TrimPrefix(raw, "-"), Desc: strings.HasPrefix(raw, "-")}
+	for _, field := range allowed {
+		if field == sort.Field {
+			return sort, nil
+		}
+	}
+	return Sort{}, ErrInvalidSort
+}
+
+func EncodeCursor(cursor *Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(raw string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err = json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	// Значения полей-дат приходят строкой, для сравнения в БД их нужно вернуть к time.Time
+	if value, ok := cursor.Value.(string); ok && strings.HasSuffix(strings.TrimPrefix(cursor.Sort, "-"), "_at") {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		cursor.Value = t
+	}
+	return &cursor, nil
+}
+
+// Paginate - выполняет запрос query постранично. table - имя таблицы, к которой относятся поле сортировки и id,
+// нужно чтобы избежать неоднозначности колонок при Joins.
+// В режиме курсора используется keyset-пагинация по паре (поле сортировки, id), поэтому вставка новых
+// записей между запросами не приводит к пропускам и дублям
+func Paginate[T any](query *gorm.DB, table string, params *Params) (*Page[T], error) {
+	page := &Page[T]{Params: params}
+
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	column := table + "." + params.Sort.Field
+	idColumn := table + ".id"
+
+	q := query.Session(&gorm.Session{})
+	if params.Cursor != nil {
+		op := ">"
+		if params.Sort.Desc {
+			op = "<"
+		}
+		q = q.Where(
+			fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", column, op, column, idColumn, op),
+			params.Cursor.Value, params.Cursor.Value, params.Cursor.ID,
+		)
+	} else if params.Offset > 0 {
+		q = q.Offset(params.Offset)
+	}
+
+	q = q.Order(clause.OrderBy{Columns: []clause.OrderByColumn{
+		{Column: clause.Column{Table: table, Name: params.Sort.Field}, Desc: params.Sort.Desc},
+		{Column: clause.Column{Table: table, Name: "id"}, Desc: params.Sort.Desc},
+	}})
+
+	// Запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	items := make([]T, 0, params.Limit+1)
+	if err := q.Limit(params.Limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	if len(items) > params.Limit {
+		items = items[:params.Limit]
+		last := items[len(items)-1]
+		page.NextCursor = EncodeCursor(&Cursor{
+			Sort:  params.Sort.String(),
+			Value: fieldValue(last, params.Sort.Field),
+			ID:    fieldValue(last, "id").(uint),
+		})
+	}
+	page.Items = items
+	return page, nil
+}
+
+// fieldValue - достает значение поля структуры по имени колонки (created_at -> CreatedAt)
+func fieldValue(item any, column string) any {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	name := ""
+	for _, part := range strings.Split(column, "_") {
+		if part == "id" {
+			name += "ID"
+			continue
+		}
+		name += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return v.FieldByName(name).Interface()
+}
@@ -0,0 +1,57 @@
+package pagination
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type item struct {
+	ID        uint
+	Title     string
+	CreatedAt time.Time
+}
+
+func TestParseSort(t *testing.T) {
+	allowed := []string{"created_at", "title"}
+
+	sort, err := ParseSort("-created_at", allowed...)
+	require.NoError(t, err)
+	assert.Equal(t, Sort{Field: "created_at", Desc: true}, sort)
+	assert.Equal(t, "-created_at", sort.String())
+
+	sort, err = ParseSort("title", allowed...)
+	require.NoError(t, err)
+	assert.Equal(t, Sort{Field: "title"}, sort)
+
+	_, err = ParseSort("password", allowed...)
+	assert.ErrorIs(t, err, ErrInvalidSort)
+}
+
+func TestCursor(t *testing.T) {
+	createdAt := time.Date(2025, 6, 1, 12, 30, 0, 123456000, time.UTC)
+	last := item{ID: 7, Title: "Title", CreatedAt: createdAt}
+
+	t.Run("Time field round trip", func(t *testing.T) {
+		raw := EncodeCursor(&Cursor{Sort: "-created_at", Value: fieldValue(last, "created_at"), ID: fieldValue(last, "id").(uint)})
+
+		cursor, err := DecodeCursor(raw)
+		require.NoError(t, err)
+		assert.Equal(t, uint(7), cursor.ID)
+		assert.True(t, createdAt.Equal(cursor.Value.(time.Time)))
+	})
+
+	t.Run("String field round trip", func(t *testing.T) {
+		raw := EncodeCursor(&Cursor{Sort: "title", Value: fieldValue(&last, "title"), ID: last.ID})
+
+		cursor, err := DecodeCursor(raw)
+		require.NoError(t, err)
+		assert.Equal(t, "Title", cursor.Value)
+	})
+
+	t.Run("Garbage", func(t *testing.T) {
+		_, err := DecodeCursor("not a cursor")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
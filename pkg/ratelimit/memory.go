@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/ttlmap"
+	"sync"
+	"time"
+)
+
+// MemoryLimiter - Limiter в памяти процесса. Лимиты считаются для каждого экземпляра приложения отдельно.
+// Состояния, которые больше не влияют на решения, удаляются janitor раз в cleanupInterval, остановить его можно через Close
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	windows map[string]windowState
+	buckets map[string]bucketState
+	done    chan struct{}
+	once    sync.Once
+}
+
+type windowState struct {
+	start    time.Time
+	previous int
+	current  int
+	// expiresAt - конец следующего окна: после него оба счетчика уже не учитываются
+	expiresAt time.Time
+}
+
+type bucketState struct {
+	tokens    float64
+	updatedAt time.Time
+	// expiresAt - момент, когда корзина снова полная и не отличается от новой
+	expiresAt time.Time
+}
+
+func NewMemoryLimiter(cleanupInterval time.Duration) *MemoryLimiter {
+	if cleanupInterval <= 0 {
+		cleanupInterval = ttlmap.DefaultCleanupInterval
+	}
+	m := &MemoryLimiter{
+		now:     time.Now,
+		windows: make(map[string]windowState),
+		buckets: make(map[string]bucketState),
+		done:    make(chan struct{}),
+	}
+	go m.janitor(cleanupInterval)
+	return m
+}
+
+func (m *MemoryLimiter) Allow(_ context.Context, key string, policy Policy) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if policy.Algorithm == TokenBucket {
+		return m.tokenBucket(key, policy), nil
+	}
+	return m.slidingWindow(key, policy), nil
+}
+
+func (m *MemoryLimiter) Close() {
+	m.once.Do(func() { close(m.done) })
+}
+
+func (m *MemoryLimiter) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.sweep()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// sweep - удаляет состояния, без которых следующий запрос получит то же решение
+func (m *MemoryLimiter) sweep() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for key, state := range m.windows {
+		if !now.Before(state.expiresAt) {
+			delete(m.windows, key)
+		}
+	}
+	for key, state := range m.buckets {
+		if !now.Before(state.expiresAt) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func (m *MemoryLimiter) slidingWindow(key string, policy Policy) Result {
+	now := m.now()
+	start := now.Truncate(policy.Window)
+	state := m.windows[key]
+	switch {
+	case state.start.Equal(start):
+	case state.start.Add(policy.Window).Equal(start):
+		state = windowState{start: start, previous: state.current}
+	default:
+		state = windowState{start: start}
+	}
+
+	elapsed := now.Sub(start)
+	allowed := slidingWindowUsed(policy, state.previous, state.current, elapsed)+1 <= float64(policy.Limit)
+	result := slidingWindowResult(policy, state.previous, state.current, elapsed, allowed)
+	if allowed {
+		state.current++
+	}
+	state.expiresAt = start.Add(2 * policy.Window)
+	m.windows[key] = state
+	return result
+}
+
+func (m *MemoryLimiter) tokenBucket(key string, policy Policy) Result {
+	now := m.now()
+	state, ok := m.buckets[key]
+	if !ok {
+		state = bucketState{tokens: float64(policy.Limit), updatedAt: now}
+	}
+	refill := float64(now.Sub(state.updatedAt)) / float64(policy.Window) * float64(policy.Limit)
+	state.tokens = min(float64(policy.Limit), state.tokens+refill)
+	state.updatedAt = now
+
+	allowed := state.tokens >= 1
+	if allowed {
+		state.tokens--
+	}
+	result := tokenBucketResult(policy, state.tokens, allowed)
+	state.expiresAt = now.Add(result.Reset)
+	m.buckets[key] = state
+	return result
+}
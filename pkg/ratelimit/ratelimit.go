@@ -0,0 +1,116 @@
+// Package ratelimit - ограничение частоты запросов алгоритмами sliding window и token bucket
+package ratelimit
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/internal/config"
+	"math"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	// SlidingWindow - не больше Limit запросов за любые Window. Счетчик предыдущего окна учитывается
+	// пропорционально его пересечению со скользящим окном
+	SlidingWindow Algorithm = "sliding_window"
+	// TokenBucket - корзина на Limit запросов, которая равномерно пополняется за Window. Допускает всплески
+	TokenBucket Algorithm = "token_bucket"
+)
+
+// Policy - лимит для группы маршрутов
+type Policy struct {
+	Name      string
+	Limit     int
+	Window    time.Duration
+	Algorithm Algorithm
+}
+
+func NewPolicy(name string, cfg config.RateLimitPolicy) Policy {
+	algorithm := Algorithm(cfg.Algorithm)
+	if algorithm != TokenBucket {
+		algorithm = SlidingWindow
+	}
+	window := cfg.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	return Policy{Name: name, Limit: cfg.Limit, Window: window, Algorithm: algorithm}
+}
+
+// Enabled - политика без лимита ничего не ограничивает
+func (p Policy) Enabled() bool {
+	return p.Limit > 0
+}
+
+// Result - решение по запросу и данные для заголовков RateLimit-*
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset - через сколько лимит восстановится полностью
+	Reset time.Duration
+	// RetryAfter - через сколько можно повторить отклоненный запрос
+	RetryAfter time.Duration
+}
+
+// Limiter - учитывает запрос с ключом key по политике policy
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// slidingWindowUsed - сколько запросов учтено в скользящем окне. previous и current - счетчики предыдущего
+// и текущего фиксированного окна, elapsed - сколько прошло с начала текущего
+func slidingWindowUsed(policy Policy, previous, current int, elapsed time.Duration) float64 {
+	weight := float64(policy.Window-elapsed) / float64(policy.Window)
+	return float64(previous)*weight + float64(current)
+}
+
+// slidingWindowResult - решение sliding window по счетчикам до учета запроса
+func slidingWindowResult(policy Policy, previous, current int, elapsed time.Duration, allowed bool) Result {
+	limit := float64(policy.Limit)
+	used := slidingWindowUsed(policy, previous, current, elapsed)
+	if allowed {
+		current++
+		used++
+	}
+
+	result := Result{Allowed: allowed, Limit: policy.Limit, Remaining: max(int(math.Floor(limit-used)), 0)}
+	// Лимит восстанавливается полностью, когда учтенные запросы выйдут из скользящего окна
+	switch {
+	case current > 0:
+		result.Reset = 2*policy.Window - elapsed
+	case previous > 0:
+		result.Reset = policy.Window - elapsed
+	}
+
+	if !allowed {
+		// Момент от начала окна, когда вклад окна older уменьшится настолько, что запрос поместится
+		fits := func(older, newer int) time.Duration {
+			return time.Duration((1 - (limit-float64(newer)-1)/float64(older)) * float64(policy.Window))
+		}
+		if current+1 <= policy.Limit {
+			result.RetryAfter = fits(previous, current) - elapsed
+		} else {
+			// Текущее окно заполнено, ждем следующего, в котором оно станет предыдущим
+			result.RetryAfter = policy.Window - elapsed + fits(current, 0)
+		}
+		result.RetryAfter = max(result.RetryAfter, time.Millisecond)
+	}
+	return result
+}
+
+// tokenBucketResult - решение token bucket по числу токенов в корзине после пополнения и списания
+func tokenBucketResult(policy Policy, tokens float64, allowed bool) Result {
+	perToken := float64(policy.Window) / float64(policy.Limit)
+	result := Result{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     time.Duration((float64(policy.Limit) - tokens) * perToken),
+	}
+	if !allowed {
+		result.RetryAfter = max(time.Duration((1-tokens)*perToken), time.Millisecond)
+	}
+	return result
+}
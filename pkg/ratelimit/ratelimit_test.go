@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter(0)
+	defer limiter.Close()
+	limiter.now = func() time.Time { return now }
+
+	t.Run("Sliding window", func(t *testing.T) {
+		policy := Policy{Name: "test", Limit: 4, Window: time.Minute, Algorithm: SlidingWindow}
+
+		for i := 3; i >= 0; i-- {
+			result, _ := limiter.Allow(context.Background(), "sliding", policy)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, i, result.Remaining)
+		}
+		result, _ := limiter.Allow(context.Background(), "sliding", policy)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, time.Minute+15*time.Second, result.RetryAfter)
+
+		// Через 30 секунд следующего окна предыдущее учитывается наполовину: 4 * 0.5 = 2
+		now = now.Add(90 * time.Second)
+		for i := 0; i < 2; i++ {
+			result, _ = limiter.Allow(context.Background(), "sliding", policy)
+			assert.True(t, result.Allowed)
+		}
+		result, _ = limiter.Allow(context.Background(), "sliding", policy)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 15*time.Second, result.RetryAfter)
+		assert.Equal(t, 90*time.Second, result.Reset)
+
+		// Ключи считаются независимо
+		result, _ = limiter.Allow(context.Background(), "other", policy)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("Token bucket", func(t *testing.T) {
+		policy := Policy{Name: "test", Limit: 2, Window: time.Minute, Algorithm: TokenBucket}
+
+		for i := 0; i < 2; i++ {
+			result, _ := limiter.Allow(context.Background(), "bucket", policy)
+			assert.True(t, result.Allowed)
+		}
+		result, _ := limiter.Allow(context.Background(), "bucket", policy)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 30*time.Second, result.RetryAfter)
+		assert.Equal(t, time.Minute, result.Reset)
+
+		// Токен пополняется за window / limit
+		now = now.Add(30 * time.Second)
+		result, _ = limiter.Allow(context.Background(), "bucket", policy)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+	})
+}
+
+func TestMemoryLimiter_Sweep(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter(0)
+	defer limiter.Close()
+	limiter.now = func() time.Time { return now }
+
+	sliding := Policy{Name: "sliding", Limit: 2, Window: time.Minute, Algorithm: SlidingWindow}
+	bucket := Policy{Name: "bucket", Limit: 2, Window: time.Minute, Algorithm: TokenBucket}
+	limiter.Allow(context.Background(), "sliding", sliding)
+	limiter.Allow(context.Background(), "bucket", bucket)
+
+	// Через полминуты корзина пополнилась, а окно еще учитывается
+	now = now.Add(30 * time.Second)
+	limiter.sweep()
+	assert.Len(t, limiter.windows, 1)
+	assert.Empty(t, limiter.buckets)
+
+	// Следующее окно закончилось: счетчик текущего больше не нужен
+	now = now.Add(90 * time.Second)
+	limiter.sweep()
+	assert.Empty(t, limiter.windows)
+}
@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+)
+
+// slidingWindowScript - KEYS: счетчик текущего и предыдущего окна. ARGV: limit, window и прошедшее с начала окна (мс).
+// Возвращает {allowed, previous, current} до учета запроса
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+if previous * (window - elapsed) / window + current + 1 > limit then
+	return {0, previous, current}
+end
+redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], window * 2)
+return {1, previous, current}
+`)
+
+// tokenBucketScript - KEYS: корзина. ARGV: limit, window и текущее время (мс).
+// Возвращает {allowed, tokens}, tokens строкой, чтобы Redis не отбросил дробную часть
+var tokenBucketScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or limit
+local ts = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + math.max(now - ts, 0) * limit / window)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisLimiter - Limiter в Redis, общий для всех экземпляров приложения. Пока Redis недоступен,
+// решения принимает fallback, чтобы запросы не отклонялись из-за сбоя хранилища
+type RedisLimiter struct {
+	client   *redis.Client
+	fallback Limiter
+}
+
+func NewRedisLimiter(client *redis.Client, fallback Limiter) *RedisLimiter {
+	return &RedisLimiter{client: client, fallback: fallback}
+}
+
+func (r *RedisLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	var result Result
+	var err error
+	if policy.Algorithm == TokenBucket {
+		result, err = r.tokenBucket(ctx, key, policy)
+	} else {
+		result, err = r.slidingWindow(ctx, key, policy)
+	}
+	// Отмененный запрос не означает, что Redis недоступен
+	if err != nil && r.fallback != nil && ctx.Err() == nil {
+		logger.Log.Warn("Rate limiter storage is unavailable, using fallback", zap.Error(err))
+		return r.fallback.Allow(ctx, key, policy)
+	}
+	return result, err
+}
+
+func (r *RedisLimiter) slidingWindow(ctx context.Context, key string, policy Policy) (Result, error) {
+	window := policy.Window.Milliseconds()
+	now := time.Now().UnixMilli()
+	index := now / window
+	elapsed := now - index*window
+
+	keys := []string{
+		"ratelimit:" + key + ":" + strconv.FormatInt(index, 10),
+		"ratelimit:" + key + ":" + strconv.FormatInt(index-1, 10),
+	}
+	values, err := slidingWindowScript.Run(ctx, r.client, keys, policy.Limit, window, elapsed).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return slidingWindowResult(policy, int(values[1]), int(values[2]), time.Duration(elapsed)*time.Millisecond, values[0] == 1), nil
+}
+
+func (r *RedisLimiter) tokenBucket(ctx context.Context, key string, policy Policy) (Result, error) {
+	keys := []string{"ratelimit:" + key}
+	values, err := tokenBucketScript.Run(ctx, r.client, keys,
+		policy.Limit, policy.Window.Milliseconds(), time.Now().UnixMilli()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, _ := values[0].(int64)
+	tokens, err := strconv.ParseFloat(values[1].(string), 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return tokenBucketResult(policy, tokens, allowed == 1), nil
+}
@@ -8,6 +8,7 @@ type Response struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   *ErrorInfo  `json:"error,omitempty"`
+	Meta    interface{} `json:"meta,omitempty"`
 }
 
 type ErrorInfo struct {
@@ -23,6 +24,14 @@ func SuccessResponse(c *fiber.Ctx, data interface{}) {
 	})
 }
 
+func PageResponse(c *fiber.Ctx, data interface{}, meta interface{}) error {
+	return c.Status(fiber.StatusOK).JSON(Response{
+		Success: true,
+		Data:    data,
+		Meta:    meta,
+	})
+}
+
 func ErrorResponse(c *fiber.Ctx, code int, message string, details ...string) {
 	errorDetails := ""
 	if len(details) > 0 {
@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"encoding/base32"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Векторы из приложения B RFC 6238 (SHA1), последние 6 цифр
+func TestCode_RFC6238(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		code, err := Code(secret, time.Unix(tt.unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, code, "time %d", tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+
+	assert.True(t, Validate(secret, code, now))
+	assert.True(t, Validate(secret, code, now.Add(Period)))
+	assert.False(t, Validate(secret, code, now.Add(3*Period)))
+	assert.False(t, Validate(secret, "12345", now))
+	assert.False(t, Validate("not base32!", code, now))
+}
+
+func TestMatch(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	counter := now.Unix() / int64(Period.Seconds())
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+
+	step, ok := Match(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, counter, step)
+
+	// Тот же код, проверенный в следующем интервале, соответствует прежнему номеру
+	step, ok = Match(secret, code, now.Add(Period))
+	assert.True(t, ok)
+	assert.Equal(t, counter, step)
+
+	_, ok = Match(secret, code, now.Add(3*Period))
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("blog-api", "user@example.com", "SECRET")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/blog-api:user@example.com?"))
+	assert.Contains(t, uri, "secret=SECRET")
+	assert.Contains(t, uri, "issuer=blog-api")
+}
@@ -0,0 +1,159 @@
+// Package ttlmap - map в памяти процесса с временем жизни записей для хранилищ, которые работают без Redis
+package ttlmap
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCleanupInterval - как часто janitor удаляет истекшие записи, если интервал не задан
+const DefaultCleanupInterval = time.Minute
+
+// Map - потокобезопасный map с временем жизни записей. Истекшие записи не видны сразу,
+// а из памяти их удаляет janitor
+type Map[V any] struct {
+	mu    sync.Mutex
+	items map[string]entry[V]
+	done  chan struct{}
+	once  sync.Once
+}
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time // нулевое значение - без срока
+}
+
+func (i entry[V]) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && !now.Before(i.expiresAt)
+}
+
+// New - map, из которого janitor раз в cleanupInterval удаляет истекшие записи. Остановить janitor можно через Close
+func New[V any](cleanupInterval time.Duration) *Map[V] {
+	if cleanupInterval <= 0 {
+		cleanupInterval = DefaultCleanupInterval
+	}
+	m := &Map[V]{items: make(map[string]entry[V]), done: make(chan struct{})}
+	go m.janitor(cleanupInterval)
+	return m
+}
+
+func (m *Map[V]) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.DeleteIf(func(string, V) bool { return false })
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Close - останавливает janitor
+func (m *Map[V]) Close() {
+	m.once.Do(func() { close(m.done) })
+}
+
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// Set - сохраняет запись на ttl. При ttl <= 0 запись не истекает
+func (m *Map[V]) Set(key string, value V, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[key] = entry[V]{value: value, expiresAt: expiresAt(ttl)}
+}
+
+// Update - изменяет существующую запись под блокировкой, поэтому проверка и запись не разделяются другими вызовами.
+// Запись сохраняется, только если fn не вернула ошибку. При ttl = 0 срок жизни не меняется
+func (m *Map[V]) Update(key string, ttl time.Duration, fn func(value *V) error) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[key]
+	if !ok || item.expired(time.Now()) {
+		return false, nil
+	}
+	if err := fn(&item.value); err != nil {
+		return true, err
+	}
+	if ttl > 0 {
+		item.expiresAt = expiresAt(ttl)
+	}
+	m.items[key] = item
+	return true, nil
+}
+
+// Upsert - изменяет запись под блокировкой и продлевает ее на ttl. Отсутствующая или истекшая запись
+// начинается с нулевого значения. Возвращает новое значение
+func (m *Map[V]) Upsert(key string, ttl time.Duration, fn func(value *V)) V {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[key]
+	if !ok || item.expired(time.Now()) {
+		item = entry[V]{}
+	}
+	fn(&item.value)
+	item.expiresAt = expiresAt(ttl)
+	m.items[key] = item
+	return item.value
+}
+
+// Get - значение неистекшей записи
+func (m *Map[V]) Get(key string) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[key]
+	if !ok || item.expired(time.Now()) {
+		var zero V
+		return zero, false
+	}
+	return item.value, true
+}
+
+func (m *Map[V]) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, key)
+}
+
+// Take - возвращает и удаляет запись за одну операцию
+func (m *Map[V]) Take(key string) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[key]
+	delete(m.items, key)
+	if !ok || item.expired(time.Now()) {
+		var zero V
+		return zero, false
+	}
+	return item.value, true
+}
+
+// Each - вызывает fn для каждой неистекшей записи под блокировкой
+func (m *Map[V]) Each(fn func(key string, value V)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for key, item := range m.items {
+		if !item.expired(now) {
+			fn(key, item.value)
+		}
+	}
+}
+
+// DeleteIf - удаляет истекшие записи и те, для которых fn возвращает true
+func (m *Map[V]) DeleteIf(fn func(key string, value V) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for key, item := range m.items {
+		if item.expired(now) || fn(key, item.value) {
+			delete(m.items, key)
+		}
+	}
+}
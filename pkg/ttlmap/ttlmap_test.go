@@ -0,0 +1,87 @@
+package ttlmap
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMap(t *testing.T) {
+	t.Run("Janitor removes expired entries", func(t *testing.T) {
+		m := New[int](10 * time.Millisecond)
+		defer m.Close()
+
+		m.Set("short", 1, 20*time.Millisecond)
+		m.Set("forever", 2, 0)
+
+		assert.Eventually(t, func() bool {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			_, ok := m.items["short"]
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+
+		value, ok := m.Get("forever")
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+	})
+
+	t.Run("Update is applied only without error", func(t *testing.T) {
+		m := New[int](0)
+		defer m.Close()
+
+		found, err := m.Update("missing", 0, func(*int) error { return nil })
+		assert.False(t, found)
+		assert.NoError(t, err)
+
+		m.Set("key", 1, time.Minute)
+		errStop := errors.New("stop")
+		found, err = m.Update("key", 0, func(value *int) error {
+			*value = 2
+			return errStop
+		})
+		assert.True(t, found)
+		assert.ErrorIs(t, err, errStop)
+		value, _ := m.Get("key")
+		assert.Equal(t, 1, value)
+
+		found, err = m.Update("key", 0, func(value *int) error {
+			*value = 3
+			return nil
+		})
+		require.NoError(t, err)
+		assert.True(t, found)
+		value, _ = m.Get("key")
+		assert.Equal(t, 3, value)
+	})
+
+	t.Run("Upsert starts expired entries over", func(t *testing.T) {
+		m := New[int](0)
+		defer m.Close()
+
+		increment := func(value *int) { *value++ }
+		assert.Equal(t, 1, m.Upsert("key", 20*time.Millisecond, increment))
+		assert.Equal(t, 2, m.Upsert("key", 20*time.Millisecond, increment))
+
+		time.Sleep(30 * time.Millisecond)
+		assert.Equal(t, 1, m.Upsert("key", time.Minute, increment))
+
+		m.Delete("key")
+		_, ok := m.Get("key")
+		assert.False(t, ok)
+	})
+
+	t.Run("Take removes entry", func(t *testing.T) {
+		m := New[int](0)
+		defer m.Close()
+
+		m.Set("key", 1, time.Minute)
+		value, ok := m.Take("key")
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+		_, ok = m.Take("key")
+		assert.False(t, ok)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/crafty-ezhik/blog-api/internal/comment"
 	"github.com/crafty-ezhik/blog-api/internal/models"
 	"github.com/crafty-ezhik/blog-api/internal/post"
+	"github.com/crafty-ezhik/blog-api/internal/token"
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/gorm"
@@ -170,3 +171,37 @@ func (s *AuthIntegrationSuite) Test_Logout_BlacklistsToken() {
 	s.Require().NoError(err)
 	s.Require().Equal(http.StatusUnauthorized, resp.StatusCode)
 }
+
+// Test_LogoutEverywhere_RevokesPersonalTokens - выход со всех устройств отзывает и personal access токены
+func (s *AuthIntegrationSuite) Test_LogoutEverywhere_RevokesPersonalTokens() {
+	tokens := registerAndLogin(s.T(), s.app)
+
+	body, _ := json.Marshal(token.CreateRequest{Name: "ci", ExpiresInDays: 30, Scopes: []string{"users:read"}})
+	req := httptest.NewRequest(http.MethodPost, "/api/users/me/tokens", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	resp, err := s.app.Test(req)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusCreated, resp.StatusCode)
+	var created struct {
+		Data token.CreateResponse `json:"data"`
+	}
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&created))
+
+	getMe := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/users/me", nil)
+		req.Header.Set("Authorization", "Bearer "+created.Data.Token)
+		resp, err := s.app.Test(req)
+		s.Require().NoError(err)
+		return resp.StatusCode
+	}
+	s.Equal(http.StatusOK, getMe())
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/users/me/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	resp, err = s.app.Test(req)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	s.Equal(http.StatusUnauthorized, getMe())
+}
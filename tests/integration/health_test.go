@@ -0,0 +1,72 @@
+package integration
+
+import (
+	"encoding/json"
+	"github.com/crafty-ezhik/blog-api/internal/health"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type HealthIntegrationSuite struct {
+	suite.Suite
+	app *fiber.App
+	db  *gorm.DB
+}
+
+func (s *HealthIntegrationSuite) SetupSuite() {
+	s.db = SetupTestDB()
+	s.app = SetupApp(s.db)
+}
+
+func (s *HealthIntegrationSuite) TearDownTest() {
+	CleanupTables(s.db)
+}
+
+func (s *HealthIntegrationSuite) TearDownSuite() {
+	TeardownTestDB(s.db)
+}
+
+func TestHealthIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(HealthIntegrationSuite))
+}
+
+func (s *HealthIntegrationSuite) get(target string) (int, []byte) {
+	resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+	s.Require().NoError(err)
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, body
+}
+
+// Test_Readiness_Follows_Migrations - без токена, до миграций экземпляр жив, но не готов
+func (s *HealthIntegrationSuite) Test_Readiness_Follows_Migrations() {
+	status, _ := s.get("/healthz")
+	s.Equal(http.StatusOK, status)
+
+	status, body := s.get("/readyz")
+	s.Equal(http.StatusServiceUnavailable, status)
+	s.Contains(string(body), `"failed":["migrations"]`)
+
+	MigrateTables(s.db)
+
+	status, _ = s.get("/readyz")
+	s.Equal(http.StatusOK, status)
+
+	status, body = s.get("/health")
+	s.Equal(http.StatusOK, status)
+	var resp struct {
+		Data health.Report `json:"data"`
+	}
+	s.Require().NoError(json.Unmarshal(body, &resp))
+	s.Equal(health.StatusOK, resp.Data.Status)
+	names := make([]string, 0, len(resp.Data.Checks))
+	for _, check := range resp.Data.Checks {
+		names = append(names, check.Name)
+		s.Equal(health.StatusOK, check.Status, check.Name)
+	}
+	s.Equal([]string{"database", "redis", "migrations"}, names)
+}
@@ -14,8 +14,13 @@ import (
 )
 
 func registerAndLogin(t testing.TB, app *fiber.App) jwt.Tokens {
+	return registerAndLoginAs(t, app, "test@test.com")
+}
+
+// registerAndLoginAs - то же для другого пользователя, когда в тесте их несколько
+func registerAndLoginAs(t testing.TB, app *fiber.App, email string) jwt.Tokens {
 	payload := auth.RegisterRequest{
-		Email:    "test@test.com",
+		Email:    email,
 		Password: "12345678",
 		Name:     "Test",
 		Age:      30,
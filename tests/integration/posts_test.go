@@ -0,0 +1,221 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/crafty-ezhik/blog-api/internal/comment"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/post"
+	"github.com/crafty-ezhik/blog-api/internal/tag"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type PostsIntegrationSuite struct {
+	suite.Suite
+	app *fiber.App
+	db  *gorm.DB
+}
+
+func (s *PostsIntegrationSuite) SetupSuite() {
+	s.db = SetupTestDB()
+	s.app = SetupApp(s.db)
+}
+
+func (s *PostsIntegrationSuite) SetupTest() {
+	MigrateTables(s.db)
+}
+
+func (s *PostsIntegrationSuite) TearDownTest() {
+	CleanupTables(s.db)
+}
+
+func (s *PostsIntegrationSuite) TearDownSuite() {
+	TeardownTestDB(s.db)
+}
+
+func TestPostsIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(PostsIntegrationSuite))
+}
+
+// request - запрос с access токеном, ответ разбирается в out
+func (s *PostsIntegrationSuite) request(method, target, accessToken string, payload, out interface{}) {
+	status := s.send(method, target, accessToken, payload, out)
+	s.Require().Less(status, http.StatusBadRequest, target)
+}
+
+// send - запрос с access токеном, возвращает код ответа
+func (s *PostsIntegrationSuite) send(method, target, accessToken string, payload, out interface{}) int {
+	var body io.Reader
+	if payload != nil {
+		data, _ := json.Marshal(payload)
+		body = bytes.NewBuffer(data)
+	}
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.app.Test(req)
+	s.Require().NoError(err)
+	if out != nil && resp.StatusCode < http.StatusBadRequest {
+		data, _ := io.ReadAll(resp.Body)
+		s.Require().NoError(json.Unmarshal(data, out))
+	}
+	return resp.StatusCode
+}
+
+// Test_Search_And_Tags - поиск и счетчики тегов на SQLite, где нет tsvector
+func (s *PostsIntegrationSuite) Test_Search_And_Tags() {
+	tokens := registerAndLogin(s.T(), s.app)
+
+	s.request(http.MethodPost, "/api/posts", tokens.AccessToken, post.CreateRequest{
+		Title:  "Middleware в Fiber",
+		Text:   "Как написать свой middleware",
+		Tags:   []string{"Go", "Fiber"},
+		Status: "published",
+	}, nil)
+	s.request(http.MethodPost, "/api/posts", tokens.AccessToken, post.CreateRequest{
+		Title:  "Транзакции в GORM",
+		Text:   "Fiber тут упоминается только в тексте, 100% совпадение",
+		Tags:   []string{"Go"},
+		Status: "published",
+	}, nil)
+
+	var found struct {
+		Data []post.SearchResult `json:"data"`
+	}
+	s.request(http.MethodGet, "/api/posts/search?q=fiber", tokens.AccessToken, nil, &found)
+	s.Require().Len(found.Data, 2)
+	// Совпадение в заголовке выше совпадения в тексте
+	s.Equal("Middleware в Fiber", found.Data[0].Title)
+	s.Greater(found.Data[0].Rank, found.Data[1].Rank)
+	s.Contains(found.Data[1].Snippet, "<mark>Fiber</mark>")
+
+	// Регистр кириллицы не важен и на SQLite
+	s.request(http.MethodGet, "/api/posts/search?q="+url.QueryEscape("ТРАНЗАКЦИИ"), tokens.AccessToken, nil, &found)
+	s.Require().Len(found.Data, 1)
+	s.Equal("Транзакции в GORM", found.Data[0].Title)
+
+	// % ищется как обычный символ
+	s.request(http.MethodGet, "/api/posts/search?q="+url.QueryEscape("100%"), tokens.AccessToken, nil, &found)
+	s.Len(found.Data, 1)
+
+	var tags struct {
+		Data []tag.CountResponse `json:"data"`
+	}
+	s.request(http.MethodGet, "/api/tags", tokens.AccessToken, nil, &tags)
+	counts := make(map[string]int64)
+	for _, t := range tags.Data {
+		counts[t.Slug] = t.PostsCount
+	}
+	s.Equal(map[string]int64{"go": 2, "fiber": 1}, counts)
+}
+
+// Test_Comments_On_Unpublished_Post - комментарии к черновику доступны только его автору
+func (s *PostsIntegrationSuite) Test_Comments_On_Unpublished_Post() {
+	author := registerAndLogin(s.T(), s.app)
+	reader := registerAndLoginAs(s.T(), s.app, "reader@test.com")
+
+	var created struct {
+		Data struct {
+			ID uint `json:"id"`
+		} `json:"data"`
+	}
+	s.request(http.MethodPost, "/api/posts", author.AccessToken, post.CreateRequest{Title: "Черновик", Text: "Текст"}, &created)
+	target := fmt.Sprintf("/api/posts/%d/comments", created.Data.ID)
+	newComment := comment.CreateCommentRequest{Title: "Комментарий", Content: "Текст"}
+
+	s.Equal(http.StatusNotFound, s.send(http.MethodPost, target, reader.AccessToken, newComment, nil))
+	s.request(http.MethodPost, target, author.AccessToken, newComment, nil)
+
+	s.Equal(http.StatusNotFound, s.send(http.MethodGet, target, reader.AccessToken, nil, nil))
+	s.Equal(http.StatusNotFound, s.send(http.MethodGet, target+"?view=tree", reader.AccessToken, nil, nil))
+	s.Equal(http.StatusOK, s.send(http.MethodGet, target, author.AccessToken, nil, nil))
+}
+
+// Test_Comment_Tree_Keeps_Replies_Of_Deleted_Comment - удаленный комментарий с ответами
+// остается в дереве заглушкой, без ответов - пропадает
+func (s *PostsIntegrationSuite) Test_Comment_Tree_Keeps_Replies_Of_Deleted_Comment() {
+	author := registerAndLogin(s.T(), s.app)
+	reader := registerAndLoginAs(s.T(), s.app, "reader@test.com")
+
+	var created struct {
+		Data struct {
+			ID uint `json:"id"`
+		} `json:"data"`
+	}
+	s.request(http.MethodPost, "/api/posts", author.AccessToken,
+		post.CreateRequest{Title: "Статья", Text: "Текст", Status: "published"}, &created)
+	target := fmt.Sprintf("/api/posts/%d/comments", created.Data.ID)
+
+	s.request(http.MethodPost, target, reader.AccessToken, comment.CreateCommentRequest{Title: "Вопрос", Content: "Вопрос"}, nil)
+	s.request(http.MethodPost, target, reader.AccessToken, comment.CreateCommentRequest{Title: "Лишний", Content: "Лишний"}, nil)
+	var flat struct {
+		Data comment.GetCommentsResponse `json:"data"`
+	}
+	s.request(http.MethodGet, target, reader.AccessToken, nil, &flat)
+	s.Require().Len(flat.Data.Comments, 2)
+	question, extra := flat.Data.Comments[0].ID, flat.Data.Comments[1].ID
+
+	s.request(http.MethodPost, target, author.AccessToken,
+		comment.CreateCommentRequest{Title: "Ответ", Content: "Ответ", ParentID: &question}, nil)
+	s.request(http.MethodDelete, fmt.Sprintf("%s/%d", target, question), reader.AccessToken, nil, nil)
+	s.request(http.MethodDelete, fmt.Sprintf("%s/%d", target, extra), reader.AccessToken, nil, nil)
+
+	var tree struct {
+		Data comment.GetCommentsResponse `json:"data"`
+	}
+	s.request(http.MethodGet, target+"?view=tree", reader.AccessToken, nil, &tree)
+	s.Require().Len(tree.Data.Comments, 1)
+	root := tree.Data.Comments[0]
+	s.Equal(question, root.ID)
+	s.True(root.Deleted)
+	s.Equal(comment.DeletedPlaceholder, root.Content)
+	s.Empty(root.AuthorName)
+	s.Require().Len(root.Replies, 1)
+	s.Equal("Ответ", root.Replies[0].Content)
+	s.False(root.Replies[0].Deleted)
+}
+
+// Test_Edited_Comment_Is_Moderated_Again - при премодерации правка одобренного комментария
+// отправляет его обратно в очередь
+func (s *PostsIntegrationSuite) Test_Edited_Comment_Is_Moderated_Again() {
+	author := registerAndLogin(s.T(), s.app)
+	reader := registerAndLoginAs(s.T(), s.app, "reader@test.com")
+
+	var created struct {
+		Data struct {
+			ID uint `json:"id"`
+		} `json:"data"`
+	}
+	s.request(http.MethodPost, "/api/posts", author.AccessToken,
+		post.CreateRequest{Title: "Статья", Text: "Текст", Status: "published"}, &created)
+	target := fmt.Sprintf("/api/posts/%d/comments", created.Data.ID)
+
+	s.request(http.MethodPost, target, reader.AccessToken, comment.CreateCommentRequest{Title: "Отзыв", Content: "Спасибо"}, nil)
+	var list struct {
+		Data comment.GetCommentsResponse `json:"data"`
+	}
+	s.request(http.MethodGet, target, author.AccessToken, nil, &list)
+	s.Require().Len(list.Data.Comments, 1)
+	s.Equal(string(models.CommentApproved), list.Data.Comments[0].Status)
+	commentURL := fmt.Sprintf("%s/%d", target, list.Data.Comments[0].ID)
+
+	requireApproval := true
+	s.request(http.MethodPatch, fmt.Sprintf("/api/posts/%d/moderation", created.Data.ID), author.AccessToken,
+		post.CommentApprovalRequest{RequireApproval: &requireApproval}, nil)
+	s.request(http.MethodPatch, commentURL, reader.AccessToken, comment.UpdateCommentRequest{Content: "Реклама"}, nil)
+
+	// Другие читатели больше не видят комментарий, его автор видит его в статусе pending
+	s.Equal(http.StatusNotFound, s.send(http.MethodGet, target, author.AccessToken, nil, nil))
+	s.request(http.MethodGet, target, reader.AccessToken, nil, &list)
+	s.Require().Len(list.Data.Comments, 1)
+	s.Equal(string(models.CommentPending), list.Data.Comments[0].Status)
+}
@@ -1,58 +1,77 @@
 package integration
 
 import (
-	"fmt"
+	"github.com/crafty-ezhik/blog-api/db"
 	"github.com/crafty-ezhik/blog-api/internal/config"
-	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/migrations"
+	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/migrate"
 	"github.com/gofiber/fiber/v2/log"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"os"
 )
 
-const TestDatabaseName = "blog_api_test"
+// ConfigPath - конфигурация тестов: SQLite в памяти и хранилища токенов в памяти процесса,
+// поэтому для запуска не нужны ни PostgreSQL, ни Redis. APP_DATABASE_DRIVER=postgres и остальные
+// переменные APP_DATABASE_* позволяют прогнать те же тесты на PostgreSQL
+const ConfigPath = "./configs"
 
-func SetupTestDB() *gorm.DB {
-	cfg, err := config.LoadConfig("C:\\Users\\Alexandr\\GolandProjects\\blog-api\\tests\\integration\\configs")
+// LoadTestConfig - конфигурация из ConfigPath/test.yaml
+func LoadTestConfig() *config.Config {
+	if os.Getenv("APP_ENV") == "" {
+		os.Setenv("APP_ENV", "test")
+	}
+	cfg, err := config.LoadConfig(ConfigPath)
 	if err != nil {
-		log.Errorf("Error loading configs: %v", err)
+		panic(err)
 	}
+	if err = logger.InitLogger(cfg); err != nil {
+		panic(err)
+	}
+	return cfg
+}
 
-	cfg.DB.Database = TestDatabaseName
-
-	return getConnectionDB(cfg)
+func SetupTestDB() *gorm.DB {
+	testDB, err := db.GetConnection(LoadTestConfig())
+	if err != nil {
+		panic(err)
+	}
+	return testDB
 }
 
 func TeardownTestDB(db *gorm.DB) {
-	err := db.Migrator().DropTable(&models.User{}, &models.Post{}, &models.Comment{})
-	if err != nil {
-		log.Errorf("Error dropping table: %v", err)
+	CleanupTables(db)
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
 	}
 }
 
 func MigrateTables(db *gorm.DB) {
-	err := db.AutoMigrate(&models.User{}, &models.Comment{}, &models.Post{})
-	if err != nil {
+	if _, err := newMigrator(db).Up(0); err != nil {
 		panic(err)
 	}
 }
 
+// CleanupTables - откатывает все миграции, чтобы следующий тест начинал с пустой схемы
 func CleanupTables(db *gorm.DB) {
-	fmt.Println("Cleaning up test DB")
+	migrator := newMigrator(db)
+	statuses, err := migrator.Status()
+	if err == nil {
+		_, err = migrator.Down(len(statuses))
+	}
+	if err != nil {
+		log.Errorf("Error dropping table: %v", err)
+	}
 }
 
-func getConnectionDB(conf *config.Config) *gorm.DB {
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
-		conf.DB.Host,
-		conf.DB.Username,
-		conf.DB.Password,
-		conf.DB.Database,
-		conf.DB.Port,
-		conf.DB.SSLMode)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func newMigrator(db *gorm.DB) *migrate.Migrator {
+	all, err := migrations.All(db.Dialector.Name())
 	if err != nil {
 		panic(err)
 	}
-
-	return db
+	migrator, err := migrate.New(db, all)
+	if err != nil {
+		panic(err)
+	}
+	return migrator
 }
@@ -1,46 +1,56 @@
 package integration
 
 import (
-	"fmt"
 	"github.com/bytedance/sonic"
 	"github.com/crafty-ezhik/blog-api/internal/auth"
 	"github.com/crafty-ezhik/blog-api/internal/comment"
-	"github.com/crafty-ezhik/blog-api/internal/config"
+	"github.com/crafty-ezhik/blog-api/internal/health"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
 	"github.com/crafty-ezhik/blog-api/internal/post"
 	"github.com/crafty-ezhik/blog-api/internal/routes"
+	"github.com/crafty-ezhik/blog-api/internal/storage"
+	"github.com/crafty-ezhik/blog-api/internal/tag"
+	"github.com/crafty-ezhik/blog-api/internal/token"
 	"github.com/crafty-ezhik/blog-api/internal/user"
 	"github.com/crafty-ezhik/blog-api/pkg/jwt"
+	"github.com/crafty-ezhik/blog-api/pkg/lockout"
 	"github.com/crafty-ezhik/blog-api/pkg/logger"
+	"github.com/crafty-ezhik/blog-api/pkg/mailer"
 	"github.com/crafty-ezhik/blog-api/pkg/middleware"
+	"github.com/crafty-ezhik/blog-api/pkg/oidc"
 	"github.com/crafty-ezhik/blog-api/pkg/validate"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 func SetupApp(testDB *gorm.DB) *fiber.App {
-	cfg, err := config.LoadConfig("/configs")
+	cfg := LoadTestConfig()
+
+	// Init storages: Redis или память процесса
+	stores, err := storage.New(cfg.Redis)
 	if err != nil {
-		log.Errorf("Error loading configs: %v", err)
+		panic(err)
 	}
 
-	err = logger.InitLogger(cfg)
+	// Init JWT
+	jwtService := jwt.NewJWTService(stores.BlackList, stores.TokenVersions, stores.Sessions)
+	signingKeys, err := jwt.NewKeySetFromConfig(cfg.Auth)
 	if err != nil {
 		panic(err)
 	}
-
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port),
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.Db,
+	jwtAuth := jwt.NewJWTWithKeys(jwtService, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL, signingKeys, jwt.ClaimsOptions{
+		Issuer:   cfg.Auth.Issuer,
+		Audience: cfg.Auth.Audience,
+		Leeway:   cfg.Auth.Leeway,
 	})
 
-	// Init JWT
-	jwtService := jwt.NewJWTService(jwt.NewRedisStorage(rdb))
-	jwtAuth := jwt.NewJWT(jwtService, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL, cfg.Auth.SigningKey)
+	// Init mailer
+	mailSender, err := mailer.NewSender(cfg.Mail)
+	if err != nil {
+		panic(err)
+	}
 
 	// Init Validator
 	logger.Log.Debug("Init validator")
@@ -53,18 +63,27 @@ func SetupApp(testDB *gorm.DB) *fiber.App {
 	userRepo := user.NewUserRepository(testDB)
 	postRepo := post.NewPostRepository(testDB)
 	commentRepo := comment.NewCommentRepository(testDB)
+	tagRepo := tag.NewTagRepository(testDB)
+	tokenRepo := token.NewTokenRepository(testDB)
 
 	// Services
-	userService := user.NewUserService(userRepo)
-	authService := auth.NewAuthService(cfg, userRepo, jwtAuth)
+	userService := user.NewUserService(userRepo, user.NewUnitOfWork(testDB))
+	authService := auth.NewAuthService(cfg, userRepo, tokenRepo, jwtAuth, stores.OneTimeTokens, mailSender,
+		oidc.NewClient(cfg.OAuth, stores.OAuthStates, nil), lockout.NewGuard(stores.Lockout, cfg.Lockout))
 	postService := post.NewPostService(postRepo)
-	commentService := comment.NewCommentService(commentRepo, postRepo)
+	commentService := comment.NewCommentService(commentRepo, postRepo, comment.NewUnitOfWork(testDB), cfg.Comments)
+	tagService := tag.NewTagService(tagRepo)
+	tokenService := token.NewTokenService(tokenRepo)
 
 	// Handlers
 	authHandler := auth.NewAuthHandler(userService, authService, v)
 	userHandler := user.NewUserHandler(userService, postService, v)
 	postHandler := post.NewPostHandler(postService, v)
 	commentHandler := comment.NewCommentHandler(commentService, v)
+	tagHandler := tag.NewTagHandler(tagService, postService)
+	tokenHandler := token.NewTokenHandler(tokenService, v)
+	healthHandler := health.NewHealthHandler(cfg.Server.HealthTimeout,
+		health.Database(testDB), health.Redis(stores.Redis), health.Migrations(newMigrator(testDB)))
 
 	// Init Fiber App
 	app := fiber.New(fiber.Config{
@@ -75,6 +94,9 @@ func SetupApp(testDB *gorm.DB) *fiber.App {
 	// Middleware для логирование запросов
 	app.Use(middleware.LogMiddleware())
 
+	// Дедлайн запроса: контекст передается в сервисы, репозитории и Redis
+	app.Use(middleware.RequestTimeout(cfg.Server.RequestTimeout))
+
 	// CORS Middleware
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "https://gofiber.io, https://gofiber.net, http://localhost",
@@ -87,7 +109,15 @@ func SetupApp(testDB *gorm.DB) *fiber.App {
 		UserHandler:    userHandler,
 		PostHandler:    postHandler,
 		CommentHandler: commentHandler,
+		TagHandler:     tagHandler,
+		TokenHandler:   tokenHandler,
+		HealthHandler:  healthHandler,
 		JWT:            jwtAuth,
+		RoleProvider:   userService,
+		Permissions:    policy.Checker{},
+		PersonalTokens: tokenService,
+		RateLimiter:    stores.RateLimiter,
+		RateLimits:     cfg.RateLimit,
 	}
 
 	routes.SetupRoutes(app, routeDeps)
@@ -0,0 +1,141 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"github.com/crafty-ezhik/blog-api/internal/models"
+	"github.com/crafty-ezhik/blog-api/internal/policy"
+	"github.com/crafty-ezhik/blog-api/internal/uow"
+	"github.com/crafty-ezhik/blog-api/internal/user"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"testing"
+)
+
+type UnitOfWorkIntegrationSuite struct {
+	suite.Suite
+	db  *gorm.DB
+	uow *uow.TxManager[user.TxRepositories]
+}
+
+func (s *UnitOfWorkIntegrationSuite) SetupSuite() {
+	s.db = SetupTestDB()
+	s.uow = user.NewUnitOfWork(s.db)
+}
+
+func (s *UnitOfWorkIntegrationSuite) SetupTest() {
+	MigrateTables(s.db)
+}
+
+func (s *UnitOfWorkIntegrationSuite) TearDownTest() {
+	CleanupTables(s.db)
+}
+
+func (s *UnitOfWorkIntegrationSuite) TearDownSuite() {
+	TeardownTestDB(s.db)
+}
+
+func TestUnitOfWorkIntegrationSuite(t *testing.T) {
+	suite.Run(t, new(UnitOfWorkIntegrationSuite))
+}
+
+// createAuthor - пользователь со статьей и комментарием к ней
+func (s *UnitOfWorkIntegrationSuite) createAuthor(email string) (*models.User, *models.Post) {
+	author := &models.User{Email: email, Name: "Author"}
+	s.Require().NoError(s.db.Create(author).Error)
+	post := &models.Post{Title: "Title", Text: "Text", AuthorID: author.ID}
+	s.Require().NoError(s.db.Create(post).Error)
+	s.Require().NoError(s.db.Create(&models.Comment{PostID: post.ID, AuthorID: author.ID, Title: "Title", Content: "Content"}).Error)
+	return author, post
+}
+
+func (s *UnitOfWorkIntegrationSuite) Test_Rollback_On_Error() {
+	author, post := s.createAuthor("rollback@test.com")
+	errStop := errors.New("stop")
+
+	err := s.uow.Do(context.Background(), func(tx *uow.Tx[user.TxRepositories]) error {
+		s.Require().NoError(tx.Repos.Comments.DeleteByUser(context.Background(), author.ID))
+		s.Require().NoError(tx.Repos.Posts.DeleteByAuthor(context.Background(), author.ID))
+		return errStop
+	})
+	s.ErrorIs(err, errStop)
+
+	var count int64
+	s.db.Model(&models.Post{}).Where("id = ?", post.ID).Count(&count)
+	s.Equal(int64(1), count)
+	s.db.Model(&models.Comment{}).Where("post_id = ?", post.ID).Count(&count)
+	s.Equal(int64(1), count)
+}
+
+func (s *UnitOfWorkIntegrationSuite) Test_Rollback_On_Panic() {
+	author, _ := s.createAuthor("panic@test.com")
+
+	s.Panics(func() {
+		_ = s.uow.Do(context.Background(), func(tx *uow.Tx[user.TxRepositories]) error {
+			s.Require().NoError(tx.Repos.Users.Delete(context.Background(), author.ID))
+			panic("boom")
+		})
+	})
+
+	_, err := user.NewUserRepository(s.db).FindByID(context.Background(), author.ID)
+	s.NoError(err)
+}
+
+func (s *UnitOfWorkIntegrationSuite) Test_Savepoint() {
+	author, post := s.createAuthor("savepoint@test.com")
+
+	err := s.uow.Do(context.Background(), func(tx *uow.Tx[user.TxRepositories]) error {
+		err := tx.Savepoint(func(tx *uow.Tx[user.TxRepositories]) error {
+			if err := tx.Repos.Posts.DeleteByAuthor(context.Background(), author.ID); err != nil {
+				return err
+			}
+			return errors.New("undo posts")
+		})
+		s.Error(err)
+		return tx.Repos.Comments.DeleteByUser(context.Background(), author.ID)
+	})
+	s.NoError(err)
+
+	var count int64
+	s.db.Model(&models.Post{}).Where("id = ?", post.ID).Count(&count)
+	s.Equal(int64(1), count)
+	s.db.Model(&models.Comment{}).Where("post_id = ?", post.ID).Count(&count)
+	s.Equal(int64(0), count)
+}
+
+func (s *UnitOfWorkIntegrationSuite) Test_Delete_User_Cascade() {
+	author, post := s.createAuthor("cascade@test.com")
+	reader := &models.User{Email: "reader@test.com", Name: "Reader"}
+	s.Require().NoError(s.db.Create(reader).Error)
+	s.Require().NoError(s.db.Create(&models.Comment{PostID: post.ID, AuthorID: reader.ID, Title: "Title", Content: "Content"}).Error)
+
+	service := user.NewUserService(user.NewUserRepository(s.db), s.uow)
+	s.Require().NoError(service.Delete(context.Background(), policy.Actor{UserID: author.ID, Role: models.RoleAuthor}, author.ID))
+
+	var count int64
+	s.db.Model(&models.Post{}).Where("author_id = ?", author.ID).Count(&count)
+	s.Equal(int64(0), count)
+	// Комментарии других пользователей к удаленной статье удаляются вместе с ней
+	s.db.Model(&models.Comment{}).Where("post_id = ?", post.ID).Count(&count)
+	s.Equal(int64(0), count)
+}
+
+func (s *UnitOfWorkIntegrationSuite) Test_Cancelled_Context() {
+	author, post := s.createAuthor("cancel@test.com")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Отмененный запрос не доходит до базы
+	_, err := user.NewUserRepository(s.db).FindByID(ctx, author.ID)
+	s.ErrorIs(err, context.Canceled)
+
+	service := user.NewUserService(user.NewUserRepository(s.db), s.uow)
+	err = service.Delete(ctx, policy.Actor{UserID: author.ID, Role: models.RoleAuthor}, author.ID)
+	s.ErrorIs(err, context.Canceled)
+
+	var count int64
+	s.db.Model(&models.Post{}).Where("id = ?", post.ID).Count(&count)
+	s.Equal(int64(1), count)
+	s.db.Model(&models.User{}).Where("id = ?", author.ID).Count(&count)
+	s.Equal(int64(1), count)
+}